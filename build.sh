@@ -39,12 +39,9 @@ image="akeyless/akeyless-csi-provider"
 docker_repo="docker.io"
 arc='amd64'
 os='linux'
-versionVar="akeyless.io/akeyless-csi-provider/internal/version.Version=$version"
-buildDateVar="akeyless.io/akeyless-csi-provider/go/src/internal/version.BuildDate=$(date -u +%Y%m%d.%H%M%S)"
-goVer="github.com/akeylesslabs/akeyless-csi-provider/internal/version.GoVersion=$(go version)"
-
+# the version is stamped from the build info of the checkout, see internal/version
 GOOS=$os GOARCH=$arc CGO_ENABLED=0 go build \
-		-ldflags "-w -s -X $versionVar -X $buildDateVar" \
+		-ldflags "-w -s" \
 		-o dist/ \
 		.
 
@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/provider"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/server"
+)
+
+// Refresher forces an immediate secret refresh of a mounted target path.
+type Refresher interface {
+	Refresh(ctx context.Context, targetPath string) error
+}
+
+// Inventory lists the Akeyless paths mounted on the node.
+type Inventory interface {
+	Inventory() []server.InventoryEntry
+}
+
+// FailureLog lists the recent failed mounts of target paths.
+type FailureLog interface {
+	RecentFailures(targetPath string) []server.TargetPathFailures
+}
+
+// Server is the provider state the administrative endpoints operate on.
+type Server interface {
+	Refresher
+	Inventory
+	FailureLog
+}
+
+// Diagnostics are the provider internals served to support bundles.
+type Diagnostics struct {
+	// Logs holds the recent log lines, nil to not serve them
+	Logs *LogBuffer
+	// Flags are the effective command line flags of the provider
+	Flags map[string]string
+}
+
+// NewHandler returns the handler of the administrative endpoints.
+// It is meant to be served on a localhost-only listener.
+func NewHandler(srv Server, diag Diagnostics) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/refresh", refreshHandler(srv))
+	mux.HandleFunc("/inventory", inventoryHandler(srv))
+	mux.HandleFunc("/failures", failuresHandler(srv))
+	mux.HandleFunc("/config", configHandler(diag.Flags))
+	mux.HandleFunc("/capabilities", capabilitiesHandler)
+	if diag.Logs != nil {
+		mux.HandleFunc("/logs", logsHandler(diag.Logs))
+	}
+	return mux
+}
+
+func configHandler(flags map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"flags": flags, "environment": config.RedactedEnvironment()})
+	}
+}
+
+func capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(provider.GetCapabilities())
+}
+
+func logsHandler(logs *LogBuffer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(logs.Lines())
+	}
+}
+
+func inventoryHandler(inventory Inventory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"paths": inventory.Inventory()})
+	}
+}
+
+func failuresHandler(failures FailureLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"targetPaths": failures.RecentFailures(r.URL.Query().Get("targetPath"))})
+	}
+}
+
+func refreshHandler(refresher Refresher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		targetPath := r.URL.Query().Get("targetPath")
+		if targetPath == "" {
+			http.Error(w, "missing targetPath query parameter", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("force refresh requested for target path %v", targetPath)
+		if err := refresher.Refresh(r.Context(), targetPath); err != nil {
+			log.Printf("force refresh failed, error: %v", err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, server.ErrSessionNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/provider"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRefresher map[string]bool
+
+func (f fakeRefresher) Inventory() []server.InventoryEntry {
+	var entries []server.InventoryEntry
+	for path := range f {
+		entries = append(entries, server.InventoryEntry{Path: path, Mounts: 1})
+	}
+	return entries
+}
+
+func (f fakeRefresher) RecentFailures(targetPath string) []server.TargetPathFailures {
+	entries := []server.TargetPathFailures{}
+	for path, known := range f {
+		if !known && (targetPath == "" || targetPath == path) {
+			entries = append(entries, server.TargetPathFailures{TargetPath: path, Failures: []server.MountFailure{{Stage: server.StageFetch, Error: "item not found"}}})
+		}
+	}
+	return entries
+}
+
+func (f fakeRefresher) Refresh(_ context.Context, targetPath string) error {
+	if !f[targetPath] {
+		return fmt.Errorf("%w %v", server.ErrSessionNotFound, targetPath)
+	}
+	return nil
+}
+
+func TestRefreshHandler(t *testing.T) {
+	h := NewHandler(fakeRefresher{"/known": true}, Diagnostics{})
+
+	for _, tc := range []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodPost, "/refresh?targetPath=/known", http.StatusOK},
+		{http.MethodPost, "/refresh?targetPath=/unknown", http.StatusNotFound},
+		{http.MethodPost, "/refresh", http.StatusBadRequest},
+		{http.MethodGet, "/refresh?targetPath=/known", http.StatusMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		require.Equal(t, tc.status, rec.Code, "%s %s", tc.method, tc.target)
+	}
+}
+
+func TestInventoryHandler(t *testing.T) {
+	h := NewHandler(fakeRefresher{"/prod/db": true}, Diagnostics{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"paths":[{"path":"/prod/db","mounts":1,"secretProviderClasses":null,"namespaces":null}]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestFailuresHandler(t *testing.T) {
+	h := NewHandler(fakeRefresher{"/pods/a": false, "/pods/b": true}, Diagnostics{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/failures?targetPath=/pods/a", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"targetPaths":[{"targetPath":"/pods/a","failures":[{"time":"0001-01-01T00:00:00Z","stage":"fetch","error":"item not found","duration":""}]}]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/failures?targetPath=/pods/b", nil))
+	require.JSONEq(t, `{"targetPaths":[]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/failures", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestDiagnosticsHandlers(t *testing.T) {
+	t.Setenv(config.AkeylessAccessID, "p-1234")
+	t.Setenv(config.AkeylessAccessKey, "very-secret")
+	logs := NewLogBuffer(10)
+	_, _ = logs.Write([]byte("mounted\n"))
+	h := NewHandler(fakeRefresher{}, Diagnostics{Logs: logs, Flags: map[string]string{"cache-ttl": "0s"}})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"flags":{"cache-ttl":"0s"},"environment":{"AKEYLESS_ACCESS_ID":"p-1234","AKEYLESS_ACCESS_KEY":"[REDACTED]"}}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs", nil))
+	require.Equal(t, "mounted\n", rec.Body.String())
+}
+
+func TestCapabilitiesHandler(t *testing.T) {
+	h := NewHandler(fakeRefresher{}, Diagnostics{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var capabilities provider.Capabilities
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &capabilities))
+	require.Contains(t, capabilities.ItemTypes, "STATIC_SECRET")
+	require.Contains(t, capabilities.AccessTypes, "universal_identity")
+	require.Contains(t, capabilities.PostProcessors, "template")
+	require.Contains(t, capabilities.ObjectFormats, "yaml")
+	require.Contains(t, capabilities.TemplateFunctions, "printf")
+	require.Equal(t, []string{"jwk", "pem"}, capabilities.PublicKeyFormats)
+	require.Equal(t, []string{"tag-selection"}, capabilities.Features)
+}
@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LogBuffer keeps the most recent log lines of the provider for support bundles. It is meant
+// to be added to the log output next to stderr.
+type LogBuffer struct {
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	full  bool
+	// partial holds a line written without its trailing newline yet
+	partial []byte
+}
+
+// NewLogBuffer returns a buffer keeping the last size lines.
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{lines: make([][]byte, size)}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) == 0 {
+		return len(p), nil
+	}
+
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b.lines[b.next] = append([]byte(nil), data[:i+1]...)
+		b.next = (b.next + 1) % len(b.lines)
+		b.full = b.full || b.next == 0
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// Lines returns the buffered lines, oldest first.
+func (b *LogBuffer) Lines() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []byte
+	if b.full {
+		for _, line := range b.lines[b.next:] {
+			out = append(out, line...)
+		}
+	}
+	for _, line := range b.lines[:b.next] {
+		out = append(out, line...)
+	}
+	return out
+}
@@ -0,0 +1,24 @@
+package admin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogBuffer(t *testing.T) {
+	b := NewLogBuffer(3)
+	require.Empty(t, b.Lines())
+
+	for i := 0; i < 5; i++ {
+		_, err := fmt.Fprintf(b, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	require.Equal(t, "line 2\nline 3\nline 4\n", string(b.Lines()))
+
+	_, _ = b.Write([]byte("part"))
+	require.Equal(t, "line 2\nline 3\nline 4\n", string(b.Lines()))
+	_, _ = b.Write([]byte("ial\n"))
+	require.Equal(t, "line 3\nline 4\npartial\n", string(b.Lines()))
+}
@@ -0,0 +1,49 @@
+// Package audit records which secrets every mount accessed. Records are always logged, and can
+// additionally be shipped to a syslog endpoint or a webhook for SIEMs that can't scrape container logs.
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Record is the access of one mount request.
+type Record struct {
+	Time                time.Time `json:"time"`
+	SecretProviderClass string    `json:"secretProviderClass"`
+	Namespace           string    `json:"namespace"`
+	Pod                 string    `json:"pod"`
+	ServiceAccount      string    `json:"serviceAccount"`
+	TargetPath          string    `json:"targetPath"`
+	// Objects are the Akeyless paths the mount fetched, folders expanded
+	Objects []string `json:"objects"`
+	Result  string   `json:"result"`
+	Error   string   `json:"error,omitempty"`
+}
+
+var (
+	mu       sync.RWMutex
+	exporter *Exporter
+)
+
+// Log logs the record and queues it for the running exporter, if any.
+func Log(r Record) {
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		log.Printf("failed to marshal audit record, error: %v", err)
+		return
+	}
+	log.Printf("audit record: %s", line)
+
+	mu.RLock()
+	e := exporter
+	mu.RUnlock()
+	if e != nil {
+		e.enqueue(r)
+	}
+}
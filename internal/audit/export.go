@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// queueSize bounds the records waiting to be shipped, records beyond it are dropped.
+const queueSize = 10000
+
+// Sink ships a batch of records.
+type Sink interface {
+	Send(ctx context.Context, records []Record) error
+}
+
+// ExportConfig configures shipping records to a sink.
+type ExportConfig struct {
+	// URL is the sink, syslog://host:port (UDP), syslog+tcp://host:port, or an http(s) webhook
+	URL string
+	// BatchSize is the number of records shipped at once at most
+	BatchSize int
+	// FlushInterval is how long records wait for a batch to fill up
+	FlushInterval time.Duration
+	// MaxRetries is how often a failed batch is retried before it's dropped
+	MaxRetries int
+}
+
+// Exporter ships records to a sink in batches, retrying failed batches with backoff.
+type Exporter struct {
+	sink    Sink
+	cfg     ExportConfig
+	queue   chan Record
+	backoff time.Duration
+}
+
+// NewSink returns the sink of a sink URL.
+func NewSink(sinkURL string) (Sink, error) {
+	u, err := url.Parse(sinkURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit sink url %v: %w", sinkURL, err)
+	}
+	switch u.Scheme {
+	case "syslog", "syslog+udp":
+		return &syslogSink{network: "udp", addr: u.Host}, nil
+	case "syslog+tcp":
+		return &syslogSink{network: "tcp", addr: u.Host}, nil
+	case "http", "https":
+		return &webhookSink{url: sinkURL, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	}
+	return nil, fmt.Errorf("unsupported audit sink url %v, expected syslog://, syslog+tcp://, http:// or https://", sinkURL)
+}
+
+// StartExporter ships all records logged from now on to the sink of cfg until ctx is done.
+func StartExporter(ctx context.Context, cfg ExportConfig) error {
+	sink, err := NewSink(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if cfg.BatchSize <= 0 {
+		return fmt.Errorf("invalid audit batch size %d", cfg.BatchSize)
+	}
+	if cfg.FlushInterval <= 0 {
+		return fmt.Errorf("invalid audit flush interval %v", cfg.FlushInterval)
+	}
+
+	e := newExporter(sink, cfg)
+	mu.Lock()
+	exporter = e
+	mu.Unlock()
+
+	go func() {
+		e.run(ctx)
+		mu.Lock()
+		if exporter == e {
+			exporter = nil
+		}
+		mu.Unlock()
+	}()
+	return nil
+}
+
+func newExporter(sink Sink, cfg ExportConfig) *Exporter {
+	return &Exporter{sink: sink, cfg: cfg, queue: make(chan Record, queueSize), backoff: time.Second}
+}
+
+func (e *Exporter) enqueue(r Record) {
+	select {
+	case e.queue <- r:
+	default:
+		log.Printf("WARNING: audit queue full, dropping audit record of target path %v", r.TargetPath)
+	}
+}
+
+// run ships the queued records until ctx is done, then ships what's left once.
+func (e *Exporter) run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []Record
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case r := <-e.queue:
+					batch = append(batch, r)
+				default:
+					if len(batch) > 0 {
+						e.ship(context.Background(), batch)
+					}
+					return
+				}
+			}
+		case r := <-e.queue:
+			batch = append(batch, r)
+			if len(batch) >= e.cfg.BatchSize {
+				e.ship(ctx, batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.ship(ctx, batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+func (e *Exporter) ship(ctx context.Context, batch []Record) {
+	backoff := e.backoff
+	for attempt := 0; ; attempt++ {
+		err := e.sink.Send(ctx, batch)
+		if err == nil {
+			return
+		}
+		if attempt >= e.cfg.MaxRetries {
+			log.Printf("failed to ship %d audit records to %v, dropping them, error: %v", len(batch), e.cfg.URL, err)
+			return
+		}
+		log.Printf("failed to ship %d audit records to %v, retrying in %v, error: %v", len(batch), e.cfg.URL, backoff, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+type syslogSink struct {
+	network string
+	addr    string
+	writer  *syslog.Writer
+}
+
+func (s *syslogSink) Send(_ context.Context, records []Record) error {
+	if s.writer == nil {
+		w, err := syslog.Dial(s.network, s.addr, syslog.LOG_INFO|syslog.LOG_AUTH, "akeyless-csi-provider")
+		if err != nil {
+			return err
+		}
+		s.writer = w
+	}
+	for i, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if err = s.writer.Info(string(line)); err != nil {
+			// the failed batch is retried as a whole and its sent records duplicated, better than lost
+			_ = s.writer.Close()
+			s.writer = nil
+			return fmt.Errorf("failed after %d of %d records: %w", i, len(records), err)
+		}
+	}
+	return nil
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) Send(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
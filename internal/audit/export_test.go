@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSink(t *testing.T) {
+	for _, sinkURL := range []string{"syslog://127.0.0.1:514", "syslog+tcp://127.0.0.1:514", "https://siem.example.com/audit"} {
+		_, err := NewSink(sinkURL)
+		require.NoError(t, err, sinkURL)
+	}
+	_, err := NewSink("ftp://siem.example.com")
+	require.ErrorContains(t, err, "unsupported audit sink url")
+}
+
+func TestExporter_WebhookBatchesAndRetries(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		batches  [][]string
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Records []Record `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		var targets []string
+		for _, rec := range body.Records {
+			targets = append(targets, rec.TargetPath)
+		}
+		batches = append(batches, targets)
+	}))
+	defer hook.Close()
+
+	sink, err := NewSink(hook.URL)
+	require.NoError(t, err)
+	e := newExporter(sink, ExportConfig{URL: hook.URL, BatchSize: 2, FlushInterval: time.Hour, MaxRetries: 1})
+	e.backoff = time.Millisecond
+
+	for _, target := range []string{"/a", "/b", "/c"} {
+		e.enqueue(Record{TargetPath: target})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(batches) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the partial batch is shipped on shutdown
+	cancel()
+	<-done
+	require.Equal(t, [][]string{{"/a", "/b"}, {"/c"}}, batches)
+	require.Equal(t, 3, requests)
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := NewSink("syslog://" + conn.LocalAddr().String())
+	require.NoError(t, err)
+	require.NoError(t, sink.Send(context.Background(), []Record{{TargetPath: "/pods/a/mount", Objects: []string{"/prod/db"}}}))
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	line := string(buf[:n])
+	require.True(t, strings.Contains(line, "akeyless-csi-provider"), line)
+	require.True(t, strings.Contains(line, `"targetPath":"/pods/a/mount"`), line)
+}
@@ -0,0 +1,106 @@
+// Package cli implements the provider's administrative subcommands.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrAccessReviewFailed is returned when the access ID lacks access to any of the objects.
+var ErrAccessReviewFailed = errors.New("access review failed")
+
+// AccessReview checks, for every object of a SecretProviderClass, whether the configured access ID
+// can describe the item and get its value, and prints the resulting pass/fail matrix. Items whose
+// fetches issue credentials, certificates or detokenize are described only.
+func AccessReview(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("access-review", flag.ContinueOnError)
+	spcFile := fs.String("spc", "", "path to the SecretProviderClass yaml to review")
+	akeylessAddr := fs.String("akeyless-address", "", "Akeyless API URL, used when the SecretProviderClass sets neither akeylessGatewayURL nor AKEYLESS_URL")
+	saasFallback := fs.Bool("saas-fallback", false, "use the Akeyless SaaS API "+config.SaaSURL+" when no -akeyless-address is set, like the provider's flag")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *spcFile == "" {
+		return errors.New("missing -spc flag")
+	}
+	if *akeylessAddr == "" && *saasFallback {
+		*akeylessAddr = config.SaaSURL
+	}
+
+	params, err := readSPCParameters(*spcFile)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	cfg, err := config.Parse(ctx, config.NewClient, "", params, "/access-review", "420", *akeylessAddr, "kubernetes")
+	if err != nil {
+		return fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	results, err := provider.NewProvider().Review(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to expand objects: %w", err)
+	}
+	failed := false
+	for _, r := range results {
+		failed = failed || r.Failed()
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OBJECT\tDESCRIBE\tGET\tERROR")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.SecretPath, r.Describe, r.Get, r.Error)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if failed {
+		return ErrAccessReviewFailed
+	}
+	return nil
+}
+
+// readSPCParameters returns the parameters of a SecretProviderClass yaml in the form the provider
+// receives them from the driver.
+func readSPCParameters(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var spc struct {
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Parameters map[string]string `yaml:"parameters"`
+		} `yaml:"spec"`
+	}
+	if err = yaml.Unmarshal(data, &spc); err != nil {
+		return "", fmt.Errorf("failed to parse SecretProviderClass %v: %w", path, err)
+	}
+	if spc.Spec.Parameters == nil {
+		return "", fmt.Errorf("SecretProviderClass %v has no parameters", path)
+	}
+	if _, ok := spc.Spec.Parameters["secretProviderClass"]; !ok {
+		spc.Spec.Parameters["secretProviderClass"] = spc.Metadata.Name
+	}
+
+	params, err := json.Marshal(spc.Spec.Parameters)
+	if err != nil {
+		return "", err
+	}
+	return string(params), nil
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessReview_NoGateway(t *testing.T) {
+	t.Setenv(config.AkeylessURL, "")
+	spc := filepath.Join(t.TempDir(), "spc.yaml")
+	require.NoError(t, os.WriteFile(spc, []byte(`apiVersion: secrets-store.csi.x-k8s.io/v1
+kind: SecretProviderClass
+metadata:
+  name: review
+spec:
+  provider: akeyless
+  parameters:
+    akeylessAccessType: access_key
+    akeylessAccessID: p-review
+    objects: |
+      - secretPath: /db
+        fileName: db
+`), 0600))
+
+	// without a gateway the review never reaches out to the SaaS API on its own
+	var out bytes.Buffer
+	err := AccessReview([]string{"-spc", spc}, &out)
+	require.ErrorContains(t, err, "no gateway configured")
+}
@@ -0,0 +1,272 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	providerserver "github.com/akeylesslabs/akeyless-csi-provider/internal/server"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// ErrSoakFailed is returned when more mounts of a soak run failed than allowed.
+var ErrSoakFailed = errors.New("soak failed")
+
+type soakMount struct {
+	remount  bool
+	duration time.Duration
+	err      error
+}
+
+// Soak simulates pods mounting synthetic SecretProviderClasses against a gateway, or a fake one
+// serving synthetic items, and prints the latency percentiles and error rates of the mounts, to
+// size gateways before production rollouts. Every pod mounts once and then remounts like the
+// driver's rotation does, through the same code path as the provider serving the driver.
+func Soak(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("soak", flag.ContinueOnError)
+	akeylessAddr := fs.String("akeyless-address", "", "gateway URL to soak, authenticating with the AKEYLESS_* environment, empty for a built-in fake gateway")
+	pods := fs.Int("pods", 100, "number of simulated pods")
+	spcs := fs.Int("spcs", 10, "number of synthetic SecretProviderClasses, pods are spread evenly across them")
+	objects := fs.Int("objects", 5, "number of objects per SecretProviderClass")
+	items := fs.Int("items", 0, "number of distinct items the objects are spread across, 0 for one item per object")
+	itemPattern := fs.String("item-pattern", "/soak/item-%d", "path of the items, %d is replaced with the item number starting at 0")
+	mounts := fs.Int("mounts", 3, "mounts per pod, the first one followed by rotation remounts")
+	interval := fs.Duration("interval", 0, "pause between the rounds of remounts")
+	concurrency := fs.Int("concurrency", 50, "maximum number of mounts in flight")
+	maxErrorRate := fs.Float64("max-error-rate", 0.01, "fraction of failed mounts above which the soak fails")
+	fakeLatency := fs.Duration("fake-latency", 10*time.Millisecond, "latency of every request to the fake gateway")
+	fakeErrorRate := fs.Float64("fake-error-rate", 0, "fraction of item requests the fake gateway fails, authentication always succeeds")
+	verbose := fs.Bool("verbose", false, "keep the provider's logs of every mount")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *pods < 1 || *spcs < 1 || *objects < 1 || *mounts < 1 || *concurrency < 1 {
+		return errors.New("-pods, -spcs, -objects, -mounts and -concurrency must be at least 1")
+	}
+	if *items < 1 {
+		*items = *spcs * *objects
+	}
+
+	attributes := map[string]string{}
+	if *akeylessAddr == "" {
+		gw := httptest.NewServer(&soakGateway{latency: *fakeLatency, errorRate: *fakeErrorRate})
+		defer gw.Close()
+		*akeylessAddr = gw.URL
+		attributes["akeylessAccessType"] = string(config.AccessKey)
+		attributes["akeylessAccessID"] = "p-soak"
+		attributes["akeylessAccessKey"] = "soak"
+		fmt.Fprintf(out, "soaking the built-in fake gateway, latency: %v, error rate: %v\n", *fakeLatency, *fakeErrorRate)
+	} else {
+		fmt.Fprintf(out, "soaking %v\n", *akeylessAddr)
+	}
+	attributes["akeylessGatewayURL"] = *akeylessAddr
+
+	requests := make([]*pb.MountRequest, *pods)
+	for i := range requests {
+		spc := i % *spcs
+		var objs []string
+		for j := 0; j < *objects; j++ {
+			item := fmt.Sprintf(*itemPattern, (spc**objects+j)%*items)
+			objs = append(objs, fmt.Sprintf("- secretPath: %q\n  fileName: object-%d", item, j))
+		}
+		attrs := map[string]string{
+			"secretProviderClass":                    fmt.Sprintf("soak-%d", spc),
+			"objects":                                strings.Join(objs, "\n"),
+			"csi.storage.k8s.io/pod.name":            fmt.Sprintf("soak-%d", i),
+			"csi.storage.k8s.io/pod.uid":             fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			"csi.storage.k8s.io/pod.namespace":       "soak",
+			"csi.storage.k8s.io/serviceAccount.name": "soak",
+		}
+		for k, v := range attributes {
+			attrs[k] = v
+		}
+		encoded, err := json.Marshal(attrs)
+		if err != nil {
+			return err
+		}
+		requests[i] = &pb.MountRequest{
+			Attributes: string(encoded),
+			TargetPath: fmt.Sprintf("/soak/pods/%d/mount", i),
+			Permission: "420",
+			Secrets:    "{}",
+		}
+	}
+
+	if !*verbose {
+		previous := log.Writer()
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(previous)
+	}
+
+	// the authentication routines of the mounts run until the soak is over
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &providerserver.Server{NewClient: config.NewClient}
+
+	var results []soakMount
+	var mu sync.Mutex
+	sem := make(chan struct{}, *concurrency)
+	start := time.Now()
+	for round := 0; round < *mounts; round++ {
+		if round > 0 && *interval > 0 {
+			time.Sleep(*interval)
+		}
+		var wg sync.WaitGroup
+		for _, req := range requests {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(req *pb.MountRequest) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				mountStart := time.Now()
+				_, err := s.Mount(ctx, req)
+				r := soakMount{remount: round > 0, duration: time.Since(mountStart), err: err}
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}(req)
+		}
+		wg.Wait()
+	}
+	elapsed := time.Since(start)
+
+	failed, err := writeSoakReport(out, results, elapsed)
+	if err != nil {
+		return err
+	}
+	if rate := float64(failed) / float64(len(results)); rate > *maxErrorRate {
+		return fmt.Errorf("%w: error rate %.2f%% exceeds -max-error-rate %.2f%%", ErrSoakFailed, rate*100, *maxErrorRate*100)
+	}
+	return nil
+}
+
+// writeSoakReport prints the latency and error summary of the mounts and returns how many failed.
+func writeSoakReport(out io.Writer, results []soakMount, elapsed time.Duration) (int, error) {
+	var first, remounts []soakMount
+	errorCounts := make(map[string]int)
+	failed := 0
+	for _, r := range results {
+		if r.remount {
+			remounts = append(remounts, r)
+		} else {
+			first = append(first, r)
+		}
+		if r.err != nil {
+			failed++
+			errorCounts[r.err.Error()]++
+		}
+	}
+
+	fmt.Fprintf(out, "%d mounts in %v, %.1f mounts/s\n\n", len(results), elapsed.Round(time.Millisecond), float64(len(results))/elapsed.Seconds())
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MOUNTS\tCOUNT\tERRORS\tERROR RATE\tP50\tP90\tP99\tMAX")
+	for _, row := range []struct {
+		name    string
+		results []soakMount
+	}{{"first", first}, {"remount", remounts}, {"all", results}} {
+		if len(row.results) == 0 {
+			continue
+		}
+		durations := make([]time.Duration, len(row.results))
+		errs := 0
+		for i, r := range row.results {
+			durations[i] = r.duration
+			if r.err != nil {
+				errs++
+			}
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%v\t%v\t%v\t%v\n", row.name, len(durations), errs, 100*float64(errs)/float64(len(durations)),
+			percentile(durations, 50), percentile(durations, 90), percentile(durations, 99), durations[len(durations)-1].Round(time.Microsecond))
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+
+	if len(errorCounts) > 0 {
+		type errorCount struct {
+			err   string
+			count int
+		}
+		var counts []errorCount
+		for e, n := range errorCounts {
+			counts = append(counts, errorCount{e, n})
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			return counts[i].count > counts[j].count || counts[i].count == counts[j].count && counts[i].err < counts[j].err
+		})
+		fmt.Fprintln(out, "\nmost frequent errors:")
+		for i, c := range counts {
+			if i == 5 {
+				break
+			}
+			fmt.Fprintf(out, "%6d  %s\n", c.count, c.err)
+		}
+	}
+	return failed, nil
+}
+
+// percentile returns the nearest-rank p-th percentile of the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1].Round(time.Microsecond)
+}
+
+// soakGateway is the fake gateway of soak runs, serving a synthetic static secret for every item name.
+type soakGateway struct {
+	latency   time.Duration
+	errorRate float64
+}
+
+func (g *soakGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	select {
+	case <-time.After(g.latency):
+	case <-r.Context().Done():
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	// failed authentications fail the whole mount before any item is requested
+	if g.errorRate > 0 && r.URL.Path != "/auth" && rand.Float64() < g.errorRate {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"injected soak failure"}`))
+		return
+	}
+
+	var out interface{}
+	switch r.URL.Path {
+	case "/auth":
+		out = map[string]interface{}{"token": "t-soak"}
+	case "/describe-item":
+		out = map[string]interface{}{"item_name": body["name"], "item_type": "STATIC_SECRET", "last_version": 1}
+	case "/get-secret-value":
+		names, _ := body["names"].([]interface{})
+		values := make(map[string]interface{})
+		for _, name := range names {
+			values[fmt.Sprint(name)] = "value-of-" + fmt.Sprint(name)
+		}
+		out = values
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		out = map[string]interface{}{"error": "not served by the soak gateway"}
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoak_FakeGateway(t *testing.T) {
+	var out bytes.Buffer
+	err := Soak([]string{"-pods", "6", "-spcs", "2", "-objects", "3", "-items", "4", "-mounts", "2", "-concurrency", "3", "-fake-latency", "0"}, &out)
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "12 mounts in")
+	require.Regexp(t, `first\s+6\s+0\s+0.00%`, out.String())
+	require.Regexp(t, `remount\s+6\s+0\s+0.00%`, out.String())
+	require.Regexp(t, `all\s+12\s+0\s+0.00%`, out.String())
+
+	out.Reset()
+	err = Soak([]string{"-pods", "2", "-spcs", "1", "-objects", "1", "-mounts", "1", "-fake-latency", "0", "-fake-error-rate", "1"}, &out)
+	require.ErrorIs(t, err, ErrSoakFailed)
+	require.Contains(t, out.String(), "most frequent errors:")
+	require.Contains(t, out.String(), "injected soak failure")
+}
+
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 1; i <= 200; i++ {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, 100*time.Millisecond, percentile(durations, 50))
+	require.Equal(t, 198*time.Millisecond, percentile(durations, 99))
+	require.Equal(t, time.Millisecond, percentile(durations[:1], 50))
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/version"
+)
+
+const supportBundleTimeout = 10 * time.Second
+
+type connectivityResult struct {
+	Target     string `json:"target"`
+	URL        string `json:"url"`
+	Reachable  bool   `json:"reachable"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Duration   string `json:"duration"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SupportBundle collects the diagnostics of the provider running next to it into a gzipped
+// tarball to attach to support tickets: version info, the effective config with secrets redacted,
+// recent logs, a metrics snapshot and connectivity probe results. Anything that can't be collected
+// is listed in errors.txt of the bundle instead of failing it.
+func SupportBundle(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("support-bundle", flag.ContinueOnError)
+	adminAddr := fs.String("admin-address", "127.0.0.1:8081", "address of the provider's administrative endpoints")
+	healthAddr := fs.String("health-address", "127.0.0.1:8080", "address of the provider's health and metrics endpoints")
+	akeylessAddr := fs.String("akeyless-address", "", "Akeyless API URL to probe, defaults to the provider's -akeyless-address")
+	output := fs.String("output", fmt.Sprintf("support-bundle-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z")), "path of the bundle to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: supportBundleTimeout}
+	adminURL := "http://" + *adminAddr
+	healthURL := "http://" + *healthAddr
+
+	files := make(map[string][]byte)
+	var errs []string
+	collect := func(name string, data []byte, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		files[name] = data
+	}
+
+	v, err := version.GetVersion()
+	collect("version.json", []byte(v), err)
+
+	cfg, err := fetch(client, adminURL+"/config")
+	collect("config.json", cfg, err)
+	logs, err := fetch(client, adminURL+"/logs")
+	collect("logs.txt", logs, err)
+	metrics, err := fetch(client, healthURL+"/metrics")
+	collect("metrics.txt", metrics, err)
+
+	if *akeylessAddr == "" {
+		*akeylessAddr = providerAkeylessAddress(cfg)
+	}
+	probes := []connectivityResult{
+		probe(client, "health", healthURL+"/health/live"),
+		probe(client, "admin", adminURL+"/inventory"),
+	}
+	if *akeylessAddr != "" {
+		probes = append(probes, probe(client, "akeyless", *akeylessAddr))
+	} else {
+		errs = append(errs, "connectivity.json: unknown Akeyless API URL, set -akeyless-address")
+	}
+	connectivity, err := json.MarshalIndent(probes, "", "  ")
+	collect("connectivity.json", connectivity, err)
+
+	if len(errs) > 0 {
+		files["errors.txt"] = []byte(strings.Join(errs, "\n") + "\n")
+	}
+
+	if err = writeBundle(*output, files); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "support bundle written to %s\n", *output)
+	for _, e := range errs {
+		fmt.Fprintf(out, "not collected: %s\n", e)
+	}
+	return nil
+}
+
+func fetch(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return body, nil
+}
+
+// probe reports whether url answers at all, any HTTP status counts as reachable.
+func probe(client *http.Client, target, url string) connectivityResult {
+	r := connectivityResult{Target: target, URL: url}
+	start := time.Now()
+	resp, err := client.Get(url)
+	r.Duration = time.Since(start).String()
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	resp.Body.Close()
+	r.Reachable = true
+	r.StatusCode = resp.StatusCode
+	return r
+}
+
+// providerAkeylessAddress returns the Akeyless API URL of the provider's /config response.
+func providerAkeylessAddress(cfg []byte) string {
+	var c struct {
+		Flags       map[string]string `json:"flags"`
+		Environment map[string]string `json:"environment"`
+	}
+	if err := json.Unmarshal(cfg, &c); err != nil {
+		return ""
+	}
+	if url := c.Environment["AKEYLESS_URL"]; url != "" {
+		return url
+	}
+	return c.Flags["akeyless-address"]
+}
+
+func writeBundle(path string, files map[string][]byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create support bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, name := range []string{"version.json", "config.json", "logs.txt", "metrics.txt", "connectivity.json", "errors.txt"} {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: now}); err != nil {
+			return fmt.Errorf("failed to write support bundle: %w", err)
+		}
+		if _, err = tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write support bundle: %w", err)
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	if err = gz.Close(); err != nil {
+		return fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	return f.Close()
+}
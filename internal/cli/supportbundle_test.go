@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupportBundle(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/config":
+			_, _ = w.Write([]byte(`{"flags":{},"environment":{"AKEYLESS_ACCESS_KEY":"[REDACTED]"}}`))
+		case "/logs":
+			_, _ = w.Write([]byte("mounted\n"))
+		case "/metrics":
+			_, _ = w.Write([]byte("akeyless_csi_provider_mount_requests_total 1\n"))
+		}
+	}))
+	defer provider.Close()
+	addr := strings.TrimPrefix(provider.URL, "http://")
+
+	output := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	var out bytes.Buffer
+	require.NoError(t, SupportBundle([]string{"-admin-address", addr, "-health-address", addr, "-output", output}, &out))
+	require.Contains(t, out.String(), "unknown Akeyless API URL")
+
+	f, err := os.Open(output)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[h.Name] = string(data)
+	}
+	require.Equal(t, "mounted\n", files["logs.txt"])
+	require.Contains(t, files["config.json"], "[REDACTED]")
+	require.Contains(t, files["metrics.txt"], "mount_requests_total")
+	require.Contains(t, files["connectivity.json"], `"reachable": true`)
+	require.Contains(t, files, "version.json")
+	require.Contains(t, files["errors.txt"], "connectivity.json")
+}
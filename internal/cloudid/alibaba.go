@@ -0,0 +1,154 @@
+package cloudid
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	alibabaMetadataURL = "http://100.100.100.200/latest/meta-data/ram/security-credentials/"
+	alibabaSTSEndpoint = "https://sts.aliyuncs.com/"
+)
+
+// AlibabaOptions configures the Alibaba Cloud RAM role cloud identity.
+type AlibabaOptions struct {
+	// RoleName of the RAM role attached to the ECS instance, empty to use the attached role.
+	RoleName string
+}
+
+type alibabaIdentity struct {
+	opts        AlibabaOptions
+	metadataURL string
+	httpClient  *http.Client
+}
+
+type alibabaCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	AccessKeySecret string `json:"AccessKeySecret"`
+	SecurityToken   string `json:"SecurityToken"`
+}
+
+func NewAlibaba(opts AlibabaOptions) CloudIdentity {
+	return &alibabaIdentity{
+		opts:        opts,
+		metadataURL: alibabaMetadataURL,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// CloudID returns a signed STS GetCallerIdentity request made with the RAM role credentials
+// of the ECS instance, which Akeyless replays to verify the role.
+func (i *alibabaIdentity) CloudID() (string, error) {
+	creds, err := i.credentials()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("Action", "GetCallerIdentity")
+	query.Set("Format", "JSON")
+	query.Set("Version", "2015-04-01")
+	query.Set("AccessKeyId", creds.AccessKeyId)
+	query.Set("SecurityToken", creds.SecurityToken)
+	query.Set("SignatureMethod", "HMAC-SHA1")
+	query.Set("SignatureVersion", "1.0")
+	query.Set("SignatureNonce", hex.EncodeToString(nonce))
+	query.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	query.Set("Signature", signAlibabaQuery(http.MethodGet, query, creds.AccessKeySecret))
+
+	stsURL := alibabaSTSEndpoint + "?" + query.Encode()
+	alibabaData := map[string]string{
+		"sts_request_method": http.MethodGet,
+		"sts_request_url":    base64.StdEncoding.EncodeToString([]byte(stsURL)),
+	}
+	alibabaDataDump, err := json.Marshal(alibabaData)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(alibabaDataDump), nil
+}
+
+func (i *alibabaIdentity) credentials() (*alibabaCredentials, error) {
+	role := i.opts.RoleName
+	if role == "" {
+		data, err := i.metadata("")
+		if err != nil {
+			return nil, err
+		}
+		role = strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+		if role == "" {
+			return nil, errors.New("no RAM role is attached to the ECS instance")
+		}
+	}
+
+	data, err := i.metadata(role)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds alibabaCredentials
+	if err = json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to decode RAM role %v credentials: %w", role, err)
+	}
+	if creds.AccessKeyId == "" || creds.AccessKeySecret == "" {
+		return nil, fmt.Errorf("RAM role %v returned empty credentials", role)
+	}
+	return &creds, nil
+}
+
+func (i *alibabaIdentity) metadata(path string) ([]byte, error) {
+	resp, err := i.httpClient.Get(i.metadataURL + path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alibaba RAM role metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch alibaba RAM role metadata, status: %v", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// signAlibabaQuery computes the signature of an Alibaba Cloud RPC-style API request.
+func signAlibabaQuery(method string, query url.Values, secret string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, percentEncode(k)+"="+percentEncode(query.Get(k)))
+	}
+
+	stringToSign := method + "&" + percentEncode("/") + "&" + percentEncode(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func percentEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
@@ -0,0 +1,23 @@
+package cloudid
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAlibabaQuery(t *testing.T) {
+	// Example from the Alibaba Cloud RPC signature documentation.
+	query := url.Values{}
+	query.Set("AccessKeyId", "testid")
+	query.Set("Action", "DescribeRegions")
+	query.Set("Format", "XML")
+	query.Set("SignatureMethod", "HMAC-SHA1")
+	query.Set("SignatureNonce", "3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf")
+	query.Set("SignatureVersion", "1.0")
+	query.Set("Timestamp", "2016-02-23T12:46:24Z")
+	query.Set("Version", "2014-05-26")
+
+	require.Equal(t, "OLeaidS1JvxuMvnyHOwuJ+uX5qY=", signAlibabaQuery("GET", query, "testsecret"))
+}
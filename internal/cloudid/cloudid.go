@@ -0,0 +1,65 @@
+// Package cloudid wraps the cloud providers' identity sources behind a common interface,
+// so that authentication doesn't depend on a specific implementation.
+package cloudid
+
+import (
+	"github.com/akeylesslabs/akeyless-go-cloud-id/cloudprovider/aws"
+	"github.com/akeylesslabs/akeyless-go-cloud-id/cloudprovider/azure"
+	"github.com/akeylesslabs/akeyless-go-cloud-id/cloudprovider/gcp"
+)
+
+// CloudIdentity produces the cloud ID presented to a cloud-based Akeyless auth method.
+type CloudIdentity interface {
+	CloudID() (string, error)
+}
+
+// AWSOptions configures the AWS IAM cloud identity.
+type AWSOptions struct{}
+
+// AzureOptions configures the Azure AD cloud identity.
+type AzureOptions struct {
+	// ObjectID selects a user-assigned managed identity, empty for the system-assigned one.
+	ObjectID string
+}
+
+// GCPOptions configures the GCP cloud identity.
+type GCPOptions struct {
+	// Audience of the identity token, empty for the default audience.
+	Audience string
+}
+
+type awsIdentity struct {
+	opts AWSOptions
+}
+
+type azureIdentity struct {
+	opts AzureOptions
+}
+
+type gcpIdentity struct {
+	opts GCPOptions
+}
+
+func NewAWS(opts AWSOptions) CloudIdentity {
+	return &awsIdentity{opts: opts}
+}
+
+func NewAzure(opts AzureOptions) CloudIdentity {
+	return &azureIdentity{opts: opts}
+}
+
+func NewGCP(opts GCPOptions) CloudIdentity {
+	return &gcpIdentity{opts: opts}
+}
+
+func (i *awsIdentity) CloudID() (string, error) {
+	return aws.GetCloudId()
+}
+
+func (i *azureIdentity) CloudID() (string, error) {
+	return azure.GetCloudId(i.opts.ObjectID)
+}
+
+func (i *gcpIdentity) CloudID() (string, error) {
+	return gcp.GetCloudID(i.opts.Audience)
+}
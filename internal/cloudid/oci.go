@@ -0,0 +1,359 @@
+package cloudid
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ociMetadataURL = "http://169.254.169.254/opc/v2"
+
+	// OCIInstancePrincipal authenticates as the compute instance the provider runs on.
+	OCIInstancePrincipal = "instance"
+	// OCIResourcePrincipal authenticates as the workload, using the resource principal
+	// session token injected into the pod environment (OCI_RESOURCE_PRINCIPAL_*).
+	OCIResourcePrincipal = "resource"
+)
+
+// OCIOptions configures the Oracle Cloud cloud identity.
+type OCIOptions struct {
+	// AuthType is either OCIInstancePrincipal (default) or OCIResourcePrincipal.
+	AuthType string
+	// MetadataTimeout bounds the requests to the instance metadata service, 30s if zero. Probes
+	// set a short one, so nodes outside of OCI find out quickly that there is no such service.
+	MetadataTimeout time.Duration
+}
+
+type ociIdentity struct {
+	opts           OCIOptions
+	metadataURL    string
+	metadataClient *http.Client
+	httpClient     *http.Client
+}
+
+func NewOCI(opts OCIOptions) CloudIdentity {
+	metadataTimeout := opts.MetadataTimeout
+	if metadataTimeout == 0 {
+		metadataTimeout = 30 * time.Second
+	}
+	return &ociIdentity{
+		opts:           opts,
+		metadataURL:    ociMetadataURL,
+		metadataClient: &http.Client{Timeout: metadataTimeout},
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// CloudID returns a signed OCI identity request, which Akeyless replays to verify the principal.
+func (i *ociIdentity) CloudID() (string, error) {
+	var (
+		token, region, tenancy string
+		key                    *rsa.PrivateKey
+		err                    error
+	)
+
+	switch i.opts.AuthType {
+	case "", OCIInstancePrincipal:
+		token, key, region, tenancy, err = i.instancePrincipalToken()
+	case OCIResourcePrincipal:
+		token, key, region, tenancy, err = resourcePrincipalToken()
+	default:
+		err = fmt.Errorf("unsupported OCI auth type %v", i.opts.AuthType)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://identity.%s.oraclecloud.com/20160918/tenancies/%s", region, tenancy), nil)
+	if err != nil {
+		return "", err
+	}
+	if err = signOCIRequest(req, "ST$"+token, key, nil); err != nil {
+		return "", err
+	}
+
+	headersJson, err := json.Marshal(req.Header)
+	if err != nil {
+		return "", err
+	}
+
+	ociData := map[string]string{
+		"request_method":  req.Method,
+		"request_url":     base64.StdEncoding.EncodeToString([]byte(req.URL.String())),
+		"request_headers": base64.StdEncoding.EncodeToString(headersJson),
+	}
+	ociDataDump, err := json.Marshal(ociData)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(ociDataDump), nil
+}
+
+// instancePrincipalToken exchanges the instance certificate from the metadata service for a
+// security token bound to a freshly generated session key.
+func (i *ociIdentity) instancePrincipalToken() (string, *rsa.PrivateKey, string, string, error) {
+	certPEM, err := i.metadata("/identity/cert.pem")
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	keyPEM, err := i.metadata("/identity/key.pem")
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	intermediatePEM, err := i.metadata("/identity/intermediate.pem")
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	regionData, err := i.metadata("/instance/canonicalRegionName")
+	if err != nil {
+		return "", nil, "", "", err
+	}
+
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	intermediate, err := parseCertificate(intermediatePEM)
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	instanceKey, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	tenancy := tenancyFromCertificate(cert)
+	if tenancy == "" {
+		return "", nil, "", "", errors.New("instance certificate has no tenancy")
+	}
+
+	sessionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	sessionPub, err := x509.MarshalPKIXPublicKey(&sessionKey.PublicKey)
+	if err != nil {
+		return "", nil, "", "", err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"certificate":              base64.StdEncoding.EncodeToString(cert.Raw),
+		"intermediateCertificates": []string{base64.StdEncoding.EncodeToString(intermediate.Raw)},
+		"publicKey":                base64.StdEncoding.EncodeToString(sessionPub),
+		"purpose":                  "DEFAULT",
+		"fingerprintAlgorithm":     "SHA256",
+	})
+	if err != nil {
+		return "", nil, "", "", err
+	}
+
+	region := strings.TrimSpace(string(regionData))
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://auth.%s.oraclecloud.com/v1/x509", region), bytes.NewReader(body))
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	keyID := fmt.Sprintf("%s/fed-x509/%s", tenancy, fingerprint(cert))
+	if err = signOCIRequest(req, keyID, instanceKey, body); err != nil {
+		return "", nil, "", "", err
+	}
+
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		return "", nil, "", "", fmt.Errorf("failed to fetch OCI instance principal token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return "", nil, "", "", fmt.Errorf("failed to fetch OCI instance principal token, status: %v, body: %s", resp.StatusCode, msg)
+	}
+
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", nil, "", "", fmt.Errorf("failed to decode OCI instance principal token: %w", err)
+	}
+
+	return out.Token, sessionKey, region, tenancy, nil
+}
+
+// resourcePrincipalToken reads the resource principal session token and key from the
+// OCI_RESOURCE_PRINCIPAL_* environment, each of which can be given inline or as a file path.
+func resourcePrincipalToken() (string, *rsa.PrivateKey, string, string, error) {
+	token, err := envOrFile("OCI_RESOURCE_PRINCIPAL_RPST")
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	keyPEM, err := envOrFile("OCI_RESOURCE_PRINCIPAL_PRIVATE_PEM")
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	region := os.Getenv("OCI_RESOURCE_PRINCIPAL_REGION")
+	if region == "" {
+		return "", nil, "", "", errors.New("OCI_RESOURCE_PRINCIPAL_REGION is not set")
+	}
+
+	key, err := parsePrivateKey([]byte(keyPEM))
+	if err != nil {
+		return "", nil, "", "", err
+	}
+
+	tenancy, err := tenancyFromToken(token)
+	if err != nil {
+		return "", nil, "", "", err
+	}
+
+	return token, key, region, tenancy, nil
+}
+
+func (i *ociIdentity) metadata(path string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, i.metadataURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer Oracle")
+
+	resp, err := i.metadataClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OCI instance metadata %v: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OCI instance metadata %v, status: %v", path, resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// signOCIRequest signs the request following the OCI HTTP signature scheme.
+func signOCIRequest(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Host", req.URL.Host)
+
+	headers := []string{"date", "(request-target)", "host"}
+	if body != nil {
+		sum := sha256.Sum256(body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Length", fmt.Sprint(len(body)))
+		req.Header.Set("X-Content-Sha256", base64.StdEncoding.EncodeToString(sum[:]))
+		headers = append(headers, "content-length", "content-type", "x-content-sha256")
+	}
+
+	lines := make([]string, 0, len(headers))
+	for _, h := range headers {
+		if h == "(request-target)" {
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI()))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", h, req.Header.Get(h)))
+	}
+
+	digest := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign OCI request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(`Signature version="1",keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key must be RSA, got %T instead", key)
+	}
+	return rsaKey, nil
+}
+
+// tenancyFromCertificate extracts the tenancy OCID from the "opc-tenant:" subject OU.
+func tenancyFromCertificate(cert *x509.Certificate) string {
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if strings.HasPrefix(ou, "opc-tenant:") {
+			return strings.TrimPrefix(ou, "opc-tenant:")
+		}
+	}
+	return ""
+}
+
+// tenancyFromToken extracts the tenant claim of a resource principal session token.
+func tenancyFromToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed OCI resource principal token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed OCI resource principal token: %w", err)
+	}
+
+	var claims struct {
+		Tenant string `json:"res_tenant"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("malformed OCI resource principal token: %w", err)
+	}
+	if claims.Tenant == "" {
+		return "", errors.New("OCI resource principal token has no tenant")
+	}
+	return claims.Tenant, nil
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+func envOrFile(name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("%v is not set", name)
+	}
+	if strings.HasPrefix(value, "/") {
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %v: %w", name, err)
+		}
+		value = string(data)
+	}
+	return strings.TrimSpace(value), nil
+}
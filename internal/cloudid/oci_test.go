@@ -0,0 +1,54 @@
+package cloudid
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignOCIRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "https://auth.us-ashburn-1.oraclecloud.com/v1/x509", nil)
+	require.NoError(t, err)
+	require.NoError(t, signOCIRequest(req, "tenancy/fed-x509/AA:BB", key, []byte(`{}`)))
+
+	auth := req.Header.Get("Authorization")
+	require.True(t, strings.HasPrefix(auth, `Signature version="1",keyId="tenancy/fed-x509/AA:BB",algorithm="rsa-sha256"`))
+	require.Contains(t, auth, `headers="date (request-target) host content-length content-type x-content-sha256"`)
+	require.Equal(t, "2", req.Header.Get("Content-Length"))
+}
+
+func TestTenancyFromToken(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"res_tenant":"ocid1.tenancy.oc1..aaa"}`))
+	tenancy, err := tenancyFromToken("header." + payload + ".signature")
+	require.NoError(t, err)
+	require.Equal(t, "ocid1.tenancy.oc1..aaa", tenancy)
+
+	_, err = tenancyFromToken("not-a-jwt")
+	require.Error(t, err)
+}
+
+func TestOCIMetadataTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer metadata.Close()
+	defer close(hang)
+
+	identity := NewOCI(OCIOptions{MetadataTimeout: 50 * time.Millisecond}).(*ociIdentity)
+	identity.metadataURL = metadata.URL
+	start := time.Now()
+	_, err := identity.CloudID()
+	require.ErrorContains(t, err, "failed to fetch OCI instance metadata")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
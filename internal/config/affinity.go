@@ -0,0 +1,11 @@
+package config
+
+// gatewaySessionAffinity makes clients keep the cookies of gateway responses, see SetGatewaySessionAffinity.
+var gatewaySessionAffinity = false
+
+// SetGatewaySessionAffinity makes the clients of mounts send the cookies their gateway responses
+// set, so gateway clusters behind sticky load balancers serve every call of a mount from the
+// same backend, keeping the backend's auth token and cache state consistent within the mount.
+func SetGatewaySessionAffinity(enabled bool) {
+	gatewaySessionAffinity = enabled
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewaySessionAffinity(t *testing.T) {
+	var backends []string
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backend := "none"
+		if c, err := r.Cookie("backend"); err == nil {
+			backend = c.Value
+		}
+		backends = append(backends, backend)
+		http.SetCookie(w, &http.Cookie{Name: "backend", Value: "b-1"})
+	}))
+	defer gw.Close()
+
+	get := func(client *http.Client) {
+		resp, err := client.Get(gw.URL + "/describe-item")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	client := newClientConfiguration(gw.URL).HTTPClient
+	get(client)
+	get(client)
+	require.Equal(t, []string{"none", "none"}, backends)
+
+	defer SetGatewaySessionAffinity(false)
+	SetGatewaySessionAffinity(true)
+	backends = nil
+	client = newClientConfiguration(gw.URL).HTTPClient
+	get(client)
+	get(client)
+	require.Equal(t, []string{"none", "b-1"}, backends)
+
+	// every client starts without affinity
+	get(newClientConfiguration(gw.URL).HTTPClient)
+	require.Equal(t, "none", backends[2])
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/akeylesslabs/akeyless-go/v4"
 	"io"
@@ -11,57 +12,42 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/akeylesslabs/akeyless-go-cloud-id/cloudprovider/aws"
-	"github.com/akeylesslabs/akeyless-go-cloud-id/cloudprovider/azure"
-	"github.com/akeylesslabs/akeyless-go-cloud-id/cloudprovider/gcp"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/cloudid"
 )
 
 const (
 	authenticationInterval   = time.Second * 870 // 14.5 minutes - Relevant only for non-UID authentications
 	uidTokenRotationInterval = time.Second * 120
+	uidTokenFileInterval     = time.Second * 10
 	DefServiceAccountFile    = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 )
 
-var (
-	akeylessAuthToken string
-	mutexAuthToken    = &sync.RWMutex{}
-	authenticator     = func(ctx context.Context, aklClient *akeyless.V2ApiService) error { return nil }
-)
-
-func setAuthToken(t string) {
-	mutexAuthToken.Lock()
-	defer mutexAuthToken.Unlock()
-
-	akeylessAuthToken = t
-}
-
-func GetAuthToken() string {
-	mutexAuthToken.RLock()
-	defer mutexAuthToken.RUnlock()
+// ErrAuthentication is wrapped by the errors of parsing a mount whose credentials didn't
+// authenticate using any access type.
+var ErrAuthentication = errors.New("authentication failed")
 
-	return akeylessAuthToken
-}
+// newCloudIdentity builds the cloud identity of cloud-based access types, replaceable in tests
+var newCloudIdentity = defaultCloudIdentity
 
-func (c *Config) authenticate(ctx context.Context, aklClient *akeyless.V2ApiService, authBody *akeyless.Auth) error {
+func (c *Config) authenticate(ctx context.Context, s *Session, authBody *akeyless.Auth) error {
 	authBody.SetAccessId(c.AkeylessAccessID)
 
-	authOut, _, err := aklClient.Auth(ctx).Body(*authBody).Execute()
+	authOut, _, err := s.Client.Auth(ctx).Body(*authBody).Execute()
 	if err != nil {
 		return fmt.Errorf("authentication failed %v, %w", c.AkeylessGatewayURL, err)
 	}
 
-	setAuthToken(authOut.GetToken())
+	s.setToken(authOut.GetToken())
 	return nil
 }
 
-func (c *Config) authWithAccessKey(ctx context.Context, aklClient *akeyless.V2ApiService) error {
+func (c *Config) authWithAccessKey(ctx context.Context, s *Session) error {
 	authBody := akeyless.NewAuthWithDefaults()
 	authBody.SetAccessType(string(AccessKey))
-	authBody.SetAccessKey(c.AkeylessAccessKey)
-	err := c.authenticate(ctx, aklClient, authBody)
+	authBody.SetAccessKey(c.AkeylessAccessKey.Reveal())
+	err := c.authenticate(ctx, s, authBody)
 
 	if err != nil {
 		log.Printf("authWithAccessKey ERR: %v", err.Error())
@@ -69,15 +55,8 @@ func (c *Config) authWithAccessKey(ctx context.Context, aklClient *akeyless.V2Ap
 	return err
 }
 
-func (c *Config) authWithAWS(ctx context.Context, aklClient *akeyless.V2ApiService) error {
-	authBody := akeyless.NewAuthWithDefaults()
-	authBody.SetAccessType(string(AWSIAM))
-	cloudId, err := aws.GetCloudId()
-	if err != nil {
-		return fmt.Errorf("requested access type %v but failed to get cloud ID, error: %v", AWSIAM, err)
-	}
-	authBody.SetCloudId(cloudId)
-	err = c.authenticate(ctx, aklClient, authBody)
+func (c *Config) authWithAWS(ctx context.Context, s *Session) error {
+	err := c.authWithCloudIdentity(ctx, s, AWSIAM, akeyless.NewAuthWithDefaults())
 
 	if err != nil {
 		log.Printf("authWithAWS ERR: %v", err.Error())
@@ -85,39 +64,92 @@ func (c *Config) authWithAWS(ctx context.Context, aklClient *akeyless.V2ApiServi
 	return err
 }
 
-func (c *Config) authWithAzure(ctx context.Context, aklClient *akeyless.V2ApiService) error {
-	authBody := akeyless.NewAuthWithDefaults()
-	authBody.SetAccessType(string(AzureAD))
-	cloudId, err := azure.GetCloudId(c.AkeylessAzureObjectID)
+func (c *Config) authWithAzure(ctx context.Context, s *Session) error {
+	err := c.authWithCloudIdentity(ctx, s, AzureAD, akeyless.NewAuthWithDefaults())
+
 	if err != nil {
-		return fmt.Errorf("requested access type %v but failed to get cloud ID, error: %v", AzureAD, err)
+		log.Printf("authWithAzure ERR: %v", err.Error())
 	}
-	authBody.SetCloudId(cloudId)
-	err = c.authenticate(ctx, aklClient, authBody)
+	return err
+}
+
+func (c *Config) authWithGCP(ctx context.Context, s *Session) error {
+	err := c.authWithCloudIdentity(ctx, s, GCP, akeyless.NewAuthWithDefaults())
 
 	if err != nil {
-		log.Printf("authWithAzure ERR: %v", err.Error())
+		log.Printf("authWithGCP ERR: %v", err.Error())
 	}
 	return err
 }
 
-func (c *Config) authWithGCP(ctx context.Context, aklClient *akeyless.V2ApiService) error {
+func (c *Config) authWithOCI(ctx context.Context, s *Session) error {
 	authBody := akeyless.NewAuthWithDefaults()
-	authBody.SetAccessType(string(GCP))
-	cloudId, err := gcp.GetCloudID(c.AkeylessGCPAudience)
+	authBody.SetOciAuthType(c.ociAuthType())
+	if c.AkeylessOCIGroupOCIDs != "" {
+		var groups []string
+		for _, group := range strings.Split(c.AkeylessOCIGroupOCIDs, ",") {
+			groups = append(groups, strings.TrimSpace(group))
+		}
+		authBody.SetOciGroupOcid(groups)
+	}
+	err := c.authWithCloudIdentity(ctx, s, OCI, authBody)
+
 	if err != nil {
-		return fmt.Errorf("requested access type %v but failed to get cloud ID, error: %v", GCP, err)
+		log.Printf("authWithOCI ERR: %v", err.Error())
 	}
-	authBody.SetCloudId(cloudId)
-	err = c.authenticate(ctx, aklClient, authBody)
+	return err
+}
+
+func (c *Config) authWithAlibaba(ctx context.Context, s *Session) error {
+	err := c.authWithCloudIdentity(ctx, s, AlibabaRAM, akeyless.NewAuthWithDefaults())
 
 	if err != nil {
-		log.Printf("authWithGCP ERR: %v", err.Error())
+		log.Printf("authWithAlibaba ERR: %v", err.Error())
 	}
 	return err
 }
 
-func (c *Config) authWithK8S(ctx context.Context, aklClient *akeyless.V2ApiService) error {
+func (c *Config) ociAuthType() string {
+	if c.AkeylessOCIAuthType == "" {
+		return cloudid.OCIInstancePrincipal
+	}
+	return c.AkeylessOCIAuthType
+}
+
+// authWithCloudIdentity authenticates with the cloud ID produced by the identity of the given access type.
+func (c *Config) authWithCloudIdentity(ctx context.Context, s *Session, accType accessType, authBody *akeyless.Auth) error {
+	identity, err := newCloudIdentity(c, accType)
+	if err != nil {
+		return err
+	}
+
+	authBody.SetAccessType(string(accType))
+	cloudId, err := identity.CloudID()
+	if err != nil {
+		return fmt.Errorf("requested access type %v but failed to get cloud ID, error: %v", accType, err)
+	}
+	authBody.SetCloudId(cloudId)
+	return c.authenticate(ctx, s, authBody)
+}
+
+// defaultCloudIdentity builds the cloud identity of the given access type from the config.
+func defaultCloudIdentity(c *Config, accType accessType) (cloudid.CloudIdentity, error) {
+	switch accType {
+	case AWSIAM:
+		return cloudid.NewAWS(cloudid.AWSOptions{}), nil
+	case AzureAD:
+		return cloudid.NewAzure(cloudid.AzureOptions{ObjectID: c.AkeylessAzureObjectID}), nil
+	case GCP:
+		return cloudid.NewGCP(cloudid.GCPOptions{Audience: c.AkeylessGCPAudience}), nil
+	case OCI:
+		return cloudid.NewOCI(cloudid.OCIOptions{AuthType: c.ociAuthType(), MetadataTimeout: c.cloudMetadataTimeout}), nil
+	case AlibabaRAM:
+		return cloudid.NewAlibaba(cloudid.AlibabaOptions{RoleName: c.AkeylessAlibabaRoleName}), nil
+	}
+	return nil, fmt.Errorf("access type %v has no cloud identity", accType)
+}
+
+func (c *Config) authWithK8S(ctx context.Context, s *Session) error {
 	authBody := akeyless.NewAuthWithDefaults()
 	authBody.SetAccessType(string(K8S))
 	authBody.SetK8sAuthConfigName(c.AkeylessK8sAuthConfigName)
@@ -126,7 +158,7 @@ func (c *Config) authWithK8S(ctx context.Context, aklClient *akeyless.V2ApiServi
 		return fmt.Errorf("failed to read JWT with Kubernetes Auth from %v. error: %v", DefServiceAccountFile, err.Error())
 	}
 	authBody.SetK8sServiceAccountToken(jwtString)
-	err = c.authenticate(ctx, aklClient, authBody)
+	err = c.authenticate(ctx, s, authBody)
 
 	if err != nil {
 		log.Printf("authWithK8s ERR: %v", err.Error())
@@ -134,16 +166,27 @@ func (c *Config) authWithK8S(ctx context.Context, aklClient *akeyless.V2ApiServi
 	return err
 }
 
-func (c *Config) rotateUIDToken(ctx context.Context, aklClient *akeyless.V2ApiService) error {
-	// Get current token
-	currToken := GetAuthToken()
+// rotateUIDToken rotates the token of the UID identity of s, unless another session of the
+// identity rotated it within the last half rotation interval, in which case s just uses that one.
+func (c *Config) rotateUIDToken(ctx context.Context, s *Session) error {
+	id := s.uidIdentity()
+	if id == nil {
+		return errors.New("the session has no UID identity to rotate the token of")
+	}
+	id.mu.Lock()
+	defer id.mu.Unlock()
+
+	if time.Since(id.lastRotated()) < uidTokenRotationInterval/2 {
+		return nil
+	}
+	currToken, _ := id.current()
 
 	// rotate token
 	log.Println("rotating UID token")
 	body := akeyless.UidRotateToken{
 		UidToken: akeyless.PtrString(currToken),
 	}
-	authOut, _, err := aklClient.UidRotateToken(ctx).Body(body).Execute()
+	authOut, _, err := s.Client.UidRotateToken(ctx).Body(body).Execute()
 	if err != nil {
 		return fmt.Errorf("failed to rotate UID token %w", err)
 	}
@@ -152,12 +195,57 @@ func (c *Config) rotateUIDToken(ctx context.Context, aklClient *akeyless.V2ApiSe
 		return fmt.Errorf("rotated uid token returned empty")
 	}
 
-	// Set new token
-	setAuthToken(newToken)
+	// Set new token, for every session of the identity
+	id.set(newToken, true)
+	if err = c.persistUIDToken(id.key, newToken); err != nil {
+		log.Printf("failed to persist rotated UID token: %v", err)
+	}
 	log.Println("successfully rotated UID token")
 	return nil
 }
 
+// loadUIDTokenFile reads the externally rotated UID token and sets it as the current auth token.
+func (c *Config) loadUIDTokenFile(s *Session) error {
+	info, err := os.Stat(c.AkeylessUIDTokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read UID token file %v: %w", c.AkeylessUIDTokenFile, err)
+	}
+
+	data, err := os.ReadFile(c.AkeylessUIDTokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read UID token file %v: %w", c.AkeylessUIDTokenFile, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("UID token file %v is empty", c.AkeylessUIDTokenFile)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = token
+	s.uidTokenFileModTime = info.ModTime()
+	return nil
+}
+
+// reloadUIDTokenFile re-reads the UID token file only if it was modified since the last read.
+func (c *Config) reloadUIDTokenFile(s *Session) error {
+	info, err := os.Stat(c.AkeylessUIDTokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read UID token file %v: %w", c.AkeylessUIDTokenFile, err)
+	}
+
+	s.mu.RLock()
+	changed := !info.ModTime().Equal(s.uidTokenFileModTime)
+	s.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	log.Printf("UID token file %v changed, reloading token", c.AkeylessUIDTokenFile)
+	return c.loadUIDTokenFile(s)
+}
+
 // readK8SServiceAccountJWT reads the JWT data for the Agent to submit to Akeyless Gateway.
 func readK8SServiceAccountJWT() (string, error) {
 	data, err := os.Open(DefServiceAccountFile)
@@ -176,27 +264,73 @@ func readK8SServiceAccountJWT() (string, error) {
 	return base64.StdEncoding.EncodeToString([]byte(a)), nil
 }
 
-func (c *Config) StartAuthentication(ctx context.Context, closed chan bool) error {
-	accType := c.AkeylessAccessType
-
-	switch accessType(accType) {
+// authenticatorFor returns the authentication function matching the configured access type.
+func (c *Config) authenticatorFor() func(ctx context.Context, s *Session) error {
+	switch accessType(c.AkeylessAccessType) {
 	case AccessKey:
-		authenticator = c.authWithAccessKey
+		return c.authWithAccessKey
 
 	case AWSIAM:
-		authenticator = c.authWithAWS
+		return c.authWithAWS
 
 	case AzureAD:
-		authenticator = c.authWithAzure
+		return c.authWithAzure
 
 	case GCP:
-		authenticator = c.authWithGCP
+		return c.authWithGCP
 
 	case K8S:
-		authenticator = c.authWithK8S
+		return c.authWithK8S
+
+	case OCI:
+		return c.authWithOCI
+
+	case AlibabaRAM:
+		return c.authWithAlibaba
+
+	case OAuth2:
+		return c.authWithOAuth2
+
+	case UniversalIdentity:
+		if c.AkeylessUIDTokenFile != "" {
+			return func(ctx context.Context, s *Session) error { return c.loadUIDTokenFile(s) }
+		}
+		return c.rotateUIDToken
 	}
 
-	if accessType(accType) == UniversalIdentity {
+	return func(ctx context.Context, s *Session) error {
+		return fmt.Errorf("unsupported access type %v", c.AkeylessAccessType)
+	}
+}
+
+// StartAuthentication starts the routine keeping the auth token valid. The routine keeps its
+// own copy of the credentials, the caller should wipe the ones of c once it no longer needs them.
+func (c *Config) StartAuthentication(ctx context.Context, closed chan bool) error {
+	c = c.withOwnCredentials()
+	accType := c.AkeylessAccessType
+	s := c.Session
+	authenticator := c.authenticatorFor()
+	s.setAuthenticator(accessType(accType), authenticator)
+
+	if accessType(accType) == UniversalIdentity && c.AkeylessUIDTokenFile != "" {
+		// UID token is rotated externally, re-read it every uidTokenFileInterval seconds
+		runForeverWithContext(ctx, func() error {
+			ticker := time.NewTicker(uidTokenFileInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					closed <- true
+					return nil
+				case <-ticker.C:
+					err := c.reloadUIDTokenFile(s)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}, closed)
+	} else if accessType(accType) == UniversalIdentity {
 		// Rotate UID token every uidTokenRotationInterval seconds
 		runForeverWithContext(ctx, func() error {
 			ticker := time.NewTicker(uidTokenRotationInterval)
@@ -207,7 +341,7 @@ func (c *Config) StartAuthentication(ctx context.Context, closed chan bool) erro
 					closed <- true
 					return nil
 				case <-ticker.C:
-					err := c.rotateUIDToken(ctx, AklClient)
+					err := c.rotateUIDToken(ctx, s)
 					if err != nil {
 						return err
 					}
@@ -226,7 +360,7 @@ func (c *Config) StartAuthentication(ctx context.Context, closed chan bool) erro
 					return nil
 				case <-ticker.C:
 					log.Println("retrieving new token")
-					err := authenticator(ctx, AklClient)
+					err := authenticator(ctx, s)
 					if err != nil {
 						return err
 					}
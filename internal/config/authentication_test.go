@@ -0,0 +1,152 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/cloudid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUIDTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "uid-token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("u-token-1\n"), 0600))
+
+	cfg := Config{Parameters: Parameters{AkeylessUIDTokenFile: tokenFile}}
+	s := NewSession(nil)
+	require.NoError(t, cfg.loadUIDTokenFile(s))
+	require.Equal(t, "u-token-1", s.Token())
+
+	// unchanged file is not re-read
+	s.setToken("other")
+	require.NoError(t, cfg.reloadUIDTokenFile(s))
+	require.Equal(t, "other", s.Token())
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("u-token-2"), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(tokenFile, later, later))
+	require.NoError(t, cfg.reloadUIDTokenFile(s))
+	require.Equal(t, "u-token-2", s.Token())
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte(""), 0600))
+	require.Error(t, cfg.loadUIDTokenFile(s))
+}
+
+type fakeCloudIdentity struct {
+	err error
+}
+
+func (f fakeCloudIdentity) CloudID() (string, error) {
+	return "", f.err
+}
+
+func TestAuthWithCloudIdentity_Failure(t *testing.T) {
+	defer func() { newCloudIdentity = defaultCloudIdentity }()
+	newCloudIdentity = func(c *Config, accType accessType) (cloudid.CloudIdentity, error) {
+		return fakeCloudIdentity{err: errors.New("metadata service unreachable")}, nil
+	}
+
+	cfg := Config{}
+	for _, auth := range []func(context.Context, *Session) error{cfg.authWithAWS, cfg.authWithAzure, cfg.authWithGCP, cfg.authWithOCI, cfg.authWithAlibaba} {
+		err := auth(context.Background(), NewSession(nil))
+		require.ErrorContains(t, err, "metadata service unreachable")
+	}
+}
+
+func TestPersistUIDToken(t *testing.T) {
+	cfg := Config{Parameters: Parameters{AkeylessUIDTokenPersistDir: t.TempDir()}}
+	key := cfg.uidIdentityKey()
+
+	token, err := cfg.loadPersistedUIDToken(key)
+	require.NoError(t, err)
+	require.Empty(t, token)
+
+	require.NoError(t, cfg.persistUIDToken(key, "u-rotated"))
+	token, err = cfg.loadPersistedUIDToken(key)
+	require.NoError(t, err)
+	require.Equal(t, "u-rotated", token)
+
+	info, err := os.Stat(cfg.uidTokenPersistFile(key))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// the token of another identity is kept apart
+	other := Config{Parameters: Parameters{AkeylessUIDTokenPersistDir: cfg.AkeylessUIDTokenPersistDir, AkeylessUIDInitToken: NewCredential("u-other")}}
+	token, err = other.loadPersistedUIDToken(other.uidIdentityKey())
+	require.NoError(t, err)
+	require.Empty(t, token)
+
+	cfg.AkeylessUIDTokenSeal = "unknown"
+	require.Error(t, cfg.persistUIDToken(key, "u-rotated"))
+}
+
+func TestRotateUIDToken_SharedBySessions(t *testing.T) {
+	defer func() { uidIdentities = make(map[string]*uidIdentity) }()
+	var rotations []string
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		rotations = append(rotations, body["uid-token"].(string))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"r-` + body["uid-token"].(string) + `"}`))
+	}))
+	defer gw.Close()
+
+	dir := t.TempDir()
+	newConfig := func(initToken string) *Config {
+		return &Config{Parameters: Parameters{AkeylessAccessID: "p-uid", AkeylessUIDInitToken: NewCredential(initToken), AkeylessUIDTokenPersistDir: dir}}
+	}
+
+	// the mounts of one identity rotate its token once and share the result
+	first, second := NewSession(NewClient(gw.URL)), NewSession(NewClient(gw.URL))
+	require.NoError(t, newConfig("u-init").probeUID(context.Background(), first))
+	require.NoError(t, newConfig("u-init").probeUID(context.Background(), second))
+	require.Equal(t, []string{"u-init"}, rotations)
+	require.Equal(t, "r-u-init", first.Token())
+	require.Equal(t, "r-u-init", second.Token())
+
+	// a rotation by either session is seen by both
+	first.uidIdentity().rotated = time.Time{}
+	require.NoError(t, newConfig("u-init").rotateUIDToken(context.Background(), second))
+	require.Equal(t, "r-r-u-init", first.Token())
+
+	// another identity neither takes the persisted token nor the shared one
+	other := NewSession(NewClient(gw.URL))
+	require.NoError(t, newConfig("u-other").probeUID(context.Background(), other))
+	require.Equal(t, "r-u-other", other.Token())
+	require.Equal(t, "r-r-u-init", first.Token())
+
+	// after a restart, the identity continues from its own persisted token
+	uidIdentities = make(map[string]*uidIdentity)
+	restarted := NewSession(NewClient(gw.URL))
+	require.NoError(t, newConfig("u-init").probeUID(context.Background(), restarted))
+	require.Equal(t, "r-r-r-u-init", restarted.Token())
+}
+
+func TestCheckTokenSeal(t *testing.T) {
+	require.NoError(t, CheckTokenSeal("", ""))
+	require.ErrorContains(t, CheckTokenSeal("unknown", "/var/run/akeyless/uid-tokens"), "unsupported UID token seal mode unknown")
+	require.ErrorContains(t, CheckTokenSeal(TokenSealTPM, ""), "requires a UID token persist directory")
+
+	t.Setenv("PATH", t.TempDir())
+	require.ErrorContains(t, CheckTokenSeal(TokenSealTPM, "/var/run/akeyless/uid-tokens"), "requires tpm2-tools")
+
+	bin := t.TempDir()
+	for _, tool := range tpmTools {
+		require.NoError(t, os.WriteFile(filepath.Join(bin, tool), []byte("#!/bin/sh\n"), 0755))
+	}
+	t.Setenv("PATH", bin)
+	defer func(device string) { tpmDevice = device }(tpmDevice)
+	tpmDevice = filepath.Join(t.TempDir(), "tpmrm0")
+	require.ErrorContains(t, CheckTokenSeal(TokenSealTPM, "/var/run/akeyless/uid-tokens"), "requires the TPM device")
+
+	require.NoError(t, os.WriteFile(tpmDevice, nil, 0600))
+	require.NoError(t, CheckTokenSeal(TokenSealTPM, "/var/run/akeyless/uid-tokens"))
+}
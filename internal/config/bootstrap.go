@@ -0,0 +1,77 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+const (
+	AkeylessUIDBootstrapAccessID  = "AKEYLESS_UID_BOOTSTRAP_ACCESS_ID"
+	AkeylessUIDBootstrapAccessKey = "AKEYLESS_UID_BOOTSTRAP_ACCESS_KEY"
+	AkeylessUIDAuthMethodName     = "AKEYLESS_UID_AUTH_METHOD_NAME"
+)
+
+// bootstrapUIDToken exchanges the one-time bootstrap access key for a new Universal Identity
+// token and persists it as the token of the UID identity key, so that later authentications only
+// rotate the persisted token. The bootstrap key is wiped once used, it is never needed again on
+// this node.
+func (c *Config) bootstrapUIDToken(ctx context.Context, s *Session, key string) (string, error) {
+	if c.AkeylessUIDBootstrapAccessID == "" || c.AkeylessUIDBootstrapAccessKey.Empty() {
+		return "", errors.New("missing UID bootstrap access ID or access key")
+	}
+	// validated before spending the key, the parameters are only validated after authenticating
+	if err := c.validateUIDBootstrap(); err != nil {
+		return "", err
+	}
+	defer c.AkeylessUIDBootstrapAccessKey.Wipe()
+
+	log.Printf("bootstrapping UID token of %v with access key %v", c.AkeylessUIDAuthMethodName, c.AkeylessUIDBootstrapAccessID)
+	authBody := akeyless.NewAuthWithDefaults()
+	authBody.SetAccessType(string(AccessKey))
+	authBody.SetAccessId(c.AkeylessUIDBootstrapAccessID)
+	authBody.SetAccessKey(c.AkeylessUIDBootstrapAccessKey.Reveal())
+	authOut, _, err := s.Client.Auth(ctx).Body(*authBody).Execute()
+	if err != nil {
+		return "", fmt.Errorf("UID bootstrap authentication failed %v, %w", c.AkeylessGatewayURL, err)
+	}
+
+	body := akeyless.UidGenerateToken{
+		AuthMethodName: c.AkeylessUIDAuthMethodName,
+		Token:          akeyless.PtrString(authOut.GetToken()),
+	}
+	out, _, err := s.Client.UidGenerateToken(ctx).Body(body).Execute()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate UID token of %v: %w", c.AkeylessUIDAuthMethodName, err)
+	}
+	token := out.GetToken()
+	if token == "" {
+		return "", fmt.Errorf("generated UID token of %v returned empty", c.AkeylessUIDAuthMethodName)
+	}
+
+	// the bootstrap key is gone after this, so losing the token would need a new enrollment
+	if err = c.persistUIDToken(key, token); err != nil {
+		return "", err
+	}
+	log.Printf("bootstrapped UID token of %v, the bootstrap access key can be removed", c.AkeylessUIDAuthMethodName)
+	return token, nil
+}
+
+func (c *Config) validateUIDBootstrap() error {
+	if c.AkeylessUIDBootstrapAccessKey.Empty() {
+		return nil
+	}
+	if c.AkeylessUIDAuthMethodName == "" {
+		return fmt.Errorf("UID bootstrap access key requires the UID auth method name, secretProviderClass: %v", c.SecretProviderClass)
+	}
+	if c.AkeylessUIDTokenPersistDir == "" {
+		return fmt.Errorf("UID bootstrap access key requires a UID token persist directory, secretProviderClass: %v", c.SecretProviderClass)
+	}
+	if c.AkeylessUIDTokenFile != "" {
+		return fmt.Errorf("UID bootstrap access key can't be used with an externally rotated UID token file, secretProviderClass: %v", c.SecretProviderClass)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapUIDToken(t *testing.T) {
+	defer func() { uidIdentities = make(map[string]*uidIdentity) }()
+	var calls []string
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/auth":
+			require.Equal(t, "access_key", body["access-type"])
+			require.Equal(t, "p-bootstrap", body["access-id"])
+			require.Equal(t, "one-time-key", body["access-key"])
+			_, _ = w.Write([]byte(`{"token":"t-bootstrap"}`))
+		case "/uid-generate-token":
+			require.Equal(t, "t-bootstrap", body["token"])
+			require.Equal(t, "/csi/uid", body["auth-method-name"])
+			_, _ = w.Write([]byte(`{"token":"u-generated"}`))
+		case "/uid-rotate-token":
+			_, _ = w.Write([]byte(`{"token":"u-rotated-` + body["uid-token"].(string) + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer gw.Close()
+
+	bootstrapKey := NewCredential("one-time-key")
+	cfg := Config{Parameters: Parameters{
+		AkeylessGatewayURL:            gw.URL,
+		AkeylessAccessID:              "p-uid",
+		AkeylessUIDTokenPersistDir:    t.TempDir(),
+		AkeylessUIDBootstrapAccessID:  "p-bootstrap",
+		AkeylessUIDBootstrapAccessKey: bootstrapKey,
+		AkeylessUIDAuthMethodName:     "/csi/uid",
+	}}
+	s := NewSession(NewClient(gw.URL))
+	require.NoError(t, cfg.probeUID(context.Background(), s))
+	require.Equal(t, []string{"/auth", "/uid-generate-token", "/uid-rotate-token"}, calls)
+	require.Equal(t, "u-rotated-u-generated", s.Token())
+	require.True(t, bootstrapKey.Empty())
+
+	persisted, err := cfg.loadPersistedUIDToken(cfg.uidIdentityKey())
+	require.NoError(t, err)
+	require.Equal(t, "u-rotated-u-generated", persisted)
+
+	// once persisted, the token is only rotated after a restart, even with the bootstrap key
+	// still configured
+	uidIdentities = make(map[string]*uidIdentity)
+	calls = nil
+	cfg.AkeylessUIDBootstrapAccessKey = NewCredential("one-time-key")
+	require.NoError(t, cfg.probeUID(context.Background(), s))
+	require.Equal(t, []string{"/uid-rotate-token"}, calls)
+	require.Equal(t, "u-rotated-u-rotated-u-generated", s.Token())
+}
+
+func TestBootstrapUIDToken_RequiresPersistDir(t *testing.T) {
+	calls := 0
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.NotFound(w, r)
+	}))
+	defer gw.Close()
+
+	cfg := Config{Parameters: Parameters{
+		AkeylessUIDBootstrapAccessID:  "p-bootstrap",
+		AkeylessUIDBootstrapAccessKey: NewCredential("one-time-key"),
+		AkeylessUIDAuthMethodName:     "/csi/uid",
+	}}
+	_, err := cfg.bootstrapUIDToken(context.Background(), NewSession(NewClient(gw.URL)), cfg.uidIdentityKey())
+	require.ErrorContains(t, err, "requires a UID token persist directory")
+	require.Zero(t, calls)
+
+	cfg.AkeylessUIDTokenPersistDir = t.TempDir()
+	cfg.AkeylessUIDAuthMethodName = ""
+	require.ErrorContains(t, cfg.validateUIDBootstrap(), "requires the UID auth method name")
+}
+
+func TestBootstrapUIDToken_TwoIdentities(t *testing.T) {
+	defer func() { uidIdentities = make(map[string]*uidIdentity) }()
+	var calls []string
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/auth":
+			_, _ = w.Write([]byte(`{"token":"t-bootstrap"}`))
+		case "/uid-generate-token":
+			_, _ = w.Write([]byte(`{"token":"u-node"}`))
+		case "/uid-rotate-token":
+			token, _ := body["uid-token"].(string)
+			if token == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"r-` + token + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer gw.Close()
+
+	t.Setenv(AkeylessAccessID, "p-node")
+	t.Setenv(AkeylessUIDBootstrapAccessID, "p-bootstrap")
+	t.Setenv(AkeylessUIDBootstrapAccessKey, "one-time-key")
+	t.Setenv(AkeylessUIDAuthMethodName, "/csi/uid")
+	t.Setenv(AkeylessUIDTokenPersist, t.TempDir())
+	parse := func(params map[string]string, secret map[string]string) (Config, error) {
+		params["akeylessAccessType"] = "universal_identity"
+		params["akeylessGatewayURL"] = gw.URL
+		params["objects"] = objects
+		parameters, err := json.Marshal(params)
+		require.NoError(t, err)
+		secrets, err := json.Marshal(secret)
+		require.NoError(t, err)
+		return Parse(context.Background(), NewClient, string(secrets), string(parameters), "/some/path", "420", "", "")
+	}
+
+	// the node's identity is enrolled with the bootstrap key of the provider's environment
+	node, err := parse(map[string]string{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "r-u-node", node.Session.Token())
+	require.Equal(t, []string{"/auth", "/uid-generate-token", "/uid-rotate-token"}, calls)
+
+	// a SecretProviderClass of its own identity neither bootstraps nor takes the node's token
+	calls = nil
+	team, err := parse(map[string]string{"akeylessAccessID": "p-team", "akeylessUIDInitToken": "u-team"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "r-u-team", team.Session.Token())
+	require.Equal(t, []string{"/uid-rotate-token"}, calls)
+	require.Equal(t, "r-u-node", node.Session.Token())
+
+	// nor with a bootstrap key of its nodePublishSecretRef
+	calls = nil
+	_, err = parse(map[string]string{"akeylessAccessID": "p-other"}, map[string]string{"akeylessUIDBootstrapAccessKey": "other-key"})
+	require.ErrorIs(t, err, ErrAuthentication)
+	require.NotContains(t, calls, "/auth")
+	require.Equal(t, "r-u-node", node.Session.Token())
+}
@@ -0,0 +1,319 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCAConfigMapInterval is how often the gateway CA ConfigMap is checked for changes
+	DefaultCAConfigMapInterval = 30 * time.Second
+
+	serviceAccountCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// gatewayCA is the CA bundle gateway certificates are verified with in addition to the system
+// roots, nil to use the system roots only. See WatchGatewayCAConfigMap.
+var (
+	gatewayCAMu sync.RWMutex
+	gatewayCA   *caBundle
+)
+
+// caBundle holds the current CA certificates of a ConfigMap key.
+type caBundle struct {
+	fetch configMapFetcher
+
+	mu              sync.RWMutex
+	roots           *x509.CertPool
+	resourceVersion string
+}
+
+func (b *caBundle) pool() *x509.CertPool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.roots
+}
+
+// verifyConnection verifies the gateway certificate for host against the bundle as it is at the
+// time of the handshake, so new connections pick up a rotated CA without recreating clients. The
+// host is the one dialed rather than the handshake's ServerName, which is empty for IP addresses.
+func (b *caBundle) verifyConnection(host string, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("gateway presented no certificate")
+	}
+	if host == "" {
+		return errors.New("unknown gateway host to verify the certificate for")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	// an IP address is verified against the IP SANs of the certificate
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         b.pool(),
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// gatewayTLSConfig returns the TLS config of clients of the gateway at host, trusting the CA of
+// the SecretProviderClass spcCA, if not nil, in addition to the node's. It is nil when neither
+// CA bundle is configured.
+func gatewayTLSConfig(host string, spcCA *caBundle) *tls.Config {
+	gatewayCAMu.RLock()
+	b := gatewayCA
+	gatewayCAMu.RUnlock()
+	var bundles []*caBundle
+	for _, bundle := range []*caBundle{b, spcCA} {
+		if bundle != nil {
+			bundles = append(bundles, bundle)
+		}
+	}
+	if len(bundles) == 0 {
+		return nil
+	}
+	return &tls.Config{
+		ServerName: host,
+		// the chain is verified by VerifyConnection against the current bundles instead
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			var err error
+			for _, bundle := range bundles {
+				if err = bundle.verifyConnection(host, cs); err == nil {
+					return nil
+				}
+			}
+			return err
+		},
+	}
+}
+
+// ParseConfigMapRef splits a "namespace/name#key" ConfigMap key reference.
+func ParseConfigMapRef(ref string) (namespace, name, key string, err error) {
+	object, key, ok := strings.Cut(ref, "#")
+	namespace, name, ok2 := strings.Cut(object, "/")
+	if !ok || !ok2 || namespace == "" || name == "" || key == "" || strings.Contains(name, "/") {
+		return "", "", "", fmt.Errorf("invalid ConfigMap reference %q, expected namespace/name#key", ref)
+	}
+	return namespace, name, key, nil
+}
+
+// configMapFetcher returns the data and resource version of a ConfigMap, replaceable in tests.
+type configMapFetcher func(ctx context.Context, namespace, name string) (map[string]string, string, error)
+
+var fetchConfigMap configMapFetcher = inClusterConfigMap
+
+// WatchGatewayCAConfigMap makes gateway clients trust the CA certificates of the ConfigMap key
+// ref, "namespace/name#key", in addition to the system roots. The ConfigMap is read with the
+// provider's service account once now, failing if it can't be, and then every interval until
+// ctx is done, so a rotated CA is picked up by new gateway connections without restarting the
+// provider. Unreadable updates keep the previous bundle.
+func WatchGatewayCAConfigMap(ctx context.Context, ref string, interval time.Duration) error {
+	namespace, name, key, err := ParseConfigMapRef(ref)
+	if err != nil {
+		return err
+	}
+
+	b := &caBundle{fetch: fetchConfigMap}
+	if err = b.reload(ctx, namespace, name, key); err != nil {
+		return fmt.Errorf("failed to load gateway CA from ConfigMap %v: %w", ref, err)
+	}
+	gatewayCAMu.Lock()
+	gatewayCA = b
+	gatewayCAMu.Unlock()
+	resetGatewayTransports()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := b.reload(ctx, namespace, name, key); err != nil {
+					log.Printf("failed to reload gateway CA from ConfigMap %v, keeping the previous one, error: %v", ref, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// spcCABundles are the CA bundles named by the caConfigMap parameter of SecretProviderClasses,
+// by reference. A bundle is read by the first mount naming it and checked for changes by mounts
+// at most every caConfigMapInterval.
+var (
+	spcCAMu             sync.Mutex
+	spcCABundles        = make(map[string]*spcCABundle)
+	caConfigMapInterval = DefaultCAConfigMapInterval
+)
+
+const (
+	// spcCABundleIdleTTL is how long the CA bundle of a ConfigMap no mount named is kept
+	spcCABundleIdleTTL = time.Hour
+	// maxSPCCABundles bounds the CA bundles kept, the ones used longest ago are dropped first
+	maxSPCCABundles = 100
+)
+
+type spcCABundle struct {
+	*caBundle
+	// checked is when the ConfigMap was last read, used when a mount last named it
+	checked, used time.Time
+}
+
+// SetCAConfigMapInterval sets how often the CA ConfigMaps of SecretProviderClasses are checked
+// for changes, the same interval the -gateway-ca-configmap ConfigMap is checked with.
+func SetCAConfigMapInterval(interval time.Duration) {
+	spcCAMu.Lock()
+	defer spcCAMu.Unlock()
+	caConfigMapInterval = interval
+}
+
+// spcGatewayCA returns the CA bundle of the ConfigMap key ref, read on first use and reloaded if
+// it changed since it was last checked. Unreadable updates keep the previous bundle.
+func spcGatewayCA(ctx context.Context, ref string) (*caBundle, error) {
+	namespace, name, key, err := ParseConfigMapRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	spcCAMu.Lock()
+	defer spcCAMu.Unlock()
+
+	now := time.Now()
+	b, ok := spcCABundles[ref]
+	if !ok {
+		evictSPCCABundles(now)
+		loaded := &caBundle{fetch: fetchConfigMap}
+		if err = loaded.reload(ctx, namespace, name, key); err != nil {
+			return nil, fmt.Errorf("failed to load gateway CA from ConfigMap %v: %w", ref, err)
+		}
+		b = &spcCABundle{caBundle: loaded, checked: now}
+		spcCABundles[ref] = b
+	} else if now.Sub(b.checked) > caConfigMapInterval {
+		b.checked = now
+		if err = b.reload(ctx, namespace, name, key); err != nil {
+			log.Printf("failed to reload gateway CA from ConfigMap %v, keeping the previous one, error: %v", ref, err)
+		}
+	}
+	b.used = now
+	return b.caBundle, nil
+}
+
+// evictSPCCABundles drops the bundles no mount named for spcCABundleIdleTTL and the ones used
+// longest ago beyond maxSPCCABundles. spcCAMu must be held.
+func evictSPCCABundles(now time.Time) {
+	for ref, b := range spcCABundles {
+		if now.Sub(b.used) > spcCABundleIdleTTL {
+			delete(spcCABundles, ref)
+		}
+	}
+	for len(spcCABundles) >= maxSPCCABundles {
+		oldest := ""
+		for ref, b := range spcCABundles {
+			if oldest == "" || b.used.Before(spcCABundles[oldest].used) {
+				oldest = ref
+			}
+		}
+		delete(spcCABundles, oldest)
+	}
+}
+
+// reload reads the bundle from the ConfigMap key if the ConfigMap changed since the last read.
+func (b *caBundle) reload(ctx context.Context, namespace, name, key string) error {
+	data, resourceVersion, err := b.fetch(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+	b.mu.RLock()
+	unchanged := resourceVersion != "" && resourceVersion == b.resourceVersion
+	b.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	bundle, ok := data[key]
+	if !ok {
+		return fmt.Errorf("ConfigMap %v/%v has no key %v", namespace, name, key)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM([]byte(bundle)) {
+		return fmt.Errorf("key %v of ConfigMap %v/%v holds no PEM certificates", key, namespace, name)
+	}
+
+	b.mu.Lock()
+	reloaded := b.roots != nil
+	b.roots, b.resourceVersion = roots, resourceVersion
+	b.mu.Unlock()
+	if reloaded {
+		// idle connections were verified against the previous CA
+		resetGatewayTransports()
+		log.Printf("reloaded gateway CA from ConfigMap %v/%v, resourceVersion: %v", namespace, name, resourceVersion)
+	}
+	return nil
+}
+
+// inClusterConfigMap reads a ConfigMap from the Kubernetes API with the provider's service account.
+func inClusterConfigMap(ctx context.Context, namespace, name string) (map[string]string, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, "", errors.New("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST is not set")
+	}
+	// projected service account tokens rotate, they are read for every request
+	token, err := os.ReadFile(DefServiceAccountFile)
+	if err != nil {
+		return nil, "", err
+	}
+	ca, err := os.ReadFile(serviceAccountCAFile)
+	if err != nil {
+		return nil, "", err
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca)
+
+	url := fmt.Sprintf("https://%s/api/v1/namespaces/%s/configmaps/%s", net.JoinHostPort(host, port), namespace, name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("reading ConfigMap %v/%v returned %s", namespace, name, resp.Status)
+	}
+
+	var cm struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Data map[string]string `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&cm); err != nil {
+		return nil, "", err
+	}
+	return cm.Data, cm.Metadata.ResourceVersion, nil
+}
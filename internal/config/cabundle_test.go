@@ -0,0 +1,206 @@
+package config
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConfigMap struct {
+	mu              sync.Mutex
+	data            map[string]string
+	resourceVersion string
+	err             error
+}
+
+func (f *fakeConfigMap) set(data map[string]string, resourceVersion string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data, f.resourceVersion, f.err = data, resourceVersion, err
+}
+
+func (f *fakeConfigMap) fetch(_ context.Context, _, _ string) (map[string]string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.data, f.resourceVersion, f.err
+}
+
+func serverCA(ts *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.TLS.Certificates[0].Certificate[0]}))
+}
+
+func selfSignedCert(t *testing.T, ips ...net.IP) tls.Certificate {
+	if len(ips) == 0 {
+		ips = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "rotated gateway CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           ips,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestParseConfigMapRef(t *testing.T) {
+	namespace, name, key, err := ParseConfigMapRef("csi/gateway-ca#ca.crt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"csi", "gateway-ca", "ca.crt"}, []string{namespace, name, key})
+
+	for _, ref := range []string{"", "gateway-ca#ca.crt", "csi/gateway-ca", "csi/#ca.crt", "/gateway-ca#ca.crt", "csi/gateway-ca#", "csi/a/b#ca.crt"} {
+		_, _, _, err = ParseConfigMapRef(ref)
+		require.Error(t, err, ref)
+	}
+}
+
+func TestWatchGatewayCAConfigMap(t *testing.T) {
+	gateway := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer gateway.Close()
+	// httptest servers share a certificate, the rotated gateway gets one of its own
+	rotated := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rotated.TLS = &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}
+	rotated.StartTLS()
+	defer rotated.Close()
+
+	cm := &fakeConfigMap{}
+	cm.set(map[string]string{"ca.crt": serverCA(gateway)}, "1", nil)
+	fetchConfigMap = cm.fetch
+	defer func() {
+		fetchConfigMap = inClusterConfigMap
+		gatewayCA = nil
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, WatchGatewayCAConfigMap(ctx, "csi/gateway-ca#ca.crt", 10*time.Millisecond))
+
+	get := func(url string) error {
+		resp, err := newClientConfiguration(url).HTTPClient.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	require.NoError(t, get(gateway.URL))
+	require.Error(t, get(rotated.URL))
+
+	// a broken update keeps the previous CA
+	cm.set(map[string]string{"ca.crt": "garbage"}, "2", nil)
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, get(gateway.URL))
+
+	cm.set(map[string]string{"ca.crt": serverCA(rotated)}, "3", nil)
+	require.Eventually(t, func() bool { return get(rotated.URL) == nil }, time.Second, 10*time.Millisecond)
+	require.Error(t, get(gateway.URL))
+}
+
+func TestWatchGatewayCAConfigMap_InitialLoadFails(t *testing.T) {
+	cm := &fakeConfigMap{}
+	fetchConfigMap = cm.fetch
+	defer func() { fetchConfigMap = inClusterConfigMap }()
+
+	cm.set(nil, "", errors.New("forbidden"))
+	require.ErrorContains(t, WatchGatewayCAConfigMap(context.Background(), "csi/gateway-ca#ca.crt", time.Minute), "forbidden")
+
+	cm.set(map[string]string{"other": "x"}, "1", nil)
+	require.ErrorContains(t, WatchGatewayCAConfigMap(context.Background(), "csi/gateway-ca#ca.crt", time.Minute), "has no key ca.crt")
+	require.Nil(t, gatewayTLSConfig("gateway.example.com", nil))
+}
+
+func TestWatchGatewayCAConfigMap_IPAddress(t *testing.T) {
+	// a gateway dialed by IP address whose certificate is issued for another one
+	gateway := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	gateway.TLS = &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t, net.IPv4(10, 0, 0, 5))}}
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	cm := &fakeConfigMap{}
+	cm.set(map[string]string{"ca.crt": serverCA(gateway)}, "1", nil)
+	fetchConfigMap = cm.fetch
+	defer func() {
+		fetchConfigMap = inClusterConfigMap
+		gatewayCA = nil
+		resetGatewayTransports()
+	}()
+	require.NoError(t, WatchGatewayCAConfigMap(context.Background(), "csi/gateway-ca#ca.crt", time.Minute))
+
+	resp, err := newClientConfiguration(gateway.URL).HTTPClient.Get(gateway.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	require.ErrorContains(t, err, "certificate is valid for 10.0.0.5, not 127.0.0.1")
+}
+
+func TestParseCAConfigMap(t *testing.T) {
+	gateway := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"t-1"}`))
+	}))
+	defer gateway.Close()
+
+	cm := &fakeConfigMap{}
+	cm.set(map[string]string{"ca.crt": serverCA(gateway)}, "1", nil)
+	fetchConfigMap = cm.fetch
+	defer func() {
+		fetchConfigMap = inClusterConfigMap
+		spcCABundles = make(map[string]*spcCABundle)
+		resetGatewayTransports()
+	}()
+
+	parse := func(caConfigMap string) (Config, error) {
+		parameters, err := json.Marshal(map[string]string{
+			"akeylessAccessType":               "access_key",
+			"akeylessGatewayURL":               gateway.URL,
+			"objects":                          objects,
+			"caConfigMap":                      caConfigMap,
+			"csi.storage.k8s.io/pod.namespace": "team-a",
+		})
+		require.NoError(t, err)
+		return Parse(context.Background(), NewClient, "", string(parameters), "/some/path", "420", "", "")
+	}
+
+	// only the SecretProviderClass naming the ConfigMap trusts its CA
+	_, err := parse("")
+	require.ErrorIs(t, err, ErrAuthentication)
+	cfg, err := parse("team-a/gateway-ca#ca.crt")
+	require.NoError(t, err)
+	require.Equal(t, "t-1", cfg.Session.Token())
+	require.Equal(t, "team-a/gateway-ca#ca.crt", cfg.CAConfigMap)
+
+	_, err = parse("team-b/gateway-ca#ca.crt")
+	require.ErrorContains(t, err, `the ConfigMap must be in the pod's namespace "team-a"`)
+	_, err = parse("gateway-ca")
+	require.ErrorContains(t, err, "invalid caConfigMap")
+
+	cm.set(nil, "", errors.New("forbidden"))
+	_, err = parse("team-a/other-ca#ca.crt")
+	require.ErrorContains(t, err, "forbidden")
+	// a loaded CA outlives failing reads of its ConfigMap
+	_, err = parse("team-a/gateway-ca#ca.crt")
+	require.NoError(t, err)
+}
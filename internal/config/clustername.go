@@ -0,0 +1,21 @@
+package config
+
+import "fmt"
+
+// ClusterNameHeader is the header of Akeyless requests carrying the name set with SetClusterName.
+const ClusterNameHeader = "akeylessclustername"
+
+// clusterName is sent with every Akeyless request when set, see SetClusterName.
+var clusterName = ""
+
+// SetClusterName stamps every authentication and fetch request of the provider with the name of
+// its cluster, so the Akeyless audit log tells apart clusters using the same access IDs.
+func SetClusterName(name string) error {
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e {
+			return fmt.Errorf("invalid cluster name %q, it must be printable ASCII", name)
+		}
+	}
+	clusterName = name
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akeylesslabs/akeyless-go/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterName(t *testing.T) {
+	var headers []string
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get(ClusterNameHeader))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"t-1"}`))
+	}))
+	defer gw.Close()
+
+	auth := func() {
+		_, _, err := NewClient(gw.URL).Auth(context.Background()).Body(*akeyless.NewAuthWithDefaults()).Execute()
+		require.NoError(t, err)
+	}
+	auth()
+
+	defer func() { require.NoError(t, SetClusterName("")) }()
+	require.NoError(t, SetClusterName("prod-eu-1"))
+	auth()
+	require.Equal(t, []string{"", "prod-eu-1"}, headers)
+
+	require.ErrorContains(t, SetClusterName("prod\neu"), "invalid cluster name")
+}
@@ -3,15 +3,22 @@ package config
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/akeylesslabs/akeyless-go/v4"
 	"log"
-	"net"
+	"mime"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/processor"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -26,10 +33,23 @@ const (
 	AkeylessGCPAudience       = "AKEYLESS_GCP_AUDIENCE"
 	AkeylessUIDInitToken      = "AKEYLESS_UID_INIT_TOKEN"
 	AkeylessK8sAuthConfigName = "AKEYLESS_K8S_AUTH_CONFIG_NAME"
+	AkeylessUIDTokenFile      = "AKEYLESS_UID_TOKEN_FILE"
+	AkeylessOCIAuthType       = "AKEYLESS_OCI_AUTH_TYPE"
+	AkeylessOCIGroupOCIDs     = "AKEYLESS_OCI_GROUP_OCIDS"
+	AkeylessAlibabaRoleName   = "AKEYLESS_ALIBABA_ROLE_NAME"
+	AkeylessUIDTokenPersist   = "AKEYLESS_UID_TOKEN_PERSIST_DIR"
+	AkeylessUIDTokenSeal      = "AKEYLESS_UID_TOKEN_SEAL"
 )
 
 type accessType string
 
+const (
+	// RotationFailurePolicyFail fails the whole rotation remount if any object fails, the default
+	RotationFailurePolicyFail = "fail"
+	// RotationFailurePolicyPartial returns the objects that succeeded during a rotation remount
+	RotationFailurePolicyPartial = "partial"
+)
+
 const (
 	AccessKey         accessType = "access_key"
 	AWSIAM            accessType = "aws_iam"
@@ -37,10 +57,9 @@ const (
 	GCP               accessType = "gcp"
 	UniversalIdentity accessType = "universal_identity"
 	K8S               accessType = "k8s"
-)
-
-var (
-	AklClient *akeyless.V2ApiService
+	OCI               accessType = "oci"
+	AlibabaRAM        accessType = "alibaba_ram"
+	OAuth2            accessType = "oauth2"
 )
 
 // Config represents all of the provider's configurable behaviour from the MountRequest proto message:
@@ -51,6 +70,13 @@ type Config struct {
 	Parameters
 	TargetPath     string
 	FilePermission os.FileMode
+	// Session is the authenticated Akeyless client the mount's secrets are fetched with
+	Session *Session
+	// SummarizeLogs is set during mount storms, when the per-file logs of the mount are skipped
+	SummarizeLogs bool
+	// cloudMetadataTimeout bounds the requests of cloud identities to instance metadata services
+	// while detecting the access type, zero for their defaults
+	cloudMetadataTimeout time.Duration
 }
 
 // Parameters stores the parameters specified in a mount request's `Attributes` field.
@@ -63,18 +89,66 @@ type Config struct {
 //
 // So we just deserialize by hand to avoid complexity and two passes.
 type Parameters struct {
+	// SecretProviderClass is the name of the SecretProviderClass being mounted, used to
+	// correlate logs and errors with the SPC that caused them.
+	SecretProviderClass      string
 	AkeylessGatewayURL       string
 	VaultKubernetesMountPath string
 	Secrets                  []Secret
 	PodInfo                  PodInfo
+	RotationFailurePolicy    string
+	// Features are the experimental features the SecretProviderClass opted into
+	Features []Feature
+	// Templates render additional files from the values of all the objects
+	Templates []Template
+	// Dotenv renders selected objects into one dotenv file, nil when not configured
+	Dotenv *Dotenv
+	// AggregateSecrets additionally mounts all values as one JSON object in AggregateFileName
+	AggregateSecrets bool
+	// CAConfigMap is the namespace/name#key of a ConfigMap in the pod's namespace holding PEM CA
+	// certificates to trust for the gateway, in addition to the system roots and the node's CA
+	CAConfigMap string
 
 	AkeylessAccessType        string
 	AkeylessAccessID          string
-	AkeylessAccessKey         string
+	AkeylessAccessKey         *Credential
 	AkeylessAzureObjectID     string
 	AkeylessGCPAudience       string
-	AkeylessUIDInitToken      string
+	AkeylessUIDInitToken      *Credential
 	AkeylessK8sAuthConfigName string
+	// AkeylessUIDTokenFile points to a UID token maintained by an external rotator, set by the
+	// provider's AKEYLESS_UID_TOKEN_FILE environment variable only.
+	// When set, the provider only reads the token and never rotates it itself.
+	AkeylessUIDTokenFile string
+	// AkeylessOCIAuthType is the OCI principal type, "instance" (default) or "resource"
+	AkeylessOCIAuthType string
+	// AkeylessOCIGroupOCIDs is a comma-separated list of OCI group OCIDs to authenticate with
+	AkeylessOCIGroupOCIDs string
+	// AkeylessAlibabaRoleName is the ECS RAM role to authenticate with, empty for the attached role
+	AkeylessAlibabaRoleName string
+	// AkeylessUIDTokenPersistDir is where the rotated UID tokens are persisted across restarts,
+	// one file per identity, set by the provider's AKEYLESS_UID_TOKEN_PERSIST_DIR environment
+	// variable only
+	AkeylessUIDTokenPersistDir string
+	// AkeylessUIDTokenSeal protects the persisted UID token at rest, "tpm" or empty for none,
+	// set by the provider's AKEYLESS_UID_TOKEN_SEAL environment variable only
+	AkeylessUIDTokenSeal string
+	// AkeylessUIDBootstrapAccessID and AkeylessUIDBootstrapAccessKey are a one-time access key
+	// exchanged for a token of AkeylessUIDAuthMethodName when no UID token was persisted yet, set
+	// by the provider's environment variables only
+	AkeylessUIDBootstrapAccessID  string
+	AkeylessUIDBootstrapAccessKey *Credential
+	AkeylessUIDAuthMethodName     string
+	// AkeylessOAuth2Issuer is the identity provider whose OpenID configuration advertises the token endpoint
+	AkeylessOAuth2Issuer string
+	// AkeylessOAuth2TokenURL is the token endpoint of the client credentials grant, overriding discovery
+	AkeylessOAuth2TokenURL     string
+	AkeylessOAuth2ClientID     string
+	AkeylessOAuth2ClientSecret *Credential
+	// AkeylessOAuth2Scopes is a comma or space separated list of scopes to request
+	AkeylessOAuth2Scopes string
+	// AkeylessOAuth2Audience is requested as the audience of the token, for identity providers requiring one
+	AkeylessOAuth2Audience string
 }
 
 type TLSConfig struct {
@@ -96,11 +170,149 @@ type PodInfo struct {
 type Secret struct {
 	FileName   string                 `yaml:"fileName,omitempty"`
 	SecretPath string                 `yaml:"secretPath,omitempty"`
-	SecretType string                 `yaml:"secretType,omitempty"` // Deprecated, will be ignored
+	SecretType string                 `yaml:"secretType,omitempty"` // Item type, skips describing the item when set
 	SecretArgs map[string]interface{} `yaml:"secretArgs,omitempty"`
+	// PostProcessor names the registered post-processor that turns the value into the mounted files.
+	PostProcessor string `yaml:"postProcessor,omitempty"`
+	// SubPath is the directory of the mount the object's files are placed in, relative to the target path.
+	SubPath string `yaml:"subPath,omitempty"`
+	// ContentType is the media type of the object's files, e.g. application/x-pem-file. It is only
+	// recorded in logs and the inventory, for tooling reasoning about mounted material without reading it.
+	ContentType string `yaml:"contentType,omitempty"`
+	// Tags selects all items having every one of the tags instead of a single item, below the
+	// folder secretPath names if set.
+	Tags []string `yaml:"tags,omitempty"`
+	// Explode writes every top-level key of a JSON value to its own file below fileName,
+	// shorthand for the json-explode postProcessor.
+	Explode bool `yaml:"explode,omitempty"`
+	// Exclude skips the items of folders and tag selections matching any of the patterns, see ExcludePattern.
+	Exclude []string `yaml:"exclude,omitempty"`
+	// TemplateOnly makes the value only available to templates, the dotenv and the aggregate file
+	// instead of mounting it as a file.
+	TemplateOnly bool `yaml:"templateOnly,omitempty"`
+	// DecodeBase64 mounts the base64 decoded value, for binary material stored base64 encoded.
+	// Templates, the dotenv and the aggregate file keep receiving the value as stored.
+	DecodeBase64 bool `yaml:"decodeBase64,omitempty"`
+	// Transform is a jq-style expression reducing a JSON value before it is mounted, e.g.
+	// ".credentials.password", see processor.Transform.
+	Transform string `yaml:"transform,omitempty"`
+	// TrailingNewline, LineEndings and BOM normalize the object's files for applications strict
+	// about their bytes, see processor.Encoding.
+	TrailingNewline string `yaml:"trailingNewline,omitempty"`
+	LineEndings     string `yaml:"lineEndings,omitempty"`
+	BOM             string `yaml:"bom,omitempty"`
+	// Concat concatenates the object with the other objects setting it for the same fileName, in
+	// the order they are listed, e.g. to build a full PEM chain from several items.
+	Concat bool `yaml:"concat,omitempty"`
+	// WriteMode is how the object writes a file shared with the other objects of the same
+	// fileName, WriteModeAppend or WriteModeOverwrite.
+	WriteMode string `yaml:"writeMode,omitempty"`
+	// Metadata additionally mounts the item's type, version, tags and last rotation time as
+	// JSON in <fileName>.meta.json, next to the object's file.
+	Metadata bool `yaml:"metadata,omitempty"`
+}
+
+// Encoding returns the file encoding options of the object.
+func (s Secret) Encoding() processor.Encoding {
+	return processor.Encoding{TrailingNewline: s.TrailingNewline, LineEndings: s.LineEndings, BOM: s.BOM}
+}
+
+// NoCacheArg is the secretArg of objects that must always be fetched fresh, see Secret.NoCache.
+const NoCacheArg = "noCache"
+
+// NoCache reports whether the object sets the noCache secretArg: its value is fetched on every
+// mount and never kept by any of the provider's caches once the mount response is built.
+func (s Secret) NoCache() bool {
+	v, ok := s.SecretArgs[NoCacheArg]
+	if !ok {
+		return false
+	}
+	noCache, _ := strconv.ParseBool(fmt.Sprint(v))
+	return noCache
+}
+
+// RawArg is the secretArg of rotated secrets mounted as stored, see Secret.Raw.
+const RawArg = "raw"
+
+// Raw reports whether the object sets the raw secretArg: its rotated secret value is mounted as the
+// gateway stores it, instead of as indented JSON.
+func (s Secret) Raw() bool {
+	v, ok := s.SecretArgs[RawArg]
+	if !ok {
+		return false
+	}
+	raw, _ := strconv.ParseBool(fmt.Sprint(v))
+	return raw
+}
+
+// AggregateFileName is the file of the mount holding all values when AggregateSecrets is set.
+const AggregateFileName = "all-secrets.json"
+
+// Template is a Go text/template rendered into the file FileName of the mount, with .Secrets
+// holding the values of the objects by fileName and .JSON their parsed form for JSON values.
+type Template struct {
+	FileName string `yaml:"fileName"`
+	Template string `yaml:"template"`
+}
+
+const (
+	// KeyCaseUpper turns the fileName db-password into the key DB_PASSWORD, the default
+	KeyCaseUpper = "upper"
+	// KeyCaseLower turns the fileName db-password into the key db_password
+	KeyCaseLower = "lower"
+	// KeyCasePreserve keeps the case of the fileName, only replacing characters invalid in keys
+	KeyCasePreserve = "preserve"
+)
+
+// Dotenv renders the objects into the file FileName of the mount as KEY=value lines, with
+// the keys derived from the objects' fileNames.
+type Dotenv struct {
+	FileName string `yaml:"fileName"`
+	// KeyCase is KeyCaseUpper, KeyCaseLower or KeyCasePreserve
+	KeyCase string `yaml:"keyCase,omitempty"`
+	// Objects are the fileNames of the objects to include, all objects when empty
+	Objects []string `yaml:"objects,omitempty"`
+}
+
+// excludeRegexPrefix marks exclude patterns that are regular expressions rather than globs.
+const excludeRegexPrefix = "regex:"
+
+// ExcludePattern returns whether an item of a folder is excluded by the pattern, given the
+// item's path relative to the folder. Patterns are globs matched against the relative path and
+// the item's name, e.g. "*-staging", or regular expressions matched against the relative path
+// when prefixed with "regex:", e.g. "regex:^legacy/".
+func ExcludePattern(pattern string) (func(relPath string) bool, error) {
+	if expr, ok := strings.CutPrefix(pattern, excludeRegexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(relPath string) bool {
+		full, _ := path.Match(pattern, relPath)
+		name, _ := path.Match(pattern, path.Base(relPath))
+		return full || name
+	}, nil
 }
 
-func Parse(secretStr, parametersStr, targetPath, permissionStr string, defaultVaultAddr string, defaultVaultKubernetesMountPath string) (Config, error) {
+// MountPath returns the path of the object's file relative to the target path.
+func (s Secret) MountPath(fileName string) string {
+	return path.Join(s.SubPath, fileName)
+}
+
+// Parse parses the mount request, detecting the access type and performing the initial
+// authentication within the deadline of ctx, using a session with a client of newClient.
+func Parse(ctx context.Context, newClient ClientFactory, secretStr, parametersStr, targetPath, permissionStr string, defaultVaultAddr string, defaultVaultKubernetesMountPath string) (Config, error) {
+	return ParseWithSession(ctx, nil, newClient, secretStr, parametersStr, targetPath, permissionStr, defaultVaultAddr, defaultVaultKubernetesMountPath)
+}
+
+// ParseWithSession parses the mount request like Parse, but reuses the authenticated session of a
+// previous mount of the same request instead of authenticating again, if warm isn't nil.
+func ParseWithSession(ctx context.Context, warm *Session, newClient ClientFactory, secretStr, parametersStr, targetPath, permissionStr string, defaultVaultAddr string, defaultVaultKubernetesMountPath string) (Config, error) {
 	config := Config{
 		TargetPath: targetPath,
 	}
@@ -111,17 +323,44 @@ func Parse(secretStr, parametersStr, targetPath, permissionStr string, defaultVa
 		return Config{}, err
 	}
 
-	AklClient = createClient(config.AkeylessGatewayURL)
-	if config.Parameters.AkeylessAccessType == "" {
-		config.Parameters.AkeylessAccessType = string(config.detectAccessType(AklClient))
+	var warmAccessType accessType
+	reuse := false
+	if warm != nil {
+		warmAccessType, reuse = warm.reusable()
+	}
 
+	if reuse {
+		config.Session = warm
+		config.Parameters.AkeylessAccessType = string(warmAccessType)
+		log.Printf("reusing pre-warmed token, secretProviderClass: %v", config.SecretProviderClass)
+	} else {
+		client := newClient(config.AkeylessGatewayURL)
+		if config.CAConfigMap != "" {
+			// the client trusts the CA of the SecretProviderClass, which the factory knows nothing of
+			ca, err := spcGatewayCA(ctx, config.CAConfigMap)
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to load caConfigMap of SecretProviderClass %s: %w", config.SecretProviderClass, err)
+			}
+			client = newCAClient(config.AkeylessGatewayURL, config.CAConfigMap, ca)
+		}
+		config.Session = NewSession(client)
 		if config.Parameters.AkeylessAccessType == "" {
-			return Config{}, fmt.Errorf("failed to detect access type of %s", config.AkeylessAccessID)
+			config.Parameters.AkeylessAccessType = string(config.detectAccessType(ctx, config.Session))
+
+			if config.Parameters.AkeylessAccessType == "" {
+				return Config{}, fmt.Errorf("failed to detect access type of %s for SecretProviderClass %s: %w", config.AkeylessAccessID, config.SecretProviderClass, ErrAuthentication)
+			}
+			log.Printf("successfully connected using %s access type, secretProviderClass: %v", config.AkeylessAccessType, config.SecretProviderClass)
+		} else {
+			// the initial authentication, with the configured access type or the first one of a
+			// chain that succeeds, mounts must not go on without it
+			accType, err := config.authenticateChain(ctx, config.Session, strings.Split(config.AkeylessAccessType, ","))
+			if err != nil {
+				return Config{}, err
+			}
+			config.Parameters.AkeylessAccessType = string(accType)
 		}
-		log.Printf("successfully connected using %s access type", config.AkeylessAccessType)
-	} else {
-		// will perform initial authentiaction
-		config.detectAccessType(AklClient)
+		config.Session.setIdentity(config.identity())
 	}
 
 	err = json.Unmarshal([]byte(permissionStr), &config.FilePermission)
@@ -144,6 +383,12 @@ func parseParameters(secretStr, parametersStr string, defaultAkeylessGatewayURL
 		return Parameters{}, err
 	}
 
+	if VaultCompatParameters {
+		if err = applyVaultCompat(params); err != nil {
+			return Parameters{}, fmt.Errorf("failed to map Vault parameters: %w", err)
+		}
+	}
+
 	var secret map[string]string
 	if secretStr != "" {
 		err = json.Unmarshal([]byte(secretStr), &secret)
@@ -153,22 +398,38 @@ func parseParameters(secretStr, parametersStr string, defaultAkeylessGatewayURL
 	}
 
 	var parameters Parameters
+	parameters.SecretProviderClass = params["secretProviderClass"]
 	parameters.AkeylessGatewayURL = params["akeylessGatewayURL"]
 	parameters.VaultKubernetesMountPath = params["vaultKubernetesMountPath"]
+	parameters.RotationFailurePolicy = params["rotationFailurePolicy"]
+	parameters.Features = parseFeatures(params["features"])
 	parameters.PodInfo.Name = params["csi.storage.k8s.io/pod.name"]
 	parameters.PodInfo.UID = types.UID(params["csi.storage.k8s.io/pod.uid"])
 	parameters.PodInfo.Namespace = params["csi.storage.k8s.io/pod.namespace"]
 	parameters.PodInfo.ServiceAccountName = params["csi.storage.k8s.io/serviceAccount.name"]
 	parameters.AkeylessAccessType = params["akeylessAccessType"]
 	parameters.AkeylessAccessID = params["akeylessAccessID"]
-	parameters.AkeylessAccessKey = params["akeylessAccessKey"]
+	parameters.AkeylessAccessKey = NewCredential(params["akeylessAccessKey"])
 	parameters.AkeylessAzureObjectID = params["akeylessAzureObjectID"]
 	parameters.AkeylessGCPAudience = params["akeylessGCPAudience"]
-	parameters.AkeylessUIDInitToken = params["akeylessUIDInitToken"]
+	parameters.AkeylessUIDInitToken = NewCredential(params["akeylessUIDInitToken"])
 	parameters.AkeylessK8sAuthConfigName = params["akeylessK8sAuthConfigName"]
+	parameters.AkeylessOCIAuthType = params["akeylessOCIAuthType"]
+	parameters.AkeylessOCIGroupOCIDs = params["akeylessOCIGroupOCIDs"]
+	parameters.AkeylessAlibabaRoleName = params["akeylessAlibabaRoleName"]
+	parameters.AkeylessOAuth2Issuer = params["akeylessOAuth2Issuer"]
+	parameters.AkeylessOAuth2TokenURL = params["akeylessOAuth2TokenURL"]
+	parameters.AkeylessOAuth2ClientID = params["akeylessOAuth2ClientID"]
+	parameters.AkeylessOAuth2ClientSecret = NewCredential(params["akeylessOAuth2ClientSecret"])
+	parameters.AkeylessOAuth2Scopes = params["akeylessOAuth2Scopes"]
+	parameters.AkeylessOAuth2Audience = params["akeylessOAuth2Audience"]
 
-	if parameters.AkeylessAccessKey == "" && secret != nil {
-		parameters.AkeylessAccessKey = secret["akeylessAccessKey"]
+	if parameters.AkeylessAccessKey.Empty() && secret != nil {
+		parameters.AkeylessAccessKey = NewCredential(secret["akeylessAccessKey"])
+	}
+
+	if parameters.AkeylessOAuth2ClientSecret.Empty() && secret != nil {
+		parameters.AkeylessOAuth2ClientSecret = NewCredential(secret["akeylessOAuth2ClientSecret"])
 	}
 
 	secretsYaml := params["objects"]
@@ -177,6 +438,49 @@ func parseParameters(secretStr, parametersStr string, defaultAkeylessGatewayURL
 		if err != nil {
 			return Parameters{}, err
 		}
+		for i, s := range parameters.Secrets {
+			if err := applyWriteMode(&parameters.Secrets[i]); err != nil {
+				return Parameters{}, err
+			}
+			if !s.Explode {
+				continue
+			}
+			if s.PostProcessor != "" && s.PostProcessor != processor.JSONExplode {
+				return Parameters{}, fmt.Errorf("object %v sets both explode and postProcessor %v", s.FileName, s.PostProcessor)
+			}
+			parameters.Secrets[i].PostProcessor = processor.JSONExplode
+		}
+	}
+
+	if templatesYaml := params["templates"]; templatesYaml != "" {
+		if err = yaml.Unmarshal([]byte(templatesYaml), &parameters.Templates); err != nil {
+			return Parameters{}, fmt.Errorf("failed to parse templates: %w", err)
+		}
+	}
+
+	if aggregate := params["aggregateSecrets"]; aggregate != "" {
+		if parameters.AggregateSecrets, err = strconv.ParseBool(aggregate); err != nil {
+			return Parameters{}, fmt.Errorf("invalid aggregateSecrets %q, must be true or false", aggregate)
+		}
+	}
+
+	if dotenvYaml := params["dotenv"]; dotenvYaml != "" {
+		parameters.Dotenv = &Dotenv{}
+		if err = yaml.Unmarshal([]byte(dotenvYaml), parameters.Dotenv); err != nil {
+			return Parameters{}, fmt.Errorf("failed to parse dotenv: %w", err)
+		}
+	}
+
+	if caConfigMap := params["caConfigMap"]; caConfigMap != "" {
+		namespace, _, _, err := ParseConfigMapRef(caConfigMap)
+		if err != nil {
+			return Parameters{}, fmt.Errorf("invalid caConfigMap: %w", err)
+		}
+		// a pod may only trust the CAs of its own namespace
+		if namespace != parameters.PodInfo.Namespace {
+			return Parameters{}, fmt.Errorf("invalid caConfigMap %q, the ConfigMap must be in the pod's namespace %q", caConfigMap, parameters.PodInfo.Namespace)
+		}
+		parameters.CAConfigMap = caConfigMap
 	}
 
 	if parameters.AkeylessGatewayURL == "" {
@@ -191,12 +495,12 @@ func parseParameters(secretStr, parametersStr string, defaultAkeylessGatewayURL
 		parameters.AkeylessAccessID = os.Getenv(AkeylessAccessID)
 	}
 
-	if parameters.AkeylessAccessKey == "" {
-		parameters.AkeylessAccessKey = os.Getenv(AkeylessAccessKey)
+	if parameters.AkeylessAccessKey.Empty() {
+		parameters.AkeylessAccessKey = NewCredential(os.Getenv(AkeylessAccessKey))
 	}
 
-	if parameters.AkeylessAccessKey == "" {
-		parameters.AkeylessAccessKey = os.Getenv(Credentials)
+	if parameters.AkeylessAccessKey.Empty() {
+		parameters.AkeylessAccessKey = NewCredential(os.Getenv(Credentials))
 	}
 
 	if parameters.AkeylessAzureObjectID == "" {
@@ -207,18 +511,79 @@ func parseParameters(secretStr, parametersStr string, defaultAkeylessGatewayURL
 		parameters.AkeylessGCPAudience = os.Getenv(AkeylessGCPAudience)
 	}
 
-	if parameters.AkeylessUIDInitToken == "" {
-		parameters.AkeylessUIDInitToken = os.Getenv(AkeylessUIDInitToken)
+	if parameters.AkeylessUIDInitToken.Empty() {
+		parameters.AkeylessUIDInitToken = NewCredential(os.Getenv(AkeylessUIDInitToken))
 	}
 
 	if parameters.AkeylessK8sAuthConfigName == "" {
 		parameters.AkeylessK8sAuthConfigName = os.Getenv(AkeylessK8sAuthConfigName)
 	}
 
+	// the UID token file is only read from the provider's environment, a SecretProviderClass
+	// naming it could read any file of the node
+	parameters.AkeylessUIDTokenFile = os.Getenv(AkeylessUIDTokenFile)
+
+	if parameters.AkeylessOCIAuthType == "" {
+		parameters.AkeylessOCIAuthType = os.Getenv(AkeylessOCIAuthType)
+	}
+
+	if parameters.AkeylessOCIGroupOCIDs == "" {
+		parameters.AkeylessOCIGroupOCIDs = os.Getenv(AkeylessOCIGroupOCIDs)
+	}
+
+	if parameters.AkeylessAlibabaRoleName == "" {
+		parameters.AkeylessAlibabaRoleName = os.Getenv(AkeylessAlibabaRoleName)
+	}
+
+	// like the UID token file, where and how the UID token is persisted is up to the node
+	parameters.AkeylessUIDTokenPersistDir = os.Getenv(AkeylessUIDTokenPersist)
+	parameters.AkeylessUIDTokenSeal = os.Getenv(AkeylessUIDTokenSeal)
+
+	// the bootstrap key enrolls the node's own UID identity, the one of the provider's environment,
+	// so it is only read from there and only used by SecretProviderClasses not naming an identity
+	// of their own, a SecretProviderClass can't enroll an identity other mounts would then share
+	if params["akeylessAccessID"] == "" && params["akeylessUIDInitToken"] == "" {
+		parameters.AkeylessUIDBootstrapAccessID = os.Getenv(AkeylessUIDBootstrapAccessID)
+		parameters.AkeylessUIDBootstrapAccessKey = NewCredential(os.Getenv(AkeylessUIDBootstrapAccessKey))
+		parameters.AkeylessUIDAuthMethodName = os.Getenv(AkeylessUIDAuthMethodName)
+	}
+
+	if parameters.AkeylessOAuth2Issuer == "" {
+		parameters.AkeylessOAuth2Issuer = os.Getenv(AkeylessOAuth2Issuer)
+	}
+
+	if parameters.AkeylessOAuth2TokenURL == "" {
+		parameters.AkeylessOAuth2TokenURL = os.Getenv(AkeylessOAuth2TokenURL)
+	}
+
+	if parameters.AkeylessOAuth2ClientID == "" {
+		parameters.AkeylessOAuth2ClientID = os.Getenv(AkeylessOAuth2ClientID)
+	}
+
+	if parameters.AkeylessOAuth2ClientSecret.Empty() {
+		parameters.AkeylessOAuth2ClientSecret = NewCredential(os.Getenv(AkeylessOAuth2ClientSecret))
+	}
+
+	if parameters.AkeylessOAuth2Scopes == "" {
+		parameters.AkeylessOAuth2Scopes = os.Getenv(AkeylessOAuth2Scopes)
+	}
+
+	if parameters.AkeylessOAuth2Audience == "" {
+		parameters.AkeylessOAuth2Audience = os.Getenv(AkeylessOAuth2Audience)
+	}
+
 	// Set default values.
 	if parameters.AkeylessGatewayURL == "" {
 		parameters.AkeylessGatewayURL = defaultAkeylessGatewayURL
 	}
+	if parameters.AkeylessGatewayURL == "" {
+		// never fall back to the SaaS API implicitly, on-prem setups must not send credentials there
+		return Parameters{}, fmt.Errorf("no gateway configured, set akeylessGatewayURL, %v or -akeyless-address, or start the provider with -saas-fallback to use %v", AkeylessURL, SaaSURL)
+	}
+	gatewayURL := parameters.AkeylessGatewayURL
+	if parameters.AkeylessGatewayURL, err = NormalizeGatewayURL(gatewayURL); err != nil {
+		return Parameters{}, fmt.Errorf("invalid akeylessGatewayURL %q: %w", gatewayURL, err)
+	}
 
 	if parameters.AkeylessAccessType == "" {
 		parameters.AkeylessAccessType = string(AccessKey)
@@ -255,19 +620,273 @@ func (c *Config) UsingK8S() bool {
 	return accessType(c.AkeylessAccessType) == K8S
 }
 
+func (c *Config) UsingOCI() bool {
+	return accessType(c.AkeylessAccessType) == OCI
+}
+
+func (c *Config) UsingAlibaba() bool {
+	return accessType(c.AkeylessAccessType) == AlibabaRAM
+}
+
+func (c *Config) UsingOAuth2() bool {
+	return accessType(c.AkeylessAccessType) == OAuth2
+}
+
+// UsingSaaS reports whether the provider talks to the Akeyless SaaS API directly rather than a gateway.
+func (c *Config) UsingSaaS() bool {
+	u, err := url.Parse(c.AkeylessGatewayURL)
+	if err != nil {
+		return false
+	}
+	return saasHost.MatchString(strings.ToLower(u.Hostname()))
+}
+
+// saasHost matches the public Akeyless API endpoints, e.g. api.akeyless.io or api.eu.akeyless.io
+var saasHost = regexp.MustCompile(`^api(\.[a-z0-9-]+)?\.akeyless\.io$`)
+
+// PartialRotation reports whether rotation remounts may succeed with only part of the objects.
+func (c *Config) PartialRotation() bool {
+	return c.RotationFailurePolicy == RotationFailurePolicyPartial
+}
+
 func (c *Config) validate() error {
 	// Some basic validation checks.
 	if c.TargetPath == "" {
-		return errors.New("missing target path field")
+		return fmt.Errorf("missing target path field, secretProviderClass: %v", c.SecretProviderClass)
 	}
 	if len(c.Parameters.Secrets) == 0 {
-		return errors.New("no secrets configured - the provider will not read any secret material")
+		return fmt.Errorf("no secrets configured for SecretProviderClass %v - the provider will not read any secret material", c.SecretProviderClass)
+	}
+	switch c.RotationFailurePolicy {
+	case "", RotationFailurePolicyFail, RotationFailurePolicyPartial:
+	default:
+		return fmt.Errorf("unsupported rotationFailurePolicy %v, must be %v or %v", c.RotationFailurePolicy, RotationFailurePolicyFail, RotationFailurePolicyPartial)
+	}
+	if c.AkeylessUIDTokenSeal != "" && c.AkeylessUIDTokenSeal != TokenSealTPM {
+		return fmt.Errorf("unsupported UID token seal mode %v", c.AkeylessUIDTokenSeal)
+	}
+	if c.AkeylessUIDTokenSeal != "" && c.AkeylessUIDTokenPersistDir == "" {
+		return fmt.Errorf("UID token seal mode %v requires a UID token persist directory", c.AkeylessUIDTokenSeal)
+	}
+	if err := c.validateUIDBootstrap(); err != nil {
+		return err
+	}
+	if err := c.validateFeatures(); err != nil {
+		return err
+	}
+	if err := c.validateTemplates(); err != nil {
+		return err
 	}
+	if err := c.validateDotenv(); err != nil {
+		return err
+	}
+	if err := c.validateWriteModes(); err != nil {
+		return err
+	}
+	for _, secret := range c.Parameters.Secrets {
+		if secret.FileName != "" && !isRelativeSubPath(secret.FileName) {
+			return fmt.Errorf("invalid fileName %v for %v, secretProviderClass: %v, it must be a relative path within the mount", secret.FileName, secret.SecretPath, c.SecretProviderClass)
+		}
+		if secret.SubPath != "" && !isRelativeSubPath(secret.SubPath) {
+			return fmt.Errorf("invalid subPath %v for %v, secretProviderClass: %v, it must be a relative path within the mount", secret.SubPath, secret.FileName, c.SecretProviderClass)
+		}
+		if len(secret.Tags) > 0 && secret.SecretPath != "" && !strings.HasSuffix(secret.SecretPath, "/") && !strings.HasSuffix(secret.SecretPath, "/*") {
+			return fmt.Errorf("invalid secretPath %v for tags %v, secretProviderClass: %v, tags select items of a folder, the path must end with / or /*", secret.SecretPath, strings.Join(secret.Tags, ","), c.SecretProviderClass)
+		}
+		for _, pattern := range secret.Exclude {
+			if _, err := ExcludePattern(pattern); err != nil {
+				return fmt.Errorf("invalid exclude pattern %v for %v, secretProviderClass: %v: %w", pattern, secret.FileName, c.SecretProviderClass, err)
+			}
+		}
+		if secret.ContentType != "" {
+			if _, _, err := mime.ParseMediaType(secret.ContentType); err != nil {
+				return fmt.Errorf("invalid contentType %v for %v, secretProviderClass: %v: %w", secret.ContentType, secret.FileName, c.SecretProviderClass, err)
+			}
+		}
+		if secret.Concat {
+			if err := c.validateConcat(secret); err != nil {
+				return err
+			}
+		}
+		if secret.WriteMode == WriteModeOverwrite {
+			if err := c.validateOverwrite(secret); err != nil {
+				return err
+			}
+		}
+		if secret.Transform != "" {
+			if _, err := processor.ParseTransform(secret.Transform); err != nil {
+				return fmt.Errorf("object %v, secretProviderClass: %v: %w", secret.FileName, c.SecretProviderClass, err)
+			}
+		}
+		format, hasFormat := secret.SecretArgs[processor.FormatArg]
+		if hasFormat && !processor.ValidFormat(fmt.Sprint(format)) {
+			return fmt.Errorf("unsupported objectFormat %v for %v, secretProviderClass: %v, available: %v",
+				format, secret.FileName, c.SecretProviderClass, strings.Join(processor.Formats(), ", "))
+		}
+		if secret.DecodeBase64 && hasFormat {
+			return fmt.Errorf("object %v sets both decodeBase64 and objectFormat, secretProviderClass: %v, decoded values are binary", secret.FileName, c.SecretProviderClass)
+		}
+		if err := secret.Encoding().Validate(); err != nil {
+			return fmt.Errorf("object %v, secretProviderClass: %v: %w", secret.FileName, c.SecretProviderClass, err)
+		}
+		if secret.DecodeBase64 && !secret.Encoding().IsZero() {
+			return fmt.Errorf("object %v sets decodeBase64 with trailingNewline, lineEndings or bom, secretProviderClass: %v, decoded values are binary", secret.FileName, c.SecretProviderClass)
+		}
+		if v, ok := secret.SecretArgs[NoCacheArg]; ok {
+			if _, err := strconv.ParseBool(fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("invalid noCache secretArg %v for %v, secretProviderClass: %v, must be true or false", v, secret.FileName, c.SecretProviderClass)
+			}
+		}
+		if v, ok := secret.SecretArgs[RawArg]; ok {
+			if _, err := strconv.ParseBool(fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("invalid raw secretArg %v for %v, secretProviderClass: %v, must be true or false", v, secret.FileName, c.SecretProviderClass)
+			}
+		}
+		if secret.SecretArgs[processor.CertificateFormatArg] == processor.CertificateFormatDER {
+			if err := c.validateDER(secret); err != nil {
+				return err
+			}
+		}
+		if v, ok := secret.SecretArgs["encryptionContext"]; ok {
+			if _, isMap := v.(map[string]interface{}); !isMap || secret.SecretArgs["decryptKey"] == nil {
+				return fmt.Errorf("invalid encryptionContext secretArg for %v, secretProviderClass: %v, it must be a map and needs decryptKey", secret.FileName, c.SecretProviderClass)
+			}
+		}
+		if _, ok := secret.SecretArgs["keyPassphraseSecret"]; ok {
+			if err := c.validateKeyPassphrase(secret); err != nil {
+				return err
+			}
+		}
+		if secret.PostProcessor == "" {
+			continue
+		}
+		if _, ok := processor.Get(secret.PostProcessor); !ok {
+			return fmt.Errorf("unknown postProcessor %v for %v, secretProviderClass: %v, available: %v",
+				secret.PostProcessor, secret.FileName, c.SecretProviderClass, strings.Join(processor.Names(), ", "))
+		}
+	}
+
+	return nil
+}
 
+// validateDER checks that an object asking for DER files splits a certificate into them, the
+// files being binary.
+func (c *Config) validateDER(secret Secret) error {
+	switch {
+	case secret.PostProcessor != processor.CertificateFiles:
+		return fmt.Errorf("object %v sets format %v, secretProviderClass: %v, DER files are written by the %v postProcessor", secret.FileName, processor.CertificateFormatDER, c.SecretProviderClass, processor.CertificateFiles)
+	case !secret.Encoding().IsZero():
+		return fmt.Errorf("object %v sets format %v with trailingNewline, lineEndings or bom, secretProviderClass: %v, DER files are binary", secret.FileName, processor.CertificateFormatDER, c.SecretProviderClass)
+	}
+	return nil
+}
+
+// validateKeyPassphrase checks that an object encrypting its private keys mounts them as PEM,
+// keystores protect their keys with their own password.
+func (c *Config) validateKeyPassphrase(secret Secret) error {
+	for _, arg := range []string{"pkcs12PasswordSecret", "jksPasswordSecret"} {
+		if _, ok := secret.SecretArgs[arg]; ok {
+			return fmt.Errorf("object %v sets both keyPassphraseSecret and %v, secretProviderClass: %v", secret.FileName, arg, c.SecretProviderClass)
+		}
+	}
+	if secret.SecretArgs[processor.CertificateFormatArg] == processor.CertificateFormatDER {
+		return fmt.Errorf("object %v sets keyPassphraseSecret with format %v, secretProviderClass: %v, encrypted keys are written as PEM", secret.FileName, processor.CertificateFormatDER, c.SecretProviderClass)
+	}
+	return nil
+}
+
+// validateConcat checks that a concatenated object mounts a single file.
+func (c *Config) validateConcat(secret Secret) error {
+	selection := strings.HasSuffix(secret.SecretPath, "/") || strings.HasSuffix(secret.SecretPath, "/*") || len(secret.Tags) > 0
+	switch {
+	case selection, secret.Explode, secret.PostProcessor != "":
+		return fmt.Errorf("object %v sets concat, secretProviderClass: %v, concatenated objects must mount a single file, not a folder, tag selection, explode or postProcessor", secret.FileName, c.SecretProviderClass)
+	case secret.TemplateOnly, secret.Metadata:
+		return fmt.Errorf("object %v sets concat with templateOnly or metadata, secretProviderClass: %v", secret.FileName, c.SecretProviderClass)
+	}
+	return nil
+}
+
+func (c *Config) validateTemplates() error {
+	for _, t := range c.Templates {
+		if !isRelativeSubPath(t.FileName) {
+			return fmt.Errorf("invalid template fileName %q, secretProviderClass: %v, it must be a relative path within the mount", t.FileName, c.SecretProviderClass)
+		}
+		if _, err := processor.ParseTemplate(t.FileName, t.Template); err != nil {
+			return fmt.Errorf("template %v, secretProviderClass: %v: %w", t.FileName, c.SecretProviderClass, err)
+		}
+	}
+	if len(c.Templates) > 0 || c.Dotenv != nil || c.AggregateSecrets {
+		return nil
+	}
+	for _, secret := range c.Secrets {
+		if secret.TemplateOnly {
+			return fmt.Errorf("object %v is templateOnly but secretProviderClass %v has no templates, dotenv or aggregate file", secret.FileName, c.SecretProviderClass)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateDotenv() error {
+	if c.Dotenv == nil {
+		return nil
+	}
+	if !isRelativeSubPath(c.Dotenv.FileName) {
+		return fmt.Errorf("invalid dotenv fileName %q, secretProviderClass: %v, it must be a relative path within the mount", c.Dotenv.FileName, c.SecretProviderClass)
+	}
+	switch c.Dotenv.KeyCase {
+	case "", KeyCaseUpper, KeyCaseLower, KeyCasePreserve:
+	default:
+		return fmt.Errorf("unsupported dotenv keyCase %v, secretProviderClass: %v, must be %v, %v or %v", c.Dotenv.KeyCase, c.SecretProviderClass, KeyCaseUpper, KeyCaseLower, KeyCasePreserve)
+	}
+	for _, name := range c.Dotenv.Objects {
+		found := false
+		for _, secret := range c.Secrets {
+			mountPath := secret.MountPath(secret.FileName)
+			found = found || DotenvIncludes(name, mountPath) || DotenvIncludes(mountPath, name)
+		}
+		if !found {
+			return fmt.Errorf("dotenv object %v is not an object of secretProviderClass %v", name, c.SecretProviderClass)
+		}
+	}
 	return nil
 }
 
-func createClient(akeylessGatewayURL string) *akeyless.V2ApiService {
+// DotenvIncludes returns whether the dotenv objects entry name selects the file at mountPath,
+// the file itself or, for folders, every file below it.
+func DotenvIncludes(name, mountPath string) bool {
+	return mountPath == name || strings.HasPrefix(mountPath, name+"/")
+}
+
+// WithinMount reports whether the file path p of a mount response stays within the target path.
+func WithinMount(p string) bool {
+	return isRelativeSubPath(p)
+}
+
+func isRelativeSubPath(p string) bool {
+	if path.IsAbs(p) || strings.Contains(p, "\\") {
+		return false
+	}
+	clean := path.Clean(p)
+	return clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// NewClient creates the Akeyless API client of a gateway, it is the ClientFactory used outside of tests.
+func NewClient(akeylessGatewayURL string) *akeyless.V2ApiService {
+	return akeyless.NewAPIClient(newClientConfiguration(akeylessGatewayURL)).V2Api
+}
+
+// newCAClient creates the Akeyless API client of a gateway like NewClient, additionally trusting
+// the CA bundle ca of the caConfigMap parameter of a SecretProviderClass.
+func newCAClient(akeylessGatewayURL, caConfigMap string, ca *caBundle) *akeyless.V2ApiService {
+	return akeyless.NewAPIClient(clientConfiguration(akeylessGatewayURL, gatewayTransport(akeylessGatewayURL, caConfigMap, ca))).V2Api
+}
+
+func newClientConfiguration(akeylessGatewayURL string) *akeyless.Configuration {
+	return clientConfiguration(akeylessGatewayURL, gatewayTransport(akeylessGatewayURL, "", nil))
+}
+
+func clientConfiguration(akeylessGatewayURL string, transport *http.Transport) *akeyless.Configuration {
 	cfg := &akeyless.Configuration{
 		Servers: []akeyless.ServerConfiguration{
 			{
@@ -275,56 +894,181 @@ func createClient(akeylessGatewayURL string) *akeyless.V2ApiService {
 			},
 		},
 		HTTPClient: &http.Client{
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   55 * time.Second,
-					KeepAlive: 55 * time.Second,
-				}).DialContext,
-				TLSHandshakeTimeout:   30 * time.Second,
-				ExpectContinueTimeout: 30 * time.Second,
-				// the total limit is bounded per host (MaxIdleConnsPerHost)
-				// MaxIdleConns: 0,
-				MaxIdleConnsPerHost: 100,
-				MaxConnsPerHost:     200,
-			},
-			Timeout: 55 * time.Second,
+			Transport: newCachingTransport(newDeadlineTransport(transport, currentTimeouts())),
 		},
 	}
-	return akeyless.NewAPIClient(cfg).V2Api
+	if clusterName != "" {
+		cfg.DefaultHeader = map[string]string{ClusterNameHeader: clusterName}
+	}
+	if gatewaySessionAffinity {
+		// every client serves a single mount, so the mount sticks to the backend it authenticated with
+		cfg.HTTPClient.Jar, _ = cookiejar.New(nil)
+	}
+	return cfg
 }
 
-func (c *Config) detectAccessType(aklClient *akeyless.V2ApiService) accessType {
+// detectAccessType tries the access types in order and returns the first one that authenticates,
+// starting with the one cached for the gateway and access ID. The attempts are logged as a single
+// entry and counted per access type.
+func (c *Config) detectAccessType(ctx context.Context, s *Session) (detected accessType) {
 	if c.AkeylessAccessID == "" {
 		return ""
 	}
 
+	var d accessTypeDetection
+	defer func() { d.log(c, detected) }()
+
+	// nodes outside of the cloud have no metadata service worth waiting the default timeout for
+	probing := *c
+	probing.cloudMetadataTimeout = detectionMetadataTimeout
+	probes := probing.accessTypeProbes()
+	key := accessTypeCacheKey(c.AkeylessGatewayURL, c.AkeylessAccessID)
+	if cached, ok := probedAccessTypes.get(key); ok {
+		for _, p := range probes {
+			if p.accType != cached {
+				continue
+			}
+			if err := d.run(ctx, s, p, true); err == nil {
+				return cached
+			}
+			log.Printf("cached access type %v of %v no longer authenticates, probing again", cached, c.AkeylessAccessID)
+			probedAccessTypes.delete(key)
+			break
+		}
+	}
+
 	log.Printf("trying to detect privileged credentials for %v", c.AkeylessAccessID)
 
-	if err := c.authWithAccessKey(context.Background(), aklClient); err == nil {
-		return AccessKey
+	for _, p := range probes {
+		if err := d.run(ctx, s, p, false); err == nil {
+			probedAccessTypes.set(key, p.accType)
+			return p.accType
+		}
 	}
 
-	if err := c.authWithAWS(context.Background(), aklClient); err == nil {
-		return AWSIAM
+	return ""
+}
+
+// AccessTypes returns the names of the supported access types, sorted.
+func AccessTypes() []string {
+	var types []string
+	for _, p := range (&Config{}).accessTypeProbes() {
+		types = append(types, string(p.accType))
 	}
+	sort.Strings(types)
+	return types
+}
 
-	if err := c.authWithAzure(context.Background(), aklClient); err == nil {
-		return AzureAD
+// detectionMetadataTimeout bounds the instance metadata requests of access type detection.
+const detectionMetadataTimeout = 2 * time.Second
+
+// accessTypeProbes lists the authentication methods tried by detectAccessType, in order.
+func (c *Config) accessTypeProbes() []accessTypeProbe {
+	return []accessTypeProbe{
+		{AccessKey, c.authWithAccessKey},
+		{AWSIAM, c.authWithAWS},
+		{AzureAD, c.authWithAzure},
+		{GCP, c.authWithGCP},
+		{K8S, c.authWithK8S},
+		{OCI, c.authWithOCI},
+		{AlibabaRAM, c.authWithAlibaba},
+		{OAuth2, c.authWithOAuth2},
+		{UniversalIdentity, c.probeUID},
 	}
+}
 
-	if err := c.authWithGCP(context.Background(), aklClient); err == nil {
-		return GCP
+// identity describes whom a session of c authenticates as, see Session.Identity. Besides the
+// access type and ID, it holds the parameters the claims of the authentication depend on, which
+// the roles of an access ID may tell apart.
+func (c *Config) identity() string {
+	parts := []string{c.AkeylessGatewayURL, c.AkeylessAccessType, c.AkeylessAccessID}
+	switch accessType(c.AkeylessAccessType) {
+	case AzureAD:
+		parts = append(parts, c.AkeylessAzureObjectID)
+	case GCP:
+		parts = append(parts, c.AkeylessGCPAudience)
+	case K8S:
+		parts = append(parts, c.AkeylessK8sAuthConfigName)
+	case OCI:
+		parts = append(parts, c.AkeylessOCIAuthType, c.AkeylessOCIGroupOCIDs)
+	case AlibabaRAM:
+		parts = append(parts, c.AkeylessAlibabaRoleName)
+	case OAuth2:
+		parts = append(parts, c.AkeylessOAuth2Issuer, c.AkeylessOAuth2TokenURL, c.AkeylessOAuth2ClientID, c.AkeylessOAuth2Scopes, c.AkeylessOAuth2Audience)
+	case UniversalIdentity:
+		parts = append(parts, c.AkeylessUIDTokenFile, c.uidIdentityKey())
 	}
+	return strings.Join(parts, "\x00")
+}
 
-	if err := c.authWithK8S(context.Background(), aklClient); err == nil {
-		return K8S
+// authenticateChain tries the listed access types in order and returns the first one that
+// authenticates, e.g. "k8s,aws_iam" for clusters migrating between authentication methods.
+func (c *Config) authenticateChain(ctx context.Context, s *Session, chain []string) (accessType, error) {
+	probes := make(map[accessType]accessTypeProbe)
+	for _, p := range c.accessTypeProbes() {
+		probes[p.accType] = p
 	}
 
-	setAuthToken(c.AkeylessUIDInitToken)
+	var errs []string
+	for i, name := range chain {
+		accType := accessType(strings.TrimSpace(name))
+		p, ok := probes[accType]
+		if !ok {
+			return "", fmt.Errorf("unsupported access type %v in access type chain %v, secretProviderClass: %v", accType, c.AkeylessAccessType, c.SecretProviderClass)
+		}
 
-	if err := c.rotateUIDToken(context.Background(), aklClient); err == nil {
-		return UniversalIdentity
+		err := p.probe(ctx, s)
+		if err == nil {
+			log.Printf("authenticated using %v access type, choice %d of chain %v, secretProviderClass: %v", accType, i+1, c.AkeylessAccessType, c.SecretProviderClass)
+			return accType, nil
+		}
+		log.Printf("access type %v of chain %v failed, secretProviderClass: %v, error: %v", accType, c.AkeylessAccessType, c.SecretProviderClass, err)
+		errs = append(errs, fmt.Sprintf("%v: %v", accType, err))
 	}
 
-	return ""
+	if len(chain) == 1 {
+		return "", fmt.Errorf("access type %v failed for SecretProviderClass %v, %w: %v", c.AkeylessAccessType, c.SecretProviderClass, ErrAuthentication, strings.Join(errs, "; "))
+	}
+	return "", fmt.Errorf("all access types of chain %v failed for SecretProviderClass %v, %w: %v", c.AkeylessAccessType, c.SecretProviderClass, ErrAuthentication, strings.Join(errs, "; "))
+}
+
+func (c *Config) probeUID(ctx context.Context, s *Session) error {
+	if c.AkeylessUIDTokenFile != "" {
+		// the token is rotated externally, so it must not be rotated here
+		return c.loadUIDTokenFile(s)
+	}
+
+	id := c.uidIdentity()
+	if err := c.loadUIDIdentity(ctx, id, s); err != nil {
+		return err
+	}
+	s.shareUIDIdentity(id)
+
+	return c.rotateUIDToken(ctx, s)
+}
+
+// loadUIDIdentity loads the token of the UID identity id unless another session already did:
+// the persisted token, the init token or a bootstrapped one, in that order.
+func (c *Config) loadUIDIdentity(ctx context.Context, id *uidIdentity, s *Session) error {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+
+	if token, _ := id.current(); token != "" {
+		return nil
+	}
+
+	uidToken := c.AkeylessUIDInitToken.Reveal()
+	persisted, err := c.loadPersistedUIDToken(id.key)
+	if err != nil {
+		log.Printf("failed to load persisted UID token, falling back to init token: %v", err)
+	} else if persisted != "" {
+		uidToken = persisted
+	}
+	if uidToken == "" && err == nil && !c.AkeylessUIDBootstrapAccessKey.Empty() {
+		if uidToken, err = c.bootstrapUIDToken(ctx, s, id.key); err != nil {
+			return err
+		}
+	}
+	id.set(uidToken, false)
+	return nil
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"testing"
 
@@ -78,8 +81,8 @@ func TestParseParameters(t *testing.T) {
 		AkeylessGatewayURL: "https://vault.akeyless.io",
 		AkeylessAccessType: "access_key",
 		Secrets: []Secret{
-			{"bar1", "/foo/bar", "", nil},
-			{"bar2", "/bar2", "", nil},
+			{FileName: "bar1", SecretPath: "/foo/bar"},
+			{FileName: "bar2", SecretPath: "/bar2"},
 		},
 		VaultKubernetesMountPath: defaultVaultKubernetesMountPath,
 		PodInfo: PodInfo{
@@ -92,10 +95,187 @@ func TestParseParameters(t *testing.T) {
 	require.Equal(t, expected, actual)
 }
 
+func TestParseParameters_Explode(t *testing.T) {
+	params, err := parseParameters("", `{"objects":"- secretPath: /db\n  fileName: db\n  explode: true"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Equal(t, []Secret{{FileName: "db", SecretPath: "/db", Explode: true, PostProcessor: "json-explode"}}, params.Secrets)
+
+	_, err = parseParameters("", `{"objects":"- secretPath: /db\n  fileName: db\n  explode: true\n  postProcessor: template"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.ErrorContains(t, err, "sets both explode and postProcessor template")
+}
+
+func TestParseParameters_Templates(t *testing.T) {
+	params, err := parseParameters("", `{"objects":"- secretPath: /db\n  fileName: db\n  templateOnly: true","templates":"- fileName: app.yaml\n  template: 'password: {{ .Secrets.db }}'"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Equal(t, []Template{{FileName: "app.yaml", Template: "password: {{ .Secrets.db }}"}}, params.Templates)
+
+	cfg := Config{Parameters: params, TargetPath: "some/path"}
+	require.NoError(t, cfg.validateTemplates())
+
+	cfg.Templates = []Template{{FileName: "../app.yaml", Template: "x"}}
+	require.ErrorContains(t, cfg.validateTemplates(), "must be a relative path")
+	cfg.Templates = []Template{{FileName: "app.yaml", Template: "{{ .Secrets.db "}}
+	require.ErrorContains(t, cfg.validateTemplates(), "invalid template")
+	cfg.Templates = nil
+	require.ErrorContains(t, cfg.validateTemplates(), "is templateOnly but secretProviderClass")
+}
+
+func TestParseParameters_DecodeBase64(t *testing.T) {
+	params, err := parseParameters("", `{"objects":"- secretPath: /keystore\n  fileName: keystore.p12\n  decodeBase64: true"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.True(t, params.Secrets[0].DecodeBase64)
+
+	cfg := Config{TargetPath: "/target", Parameters: params}
+	require.NoError(t, cfg.validate())
+	cfg.Secrets[0].SecretArgs = map[string]interface{}{"objectFormat": "yaml"}
+	require.ErrorContains(t, cfg.validate(), "sets both decodeBase64 and objectFormat")
+}
+
+func TestParseParameters_Encoding(t *testing.T) {
+	params, err := parseParameters("", `{"objects":"- secretPath: /app/config\n  fileName: app.ini\n  lineEndings: crlf\n  trailingNewline: add\n  bom: add"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Equal(t, "crlf", params.Secrets[0].LineEndings)
+	require.Equal(t, "add", params.Secrets[0].TrailingNewline)
+	require.Equal(t, "add", params.Secrets[0].BOM)
+
+	cfg := Config{TargetPath: "/target", Parameters: params}
+	require.NoError(t, cfg.validate())
+	cfg.Secrets[0].LineEndings = "windows"
+	require.ErrorContains(t, cfg.validate(), "unsupported lineEndings windows")
+	cfg.Secrets[0].LineEndings = ""
+	cfg.Secrets[0].DecodeBase64 = true
+	require.ErrorContains(t, cfg.validate(), "sets decodeBase64 with trailingNewline, lineEndings or bom")
+}
+
+func TestValidateConfig_Transform(t *testing.T) {
+	cfg := Config{TargetPath: "/target", Parameters: Parameters{Secrets: []Secret{{FileName: "password", SecretPath: "/db", Transform: ".credentials.password"}}}}
+	require.NoError(t, cfg.validate())
+
+	cfg.Secrets[0].Transform = "credentials.password"
+	require.ErrorContains(t, cfg.validate(), `invalid transform "credentials.password": unknown stage credentials.password`)
+}
+
+func TestValidateConfig_Concat(t *testing.T) {
+	cfg := Config{TargetPath: "/target", Parameters: Parameters{Secrets: []Secret{
+		{FileName: "chain.pem", SecretPath: "/pki/leaf", Concat: true},
+		{FileName: "chain.pem", SecretPath: "/pki/root", Concat: true},
+	}}}
+	require.NoError(t, cfg.validate())
+
+	for _, secret := range []Secret{
+		{FileName: "chain.pem", SecretPath: "/pki/", Concat: true},
+		{FileName: "chain.pem", SecretPath: "/pki/*", Concat: true},
+		{FileName: "chain.pem", SecretPath: "/pki/bundle", Explode: true, Concat: true},
+		{FileName: "chain.pem", SecretPath: "/pki/bundle", PostProcessor: "json-explode", Concat: true},
+	} {
+		cfg.Secrets[1] = secret
+		require.ErrorContains(t, cfg.validate(), "concatenated objects must mount a single file", secret.SecretPath)
+	}
+	cfg.Secrets[1] = Secret{FileName: "chain.pem", SecretPath: "/pki/root", Concat: true, Metadata: true}
+	require.ErrorContains(t, cfg.validate(), "sets concat with templateOnly or metadata")
+}
+
+func TestParseParameters_WriteMode(t *testing.T) {
+	params, err := parseParameters("", `{"objects":"- secretPath: /pki/leaf\n  fileName: chain.pem\n  writeMode: append\n- secretPath: /pki/root\n  fileName: chain.pem\n  concat: true"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.True(t, params.Secrets[0].Concat)
+	require.True(t, params.Secrets[1].Concat)
+
+	_, err = parseParameters("", `{"objects":"- secretPath: /pki/leaf\n  fileName: chain.pem\n  writeMode: prepend"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.ErrorContains(t, err, "unsupported writeMode prepend")
+	_, err = parseParameters("", `{"objects":"- secretPath: /pki/leaf\n  fileName: chain.pem\n  writeMode: overwrite\n  concat: true"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.ErrorContains(t, err, "sets both concat and writeMode overwrite")
+}
+
+func TestValidateConfig_WriteMode(t *testing.T) {
+	cfg := Config{TargetPath: "/target", Parameters: Parameters{Secrets: []Secret{
+		{FileName: "ca.pem", SecretPath: "/ca/default", WriteMode: WriteModeOverwrite},
+		{FileName: "ca.pem", SecretPath: "/ca/team", WriteMode: WriteModeOverwrite},
+		{FileName: "db", SecretPath: "/db"},
+	}}}
+	require.NoError(t, cfg.validate())
+
+	cfg.Secrets[1] = Secret{FileName: "ca.pem", SecretPath: "/ca/team", WriteMode: WriteModeAppend, Concat: true}
+	require.ErrorContains(t, cfg.validate(), "objects writing ca.pem set conflicting write modes overwrite and append")
+	cfg.Secrets[1] = Secret{FileName: "ca.pem", SecretPath: "/ca/team"}
+	require.ErrorContains(t, cfg.validate(), "conflicting write modes overwrite and unset")
+	cfg.Secrets[1] = Secret{FileName: "ca.pem", SecretPath: "/ca/", WriteMode: WriteModeOverwrite}
+	require.ErrorContains(t, cfg.validate(), "only single files can be overwritten")
+}
+
+func TestValidateConfig_NoCache(t *testing.T) {
+	cfg := Config{TargetPath: "/target", Parameters: Parameters{Secrets: []Secret{
+		{FileName: "root", SecretPath: "/prod/root", SecretArgs: map[string]interface{}{"noCache": true}},
+	}}}
+	require.NoError(t, cfg.validate())
+	require.True(t, cfg.Secrets[0].NoCache())
+
+	cfg.Secrets[0].SecretArgs["noCache"] = "always"
+	require.ErrorContains(t, cfg.validate(), "invalid noCache secretArg always")
+}
+
+func TestValidateConfig_KeyPassphrase(t *testing.T) {
+	cfg := Config{TargetPath: "/target", Parameters: Parameters{Secrets: []Secret{
+		{FileName: "web", SecretPath: "/prod/web-cert", SecretArgs: map[string]interface{}{"keyPassphraseSecret": "/prod/web-key-passphrase"}},
+	}}}
+	require.NoError(t, cfg.validate())
+
+	cfg.Secrets[0].SecretArgs["pkcs12PasswordSecret"] = "/prod/web-keystore-password"
+	require.ErrorContains(t, cfg.validate(), "sets both keyPassphraseSecret and pkcs12PasswordSecret")
+	delete(cfg.Secrets[0].SecretArgs, "pkcs12PasswordSecret")
+	cfg.Secrets[0].SecretArgs["format"] = "der"
+	cfg.Secrets[0].PostProcessor = "certificate-files"
+	require.ErrorContains(t, cfg.validate(), "encrypted keys are written as PEM")
+}
+
+func TestValidateConfig_DER(t *testing.T) {
+	der := map[string]interface{}{"format": "der"}
+	cfg := Config{TargetPath: "/target", Parameters: Parameters{Secrets: []Secret{
+		{FileName: "web", SecretPath: "/prod/web-cert", PostProcessor: "certificate-files", SecretArgs: der},
+	}}}
+	require.NoError(t, cfg.validate())
+
+	cfg.Secrets[0].PostProcessor = ""
+	require.ErrorContains(t, cfg.validate(), "DER files are written by the certificate-files postProcessor")
+	cfg.Secrets[0] = Secret{FileName: "web", SecretPath: "/prod/web-cert", PostProcessor: "certificate-files", SecretArgs: der, TrailingNewline: "add"}
+	require.ErrorContains(t, cfg.validate(), "DER files are binary")
+}
+
+func TestParseParameters_AggregateSecrets(t *testing.T) {
+	params, err := parseParameters("", `{"aggregateSecrets":"true"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.True(t, params.AggregateSecrets)
+
+	_, err = parseParameters("", `{"aggregateSecrets":"yes please"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.ErrorContains(t, err, "invalid aggregateSecrets")
+}
+
+func TestParseParameters_Dotenv(t *testing.T) {
+	params, err := parseParameters("", `{"objects":"- secretPath: /db/\n  fileName: db","dotenv":"fileName: .env\nkeyCase: lower\nobjects: [db/password]"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Equal(t, &Dotenv{FileName: ".env", KeyCase: KeyCaseLower, Objects: []string{"db/password"}}, params.Dotenv)
+
+	cfg := Config{Parameters: params, TargetPath: "some/path"}
+	require.NoError(t, cfg.validateDotenv())
+
+	cfg.Dotenv = &Dotenv{FileName: ".env", KeyCase: "camel"}
+	require.ErrorContains(t, cfg.validateDotenv(), "unsupported dotenv keyCase camel")
+	cfg.Dotenv = &Dotenv{FileName: ".env", Objects: []string{"api"}}
+	require.ErrorContains(t, cfg.validateDotenv(), "dotenv object api is not an object")
+	cfg.Dotenv = &Dotenv{FileName: "/.env"}
+	require.ErrorContains(t, cfg.validateDotenv(), "must be a relative path")
+}
+
 func TestParseConfig(t *testing.T) {
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"t-1"}`))
+	}))
+	defer gw.Close()
+
 	const targetPath = "/some/path"
 	defaultParams := Parameters{
-		AkeylessGatewayURL:       defaultAkeylessGatewayURL,
+		AkeylessGatewayURL:       gw.URL,
 		VaultKubernetesMountPath: defaultVaultKubernetesMountPath,
 		AkeylessAccessType:       "access_key",
 	}
@@ -118,7 +298,7 @@ func TestParseConfig(t *testing.T) {
 				Parameters: func() Parameters {
 					expected := defaultParams
 					expected.Secrets = []Secret{
-						{"bar1", "/foo/bar", "", nil},
+						{FileName: "bar1", SecretPath: "/foo/bar"},
 					}
 					return expected
 				}(),
@@ -128,8 +308,9 @@ func TestParseConfig(t *testing.T) {
 			name:       "non-defaults can be set",
 			targetPath: targetPath,
 			parameters: map[string]string{
-				"akeylessAccessType":           "aws",
-				"akeylessGatewayURL":           "my-vault-address",
+				"secretProviderClass":          "my-spc",
+				"akeylessAccessType":           "access_key,aws_iam",
+				"akeylessGatewayURL":           gw.URL + "/api/v2/",
 				"vaultKubernetesMountPath":     "my-mount-path",
 				"KubernetesServiceAccountPath": "my-account-path",
 				"objects":                      objects,
@@ -139,11 +320,11 @@ func TestParseConfig(t *testing.T) {
 				FilePermission: 420,
 				Parameters: func() Parameters {
 					expected := defaultParams
-					expected.AkeylessAccessType = "aws"
-					expected.AkeylessGatewayURL = "my-vault-address"
+					expected.SecretProviderClass = "my-spc"
+					expected.AkeylessGatewayURL = gw.URL + "/api/v2"
 					expected.VaultKubernetesMountPath = "my-mount-path"
 					expected.Secrets = []Secret{
-						{"bar1", "/foo/bar", "", nil},
+						{FileName: "bar1", SecretPath: "/foo/bar"},
 					}
 					return expected
 				}(),
@@ -152,12 +333,36 @@ func TestParseConfig(t *testing.T) {
 	} {
 		parametersStr, err := json.Marshal(tc.parameters)
 		require.NoError(t, err)
-		cfg, err := Parse("", string(parametersStr), tc.targetPath, "420", defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+		cfg, err := Parse(context.Background(), NewClient, "", string(parametersStr), tc.targetPath, "420", gw.URL, defaultVaultKubernetesMountPath)
 		require.NoError(t, err, tc.name)
+		require.NotNil(t, cfg.Session, tc.name)
+		require.Equal(t, "t-1", cfg.Session.Token(), tc.name)
+		cfg.Session = nil
 		require.Equal(t, tc.expected, cfg)
 	}
 }
 
+func TestParseConfig_AuthenticationFails(t *testing.T) {
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"access denied"}`))
+	}))
+	defer gw.Close()
+
+	// a mount naming an access ID without its credential must not get a session
+	parametersStr, err := json.Marshal(map[string]string{
+		"akeylessAccessType": "access_key",
+		"akeylessAccessID":   "p-victim",
+		"akeylessAccessKey":  "junk",
+		"objects":            objects,
+	})
+	require.NoError(t, err)
+	_, err = Parse(context.Background(), NewClient, "", string(parametersStr), "/some/path", "420", gw.URL, defaultVaultKubernetesMountPath)
+	require.ErrorIs(t, err, ErrAuthentication)
+	require.ErrorContains(t, err, "access type access_key failed")
+}
+
 func TestParseConfig_Errors(t *testing.T) {
 	for _, tc := range []struct {
 		name       string
@@ -224,6 +429,81 @@ func TestValidateConfig(t *testing.T) {
 				return cfg
 			}(),
 		},
+		{
+			name:     "Relative subPath",
+			cfgValid: true,
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{SubPath: "team-a/db"}}
+				return cfg
+			}(),
+		},
+		{
+			name: "Absolute subPath",
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{SubPath: "/etc"}}
+				return cfg
+			}(),
+		},
+		{
+			name: "subPath escaping the mount",
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{SubPath: "team-a/../../etc"}}
+				return cfg
+			}(),
+		},
+		{
+			name:     "contentType",
+			cfgValid: true,
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{ContentType: "application/x-pem-file"}}
+				return cfg
+			}(),
+		},
+		{
+			name: "Invalid exclude regex",
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{SecretPath: "/team-a/", Exclude: []string{"regex:("}}}
+				return cfg
+			}(),
+		},
+		{
+			name: "Invalid contentType",
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{ContentType: "pem file"}}
+				return cfg
+			}(),
+		},
+		{
+			name:     "encryptionContext with decryptKey",
+			cfgValid: true,
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{SecretArgs: map[string]interface{}{"decryptKey": "/keys/aes", "encryptionContext": map[string]interface{}{"app": "orders"}}}}
+				return cfg
+			}(),
+		},
+		{
+			name: "encryptionContext without decryptKey",
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{SecretArgs: map[string]interface{}{"encryptionContext": map[string]interface{}{"app": "orders"}}}}
+				return cfg
+			}(),
+		},
+		{
+			name: "decodeBase64 with objectFormat",
+			cfg: func() Config {
+				cfg := minimumValid
+				cfg.Secrets = []Secret{{DecodeBase64: true, SecretArgs: map[string]interface{}{"objectFormat": "yaml"}}}
+				return cfg
+			}(),
+		},
 	} {
 		err := tc.cfg.validate()
 		if tc.cfgValid {
@@ -233,3 +513,108 @@ func TestValidateConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateConfig_FileName(t *testing.T) {
+	cfg := Config{TargetPath: "/target", Parameters: Parameters{Secrets: []Secret{{FileName: "certs/tls.crt", SecretPath: "/tls"}}}}
+	require.NoError(t, cfg.validate())
+
+	for _, fileName := range []string{"../tls.crt", "certs/../../tls.crt", "/etc/tls.crt", "..", ".", `certs\tls.crt`} {
+		cfg.Secrets[0].FileName = fileName
+		require.ErrorContains(t, cfg.validate(), "invalid fileName", fileName)
+	}
+}
+
+func TestNormalizeGatewayURL(t *testing.T) {
+	for raw, normalized := range map[string]string{
+		"https://api.akeyless.io":                 "https://api.akeyless.io",
+		" https://gw.example.com:8000/api/v2/ \n": "https://gw.example.com:8000/api/v2",
+		"http://akeyless-gw.akeyless:8080//":      "http://akeyless-gw.akeyless:8080",
+		"https://gw.example.com/api/../api/./v2":  "https://gw.example.com/api/v2",
+		"https://gw.example.com/../api":           "https://gw.example.com/api",
+		"HTTPS://gw.example.com":                  "https://gw.example.com",
+		"http://[fd00::1]:8080/api/v2":            "http://[fd00::1]:8080/api/v2",
+	} {
+		out, err := NormalizeGatewayURL(raw)
+		require.NoError(t, err, raw)
+		require.Equal(t, normalized, out, raw)
+	}
+
+	for raw, msg := range map[string]string{
+		"":                               "empty URL",
+		"gw.example.com:8000":            "missing scheme",
+		"https://gw.example.com/api v2":  "must not contain spaces",
+		"ftp://gw.example.com":           "unsupported scheme ftp",
+		"https:///api/v2":                "missing host",
+		"https://gw.example.com:80000":   "invalid port 80000",
+		"https://gw.example.com:":        "empty port",
+		"https://user:pw@gw.example.com": "must not contain credentials",
+		"https://gw.example.com/api?x=1": "must not have a query or fragment",
+	} {
+		_, err := NormalizeGatewayURL(raw)
+		require.ErrorContains(t, err, msg, raw)
+	}
+
+	_, err := parseParameters("", `{"akeylessGatewayURL":"gw.example.com:8000"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.ErrorContains(t, err, `invalid akeylessGatewayURL "gw.example.com:8000": missing scheme`)
+}
+
+func TestParseParameters_NoGateway(t *testing.T) {
+	t.Setenv(AkeylessURL, "")
+	_, err := parseParameters("", `{"objects":"- secretPath: /db\n  fileName: db"}`, "", defaultVaultKubernetesMountPath)
+	require.ErrorContains(t, err, "no gateway configured")
+
+	t.Setenv(AkeylessURL, "https://gw.example.com:8000/api/v2/")
+	params, err := parseParameters("", `{"objects":"- secretPath: /db\n  fileName: db"}`, "", defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Equal(t, "https://gw.example.com:8000/api/v2", params.AkeylessGatewayURL)
+}
+
+func TestParseParameters_UIDTokenFile(t *testing.T) {
+	t.Setenv(AkeylessUIDTokenFile, "")
+	params, err := parseParameters("", `{"akeylessUIDTokenFile":"/etc/shadow","objects":"- secretPath: /db\n  fileName: db"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Empty(t, params.AkeylessUIDTokenFile)
+
+	t.Setenv(AkeylessUIDTokenFile, "/var/run/akeyless/uid-token")
+	params, err = parseParameters("", `{"objects":"- secretPath: /db\n  fileName: db"}`, defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Equal(t, "/var/run/akeyless/uid-token", params.AkeylessUIDTokenFile)
+}
+
+func TestUsingSaaS(t *testing.T) {
+	for url, saas := range map[string]bool{
+		"https://api.akeyless.io":                 true,
+		"https://API.akeyless.io/":                true,
+		"https://api.eu.akeyless.io":              true,
+		"https://gateway.example.com:8000/api/v2": false,
+		"http://akeyless-gw.akeyless:8080":        false,
+		"https://api.akeyless.io.evil.com":        false,
+	} {
+		cfg := Config{Parameters: Parameters{AkeylessGatewayURL: url}}
+		require.Equal(t, saas, cfg.UsingSaaS(), url)
+	}
+}
+
+func TestExcludePattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		relPath string
+		match   bool
+	}{
+		{"*-staging", "db-staging", true},
+		{"*-staging", "nested/db-staging", true},
+		{"*-staging", "db", false},
+		{"nested/*", "nested/db", true},
+		{"regex:^legacy/", "legacy/token", true},
+		{"regex:^legacy/", "nested/legacy/token", false},
+	} {
+		exclude, err := ExcludePattern(tc.pattern)
+		require.NoError(t, err)
+		require.Equal(t, tc.match, exclude(tc.relPath), "%v %v", tc.pattern, tc.relPath)
+	}
+
+	_, err := ExcludePattern("[")
+	require.Error(t, err)
+	_, err = ExcludePattern("regex:(")
+	require.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// Credential holds raw credential material, such as an access key or a UID init token.
+// It never reveals its value through fmt, JSON or YAML, so a Config can be logged or dumped
+// safely, and it is wiped once the authentication routine took its own copy.
+// A nil Credential is empty.
+type Credential struct {
+	mu    sync.RWMutex
+	value []byte
+}
+
+// NewCredential wraps value, returning nil for an empty value.
+func NewCredential(value string) *Credential {
+	if value == "" {
+		return nil
+	}
+	return &Credential{value: []byte(value)}
+}
+
+// Reveal returns the raw value, meant to be used only when building an authentication request.
+func (c *Credential) Reveal() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return string(c.value)
+}
+
+func (c *Credential) Empty() bool {
+	return c.Reveal() == ""
+}
+
+// Clone returns an independent copy, which isn't affected by wiping c.
+func (c *Credential) Clone() *Credential {
+	return NewCredential(c.Reveal())
+}
+
+// Wipe zeroes the value in place, for every copy of the Config sharing c.
+func (c *Credential) Wipe() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.value {
+		c.value[i] = 0
+	}
+	c.value = nil
+}
+
+func (c *Credential) String() string {
+	if c.Empty() {
+		return ""
+	}
+	return redacted
+}
+
+func (c *Credential) GoString() string {
+	return fmt.Sprintf("config.Credential(%q)", c.String())
+}
+
+func (c *Credential) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Credential) MarshalYAML() (interface{}, error) {
+	return c.String(), nil
+}
+
+// withOwnCredentials returns a copy of c holding its own copies of the credentials.
+func (c *Config) withOwnCredentials() *Config {
+	own := *c
+	own.AkeylessAccessKey = c.AkeylessAccessKey.Clone()
+	own.AkeylessUIDInitToken = c.AkeylessUIDInitToken.Clone()
+	own.AkeylessOAuth2ClientSecret = c.AkeylessOAuth2ClientSecret.Clone()
+	own.AkeylessUIDBootstrapAccessKey = c.AkeylessUIDBootstrapAccessKey.Clone()
+	return &own
+}
+
+// WipeCredentials wipes the raw credentials of c, which are no longer needed once the
+// authentication routine is running with its own copy.
+func (c *Config) WipeCredentials() {
+	c.AkeylessAccessKey.Wipe()
+	c.AkeylessUIDInitToken.Wipe()
+	c.AkeylessOAuth2ClientSecret.Wipe()
+	c.AkeylessUIDBootstrapAccessKey.Wipe()
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCredential_Redacted(t *testing.T) {
+	cfg := Config{Parameters: Parameters{
+		AkeylessAccessID:     "p-1234",
+		AkeylessAccessKey:    NewCredential("very-secret-key"),
+		AkeylessUIDInitToken: NewCredential("u-init-token"),
+	}}
+
+	for _, out := range []string{
+		fmt.Sprintf("%v", cfg),
+		fmt.Sprintf("%+v", cfg),
+		fmt.Sprintf("%#v", cfg),
+		fmt.Sprint(cfg.AkeylessAccessKey),
+		func() string { b, _ := json.Marshal(cfg); return string(b) }(),
+		func() string { b, _ := yaml.Marshal(cfg); return string(b) }(),
+	} {
+		require.NotContains(t, out, "very-secret-key")
+		require.NotContains(t, out, "u-init-token")
+	}
+	require.Equal(t, "very-secret-key", cfg.AkeylessAccessKey.Reveal())
+}
+
+func TestCredential_Wipe(t *testing.T) {
+	cfg := Config{Parameters: Parameters{AkeylessAccessKey: NewCredential("key")}}
+	own := cfg.withOwnCredentials()
+	copied := cfg
+
+	cfg.WipeCredentials()
+	require.True(t, cfg.AkeylessAccessKey.Empty())
+	require.True(t, copied.AkeylessAccessKey.Empty(), "copies of the config share the credential")
+	require.Equal(t, "key", own.AkeylessAccessKey.Reveal())
+
+	var empty *Credential
+	require.True(t, empty.Empty())
+	require.Equal(t, "", empty.String())
+	empty.Wipe()
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultRequestTimeout bounds a single gateway call of any kind unless configured otherwise.
+const DefaultRequestTimeout = 55 * time.Second
+
+// requestTimeouts bound single gateway calls by their kind when the caller's context has no
+// earlier deadline. Authentication may wait on cloud metadata services behind the gateway and
+// large folders take long to list, while fetching a value usually is quick.
+type requestTimeouts struct {
+	auth  time.Duration
+	fetch time.Duration
+	list  time.Duration
+}
+
+func uniformTimeouts(d time.Duration) requestTimeouts {
+	return requestTimeouts{auth: d, fetch: d, list: d}
+}
+
+var (
+	timeoutsMu sync.RWMutex
+	timeouts   = uniformTimeouts(DefaultRequestTimeout)
+)
+
+// SetRequestTimeouts sets the timeouts of single authentication, value fetching and folder
+// listing calls, for the clients created from now on.
+func SetRequestTimeouts(auth, fetch, list time.Duration) {
+	timeoutsMu.Lock()
+	defer timeoutsMu.Unlock()
+	timeouts = requestTimeouts{auth: auth, fetch: fetch, list: list}
+}
+
+func currentTimeouts() requestTimeouts {
+	timeoutsMu.RLock()
+	defer timeoutsMu.RUnlock()
+	return timeouts
+}
+
+// forOperation returns the timeout of a gateway operation, see operation.
+func (t requestTimeouts) forOperation(op string) time.Duration {
+	switch op {
+	case "/auth", "/uid-rotate-token":
+		return t.auth
+	case "/list-items":
+		return t.list
+	}
+	return t.fetch
+}
+
+// deadlineTransport bounds every request by the remaining deadline of its context, capped at the
+// timeout of its operation, so a mount whose budget is nearly exhausted fails fast instead of
+// waiting on a hung request after the kubelet has already given up on it.
+type deadlineTransport struct {
+	next     http.RoundTripper
+	timeouts requestTimeouts
+}
+
+func newDeadlineTransport(next http.RoundTripper, timeouts requestTimeouts) *deadlineTransport {
+	return &deadlineTransport{next: next, timeouts: timeouts}
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if deadline, ok := req.Context().Deadline(); ok && !time.Now().Before(deadline) {
+		return nil, fmt.Errorf("no time left in the request budget for %v: %w", operation(req), context.DeadlineExceeded)
+	}
+
+	// the shorter of the context deadline and the operation's timeout wins
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeouts.forOperation(operation(req)))
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the per-request context once the response body is consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadlineTransport(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := &http.Client{Transport: newDeadlineTransport(http.DefaultTransport, uniformTimeouts(100*time.Millisecond))}
+
+	// bounded by max without a context deadline
+	start := time.Now()
+	_, err := client.Get(srv.URL + "/hang")
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// bounded by the context deadline when it is shorter than max
+	client.Transport = newDeadlineTransport(http.DefaultTransport, uniformTimeouts(time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/hang", nil)
+	start = time.Now()
+	_, err = client.Do(req)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// exhausted budget fails without calling the gateway
+	_, err = client.Do(req)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the body stays readable until closed
+	resp, err := client.Get(srv.URL + "/ok")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "ok", string(body))
+}
+
+func TestDeadlineTransport_PerOperation(t *testing.T) {
+	timeouts := requestTimeouts{auth: time.Second, fetch: 2 * time.Second, list: 3 * time.Second}
+	require.Equal(t, time.Second, timeouts.forOperation("/auth"))
+	require.Equal(t, time.Second, timeouts.forOperation("/uid-rotate-token"))
+	require.Equal(t, 3*time.Second, timeouts.forOperation("/list-items"))
+	require.Equal(t, 2*time.Second, timeouts.forOperation("/get-secret-value"))
+	require.Equal(t, 2*time.Second, timeouts.forOperation("/describe-item"))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	// a slow authentication fails while an equally slow fetch succeeds
+	client := &http.Client{Transport: newDeadlineTransport(http.DefaultTransport, requestTimeouts{auth: 50 * time.Millisecond, fetch: time.Hour, list: time.Hour})}
+	_, err := client.Post(srv.URL+"/api/v2/auth", "application/json", nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	resp, err := client.Post(srv.URL+"/api/v2/get-secret-value", "application/json", nil)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	SetRequestTimeouts(time.Second, 2*time.Second, 3*time.Second)
+	defer SetRequestTimeouts(DefaultRequestTimeout, DefaultRequestTimeout, DefaultRequestTimeout)
+	require.Equal(t, timeouts, currentTimeouts())
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+)
+
+// probeAttempt is an authentication attempt of access type detection.
+type probeAttempt struct {
+	AccessType accessType `json:"accessType"`
+	Cached     bool       `json:"cached,omitempty"`
+	DurationMs int64      `json:"durationMs"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// accessTypeDetection records the attempts of a detection, so a failed detection tells which
+// methods were tried, how long each took and why each failed.
+type accessTypeDetection struct {
+	attempts []probeAttempt
+}
+
+// run authenticates with the access type of p, recording the attempt in the log entry and metrics.
+func (d *accessTypeDetection) run(ctx context.Context, s *Session, p accessTypeProbe, cached bool) error {
+	start := time.Now()
+	err := p.probe(ctx, s)
+	elapsed := time.Since(start)
+
+	attempt := probeAttempt{AccessType: p.accType, Cached: cached, DurationMs: elapsed.Milliseconds()}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	d.attempts = append(d.attempts, attempt)
+	metrics.AccessTypeProbes.Inc(string(p.accType), metrics.Result(err))
+	metrics.AccessTypeProbeDuration.Observe(elapsed.Seconds(), string(p.accType))
+	return err
+}
+
+// log writes the attempts of the detection as a single JSON log entry.
+func (d *accessTypeDetection) log(c *Config, detected accessType) {
+	attempts, err := json.Marshal(d.attempts)
+	if err != nil {
+		return
+	}
+	if detected == "" {
+		log.Printf("failed to detect the access type of %v, secretProviderClass: %v, attempts: %s", c.AkeylessAccessID, c.SecretProviderClass, attempts)
+		return
+	}
+	log.Printf("detected access type %v of %v, secretProviderClass: %v, attempts: %s", detected, c.AkeylessAccessID, c.SecretProviderClass, attempts)
+}
@@ -0,0 +1,51 @@
+package config
+
+import "os"
+
+// environmentVariables are the provider's AKEYLESS_* environment variables, mapped to whether
+// they hold a secret.
+var environmentVariables = map[string]bool{
+	AkeylessURL:               false,
+	AkeylessAccessType:        false,
+	AkeylessAccessID:          false,
+	AkeylessAccessKey:         true,
+	Credentials:               true,
+	AkeylessAzureObjectID:     false,
+	AkeylessGCPAudience:       false,
+	AkeylessUIDInitToken:      true,
+	AkeylessK8sAuthConfigName: false,
+	AkeylessUIDTokenFile:      false,
+	AkeylessOCIAuthType:       false,
+	AkeylessOCIGroupOCIDs:     false,
+	AkeylessAlibabaRoleName:   false,
+	AkeylessUIDTokenPersist:   false,
+	AkeylessUIDTokenSeal:      false,
+
+	AkeylessOAuth2Issuer:       false,
+	AkeylessOAuth2TokenURL:     false,
+	AkeylessOAuth2ClientID:     false,
+	AkeylessOAuth2ClientSecret: true,
+	AkeylessOAuth2Scopes:       false,
+	AkeylessOAuth2Audience:     false,
+
+	AkeylessUIDBootstrapAccessID:  false,
+	AkeylessUIDBootstrapAccessKey: true,
+	AkeylessUIDAuthMethodName:     false,
+}
+
+// RedactedEnvironment returns the set AKEYLESS_* environment variables of the provider, with
+// the values of secret ones redacted, for support bundles.
+func RedactedEnvironment() map[string]string {
+	env := make(map[string]string)
+	for name, secret := range environmentVariables {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if secret && value != "" {
+			value = redacted
+		}
+		env[name] = value
+	}
+	return env
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Feature is an experimental behavior a SecretProviderClass opts into with the comma-separated
+// features parameter, so new capabilities can be rolled out selectively before becoming defaults.
+type Feature string
+
+const (
+	// FeatureTagSelection allows objects to select items by tags instead of paths
+	FeatureTagSelection Feature = "tag-selection"
+)
+
+// features are the known experimental features. Features becoming defaults are kept, so
+// SecretProviderClasses still naming them remain valid.
+var features = map[Feature]bool{
+	FeatureTagSelection: true,
+}
+
+// FeatureNames returns the names of the known features, sorted.
+func FeatureNames() []string {
+	names := make([]string, 0, len(features))
+	for f := range features {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseFeatures(s string) []Feature {
+	var out []Feature
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, Feature(f))
+		}
+	}
+	return out
+}
+
+// FeatureEnabled reports whether the SecretProviderClass opted into the feature.
+func (c *Config) FeatureEnabled(feature Feature) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) validateFeatures() error {
+	for _, f := range c.Features {
+		if !features[f] {
+			return fmt.Errorf("unknown feature %v, secretProviderClass: %v, available: %v", f, c.SecretProviderClass, strings.Join(FeatureNames(), ", "))
+		}
+	}
+	for _, secret := range c.Secrets {
+		if len(secret.Tags) > 0 && !c.FeatureEnabled(FeatureTagSelection) {
+			return fmt.Errorf("tags of %v require the %v feature, secretProviderClass: %v", secret.FileName, FeatureTagSelection, c.SecretProviderClass)
+		}
+	}
+	return nil
+}
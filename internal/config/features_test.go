@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatures(t *testing.T) {
+	params, err := parseParameters("", `{"features":" tag-selection , ","objects":"- tags: [prod]\n  fileName: prod"}`, "https://api.akeyless.io", "kubernetes")
+	require.NoError(t, err)
+	require.Equal(t, []Feature{FeatureTagSelection}, params.Features)
+
+	cfg := Config{Parameters: params}
+	require.True(t, cfg.FeatureEnabled(FeatureTagSelection))
+	require.NoError(t, cfg.validateFeatures())
+
+	cfg.Features = nil
+	require.ErrorContains(t, cfg.validateFeatures(), "require the tag-selection feature")
+
+	cfg.Features = []Feature{"folder-magic"}
+	require.ErrorContains(t, cfg.validateFeatures(), "unknown feature folder-magic")
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SaaSURL is the Akeyless SaaS API, used when no gateway is configured only if the provider opts in
+// with -saas-fallback.
+const SaaSURL = "https://api.akeyless.io"
+
+// NormalizeGatewayURL validates an Akeyless API or gateway URL and returns it in its canonical
+// form: surrounding whitespace trimmed, the path cleaned of "." and ".." elements and trailing
+// slashes, e.g. " https://gw:8000/api/v2/ " becomes "https://gw:8000/api/v2". Malformed URLs
+// fail here with an explicit error instead of as a transport error of the first API call.
+func NormalizeGatewayURL(raw string) (string, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return "", errors.New("empty URL")
+	}
+	if strings.IndexFunc(s, unicode.IsSpace) >= 0 {
+		return "", errors.New("URL must not contain spaces")
+	}
+	if !strings.Contains(s, "://") {
+		return "", errors.New("missing scheme, e.g. https://")
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %v, must be http or https", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", errors.New("missing host")
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid port %v", port)
+		}
+	} else if strings.HasSuffix(u.Host, ":") {
+		return "", errors.New("empty port")
+	}
+	if u.User != nil {
+		return "", errors.New("URL must not contain credentials")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.New("URL must not have a query or fragment")
+	}
+
+	if u.Path != "" {
+		u.Path = path.Clean("/" + u.Path)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), nil
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheablePaths lists the gateway metadata operations whose responses may be cached
+// according to the Cache-Control/ETag headers returned by the gateway.
+var cacheablePaths = map[string]bool{
+	"/describe-item": true,
+	"/list-items":    true,
+}
+
+type cachedResponse struct {
+	etag       string
+	expires    time.Time
+	tokenHash  string
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// cachingTransport honors Cache-Control and ETag semantics for describe/list operations.
+// Fresh responses (max-age) are served locally only to the token that fetched them, otherwise
+// a conditional request is sent and a 304 Not Modified answer is served from the cache.
+type cachingTransport struct {
+	next    http.RoundTripper
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+func newCachingTransport(next http.RoundTripper) *cachingTransport {
+	return &cachingTransport{
+		next:    next,
+		entries: make(map[string]*cachedResponse),
+	}
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || !cacheablePaths[operation(req)] || req.Body == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	key, tokenHash := cacheKey(operation(req), body)
+
+	t.mu.Lock()
+	entry := t.entries[key]
+	t.mu.Unlock()
+
+	if entry != nil {
+		if entry.tokenHash == tokenHash && time.Now().Before(entry.expires) {
+			return entry.response(req), nil
+		}
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		_ = resp.Body.Close()
+		t.store(key, tokenHash, entry.statusCode, resp.Header, entry.body)
+		return entry.response(req), nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	t.store(key, tokenHash, resp.StatusCode, resp.Header, respBody)
+
+	return resp, nil
+}
+
+// store records a response unless the gateway forbids it, refreshing the freshness lifetime
+// from the latest Cache-Control/ETag headers.
+func (t *cachingTransport) store(key, tokenHash string, statusCode int, header http.Header, body []byte) {
+	etag := header.Get("ETag")
+	maxAge, noStore := parseCacheControl(header.Get("Cache-Control"))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if noStore || (etag == "" && maxAge <= 0) {
+		delete(t.entries, key)
+		return
+	}
+
+	if etag == "" {
+		if old, ok := t.entries[key]; ok {
+			etag = old.etag
+		}
+	}
+
+	t.entries[key] = &cachedResponse{
+		etag:       etag,
+		expires:    time.Now().Add(maxAge),
+		tokenHash:  tokenHash,
+		statusCode: statusCode,
+		header:     header.Clone(),
+		body:       body,
+	}
+}
+
+func (e *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.statusCode) + " " + http.StatusText(e.statusCode),
+		StatusCode:    e.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// operation returns the last path segment of the request URL, which names the API operation.
+func operation(req *http.Request) string {
+	p := req.URL.Path
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[i:]
+	}
+	return p
+}
+
+// cacheKey derives a cache key from the request body without the authentication token, so that
+// token renewals don't invalidate cached metadata, and returns the token hash separately.
+func cacheKey(op string, body []byte) (string, string) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return op + ":" + hash(body), ""
+	}
+
+	token := ""
+	for _, name := range []string{"token", "uid-token"} {
+		if v, ok := fields[name].(string); ok {
+			token += v
+		}
+		delete(fields, name)
+	}
+
+	stripped, _ := json.Marshal(fields)
+	return op + ":" + hash(stripped), hash([]byte(token))
+}
+
+func hash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// parseCacheControl returns the max-age lifetime and whether the response must not be stored.
+func parseCacheControl(value string) (time.Duration, bool) {
+	var maxAge time.Duration
+	noCache := false
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			return 0, true
+		case directive == "no-cache":
+			noCache = true
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if noCache {
+		// the response may be stored but must always be revalidated
+		maxAge = 0
+	}
+	return maxAge, false
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingTransport(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"item_name":"/foo"}`))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newCachingTransport(http.DefaultTransport)}
+	post := func(token string) string {
+		resp, err := client.Post(srv.URL+"/describe-item", "application/json", strings.NewReader(`{"name":"/foo","token":"`+token+`"}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	require.Equal(t, `{"item_name":"/foo"}`, post("t-1"))
+	// a renewed token still revalidates the same entry
+	require.Equal(t, `{"item_name":"/foo"}`, post("t-2"))
+	require.Equal(t, 2, requests)
+}
+
+func TestParseCacheControl(t *testing.T) {
+	maxAge, noStore := parseCacheControl("private, max-age=30")
+	require.Equal(t, 30*time.Second, maxAge)
+	require.False(t, noStore)
+
+	maxAge, _ = parseCacheControl("max-age=30, no-cache")
+	require.Zero(t, maxAge)
+
+	_, noStore = parseCacheControl("no-store")
+	require.True(t, noStore)
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+const (
+	AkeylessOAuth2Issuer       = "AKEYLESS_OAUTH2_ISSUER"
+	AkeylessOAuth2TokenURL     = "AKEYLESS_OAUTH2_TOKEN_URL"
+	AkeylessOAuth2ClientID     = "AKEYLESS_OAUTH2_CLIENT_ID"
+	AkeylessOAuth2ClientSecret = "AKEYLESS_OAUTH2_CLIENT_SECRET"
+	AkeylessOAuth2Scopes       = "AKEYLESS_OAUTH2_SCOPES"
+	AkeylessOAuth2Audience     = "AKEYLESS_OAUTH2_AUDIENCE"
+)
+
+// jwtAccessType is the Akeyless access type OAuth2 access tokens are presented to
+const jwtAccessType = "jwt"
+
+// oauth2Client is the HTTP client of identity provider requests, replaceable in tests
+var oauth2Client = &http.Client{}
+
+// authWithOAuth2 runs the OAuth2 client credentials flow against the configured identity provider
+// and authenticates to Akeyless JWT auth with the issued access token.
+func (c *Config) authWithOAuth2(ctx context.Context, s *Session) error {
+	jwt, err := c.oauth2AccessToken(ctx)
+	if err != nil {
+		err = fmt.Errorf("requested access type %v but failed to get a token from the identity provider, error: %w", OAuth2, err)
+		log.Printf("authWithOAuth2 ERR: %v", err.Error())
+		return err
+	}
+
+	authBody := akeyless.NewAuthWithDefaults()
+	authBody.SetAccessType(jwtAccessType)
+	authBody.SetJwt(jwt)
+	err = c.authenticate(ctx, s, authBody)
+
+	if err != nil {
+		log.Printf("authWithOAuth2 ERR: %v", err.Error())
+	}
+	return err
+}
+
+// oauth2AccessToken returns an access token of the client credentials grant.
+func (c *Config) oauth2AccessToken(ctx context.Context) (string, error) {
+	if c.AkeylessOAuth2ClientID == "" || c.AkeylessOAuth2ClientSecret.Empty() {
+		return "", errors.New("missing OAuth2 client ID or client secret")
+	}
+	tokenURL, err := c.oauth2TokenURL(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if c.AkeylessOAuth2Scopes != "" {
+		form.Set("scope", strings.Join(strings.FieldsFunc(c.AkeylessOAuth2Scopes, func(r rune) bool { return r == ',' || r == ' ' }), " "))
+	}
+	if c.AkeylessOAuth2Audience != "" {
+		form.Set("audience", c.AkeylessOAuth2Audience)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 2.3.1, the credentials are form-urlencoded before they are used as basic auth
+	req.SetBasicAuth(url.QueryEscape(c.AkeylessOAuth2ClientID), url.QueryEscape(c.AkeylessOAuth2ClientSecret.Reveal()))
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := oauth2Do(req, &token)
+	if err != nil {
+		return "", err
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("token endpoint %v returned %v: %v", tokenURL, token.Error, token.ErrorDescription)
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("token endpoint %v returned status %d", tokenURL, status)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint %v returned no access token", tokenURL)
+	}
+	return token.AccessToken, nil
+}
+
+// oauth2TokenURL returns the configured token endpoint, or the one the issuer's OpenID
+// configuration advertises.
+func (c *Config) oauth2TokenURL(ctx context.Context) (string, error) {
+	if c.AkeylessOAuth2TokenURL != "" {
+		return c.AkeylessOAuth2TokenURL, nil
+	}
+	if c.AkeylessOAuth2Issuer == "" {
+		return "", errors.New("missing OAuth2 issuer or token URL")
+	}
+
+	discoveryURL := strings.TrimSuffix(c.AkeylessOAuth2Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var discovery struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	status, err := oauth2Do(req, &discovery)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("OpenID configuration %v returned status %d", discoveryURL, status)
+	}
+	if discovery.TokenEndpoint == "" {
+		return "", fmt.Errorf("OpenID configuration %v has no token_endpoint", discoveryURL)
+	}
+	return discovery.TokenEndpoint, nil
+}
+
+// oauth2Do sends an identity provider request and decodes its JSON response into v.
+// Like authentication calls to the gateway, they are bounded by the auth timeout.
+func oauth2Do(req *http.Request, v interface{}) (int, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), currentTimeouts().auth)
+	defer cancel()
+	resp, err := oauth2Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("failed to decode response of %v: %w", req.URL, err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthWithOAuth2(t *testing.T) {
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": idp.URL, "token_endpoint": idp.URL + "/oauth2/token"})
+		case "/oauth2/token":
+			id, secret, _ := r.BasicAuth()
+			if id != "csi-provider" || secret != "s%3Acret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "bad credentials"})
+				return
+			}
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			require.Equal(t, "akeyless openid", r.PostForm.Get("scope"))
+			require.Equal(t, "akeyless", r.PostForm.Get("audience"))
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "eyJ.idp.jwt", "token_type": "Bearer", "expires_in": 3600})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer idp.Close()
+
+	var auth map[string]interface{}
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/auth", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&auth))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"t-oauth2"}`))
+	}))
+	defer gw.Close()
+
+	cfg := Config{Parameters: Parameters{
+		AkeylessGatewayURL:         gw.URL,
+		AkeylessAccessID:           "p-jwt",
+		AkeylessOAuth2Issuer:       idp.URL + "/",
+		AkeylessOAuth2ClientID:     "csi-provider",
+		AkeylessOAuth2ClientSecret: NewCredential("s:cret"),
+		AkeylessOAuth2Scopes:       "akeyless, openid",
+		AkeylessOAuth2Audience:     "akeyless",
+	}}
+	s := NewSession(NewClient(gw.URL))
+	require.NoError(t, cfg.authWithOAuth2(context.Background(), s))
+	require.Equal(t, "t-oauth2", s.Token())
+	require.Equal(t, "jwt", auth["access-type"])
+	require.Equal(t, "eyJ.idp.jwt", auth["jwt"])
+	require.Equal(t, "p-jwt", auth["access-id"])
+
+	cfg.AkeylessOAuth2ClientSecret = NewCredential("wrong")
+	require.ErrorContains(t, cfg.authWithOAuth2(context.Background(), s), "invalid_client: bad credentials")
+
+	// an explicit token URL skips discovery
+	cfg.AkeylessOAuth2Issuer = ""
+	cfg.AkeylessOAuth2TokenURL = idp.URL + "/missing"
+	require.ErrorContains(t, cfg.authWithOAuth2(context.Background(), s), "returned status 404")
+
+	cfg.AkeylessOAuth2TokenURL = ""
+	require.ErrorContains(t, cfg.authWithOAuth2(context.Background(), s), "missing OAuth2 issuer or token URL")
+	cfg.AkeylessOAuth2ClientID = ""
+	require.ErrorContains(t, cfg.authWithOAuth2(context.Background(), s), "missing OAuth2 client ID")
+}
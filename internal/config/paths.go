@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// TempDir is the directory used for temporary files, such as TPM sealing work files.
+// It must be writable, which matters when running with a read-only root filesystem.
+var TempDir = os.TempDir()
+
+// CheckWritableDir verifies that dir exists and files can be created in it, so that a
+// misconfigured volume fails fast with a precise message instead of deep in a mount.
+func CheckWritableDir(purpose, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%v directory %v is not accessible: %w", purpose, dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%v directory %v is not a directory", purpose, dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".write-check-")
+	if err != nil {
+		return fmt.Errorf("%v directory %v is not writable, mount a writable volume (e.g. emptyDir) there: %w", purpose, dir, err)
+	}
+	name := f.Name()
+	_ = f.Close()
+	_ = os.Remove(name)
+
+	return nil
+}
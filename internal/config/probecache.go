@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// accessTypeProbe authenticates with one access type, used to detect which one the access ID has.
+type accessTypeProbe struct {
+	accType accessType
+	probe   func(ctx context.Context, s *Session) error
+}
+
+// accessTypeCache remembers the access type detected per gateway and access ID, so repeated mounts
+// of the same SecretProviderClass authenticate right away instead of walking through every method,
+// each failed attempt of which costs time and an audit log entry.
+type accessTypeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]accessTypeCacheEntry
+}
+
+type accessTypeCacheEntry struct {
+	accType accessType
+	expires time.Time
+}
+
+var probedAccessTypes = &accessTypeCache{entries: make(map[string]accessTypeCacheEntry)}
+
+// SetAccessTypeCacheTTL sets how long a detected access type is remembered, 0 disables the cache.
+func SetAccessTypeCacheTTL(ttl time.Duration) {
+	probedAccessTypes.mu.Lock()
+	defer probedAccessTypes.mu.Unlock()
+
+	probedAccessTypes.ttl = ttl
+	probedAccessTypes.entries = make(map[string]accessTypeCacheEntry)
+}
+
+func accessTypeCacheKey(gatewayURL, accessID string) string {
+	return gatewayURL + "\x00" + accessID
+}
+
+func (c *accessTypeCache) get(key string) (accessType, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return e.accType, true
+}
+
+func (c *accessTypeCache) set(key string, accType accessType) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+	c.entries[key] = accessTypeCacheEntry{accType: accType, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *accessTypeCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/cloudid"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectAccessType_Cached(t *testing.T) {
+	defer func() { newCloudIdentity = defaultCloudIdentity }()
+	metadataTimeouts := make(map[accessType]time.Duration)
+	newCloudIdentity = func(c *Config, accType accessType) (cloudid.CloudIdentity, error) {
+		metadataTimeouts[accType] = c.cloudMetadataTimeout
+		return fakeCloudIdentity{err: errors.New("not running in the cloud")}, nil
+	}
+	SetAccessTypeCacheTTL(time.Minute)
+	defer SetAccessTypeCacheTTL(0)
+
+	var authCalls int32
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&authCalls, 1)
+		http.Error(w, `{"error":"access denied"}`, http.StatusUnauthorized)
+	}))
+	defer gw.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "uid-token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("u-token"), 0600))
+	cfg := Config{Parameters: Parameters{
+		AkeylessGatewayURL:   gw.URL,
+		AkeylessAccessID:     "p-uid",
+		AkeylessUIDTokenFile: tokenFile,
+	}}
+	s := NewSession(NewClient(gw.URL))
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	require.Equal(t, UniversalIdentity, cfg.detectAccessType(context.Background(), s))
+	require.NotZero(t, atomic.LoadInt32(&authCalls))
+	require.Contains(t, logs.String(), "detected access type universal_identity of p-uid")
+	require.Contains(t, logs.String(), `{"accessType":"aws_iam","durationMs":`)
+	require.Contains(t, logs.String(), `"error":"requested access type aws_iam but failed to get cloud ID`)
+	// probes don't wait long for metadata services the node may not have
+	require.Equal(t, detectionMetadataTimeout, metadataTimeouts[OCI])
+	require.Zero(t, cfg.cloudMetadataTimeout)
+
+	var out bytes.Buffer
+	require.NoError(t, metrics.Default.WritePrometheus(&out))
+	require.Contains(t, out.String(), `akeyless_csi_provider_access_type_probes_total{access_type="aws_iam",result="error"}`)
+	require.Contains(t, out.String(), `akeyless_csi_provider_access_type_probes_total{access_type="universal_identity",result="success"}`)
+	require.Contains(t, out.String(), `akeyless_csi_provider_access_type_probe_duration_seconds_count{access_type="gcp"}`)
+
+	atomic.StoreInt32(&authCalls, 0)
+	require.Equal(t, UniversalIdentity, cfg.detectAccessType(context.Background(), s))
+	require.Zero(t, atomic.LoadInt32(&authCalls), "cached access type must not probe other methods")
+
+	// a cached access type that stopped working is probed again
+	require.NoError(t, os.WriteFile(tokenFile, nil, 0600))
+	logs.Reset()
+	require.Equal(t, accessType(""), cfg.detectAccessType(context.Background(), s))
+	require.Contains(t, logs.String(), `failed to detect the access type of p-uid, secretProviderClass: , attempts: [{"accessType":"universal_identity","cached":true,`)
+	_, ok := probedAccessTypes.get(accessTypeCacheKey(gw.URL, "p-uid"))
+	require.False(t, ok)
+}
+
+func TestAccessTypeCache_Expiry(t *testing.T) {
+	c := &accessTypeCache{ttl: time.Millisecond, entries: make(map[string]accessTypeCacheEntry)}
+	c.set("k", AccessKey)
+	got, ok := c.get("k")
+	require.True(t, ok)
+	require.Equal(t, AccessKey, got)
+
+	time.Sleep(5 * time.Millisecond)
+	_, ok = c.get("k")
+	require.False(t, ok)
+
+	c.ttl = 0
+	c.set("k", AccessKey)
+	_, ok = c.get("k")
+	require.False(t, ok)
+}
+
+func TestAuthenticateChain(t *testing.T) {
+	defer func() { newCloudIdentity = defaultCloudIdentity }()
+	newCloudIdentity = func(c *Config, accType accessType) (cloudid.CloudIdentity, error) {
+		return fakeCloudIdentity{err: errors.New("not running in the cloud")}, nil
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "uid-token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("u-token"), 0600))
+	cfg := Config{Parameters: Parameters{
+		AkeylessAccessID:     "p-uid",
+		AkeylessAccessType:   "aws_iam, universal_identity",
+		AkeylessUIDTokenFile: tokenFile,
+	}}
+
+	accType, err := cfg.authenticateChain(context.Background(), NewSession(nil), []string{"aws_iam", " universal_identity"})
+	require.NoError(t, err)
+	require.Equal(t, UniversalIdentity, accType)
+
+	_, err = cfg.authenticateChain(context.Background(), NewSession(nil), []string{"aws_iam", "gcp"})
+	require.ErrorContains(t, err, "not running in the cloud")
+
+	_, err = cfg.authenticateChain(context.Background(), NewSession(nil), []string{"kerberos"})
+	require.ErrorContains(t, err, "unsupported access type kerberos")
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CheckDefaultCredential authenticates once with the node-level default credential, configured
+// through the AKEYLESS_* environment variables of the provider, without touching any mount.
+// UID tokens are not rotated by the check, since that would invalidate the token the mounts use,
+// so only their presence is verified.
+func CheckDefaultCredential(ctx context.Context, defaultGatewayURL, defaultMountPath string) error {
+	c, err := defaultCredentialConfig(NewClient, defaultGatewayURL, defaultMountPath)
+	if err != nil {
+		return err
+	}
+
+	if c.UsingUID() {
+		if c.AkeylessUIDTokenFile != "" {
+			return c.loadUIDTokenFile(c.Session)
+		}
+		if c.AkeylessUIDInitToken.Empty() && c.AkeylessUIDTokenPersistDir == "" {
+			return fmt.Errorf("no UID token configured for %v", c.AkeylessAccessID)
+		}
+		return nil
+	}
+
+	if err = c.authenticatorFor()(ctx, c.Session); err != nil {
+		return fmt.Errorf("default credential %v failed to authenticate using %v: %w", c.AkeylessAccessID, c.AkeylessAccessType, err)
+	}
+	return nil
+}
+
+// AuthenticateDefaultCredential returns a config authenticated with the node-level default
+// credential, for fetching items outside of any mount. As with CheckDefaultCredential, UID
+// tokens are used as they are and never rotated.
+func AuthenticateDefaultCredential(ctx context.Context, newClient ClientFactory, defaultGatewayURL, defaultMountPath string) (Config, error) {
+	c, err := defaultCredentialConfig(newClient, defaultGatewayURL, defaultMountPath)
+	if err != nil {
+		return Config{}, err
+	}
+	defer c.WipeCredentials()
+
+	if !c.UsingUID() {
+		if err = c.authenticatorFor()(ctx, c.Session); err != nil {
+			return Config{}, fmt.Errorf("default credential %v failed to authenticate using %v: %w", c.AkeylessAccessID, c.AkeylessAccessType, err)
+		}
+		return *c, nil
+	}
+
+	if c.AkeylessUIDTokenFile != "" {
+		if err = c.loadUIDTokenFile(c.Session); err != nil {
+			return Config{}, err
+		}
+		return *c, nil
+	}
+	// the identity's token, as rotated by the mounts of the same identity
+	id := c.uidIdentity()
+	if err = c.loadUIDIdentity(ctx, id, c.Session); err != nil {
+		return Config{}, err
+	}
+	if token, _ := id.current(); token == "" {
+		return Config{}, fmt.Errorf("no UID token configured for %v", c.AkeylessAccessID)
+	}
+	c.Session.shareUIDIdentity(id)
+	return *c, nil
+}
+
+func defaultCredentialConfig(newClient ClientFactory, defaultGatewayURL, defaultMountPath string) (*Config, error) {
+	params, err := parseParameters("", "{}", defaultGatewayURL, defaultMountPath)
+	if err != nil {
+		return nil, err
+	}
+	if params.AkeylessAccessID == "" {
+		return nil, errors.New("no default credential configured, set " + AkeylessAccessID)
+	}
+	return &Config{Parameters: params, Session: NewSession(newClient(params.AkeylessGatewayURL))}, nil
+}
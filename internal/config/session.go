@@ -0,0 +1,156 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// ClientFactory creates the Akeyless API client of a gateway. It is created in main and
+// injected into the server, so that tests can point mounts to a fake gateway.
+type ClientFactory func(akeylessGatewayURL string) *akeyless.V2ApiService
+
+// Session is the Akeyless client of a mount together with the auth token the mount's
+// authentication routine keeps valid. The provider fetches the mount's secrets through it.
+type Session struct {
+	Client *akeyless.V2ApiService
+
+	mu    sync.RWMutex
+	token string
+	// issued is when the token was obtained
+	issued time.Time
+	// accessType is the access type the authentication routine authenticates with
+	accessType accessType
+	// authenticator authenticates with the credentials held by the authentication routine
+	authenticator func(ctx context.Context, s *Session) error
+	// uidTokenFileModTime is the modification time of the UID token file at its last read
+	uidTokenFileModTime time.Time
+	// uid is the UID identity whose token the session uses instead of token, nil for the
+	// other access types and externally rotated UID tokens
+	uid *uidIdentity
+	// identity is whom the session authenticated as, set once its initial authentication succeeded
+	identity string
+}
+
+// NewSession returns an unauthenticated session using the given client.
+func NewSession(client *akeyless.V2ApiService) *Session {
+	return &Session{Client: client}
+}
+
+// NewSessionWithToken returns a session using the given client, authenticated with a token
+// obtained elsewhere.
+func NewSessionWithToken(client *akeyless.V2ApiService, token string) *Session {
+	s := NewSession(client)
+	s.setToken(token)
+	return s
+}
+
+// Token returns the current auth token of the session.
+func (s *Session) Token() string {
+	if uid := s.uidIdentity(); uid != nil {
+		token, _ := uid.current()
+		return token
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.token
+}
+
+// TokenIssued returns when the current auth token of the session was obtained.
+func (s *Session) TokenIssued() time.Time {
+	if uid := s.uidIdentity(); uid != nil {
+		_, issued := uid.current()
+		return issued
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.issued
+}
+
+// AccessType returns the access type the session's authentication routine authenticates with.
+func (s *Session) AccessType() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return string(s.accessType)
+}
+
+// Identity returns whom the session authenticated as: the gateway, the access type and ID, and
+// the parameters selecting among the identities of one access ID. Sessions of the same identity
+// are granted the same items. It is empty until the initial authentication succeeded.
+func (s *Session) Identity() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.identity
+}
+
+func (s *Session) setIdentity(identity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.identity = identity
+}
+
+func (s *Session) setToken(t string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = t
+	s.issued = time.Now()
+}
+
+// Reauthenticate authenticates again with the credentials held by the running authentication
+// routine, since the credentials of the mounted configs are wiped once it started.
+func (s *Session) Reauthenticate(ctx context.Context) error {
+	s.mu.RLock()
+	authenticator := s.authenticator
+	s.mu.RUnlock()
+
+	if authenticator == nil {
+		return errors.New("authentication routine of the session is not running")
+	}
+	return authenticator(ctx, s)
+}
+
+// shareUIDIdentity makes the session use the token of the UID identity uid from now on.
+func (s *Session) shareUIDIdentity(uid *uidIdentity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.uid = uid
+}
+
+func (s *Session) uidIdentity() *uidIdentity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.uid
+}
+
+func (s *Session) setAuthenticator(accType accessType, authenticator func(ctx context.Context, s *Session) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accessType = accType
+	s.authenticator = authenticator
+}
+
+// reusable reports whether the session has a token and a running authentication routine.
+func (s *Session) reusable() (accessType, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token := s.token
+	if s.uid != nil {
+		token, _ = s.uid.current()
+	}
+	return s.accessType, token != "" && s.authenticator != nil
+}
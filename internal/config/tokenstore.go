@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TokenSealTPM seals the persisted UID token with the node's TPM
+	TokenSealTPM = "tpm"
+)
+
+// tokenSealer protects the persisted UID token at rest.
+type tokenSealer interface {
+	seal(data []byte) ([]byte, error)
+	unseal(data []byte) ([]byte, error)
+}
+
+// plainSealer stores the token as is, relying on the file permissions only.
+type plainSealer struct{}
+
+func (plainSealer) seal(data []byte) ([]byte, error)   { return data, nil }
+func (plainSealer) unseal(data []byte) ([]byte, error) { return data, nil }
+
+// tpmSealer seals the token to the node's TPM using tpm2-tools, so the persisted file is
+// useless on any other machine. The primary key is re-derived from the owner hierarchy on
+// every operation, so no key material is stored besides the sealed object itself.
+type tpmSealer struct {
+	workDir string
+}
+
+type tpmSealedObject struct {
+	Public  []byte `json:"public"`
+	Private []byte `json:"private"`
+}
+
+// tpmDevice is the TPM resource manager the tpm2-tools talk to.
+var tpmDevice = "/dev/tpmrm0"
+
+// tpmTools are the tpm2-tools run by tpmSealer.
+var tpmTools = []string{"tpm2_createprimary", "tpm2_create", "tpm2_load", "tpm2_unseal"}
+
+// CheckTokenSeal verifies that UID tokens can be sealed with mode on this node, so a missing
+// TPM or tpm2-tools fail at startup rather than when the first token is rotated.
+func CheckTokenSeal(mode, persistDir string) error {
+	if mode == "" {
+		return nil
+	}
+	if _, err := newTokenSealer(mode, TempDir); err != nil {
+		return err
+	}
+	if persistDir == "" {
+		return fmt.Errorf("UID token seal mode %v requires a UID token persist directory", mode)
+	}
+	for _, tool := range tpmTools {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("UID token seal mode %v requires tpm2-tools: %w", mode, err)
+		}
+	}
+	if _, err := os.Stat(tpmDevice); err != nil {
+		return fmt.Errorf("UID token seal mode %v requires the TPM device %v, mount it into the provider container: %w", mode, tpmDevice, err)
+	}
+	return nil
+}
+
+func newTokenSealer(mode, workDir string) (tokenSealer, error) {
+	switch mode {
+	case "":
+		return plainSealer{}, nil
+	case TokenSealTPM:
+		return &tpmSealer{workDir: workDir}, nil
+	}
+	return nil, fmt.Errorf("unsupported UID token seal mode %v", mode)
+}
+
+func (s *tpmSealer) seal(data []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp(s.workDir, ".tpm-seal-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TPM work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = s.createPrimary(dir); err != nil {
+		return nil, err
+	}
+	if _, err = runTPM(data, "tpm2_create", "-Q", "-C", filepath.Join(dir, "primary.ctx"), "-g", "sha256",
+		"-i", "-", "-u", filepath.Join(dir, "seal.pub"), "-r", filepath.Join(dir, "seal.priv")); err != nil {
+		return nil, err
+	}
+
+	var obj tpmSealedObject
+	if obj.Public, err = os.ReadFile(filepath.Join(dir, "seal.pub")); err != nil {
+		return nil, err
+	}
+	if obj.Private, err = os.ReadFile(filepath.Join(dir, "seal.priv")); err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+func (s *tpmSealer) unseal(data []byte) ([]byte, error) {
+	var obj tpmSealedObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("malformed TPM sealed token: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(s.workDir, ".tpm-seal-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TPM work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = os.WriteFile(filepath.Join(dir, "seal.pub"), obj.Public, 0600); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, "seal.priv"), obj.Private, 0600); err != nil {
+		return nil, err
+	}
+	if err = s.createPrimary(dir); err != nil {
+		return nil, err
+	}
+	if _, err = runTPM(nil, "tpm2_load", "-Q", "-C", filepath.Join(dir, "primary.ctx"),
+		"-u", filepath.Join(dir, "seal.pub"), "-r", filepath.Join(dir, "seal.priv"), "-c", filepath.Join(dir, "seal.ctx")); err != nil {
+		return nil, err
+	}
+	return runTPM(nil, "tpm2_unseal", "-c", filepath.Join(dir, "seal.ctx"))
+}
+
+func (s *tpmSealer) createPrimary(dir string) error {
+	_, err := runTPM(nil, "tpm2_createprimary", "-Q", "-C", "o", "-g", "sha256", "-G", "ecc", "-c", filepath.Join(dir, "primary.ctx"))
+	return err
+}
+
+func runTPM(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v failed: %w, %v", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// uidIdentities are the UID identities mounted on this node by key, so that all the sessions
+// of an identity share its token: rotating a UID token invalidates the previous one, so
+// sessions rotating their own copies would invalidate each other's.
+var (
+	uidIdentitiesMu sync.Mutex
+	uidIdentities   = make(map[string]*uidIdentity)
+)
+
+// uidIdentity is the UID token of one identity, rotated by one session at a time.
+type uidIdentity struct {
+	key string
+	// mu serializes loading, rotating and persisting the token
+	mu sync.Mutex
+
+	tokenMu sync.RWMutex
+	token   string
+	// issued is when the token was loaded or rotated, rotated when it was last rotated
+	issued, rotated time.Time
+}
+
+func (id *uidIdentity) current() (string, time.Time) {
+	id.tokenMu.RLock()
+	defer id.tokenMu.RUnlock()
+	return id.token, id.issued
+}
+
+func (id *uidIdentity) lastRotated() time.Time {
+	id.tokenMu.RLock()
+	defer id.tokenMu.RUnlock()
+	return id.rotated
+}
+
+func (id *uidIdentity) set(token string, rotated bool) {
+	id.tokenMu.Lock()
+	defer id.tokenMu.Unlock()
+	id.token = token
+	id.issued = time.Now()
+	if rotated {
+		id.rotated = id.issued
+	}
+}
+
+// uidIdentityKey identifies the UID identity of c by its access ID, auth method and init token,
+// so a token persisted and rotated for one SecretProviderClass only ever replaces the same init
+// token, never the one of a SecretProviderClass of another identity.
+func (c *Config) uidIdentityKey() string {
+	h := sha256.New()
+	for _, part := range []string{c.AkeylessAccessID, c.AkeylessUIDAuthMethodName, c.AkeylessUIDInitToken.Reveal()} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// uidIdentity returns the UID identity of c, created on first use.
+func (c *Config) uidIdentity() *uidIdentity {
+	key := c.uidIdentityKey()
+
+	uidIdentitiesMu.Lock()
+	defer uidIdentitiesMu.Unlock()
+
+	id, ok := uidIdentities[key]
+	if !ok {
+		id = &uidIdentity{key: key}
+		uidIdentities[key] = id
+	}
+	return id
+}
+
+// uidTokenPersistFile is the file the token of the UID identity key is persisted to.
+func (c *Config) uidTokenPersistFile(key string) string {
+	return filepath.Join(c.AkeylessUIDTokenPersistDir, key+".token")
+}
+
+// persistUIDToken stores the rotated UID token of the identity key so it survives provider
+// restarts, since the init token is no longer valid once it was rotated.
+func (c *Config) persistUIDToken(key, token string) error {
+	if c.AkeylessUIDTokenPersistDir == "" {
+		return nil
+	}
+
+	sealer, err := newTokenSealer(c.AkeylessUIDTokenSeal, TempDir)
+	if err != nil {
+		return err
+	}
+	data, err := sealer.seal([]byte(token))
+	if err != nil {
+		return fmt.Errorf("failed to seal UID token: %w", err)
+	}
+
+	file := c.uidTokenPersistFile(key)
+	tmp := file + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to persist UID token to %v: %w", file, err)
+	}
+	if err = os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to persist UID token to %v: %w", file, err)
+	}
+	return nil
+}
+
+// loadPersistedUIDToken returns the last persisted UID token of the identity key, or an empty
+// string if none was persisted.
+func (c *Config) loadPersistedUIDToken(key string) (string, error) {
+	if c.AkeylessUIDTokenPersistDir == "" {
+		return "", nil
+	}
+
+	file := c.uidTokenPersistFile(key)
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read persisted UID token from %v: %w", file, err)
+	}
+
+	sealer, err := newTokenSealer(c.AkeylessUIDTokenSeal, TempDir)
+	if err != nil {
+		return "", err
+	}
+	token, err := sealer.unseal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to unseal UID token: %w", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultGatewayMaxConns is the default limit of concurrent connections to a single gateway.
+	DefaultGatewayMaxConns = 200
+	// DefaultGatewayMaxIdleConns is the default number of idle connections kept per gateway.
+	DefaultGatewayMaxIdleConns = 100
+
+	// gatewayTransportIdleTTL is how long the transport of a gateway no mount connected to is kept
+	gatewayTransportIdleTTL = time.Hour
+	// maxGatewayTransports bounds the gateways transports are kept for, since SecretProviderClasses
+	// choose the gateway URL, the ones used longest ago are dropped first
+	maxGatewayTransports = 100
+)
+
+// gatewayTransports holds the transport of every gateway URL. The clients of a gateway share its
+// connection pool, and gateways don't share one, so a slow gateway holding all of its connections
+// doesn't delay mounts of another gateway.
+var (
+	transportsMu        sync.Mutex
+	gatewayTransports   = make(map[string]*pooledTransport)
+	gatewayMaxConns     = DefaultGatewayMaxConns
+	gatewayMaxIdleConns = DefaultGatewayMaxIdleConns
+)
+
+// pooledTransport is the transport of a gateway together with when it was last used.
+type pooledTransport struct {
+	*http.Transport
+	// used is when a client of the gateway was last created
+	used time.Time
+}
+
+// SetGatewayConnectionLimits sets the concurrent and idle connections allowed per gateway, for
+// the gateways first connected to from now on.
+func SetGatewayConnectionLimits(maxConns, maxIdleConns int) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	gatewayMaxConns, gatewayMaxIdleConns = maxConns, maxIdleConns
+}
+
+// gatewayTransport returns the transport of the gateway at akeylessGatewayURL, created on first use.
+// Clients trusting the CA of a SecretProviderClass's caConfigMap, spcCA, get a transport of their
+// own per ConfigMap, empty and nil for the node's CA only.
+func gatewayTransport(akeylessGatewayURL, caConfigMap string, spcCA *caBundle) *http.Transport {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	key := akeylessGatewayURL
+	if caConfigMap != "" {
+		key += "#" + caConfigMap
+	}
+	now := time.Now()
+	if t, ok := gatewayTransports[key]; ok {
+		t.used = now
+		return t.Transport
+	}
+	evictGatewayTransports(now)
+	t := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   55 * time.Second,
+			KeepAlive: 55 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   30 * time.Second,
+		ExpectContinueTimeout: 30 * time.Second,
+		// every gateway has a transport of its own, the limits per host are the gateway's limits
+		MaxIdleConnsPerHost: gatewayMaxIdleConns,
+		MaxConnsPerHost:     gatewayMaxConns,
+	}
+	if tlsConfig := gatewayTLSConfig(gatewayHost(akeylessGatewayURL), spcCA); tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	gatewayTransports[key] = &pooledTransport{Transport: t, used: now}
+	return t
+}
+
+// evictGatewayTransports drops the transports of gateways not connected to for
+// gatewayTransportIdleTTL and the ones used longest ago beyond maxGatewayTransports. Clients still
+// holding a dropped transport keep using it until their mount ends. transportsMu must be held.
+func evictGatewayTransports(now time.Time) {
+	for gw, t := range gatewayTransports {
+		if now.Sub(t.used) > gatewayTransportIdleTTL {
+			t.CloseIdleConnections()
+			delete(gatewayTransports, gw)
+		}
+	}
+	for len(gatewayTransports) >= maxGatewayTransports {
+		oldest := ""
+		for gw, t := range gatewayTransports {
+			if oldest == "" || t.used.Before(gatewayTransports[oldest].used) {
+				oldest = gw
+			}
+		}
+		gatewayTransports[oldest].CloseIdleConnections()
+		delete(gatewayTransports, oldest)
+	}
+}
+
+// gatewayHost returns the host name or IP address of a gateway URL, the one its certificate must
+// be valid for.
+func gatewayHost(akeylessGatewayURL string) string {
+	u, err := url.Parse(akeylessGatewayURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// resetGatewayTransports closes the idle connections of all gateway transports and drops them, so
+// the next requests connect with the current TLS config and CA bundle.
+func resetGatewayTransports() {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	for gw, t := range gatewayTransports {
+		t.CloseIdleConnections()
+		delete(gatewayTransports, gw)
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayTransports(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+
+	defer SetGatewayConnectionLimits(DefaultGatewayMaxConns, DefaultGatewayMaxIdleConns)
+	SetGatewayConnectionLimits(1, 1)
+
+	// clients of a gateway share its transport
+	require.Same(t, gatewayTransport(slow.URL, "", nil), gatewayTransport(slow.URL, "", nil))
+	require.NotSame(t, gatewayTransport(slow.URL, "", nil), gatewayTransport(healthy.URL, "", nil))
+
+	get := func(ctx context.Context, url string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		require.NoError(t, err)
+		resp, err := newClientConfiguration(url).HTTPClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// a mount holds the only connection allowed to the slow gateway
+	go get(context.Background(), slow.URL)
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, get(ctx, slow.URL), context.DeadlineExceeded)
+
+	// mounts of another gateway still get a connection
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, get(ctx, healthy.URL))
+
+	resetGatewayTransports()
+	require.Empty(t, gatewayTransports)
+}
+
+func TestGatewayTransports_Eviction(t *testing.T) {
+	defer resetGatewayTransports()
+
+	idle := gatewayTransport("https://idle.example.com", "", nil)
+	gatewayTransports["https://idle.example.com"].used = time.Now().Add(-gatewayTransportIdleTTL - time.Minute)
+	recent := gatewayTransport("https://recent.example.com", "", nil)
+
+	// transports of gateways not connected to for a while are dropped
+	gatewayTransport("https://new.example.com", "", nil)
+	require.NotContains(t, gatewayTransports, "https://idle.example.com")
+	require.NotSame(t, idle, gatewayTransport("https://idle.example.com", "", nil))
+	require.Same(t, recent, gatewayTransport("https://recent.example.com", "", nil))
+
+	// beyond maxGatewayTransports the ones used longest ago are dropped first
+	resetGatewayTransports()
+	start := time.Now().Add(-time.Minute)
+	for i := 0; i < maxGatewayTransports; i++ {
+		url := fmt.Sprintf("https://gw-%d.example.com", i)
+		gatewayTransport(url, "", nil)
+		gatewayTransports[url].used = start.Add(time.Duration(i) * time.Second)
+	}
+	gatewayTransport("https://next.example.com", "", nil)
+	require.Len(t, gatewayTransports, maxGatewayTransports)
+	require.NotContains(t, gatewayTransports, "https://gw-0.example.com")
+	require.Contains(t, gatewayTransports, "https://gw-1.example.com")
+}
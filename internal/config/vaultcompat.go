@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VaultCompatParameters maps the HashiCorp Vault CSI provider's parameter names onto their
+// Akeyless equivalents, so SecretProviderClasses can be trial-migrated with minimal edits.
+var VaultCompatParameters = false
+
+// vaultSecret is an object of the Vault CSI provider's objects parameter.
+type vaultSecret struct {
+	ObjectName string                 `yaml:"objectName"`
+	SecretPath string                 `yaml:"secretPath"`
+	SecretKey  string                 `yaml:"secretKey"`
+	SecretArgs map[string]interface{} `yaml:"secretArgs"`
+}
+
+// applyVaultCompat rewrites Vault parameters in place. Akeyless parameters win when both are set.
+// Only parameters whose semantics align are mapped:
+//   - vaultAddress is the Akeyless gateway URL
+//   - roleName is the Kubernetes auth config name, and implies the k8s access type
+//   - objects take objectName as fileName, and secretKey extracts a key of a JSON secret
+func applyVaultCompat(params map[string]string) error {
+	if params["akeylessGatewayURL"] == "" {
+		params["akeylessGatewayURL"] = params["vaultAddress"]
+	}
+	if role := params["roleName"]; role != "" && params["akeylessK8sAuthConfigName"] == "" {
+		params["akeylessK8sAuthConfigName"] = role
+		if params["akeylessAccessType"] == "" {
+			params["akeylessAccessType"] = string(K8S)
+		}
+	}
+
+	if params["objects"] == "" {
+		return nil
+	}
+	var vaultSecrets []vaultSecret
+	if err := yaml.Unmarshal([]byte(params["objects"]), &vaultSecrets); err != nil {
+		return err
+	}
+
+	secrets := make([]Secret, 0, len(vaultSecrets))
+	for _, vs := range vaultSecrets {
+		if vs.ObjectName == "" {
+			// already in the Akeyless format
+			return nil
+		}
+		s := Secret{FileName: vs.ObjectName, SecretPath: vs.SecretPath, SecretArgs: vs.SecretArgs}
+		if !strings.HasPrefix(s.SecretPath, "/") {
+			s.SecretPath = "/" + s.SecretPath
+		}
+		if vs.SecretKey != "" {
+			if s.SecretArgs == nil {
+				s.SecretArgs = map[string]interface{}{}
+			}
+			s.PostProcessor = "template"
+			s.SecretArgs["template"] = fmt.Sprintf("{{ index .JSON %q }}", vs.SecretKey)
+		}
+		secrets = append(secrets, s)
+	}
+
+	out, err := yaml.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	params["objects"] = string(out)
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseParameters_VaultCompat(t *testing.T) {
+	VaultCompatParameters = true
+	defer func() { VaultCompatParameters = false }()
+
+	parametersStr, err := json.Marshal(map[string]string{
+		"vaultAddress": "https://gateway.example.com:8000/api/v2",
+		"roleName":     "my-k8s-auth-config",
+		"objects": `
+- objectName: "db-password"
+  secretPath: "secret/data/db-pass"
+  secretKey: "password"
+- objectName: "api-key"
+  secretPath: "/prod/api-key"
+`,
+	})
+	require.NoError(t, err)
+
+	params, err := parseParameters("", string(parametersStr), defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Equal(t, "https://gateway.example.com:8000/api/v2", params.AkeylessGatewayURL)
+	require.Equal(t, "my-k8s-auth-config", params.AkeylessK8sAuthConfigName)
+	require.Equal(t, string(K8S), params.AkeylessAccessType)
+	require.Equal(t, []Secret{
+		{
+			FileName:      "db-password",
+			SecretPath:    "/secret/data/db-pass",
+			PostProcessor: "template",
+			SecretArgs:    map[string]interface{}{"template": `{{ index .JSON "password" }}`},
+		},
+		{FileName: "api-key", SecretPath: "/prod/api-key"},
+	}, params.Secrets)
+
+	// Akeyless parameters are left untouched
+	parametersStr, err = json.Marshal(map[string]string{
+		"akeylessGatewayURL": "https://api.akeyless.io",
+		"vaultAddress":       "https://vault.example.com",
+		"objects":            objects,
+	})
+	require.NoError(t, err)
+	params, err = parseParameters("", string(parametersStr), defaultAkeylessGatewayURL, defaultVaultKubernetesMountPath)
+	require.NoError(t, err)
+	require.Equal(t, "https://api.akeyless.io", params.AkeylessGatewayURL)
+	require.Equal(t, []Secret{{FileName: "bar1", SecretPath: "/foo/bar"}}, params.Secrets)
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// WriteModeAppend appends the object's value to the file of the objects listed before it with
+	// the same fileName, like concat.
+	WriteModeAppend = "append"
+	// WriteModeOverwrite replaces the file of the objects listed before it with the same fileName,
+	// so the last listed object wins.
+	WriteModeOverwrite = "overwrite"
+)
+
+// applyWriteMode checks the writeMode of an object and sets concat for objects appending to
+// their file, which concatenates them.
+func applyWriteMode(s *Secret) error {
+	switch s.WriteMode {
+	case "":
+	case WriteModeAppend:
+		s.Concat = true
+	case WriteModeOverwrite:
+		if s.Concat {
+			return fmt.Errorf("object %v sets both concat and writeMode %v", s.FileName, s.WriteMode)
+		}
+	default:
+		return fmt.Errorf("unsupported writeMode %v for %v, must be %v or %v", s.WriteMode, s.FileName, WriteModeAppend, WriteModeOverwrite)
+	}
+	return nil
+}
+
+// writeMode returns how the object writes a file shared with other objects: WriteModeAppend,
+// WriteModeOverwrite or empty for objects not sharing their file.
+func (s Secret) writeMode() string {
+	if s.Concat {
+		return WriteModeAppend
+	}
+	return s.WriteMode
+}
+
+// validateWriteModes rejects objects sharing a file with conflicting write modes, e.g. one
+// appending to a file another one overwrites, which would mount a file depending on the order
+// the objects happen to be merged in.
+func (c *Config) validateWriteModes() error {
+	modes := make(map[string]string)
+	for _, secret := range c.Secrets {
+		if secret.FileName == "" {
+			continue
+		}
+		p := secret.MountPath(secret.FileName)
+		mode, ok := modes[p]
+		if !ok {
+			modes[p] = secret.writeMode()
+			continue
+		}
+		if mode != secret.writeMode() {
+			return fmt.Errorf("objects writing %v set conflicting write modes %v, secretProviderClass: %v, objects sharing a file must all set writeMode %v or %v",
+				p, strings.Join(writeModeNames(mode, secret.writeMode()), " and "), c.SecretProviderClass, WriteModeAppend, WriteModeOverwrite)
+		}
+	}
+	return nil
+}
+
+func writeModeNames(modes ...string) []string {
+	names := make([]string, len(modes))
+	for i, mode := range modes {
+		names[i] = mode
+		if mode == "" {
+			names[i] = "unset"
+		}
+	}
+	return names
+}
+
+// validateOverwrite checks that an object overwriting a shared file mounts a single file.
+func (c *Config) validateOverwrite(secret Secret) error {
+	selection := strings.HasSuffix(secret.SecretPath, "/") || strings.HasSuffix(secret.SecretPath, "/*") || len(secret.Tags) > 0
+	switch {
+	case selection, secret.Explode, secret.PostProcessor != "":
+		return fmt.Errorf("object %v sets writeMode %v, secretProviderClass: %v, only single files can be overwritten, not a folder, tag selection, explode or postProcessor", secret.FileName, WriteModeOverwrite, c.SecretProviderClass)
+	case secret.TemplateOnly, secret.Metadata:
+		return fmt.Errorf("object %v sets writeMode %v with templateOnly or metadata, secretProviderClass: %v", secret.FileName, WriteModeOverwrite, c.SecretProviderClass)
+	}
+	return nil
+}
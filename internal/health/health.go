@@ -0,0 +1,116 @@
+// Package health serves the health and metrics endpoints of the provider.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+)
+
+// Readiness returns nil once the provider is ready to serve mounts, nil Readiness is always ready.
+type Readiness func() error
+
+// Detail returns a named part of the state reported in the body of the readiness endpoint,
+// e.g. the warm-up state of the provider.
+type Detail func() (name string, value interface{})
+
+// NewHandler returns the handler of the health and metrics endpoints. Neither of them may
+// reach out to Akeyless, so that monitoring keeps working while Akeyless is unreachable.
+// With details, the readiness endpoint answers with a JSON body holding them, see writeReadyBody.
+func NewHandler(ready Readiness, details ...Detail) http.Handler {
+	mux := http.NewServeMux()
+	// liveness must not depend on readiness, or a gated provider would be restarted in a loop
+	mux.HandleFunc("/health/live", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if ready != nil {
+			err = ready()
+		}
+		if len(details) > 0 {
+			writeReadyBody(w, err, details)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+// writeReadyBody answers a readiness probe with {"ready": ..., "error": ..., <details>...}.
+func writeReadyBody(w http.ResponseWriter, err error, details []Detail) {
+	body := map[string]interface{}{"ready": err == nil}
+	if err != nil {
+		body["error"] = err.Error()
+	}
+	for _, detail := range details {
+		name, value := detail()
+		body[name] = value
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// Gate keeps the provider not ready until a check succeeded once, e.g. the node-level default
+// credential authenticated, so pods aren't scheduled to a node on which every mount would fail.
+type Gate struct {
+	mu      sync.Mutex
+	passed  bool
+	lastErr error
+}
+
+// NewGate returns a gate that is closed until Run passes.
+func NewGate() *Gate {
+	return &Gate{lastErr: errors.New("initial check has not completed yet")}
+}
+
+// Ready implements Readiness.
+func (g *Gate) Ready() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.passed {
+		return nil
+	}
+	return g.lastErr
+}
+
+// Run retries check every interval until it succeeds once or ctx is done. Readiness is served
+// from the last result, so probes never wait on the check itself.
+func (g *Gate) Run(ctx context.Context, check func(ctx context.Context) error, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			err := check(ctx)
+			g.mu.Lock()
+			g.passed, g.lastErr = err == nil, err
+			g.mu.Unlock()
+			if err == nil {
+				log.Print("initial readiness check passed")
+				return
+			}
+			log.Printf("initial readiness check failed, retrying in %v: %v", interval, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
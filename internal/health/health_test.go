@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_NoGatewayCalls(t *testing.T) {
+	var calls int32
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer gw.Close()
+
+	h := NewHandler(nil)
+	for _, path := range []string{"/health/live", "/health/ready", "/metrics"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+	}
+	require.Zero(t, atomic.LoadInt32(&calls))
+}
+
+func TestGate(t *testing.T) {
+	g := NewGate()
+	h := NewHandler(g.Ready)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var attempts int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g.Run(ctx, func(ctx context.Context) error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return errors.New("gateway unreachable")
+		}
+		return nil
+	}, time.Millisecond)
+
+	require.Eventually(t, func() bool { return g.Ready() == nil }, 5*time.Second, time.Millisecond)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestHandler_ReadyDetails(t *testing.T) {
+	var err error
+	h := NewHandler(func() error { return err }, func() (string, interface{}) {
+		return "warmUp", map[string]interface{}{"active": true, "limit": 8}
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"ready": true, "warmUp": {"active": true, "limit": 8}}`, rec.Body.String())
+
+	err = errors.New("initial check has not completed yet")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.JSONEq(t, `{"ready": false, "error": "initial check has not completed yet", "warmUp": {"active": true, "limit": 8}}`, rec.Body.String())
+}
@@ -0,0 +1,189 @@
+// Package jks encodes certificates and their private key as Java KeyStore (JKS) files, for JVM
+// workloads predating PKCS#12 keystores or configured for JKS explicitly.
+//
+// Keys are protected with the store password using Sun's proprietary JKS key protector, the
+// only key protection JKS supports, which is why PKCS#12 is preferable where it is an option.
+package jks
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	magic                 = 0xfeedfeed
+	version               = 2
+	tagPrivateKey         = 1
+	tagTrustedCertificate = 2
+	certificateType       = "X.509"
+	// integrityWhitener is mixed into the store digest by the JDK, see sun.security.provider.JavaKeyStore
+	integrityWhitener = "Mighty Aphrodite"
+	saltLength        = 20
+)
+
+// oidKeyProtector identifies the JKS key protector, sun.security.provider.KeyProtector
+var oidKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+var errNoCertificates = errors.New("no certificates to encode")
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// Keystore returns a JKS file holding the key and certs, the leaf certificate first followed by
+// its chain, as one private key entry named alias. The key and store are protected by password.
+func Keystore(key crypto.PrivateKey, certs []*x509.Certificate, password, alias string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errNoCertificates
+	}
+	protected, err := protectKey(key, password)
+	if err != nil {
+		return nil, err
+	}
+
+	w := newStore(1)
+	w.entryHeader(tagPrivateKey, alias)
+	w.bytes(protected)
+	w.int32(len(certs))
+	for _, cert := range certs {
+		w.certificate(cert)
+	}
+	return w.finish(password), nil
+}
+
+// Truststore returns a JKS file holding certs as trusted certificate entries, named alias for a
+// single certificate and alias-1, alias-2 and so on otherwise, protected by password.
+func Truststore(certs []*x509.Certificate, password, alias string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errNoCertificates
+	}
+
+	w := newStore(len(certs))
+	for i, cert := range certs {
+		name := alias
+		if len(certs) > 1 {
+			name = fmt.Sprintf("%s-%d", alias, i+1)
+		}
+		w.entryHeader(tagTrustedCertificate, name)
+		w.certificate(cert)
+	}
+	return w.finish(password), nil
+}
+
+type storeWriter struct {
+	buf bytes.Buffer
+	now int64
+}
+
+func newStore(entries int) *storeWriter {
+	w := &storeWriter{now: time.Now().UnixMilli()}
+	w.uint32(magic)
+	w.uint32(version)
+	w.int32(entries)
+	return w
+}
+
+func (w *storeWriter) uint32(v uint32) {
+	_ = binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *storeWriter) int32(v int) {
+	w.uint32(uint32(v))
+}
+
+func (w *storeWriter) bytes(b []byte) {
+	w.int32(len(b))
+	w.buf.Write(b)
+}
+
+// utf writes s the way java.io.DataOutput.writeUTF does, in modified UTF-8.
+func (w *storeWriter) utf(s string) {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		switch {
+		case c >= 0x01 && c <= 0x7f:
+			b = append(b, byte(c))
+		case c <= 0x7ff:
+			b = append(b, byte(0xc0|c>>6), byte(0x80|c&0x3f))
+		default:
+			b = append(b, byte(0xe0|c>>12), byte(0x80|(c>>6)&0x3f), byte(0x80|c&0x3f))
+		}
+	}
+	_ = binary.Write(&w.buf, binary.BigEndian, uint16(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *storeWriter) entryHeader(tag int, alias string) {
+	w.int32(tag)
+	// the JDK looks entries up by their lower case alias
+	w.utf(strings.ToLower(alias))
+	_ = binary.Write(&w.buf, binary.BigEndian, w.now)
+}
+
+func (w *storeWriter) certificate(cert *x509.Certificate) {
+	w.utf(certificateType)
+	w.bytes(cert.Raw)
+}
+
+// finish appends the store digest, the SHA-1 of the password, the whitener and the store.
+func (w *storeWriter) finish(password string) []byte {
+	h := sha1.New()
+	h.Write(passwordBytes(password))
+	h.Write([]byte(integrityWhitener))
+	h.Write(w.buf.Bytes())
+	return h.Sum(w.buf.Bytes())
+}
+
+// protectKey encrypts the PKCS#8 encoding of key with the JKS key protector: the key is XORed
+// with a keystream of chained SHA-1 digests of the password and a random salt, followed by a
+// SHA-1 of the password and plain key for integrity.
+func protectKey(key crypto.PrivateKey, password string) ([]byte, error) {
+	plain, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltLength)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+	pw := passwordBytes(password)
+
+	encrypted := make([]byte, 0, saltLength+len(plain)+sha1.Size)
+	encrypted = append(encrypted, salt...)
+	digest := salt
+	for i := 0; i < len(plain); i += sha1.Size {
+		sum := sha1.Sum(append(append([]byte{}, pw...), digest...))
+		digest = sum[:]
+		for j := 0; j < sha1.Size && i+j < len(plain); j++ {
+			encrypted = append(encrypted, plain[i+j]^digest[j])
+		}
+	}
+	check := sha1.Sum(append(pw, plain...))
+	encrypted = append(encrypted, check[:]...)
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData: encrypted,
+	})
+}
+
+// passwordBytes returns password as the JDK feeds char arrays to digests, two bytes per UTF-16 unit.
+func passwordBytes(password string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(password)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return b
+}
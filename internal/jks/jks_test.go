@@ -0,0 +1,165 @@
+package jks
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func selfSigned(t *testing.T, cn string) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: cn}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, cert
+}
+
+type entry struct {
+	tag   uint32
+	alias string
+	key   []byte
+	certs [][]byte
+}
+
+// readStore parses a JKS file the way sun.security.provider.JavaKeyStore loads it.
+func readStore(t *testing.T, data []byte, password string) []entry {
+	require.Greater(t, len(data), sha1.Size)
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	h := sha1.New()
+	h.Write(passwordBytes(password))
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(body)
+	require.Equal(t, h.Sum(nil), digest, "store digest")
+
+	r := bytes.NewReader(body)
+	u32 := func() uint32 {
+		var v uint32
+		require.NoError(t, binary.Read(r, binary.BigEndian, &v))
+		return v
+	}
+	utf := func() string {
+		var n uint16
+		require.NoError(t, binary.Read(r, binary.BigEndian, &n))
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		require.NoError(t, err)
+		return string(b)
+	}
+	blob := func() []byte {
+		b := make([]byte, u32())
+		_, err := io.ReadFull(r, b)
+		require.NoError(t, err)
+		return b
+	}
+	cert := func() []byte {
+		require.Equal(t, "X.509", utf())
+		return blob()
+	}
+
+	require.Equal(t, uint32(0xfeedfeed), u32())
+	require.Equal(t, uint32(2), u32())
+	var entries []entry
+	for n := u32(); n > 0; n-- {
+		e := entry{tag: u32(), alias: utf()}
+		var ts int64
+		require.NoError(t, binary.Read(r, binary.BigEndian, &ts))
+		require.InDelta(t, time.Now().UnixMilli(), ts, float64(time.Minute.Milliseconds()))
+		if e.tag == tagPrivateKey {
+			e.key = blob()
+			for c := u32(); c > 0; c-- {
+				e.certs = append(e.certs, cert())
+			}
+		} else {
+			e.certs = append(e.certs, cert())
+		}
+		entries = append(entries, e)
+	}
+	require.Zero(t, r.Len())
+	return entries
+}
+
+// recoverKey reverses the JKS key protector, sun.security.provider.KeyProtector.
+func recoverKey(t *testing.T, protected []byte, password string) []byte {
+	var info encryptedPrivateKeyInfo
+	_, err := asn1.Unmarshal(protected, &info)
+	require.NoError(t, err)
+	require.Equal(t, oidKeyProtector, info.Algorithm.Algorithm)
+
+	data := info.EncryptedData
+	salt, encrypted, check := data[:saltLength], data[saltLength:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	pw := passwordBytes(password)
+	plain := make([]byte, len(encrypted))
+	digest := salt
+	for i := range encrypted {
+		if i%sha1.Size == 0 {
+			sum := sha1.Sum(append(append([]byte{}, pw...), digest...))
+			digest = sum[:]
+		}
+		plain[i] = encrypted[i] ^ digest[i%sha1.Size]
+	}
+	sum := sha1.Sum(append(append([]byte{}, pw...), plain...))
+	require.Equal(t, sum[:], check, "key integrity check")
+	return plain
+}
+
+func TestKeystore(t *testing.T) {
+	key, leaf := selfSigned(t, "web")
+	_, ca := selfSigned(t, "ca")
+	password := "pässword"
+
+	out, err := Keystore(key, []*x509.Certificate{leaf, ca}, password, "Web")
+	require.NoError(t, err)
+
+	entries := readStore(t, out, password)
+	require.Len(t, entries, 1)
+	require.Equal(t, uint32(tagPrivateKey), entries[0].tag)
+	require.Equal(t, "web", entries[0].alias)
+	require.Equal(t, [][]byte{leaf.Raw, ca.Raw}, entries[0].certs)
+
+	parsed, err := x509.ParsePKCS8PrivateKey(recoverKey(t, entries[0].key, password))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	_, err = Keystore(key, nil, password, "web")
+	require.Error(t, err)
+}
+
+func TestTruststore(t *testing.T) {
+	_, ca1 := selfSigned(t, "ca1")
+	_, ca2 := selfSigned(t, "ca2")
+
+	out, err := Truststore([]*x509.Certificate{ca1, ca2}, "changeit", "web-ca")
+	require.NoError(t, err)
+	entries := readStore(t, out, "changeit")
+	require.Len(t, entries, 2)
+	for i, e := range entries {
+		require.Equal(t, uint32(tagTrustedCertificate), e.tag)
+		require.Equal(t, []string{"web-ca-1", "web-ca-2"}[i], e.alias)
+	}
+	require.Equal(t, ca2.Raw, entries[1].certs[0])
+
+	out, err = Truststore([]*x509.Certificate{ca1}, "changeit", "web-ca")
+	require.NoError(t, err)
+	require.Equal(t, "web-ca", readStore(t, out, "changeit")[0].alias)
+}
+
+func TestModifiedUTF8(t *testing.T) {
+	w := &storeWriter{}
+	w.utf("a\x00é€😀")
+	require.Equal(t, []byte{0, 14, 'a', 0xc0, 0x80, 0xc3, 0xa9, 0xe2, 0x82, 0xac, 0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80}, w.buf.Bytes())
+}
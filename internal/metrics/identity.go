@@ -0,0 +1,50 @@
+package metrics
+
+import "sync"
+
+// OtherIdentity is the namespace and service account label value of the identities
+// beyond the identity limit.
+const OtherIdentity = "other"
+
+// identityGuard bounds the number of namespace/service account label pairs, since every
+// pair creates its own series in each labeled metric.
+type identityGuard struct {
+	mu    sync.Mutex
+	limit int
+	seen  map[string]bool
+}
+
+var identities = &identityGuard{limit: 100, seen: make(map[string]bool)}
+
+// SetIdentityLimit sets how many distinct namespace/service account pairs are labeled, the
+// following ones are all labeled OtherIdentity. 0 disables the namespace and service account labels.
+func SetIdentityLimit(limit int) {
+	identities.mu.Lock()
+	defer identities.mu.Unlock()
+
+	identities.limit = limit
+	identities.seen = make(map[string]bool)
+}
+
+// Identity returns the namespace and service account label values of a pod.
+func Identity(namespace, serviceAccount string) (string, string) {
+	return identities.labels(namespace, serviceAccount)
+}
+
+func (g *identityGuard) labels(namespace, serviceAccount string) (string, string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.limit <= 0 {
+		return "", ""
+	}
+	key := namespace + "/" + serviceAccount
+	if g.seen[key] {
+		return namespace, serviceAccount
+	}
+	if len(g.seen) >= g.limit {
+		return OtherIdentity, OtherIdentity
+	}
+	g.seen[key] = true
+	return namespace, serviceAccount
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityGuard(t *testing.T) {
+	g := &identityGuard{limit: 2, seen: make(map[string]bool)}
+
+	ns, sa := g.labels("team-a", "default")
+	require.Equal(t, []string{"team-a", "default"}, []string{ns, sa})
+	ns, sa = g.labels("team-b", "app")
+	require.Equal(t, []string{"team-b", "app"}, []string{ns, sa})
+
+	ns, sa = g.labels("team-c", "app")
+	require.Equal(t, []string{OtherIdentity, OtherIdentity}, []string{ns, sa})
+
+	// already seen identities keep their labels
+	ns, sa = g.labels("team-a", "default")
+	require.Equal(t, []string{"team-a", "default"}, []string{ns, sa})
+
+	g.limit = 0
+	ns, sa = g.labels("team-a", "default")
+	require.Equal(t, []string{"", ""}, []string{ns, sa})
+}
@@ -0,0 +1,268 @@
+// Package metrics implements the provider's metrics registry, exposed for scraping in the
+// Prometheus text format and optionally pushed to a Pushgateway or OTLP endpoint.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type kind string
+
+const (
+	counterKind   kind = "counter"
+	histogramKind kind = "histogram"
+)
+
+// DefaultBuckets are the histogram buckets used for latencies, in seconds.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Registry holds all registered metrics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []collector
+}
+
+// Default is the registry served on /metrics and pushed by the pusher.
+var Default = &Registry{}
+
+type collector interface {
+	collect() family
+}
+
+// family is a point-in-time snapshot of one metric and all of its series.
+type family struct {
+	name   string
+	help   string
+	kind   kind
+	series []series
+}
+
+type series struct {
+	labels  []labelPair
+	value   float64
+	buckets []float64 // cumulative counts per upper bound, histograms only
+	bounds  []float64
+	sum     float64
+	count   uint64
+}
+
+type labelPair struct {
+	name  string
+	value string
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, c)
+}
+
+func (r *Registry) gather() []family {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.metrics...)
+	r.mu.Unlock()
+
+	families := make([]family, 0, len(collectors))
+	for _, c := range collectors {
+		families = append(families, c.collect())
+	}
+	return families
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels.
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec creates a counter registered in the Default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*counterValue)}
+	Default.register(c)
+	return c
+}
+
+// Inc increments the counter of the given label values, in the order the labels were declared.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cv, ok := c.values[key]
+	if !ok {
+		cv = &counterValue{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = cv
+	}
+	cv.value += v
+}
+
+func (c *CounterVec) collect() family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := family{name: c.name, help: c.help, kind: counterKind}
+	for _, cv := range c.values {
+		f.series = append(f.series, series{labels: pairs(c.labels, cv.labelValues), value: cv.value})
+	}
+	sortSeries(f.series)
+	return f
+}
+
+// HistogramVec samples observations into buckets, partitioned by labels.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// NewHistogramVec creates a histogram registered in the Default registry.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramValue)}
+	Default.register(h)
+	return h
+}
+
+// Observe records v for the given label values, in the order the labels were declared.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			hv.counts[i]++
+		}
+	}
+	hv.sum += v
+	hv.count++
+}
+
+func (h *HistogramVec) collect() family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f := family{name: h.name, help: h.help, kind: histogramKind}
+	for _, hv := range h.values {
+		buckets := make([]float64, len(hv.counts))
+		for i, c := range hv.counts {
+			buckets[i] = float64(c)
+		}
+		f.series = append(f.series, series{
+			labels:  pairs(h.labels, hv.labelValues),
+			buckets: buckets,
+			bounds:  h.buckets,
+			sum:     hv.sum,
+			count:   hv.count,
+		})
+	}
+	sortSeries(f.series)
+	return f
+}
+
+func pairs(names, values []string) []labelPair {
+	p := make([]labelPair, len(names))
+	for i, name := range names {
+		if i < len(values) {
+			p[i] = labelPair{name: name, value: values[i]}
+		} else {
+			p[i] = labelPair{name: name}
+		}
+	}
+	return p
+}
+
+func sortSeries(s []series) {
+	sort.Slice(s, func(i, j int) bool {
+		return formatLabels(s[i].labels, "", "") < formatLabels(s[j].labels, "", "")
+	})
+}
+
+// WritePrometheus writes all metrics of the registry in the Prometheus text exposition format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+	for _, f := range r.gather() {
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.kind)
+		for _, s := range f.series {
+			switch f.kind {
+			case counterKind:
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, formatLabels(s.labels, "", ""), formatFloat(s.value))
+			case histogramKind:
+				for i, bound := range s.bounds {
+					fmt.Fprintf(&b, "%s_bucket%s %s\n", f.name, formatLabels(s.labels, "le", formatFloat(bound)), formatFloat(s.buckets[i]))
+				}
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, formatLabels(s.labels, "le", "+Inf"), s.count)
+				fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, formatLabels(s.labels, "", ""), formatFloat(s.sum))
+				fmt.Fprintf(&b, "%s_count%s %d\n", f.name, formatLabels(s.labels, "", ""), s.count)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatLabels(labels []labelPair, extraName, extraValue string) string {
+	if len(labels) == 0 && extraName == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(labels)+1)
+	for _, l := range labels {
+		parts = append(parts, fmt.Sprintf("%s=%q", l.name, l.value))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf("%s=%q", extraName, extraValue))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+// Handler serves the Default registry for Prometheus scraping.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = Default.WritePrometheus(w)
+	})
+}
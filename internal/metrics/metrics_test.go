@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := &Registry{}
+	c := &CounterVec{name: "requests_total", help: "Requests.", labels: []string{"result"}, values: map[string]*counterValue{}}
+	h := &HistogramVec{name: "duration_seconds", help: "Duration.", buckets: []float64{1, 5}, values: map[string]*histogramValue{}}
+	r.register(c)
+	r.register(h)
+
+	c.Inc("success")
+	c.Inc("success")
+	h.Observe(2)
+
+	var b strings.Builder
+	require.NoError(t, r.WritePrometheus(&b))
+	require.Equal(t, `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{result="success"} 2
+# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="1"} 0
+duration_seconds_bucket{le="5"} 1
+duration_seconds_bucket{le="+Inf"} 1
+duration_seconds_sum 2
+duration_seconds_count 1
+`, b.String())
+}
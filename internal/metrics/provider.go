@@ -0,0 +1,40 @@
+package metrics
+
+const namespace = "akeyless_csi_provider"
+
+var (
+	MountRequests = NewCounterVec(namespace+"_mount_requests_total",
+		"Number of mount requests handled.", "secret_provider_class", "namespace", "service_account", "result")
+	MountDuration = NewHistogramVec(namespace+"_mount_duration_seconds",
+		"Duration of mount requests.", DefaultBuckets, "secret_provider_class", "namespace", "service_account")
+	SecretFetches = NewCounterVec(namespace+"_secret_fetches_total",
+		"Number of secret values fetched from Akeyless.", "secret_provider_class", "namespace", "service_account", "item_type", "result")
+	RotationObjectFailures = NewCounterVec(namespace+"_rotation_object_failures_total",
+		"Number of objects that failed during a rotation remount and kept their previous value.", "secret_provider_class")
+	SuspiciousValues = NewCounterVec(namespace+"_suspicious_values_total",
+		"Number of mounted values that look misconfigured, such as empty, placeholder or truncated PEM values.", "secret_provider_class", "reason")
+	MountStorms = NewCounterVec(namespace+"_mount_storms_total",
+		"Number of minutes exceeding the mount storm threshold of mount requests.")
+	MountRequestsPerWindow = NewHistogramVec(namespace+"_mount_requests_per_minute",
+		"Number of mount requests within each minute having any, for capacity planning of gateways.", []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500})
+	DescribeFallbacks = NewCounterVec(namespace+"_describe_fallbacks_total",
+		"Number of throttled item describes served from the metadata of an earlier describe.", "secret_provider_class")
+	CanaryProbes = NewCounterVec(namespace+"_canary_probes_total",
+		"Number of canary item fetches, verifying authentication and the gateway path without mounts.", "result")
+	CanaryDuration = NewHistogramVec(namespace+"_canary_probe_duration_seconds",
+		"Duration of canary item fetches, including authentication.", DefaultBuckets)
+	AccessTypeProbes = NewCounterVec(namespace+"_access_type_probes_total",
+		"Number of authentication attempts of access type detection.", "access_type", "result")
+	AccessTypeProbeDuration = NewHistogramVec(namespace+"_access_type_probe_duration_seconds",
+		"Duration of authentication attempts of access type detection.", DefaultBuckets, "access_type")
+	WarmUpWaits = NewHistogramVec(namespace+"_warm_up_wait_seconds",
+		"Time mount requests waited for a slot of the warm-up ramp after the provider started.", DefaultBuckets)
+)
+
+// Result returns the result label matching err.
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PushFormatPushgateway pushes the Prometheus text format to a Pushgateway.
+	PushFormatPushgateway = "pushgateway"
+	// PushFormatOTLP pushes OTLP/HTTP JSON to an OpenTelemetry metrics endpoint.
+	PushFormatOTLP = "otlp"
+)
+
+// PushConfig configures pushing metrics for nodes that can't be scraped.
+type PushConfig struct {
+	URL      string
+	Format   string
+	Interval time.Duration
+	Job      string
+	Instance string
+}
+
+// StartPusher pushes the Default registry every interval until ctx is done.
+func StartPusher(ctx context.Context, cfg PushConfig) error {
+	if cfg.Format != PushFormatPushgateway && cfg.Format != PushFormatOTLP {
+		return fmt.Errorf("unsupported metrics push format %v", cfg.Format)
+	}
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("invalid metrics push interval %v", cfg.Interval)
+	}
+	if _, err := url.Parse(cfg.URL); err != nil {
+		return fmt.Errorf("invalid metrics push url %v: %w", cfg.URL, err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := push(ctx, client, cfg); err != nil {
+					log.Printf("failed to push metrics to %v, error: %v", cfg.URL, err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func push(ctx context.Context, client *http.Client, cfg PushConfig) error {
+	var (
+		req *http.Request
+		err error
+	)
+
+	switch cfg.Format {
+	case PushFormatPushgateway:
+		var body bytes.Buffer
+		if err = Default.WritePrometheus(&body); err != nil {
+			return err
+		}
+		target := strings.TrimSuffix(cfg.URL, "/") + "/metrics/job/" + url.PathEscape(cfg.Job)
+		if cfg.Instance != "" {
+			target += "/instance/" + url.PathEscape(cfg.Instance)
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, target, &body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	case PushFormatOTLP:
+		body, err := json.Marshal(otlpPayload(Default.gather(), cfg))
+		if err != nil {
+			return err
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// otlpPayload converts the families into an OTLP ExportMetricsServiceRequest in its JSON encoding.
+func otlpPayload(families []family, cfg PushConfig) map[string]interface{} {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	var metrics []map[string]interface{}
+	for _, f := range families {
+		var points []map[string]interface{}
+		for _, s := range f.series {
+			point := map[string]interface{}{
+				"attributes":   otlpAttributes(s.labels),
+				"timeUnixNano": now,
+			}
+			switch f.kind {
+			case counterKind:
+				point["asDouble"] = s.value
+			case histogramKind:
+				// OTLP bucket counts are per bucket rather than cumulative
+				counts := make([]string, 0, len(s.buckets)+1)
+				prev := 0.0
+				for _, c := range s.buckets {
+					counts = append(counts, strconv.FormatFloat(c-prev, 'f', 0, 64))
+					prev = c
+				}
+				counts = append(counts, strconv.FormatFloat(float64(s.count)-prev, 'f', 0, 64))
+				point["count"] = strconv.FormatUint(s.count, 10)
+				point["sum"] = s.sum
+				point["explicitBounds"] = s.bounds
+				point["bucketCounts"] = counts
+			}
+			points = append(points, point)
+		}
+
+		metric := map[string]interface{}{"name": f.name, "description": f.help}
+		switch f.kind {
+		case counterKind:
+			metric["sum"] = map[string]interface{}{"dataPoints": points, "aggregationTemporality": 2, "isMonotonic": true}
+		case histogramKind:
+			metric["histogram"] = map[string]interface{}{"dataPoints": points, "aggregationTemporality": 2}
+		}
+		metrics = append(metrics, metric)
+	}
+
+	resource := otlpAttributes([]labelPair{{name: "service.name", value: cfg.Job}, {name: "service.instance.id", value: cfg.Instance}})
+	return map[string]interface{}{
+		"resourceMetrics": []map[string]interface{}{{
+			"resource":     map[string]interface{}{"attributes": resource},
+			"scopeMetrics": []map[string]interface{}{{"scope": map[string]interface{}{"name": "akeyless-csi-provider"}, "metrics": metrics}},
+		}},
+	}
+}
+
+func otlpAttributes(labels []labelPair) []map[string]interface{} {
+	attrs := make([]map[string]interface{}, 0, len(labels))
+	for _, l := range labels {
+		attrs = append(attrs, map[string]interface{}{"key": l.name, "value": map[string]string{"stringValue": l.value}})
+	}
+	return attrs
+}
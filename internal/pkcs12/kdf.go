@@ -0,0 +1,57 @@
+package pkcs12
+
+import (
+	"hash"
+)
+
+// deriveKey is the PKCS#12 key derivation of RFC 7292 appendix B.2, with v the block size of the
+// hash in bytes and id 1 for encryption keys, 2 for IVs and 3 for MAC keys.
+func deriveKey(newHash func() hash.Hash, v int, id byte, password, salt []byte, iterations, size int) []byte {
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	i := append(repeatTo(salt, v), repeatTo(password, v)...)
+
+	var out []byte
+	for len(out) < size {
+		h := newHash()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// every v byte block of i becomes (block + b + 1) mod 2^(8v), b being a repeated to v bytes
+		b := make([]byte, v)
+		for j := range b {
+			b[j] = a[j%len(a)]
+		}
+		for block := 0; block < len(i); block += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(i[block+k]) + int(b[k]) + carry
+				i[block+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// repeatTo repeats s to the next multiple of v bytes, empty for an empty s.
+func repeatTo(s []byte, v int) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	n := v * ((len(s) + v - 1) / v)
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = s[i%len(s)]
+	}
+	return out
+}
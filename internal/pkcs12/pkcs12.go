@@ -0,0 +1,293 @@
+// Package pkcs12 encodes certificates and their private key as PKCS#12 (.p12/.pfx) files, for
+// JVM and Windows workloads consuming them directly.
+//
+// Files are protected the way OpenSSL 3 does by default: the key is encrypted with
+// PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC) and the file integrity protected with an
+// HMAC-SHA256 MAC. Certificates aren't secret and are stored unencrypted.
+package pkcs12
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// iterations of the key and MAC derivations, OpenSSL's default
+	iterations = 2048
+	saltLength = 16
+)
+
+var (
+	oidData            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidShroudedKeyBag  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256  = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	asn1NULL           = asn1.RawValue{Tag: asn1.TagNull}
+	errNoCertificates  = errors.New("no certificates to encode")
+)
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	// Content is the [0] EXPLICIT content, see explicit
+	Content asn1.RawValue
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID asn1.ObjectIdentifier
+	// Value is the [0] EXPLICIT bag, see explicit
+	Value      asn1.RawValue
+	Attributes []attribute `asn1:"set"`
+}
+
+type attribute struct {
+	ID asn1.ObjectIdentifier
+	// Value is the SET of the attribute's values, see setOf
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Data      []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	PRF        pkix.AlgorithmIdentifier
+}
+
+// Encode returns a PKCS#12 file holding the key and certs, the leaf certificate first followed
+// by its chain, protected by password. The key and leaf certificate are named friendlyName.
+func Encode(key crypto.PrivateKey, certs []*x509.Certificate, password, friendlyName string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errNoCertificates
+	}
+
+	localKeyID := sha1.Sum(certs[0].Raw)
+	leafAttributes, err := bagAttributes(localKeyID[:], friendlyName)
+	if err != nil {
+		return nil, err
+	}
+
+	var certBags []safeBag
+	for i, cert := range certs {
+		bag, err := asn1.Marshal(certBag{ID: oidX509Certificate, Data: cert.Raw})
+		if err != nil {
+			return nil, err
+		}
+		sb := safeBag{ID: oidCertBag, Value: explicit(bag)}
+		if i == 0 {
+			sb.Attributes = leafAttributes
+		}
+		certBags = append(certBags, sb)
+	}
+
+	shroudedKey, err := encryptKey(key, password)
+	if err != nil {
+		return nil, err
+	}
+	keyBags := []safeBag{{ID: oidShroudedKeyBag, Value: explicit(shroudedKey), Attributes: leafAttributes}}
+
+	var authSafe []contentInfo
+	for _, bags := range [][]safeBag{certBags, keyBags} {
+		ci, err := dataContentInfo(bags)
+		if err != nil {
+			return nil, err
+		}
+		authSafe = append(authSafe, ci)
+	}
+	authSafeBytes, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := computeMAC(authSafeBytes, password)
+	if err != nil {
+		return nil, err
+	}
+	content, err := asn1.Marshal(authSafeBytes)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pfx{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidData, Content: explicit(content)},
+		MacData:  mac,
+	})
+}
+
+func bagAttributes(localKeyID []byte, friendlyName string) ([]attribute, error) {
+	id, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	attributes := []attribute{{ID: oidLocalKeyID, Value: asn1.RawValue{FullBytes: setOf(id)}}}
+	if friendlyName == "" {
+		return attributes, nil
+	}
+	name, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpString(friendlyName, false)})
+	if err != nil {
+		return nil, err
+	}
+	return append(attributes, attribute{ID: oidFriendlyName, Value: asn1.RawValue{FullBytes: setOf(name)}}), nil
+}
+
+// explicit wraps a DER encoded value into a [0] EXPLICIT tag. Struct tags can't do it for raw
+// values, whose encoding encoding/asn1 writes as is.
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// setOf wraps a single DER encoded value into a SET.
+func setOf(der []byte) []byte {
+	out, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der})
+	return out
+}
+
+func dataContentInfo(bags []safeBag) (contentInfo, error) {
+	contents, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	data, err := asn1.Marshal(contents)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidData, Content: explicit(data)}, nil
+}
+
+// EncryptPrivateKey returns the key as a PKCS#8 EncryptedPrivateKeyInfo protected by password,
+// the DER of an "ENCRYPTED PRIVATE KEY" PEM block. Keys are encrypted as in PKCS#12 files.
+func EncryptPrivateKey(key crypto.PrivateKey, password string) ([]byte, error) {
+	return encryptKey(key, password)
+}
+
+// encryptKey returns the key as a PBES2 EncryptedPrivateKeyInfo.
+func encryptKey(key crypto.PrivateKey, password string) ([]byte, error) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported private key: %w", err)
+	}
+
+	salt := make([]byte, saltLength)
+	iv := make([]byte, aes.BlockSize)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err = rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(password), salt, iterations, 32, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(pkcs8)%aes.BlockSize
+	plaintext := append(pkcs8, make([]byte, padding)...)
+	for i := len(pkcs8); i < len(plaintext); i++ {
+		plaintext[i] = byte(padding)
+	}
+	encrypted := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plaintext)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1NULL},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		Data:      encrypted,
+	})
+}
+
+func computeMAC(content []byte, password string) (macData, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return macData{}, err
+	}
+	key := deriveKey(sha256.New, 64, 3, bmpString(password, true), salt, iterations, sha256.Size)
+	h := hmac.New(sha256.New, key)
+	h.Write(content)
+	return macData{
+		Mac: digestInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1NULL},
+			Digest:    h.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: iterations,
+	}, nil
+}
+
+// bmpString encodes s as UTF-16BE, the MAC key derivation expects it null terminated.
+func bmpString(s string, terminated bool) []byte {
+	var out []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(c>>8), byte(c))
+	}
+	if terminated {
+		out = append(out, 0, 0)
+	}
+	return out
+}
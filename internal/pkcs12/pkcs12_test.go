@@ -0,0 +1,142 @@
+package pkcs12
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func selfSigned(t *testing.T, cn string) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: cn}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, cert
+}
+
+// unwrap returns the contents of a [0] EXPLICIT raw value.
+func unwrap(t *testing.T, v asn1.RawValue) []byte {
+	require.Equal(t, asn1.ClassContextSpecific, v.Class)
+	return v.Bytes
+}
+
+func TestEncode(t *testing.T) {
+	key, leaf := selfSigned(t, "web")
+	_, ca := selfSigned(t, "ca")
+	password := "pässword"
+
+	out, err := Encode(key, []*x509.Certificate{leaf, ca}, password, "web")
+	require.NoError(t, err)
+
+	var p pfx
+	_, err = asn1.Unmarshal(out, &p)
+	require.NoError(t, err)
+	require.Equal(t, 3, p.Version)
+	var authSafeBytes []byte
+	_, err = asn1.Unmarshal(unwrap(t, p.AuthSafe.Content), &authSafeBytes)
+	require.NoError(t, err)
+
+	// the MAC verifies with the password only
+	macKey := deriveKey(sha256.New, 64, 3, bmpString(password, true), p.MacData.MacSalt, p.MacData.Iterations, 32)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(authSafeBytes)
+	require.Equal(t, mac.Sum(nil), p.MacData.Mac.Digest)
+	wrongKey := deriveKey(sha256.New, 64, 3, bmpString("wrong", true), p.MacData.MacSalt, p.MacData.Iterations, 32)
+	require.NotEqual(t, macKey, wrongKey)
+
+	var authSafe []contentInfo
+	_, err = asn1.Unmarshal(authSafeBytes, &authSafe)
+	require.NoError(t, err)
+	require.Len(t, authSafe, 2)
+
+	bags := func(ci contentInfo) []safeBag {
+		var contents []byte
+		_, err := asn1.Unmarshal(unwrap(t, ci.Content), &contents)
+		require.NoError(t, err)
+		var bags []safeBag
+		_, err = asn1.Unmarshal(contents, &bags)
+		require.NoError(t, err)
+		return bags
+	}
+
+	certBags := bags(authSafe[0])
+	require.Len(t, certBags, 2)
+	for i, want := range []*x509.Certificate{leaf, ca} {
+		var cb certBag
+		_, err = asn1.Unmarshal(unwrap(t, certBags[i].Value), &cb)
+		require.NoError(t, err)
+		require.Equal(t, want.Raw, cb.Data)
+	}
+	require.Len(t, certBags[0].Attributes, 2, "the leaf is named and paired with the key")
+	require.Empty(t, certBags[1].Attributes)
+
+	keyBags := bags(authSafe[1])
+	require.Len(t, keyBags, 1)
+	require.Equal(t, certBags[0].Attributes, keyBags[0].Attributes)
+
+	require.True(t, key.Equal(decryptKey(t, unwrap(t, keyBags[0].Value), password)))
+
+	_, err = Encode(key, nil, password, "web")
+	require.Error(t, err)
+}
+
+func TestEncryptPrivateKey(t *testing.T) {
+	key, _ := selfSigned(t, "web")
+	der, err := EncryptPrivateKey(key, "passphrase")
+	require.NoError(t, err)
+	require.True(t, key.Equal(decryptKey(t, der, "passphrase")))
+}
+
+// decryptKey decrypts a PBES2 EncryptedPrivateKeyInfo.
+func decryptKey(t *testing.T, der []byte, password string) interface{} {
+	var epki encryptedPrivateKeyInfo
+	_, err := asn1.Unmarshal(der, &epki)
+	require.NoError(t, err)
+	require.True(t, epki.Algorithm.Algorithm.Equal(oidPBES2))
+	var params pbes2Params
+	_, err = asn1.Unmarshal(epki.Algorithm.Parameters.FullBytes, &params)
+	require.NoError(t, err)
+	var kdf pbkdf2Params
+	_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+	require.NoError(t, err)
+	var iv []byte
+	_, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(password), kdf.Salt, kdf.Iterations, 32, sha256.New))
+	require.NoError(t, err)
+	plaintext := make([]byte, len(epki.Data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, epki.Data)
+	plaintext = plaintext[:len(plaintext)-int(plaintext[len(plaintext)-1])]
+	decrypted, err := x509.ParsePKCS8PrivateKey(plaintext)
+	require.NoError(t, err)
+	return decrypted
+}
+
+func TestDeriveKey(t *testing.T) {
+	// vectors of golang.org/x/crypto/pkcs12, SHA-1 3DES key derivations
+	require.Equal(t,
+		[]byte("\x7c\xd9\xfd\x3e\x2b\x3b\xe7\x69\x1a\x44\xe3\xbe\xf0\xf9\xea\x0f\xb9\xb8\x97\xd4\xe3\x25\xd9\xd1"),
+		deriveKey(sha1.New, 64, 1, bmpString("sesame", true), []byte("\xff\xff\xff\xff\xff\xff\xff\xff"), 2048, 24))
+	// intermediate blocks with leading zero bytes
+	require.Equal(t,
+		[]byte("\x00\xf7\x59\xff\x47\xd1\x4d\xd0\x36\x65\xd5\x94\x3c\xb3\xc4\xa3\x9a\x25\x55\xc0\x2a\xed\x66\xe1"),
+		deriveKey(sha1.New, 64, 1, []byte("\x00\x00"), []byte("\xf3\x7e\x05\xb5\x18\x32\x4b\x4b"), 2048, 24))
+}
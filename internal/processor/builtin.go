@@ -0,0 +1,563 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JSONExplode is the name of the post-processor writing every top-level key of a JSON object to its own file.
+const JSONExplode = "json-explode"
+
+// CertificateFiles is the name of the post-processor splitting a certificate item into files.
+const CertificateFiles = "certificate-files"
+
+// DockerConfigJSON is the name of the post-processor rendering registry credentials as a .dockerconfigjson.
+const DockerConfigJSON = "dockerconfigjson"
+
+// DockerConfigRegistryArg is the secretArg naming the registry of the dockerconfigjson output.
+const DockerConfigRegistryArg = "registry"
+
+const (
+	// CertificateFormatArg is the secretArg selecting the encoding of the certificate-files output
+	CertificateFormatArg = "format"
+	// CertificateFormatDER writes DER encoded certificate and key files instead of PEM
+	CertificateFormatDER = "der"
+)
+
+func init() {
+	Register("pem-split", Func(pemSplit))
+	Register(JSONExplode, Func(jsonExplode))
+	Register("template", Func(renderTemplate))
+	Register("cert-key", Func(certKey))
+	Register(CertificateFiles, Func(certificateFiles))
+	Register("kubernetes-tls", Func(kubernetesTLS))
+	Register(DockerConfigJSON, Func(dockerConfigJSON))
+	Register("pgpass", Func(pgpass))
+	Register("my-cnf", Func(myCnf))
+	Register("kubeconfig", Func(kubeconfig))
+}
+
+// pemSplit writes every PEM block of the value to its own file, <fileName>/<n>.pem.
+func pemSplit(in Input) ([]File, error) {
+	var files []File
+	rest := in.Value
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		files = append(files, File{
+			Path:     path.Join(in.FileName, fmt.Sprintf("%d.pem", len(files))),
+			Contents: pem.EncodeToMemory(block),
+		})
+	}
+	if len(files) == 0 {
+		return nil, errors.New("value contains no PEM blocks")
+	}
+	return files, nil
+}
+
+// certKey writes the certificates of a PEM bundle to <fileName>.crt and its private key to <fileName>.key.
+func certKey(in Input) ([]File, error) {
+	var certs, keys []byte
+	rest := in.Value
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			certs = append(certs, pem.EncodeToMemory(block)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			keys = append(keys, pem.EncodeToMemory(block)...)
+		}
+	}
+	if len(certs) == 0 || len(keys) == 0 {
+		return nil, errors.New("value must contain PEM certificates and a private key")
+	}
+	return []File{
+		{Path: in.FileName + ".crt", Contents: certs},
+		{Path: in.FileName + ".key", Contents: keys},
+	}, nil
+}
+
+// certificateFiles splits the value of a CERTIFICATE item into <fileName>/certificate.pem holding
+// the leaf certificate, <fileName>/ca_chain.pem holding the rest of the chain and
+// <fileName>/private_key.pem. The chain and key files are only written when the item has them.
+// With the "format" secretArg set to CertificateFormatDER the files are DER encoded instead, see
+// certificateFilesDER.
+func certificateFiles(in Input) ([]File, error) {
+	var value struct {
+		CertificatePEM string `json:"certificate_pem"`
+		PrivateKeyPEM  string `json:"private_key_pem"`
+	}
+	if err := json.Unmarshal(in.Value, &value); err != nil {
+		return nil, fmt.Errorf("value is not a certificate item value: %w", err)
+	}
+
+	certs := pemCertificates([]byte(value.CertificatePEM))
+	if len(certs) == 0 {
+		return nil, errors.New("value contains no PEM certificate")
+	}
+	if format, _ := in.Args[CertificateFormatArg].(string); format == CertificateFormatDER {
+		return certificateFilesDER(in.FileName, certs, value.PrivateKeyPEM)
+	}
+
+	files := []File{{Path: path.Join(in.FileName, "certificate.pem"), Contents: pem.EncodeToMemory(certs[0])}}
+	var chain []byte
+	for _, cert := range certs[1:] {
+		chain = append(chain, pem.EncodeToMemory(cert)...)
+	}
+	if len(chain) > 0 {
+		files = append(files, File{Path: path.Join(in.FileName, "ca_chain.pem"), Contents: chain})
+	}
+	if value.PrivateKeyPEM != "" {
+		files = append(files, File{Path: path.Join(in.FileName, "private_key.pem"), Contents: []byte(value.PrivateKeyPEM)})
+	}
+	return files, nil
+}
+
+// kubernetesTLS writes a certificate in the layout of kubernetes.io/tls secrets, for syncing with
+// secretObjects and ingress controllers: <fileName>/tls.crt holding the certificate with its chain,
+// <fileName>/tls.key and <fileName>/ca.crt holding the chain only, when the certificate has one.
+// The value is either a CERTIFICATE item's or a PEM bundle, such as an issued PKI certificate.
+func kubernetesTLS(in Input) ([]File, error) {
+	var value struct {
+		CertificatePEM string `json:"certificate_pem"`
+		PrivateKeyPEM  string `json:"private_key_pem"`
+	}
+	if err := json.Unmarshal(in.Value, &value); err != nil {
+		value.CertificatePEM, value.PrivateKeyPEM = string(in.Value), string(in.Value)
+	}
+
+	certs := pemCertificates([]byte(value.CertificatePEM))
+	var key []byte
+	rest := []byte(value.PrivateKeyPEM)
+	for key == nil {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			key = pem.EncodeToMemory(block)
+		}
+	}
+	if len(certs) == 0 || key == nil {
+		return nil, errors.New("value must contain a PEM certificate and a private key")
+	}
+
+	var chain []byte
+	for _, cert := range certs[1:] {
+		chain = append(chain, pem.EncodeToMemory(cert)...)
+	}
+	files := []File{
+		{Path: path.Join(in.FileName, "tls.crt"), Contents: append(pem.EncodeToMemory(certs[0]), chain...)},
+		{Path: path.Join(in.FileName, "tls.key"), Contents: key},
+	}
+	if len(chain) > 0 {
+		files = append(files, File{Path: path.Join(in.FileName, "ca.crt"), Contents: chain})
+	}
+	return files, nil
+}
+
+// dockerConfigJSON renders registry credentials as the .dockerconfigjson of an image pull secret,
+// {"auths": {"<registry>": {"username", "password", "auth"}}}, written to <fileName>. The value is a
+// JSON object with the username (or user) and password (or token) and optionally the email. The
+// registry is the registry secretArg, otherwise the registry (or server) field of the value.
+func dockerConfigJSON(in Input) ([]File, error) {
+	var value map[string]interface{}
+	if err := json.Unmarshal(in.Value, &value); err != nil {
+		return nil, fmt.Errorf("value is not a JSON object: %w", err)
+	}
+	field := func(names ...string) string {
+		for _, name := range names {
+			if s, ok := value[name].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+
+	registry := field("registry", "server")
+	if arg, ok := in.Args[DockerConfigRegistryArg]; ok {
+		registry = fmt.Sprint(arg)
+	}
+	username, password := field("username", "user"), field("password", "token")
+	switch {
+	case registry == "":
+		return nil, fmt.Errorf("no registry, set the %v secretArg or a registry field in the value", DockerConfigRegistryArg)
+	case username == "" || password == "":
+		return nil, fmt.Errorf("value must contain a username and password, found keys: %v", strings.Join(sortedKeys(value), ", "))
+	}
+
+	auth := map[string]string{
+		"username": username,
+		"password": password,
+		"auth":     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+	}
+	if email := field("email"); email != "" {
+		auth["email"] = email
+	}
+	out, err := json.Marshal(map[string]interface{}{"auths": map[string]interface{}{registry: auth}})
+	if err != nil {
+		return nil, err
+	}
+	return []File{{Path: in.FileName, Contents: out}}, nil
+}
+
+// clientFileMode is the permission of database client files, libpq ignores a .pgpass readable by
+// group or others.
+const clientFileMode = 0600
+
+// dbCredentials are the connection settings of a database client file.
+type dbCredentials struct {
+	username, password   string
+	host, port, database string
+}
+
+// parseDBCredentials reads the credentials of a rotated or dynamic database secret, a JSON object
+// with the username (or user) and password. The host, port and database are the secretArgs of the
+// same name, otherwise the value's fields, dbname also naming the database.
+func parseDBCredentials(in Input) (dbCredentials, error) {
+	var value map[string]interface{}
+	if err := json.Unmarshal(in.Value, &value); err != nil {
+		return dbCredentials{}, fmt.Errorf("value is not a JSON object: %w", err)
+	}
+	field := func(names ...string) string {
+		for _, name := range names {
+			if v, ok := value[name]; ok && v != nil && v != "" {
+				return fmt.Sprint(v)
+			}
+		}
+		return ""
+	}
+	setting := func(arg string, names ...string) string {
+		if v, ok := in.Args[arg]; ok {
+			return fmt.Sprint(v)
+		}
+		return field(names...)
+	}
+	creds := dbCredentials{
+		username: field("username", "user"),
+		password: field("password"),
+		host:     setting("host", "host"),
+		port:     setting("port", "port"),
+		database: setting("database", "database", "dbname"),
+	}
+	if creds.username == "" || creds.password == "" {
+		return dbCredentials{}, fmt.Errorf("value must contain a username and password, found keys: %v", strings.Join(sortedKeys(value), ", "))
+	}
+	return creds, nil
+}
+
+// pgpass writes the credentials of a database secret as a PostgreSQL password file,
+// host:port:database:username:password, to <fileName> readable by its owner only. A missing host,
+// port or database is the * wildcard.
+func pgpass(in Input) ([]File, error) {
+	creds, err := parseDBCredentials(in)
+	if err != nil {
+		return nil, err
+	}
+	escape := strings.NewReplacer(`\`, `\\`, ":", `\:`)
+	fields := []string{creds.host, creds.port, creds.database, creds.username, creds.password}
+	for i, f := range fields {
+		if f == "" {
+			fields[i] = "*"
+			continue
+		}
+		fields[i] = escape.Replace(f)
+	}
+	line := strings.Join(fields, ":") + "\n"
+	return []File{{Path: in.FileName, Contents: []byte(line), Mode: clientFileMode}}, nil
+}
+
+// myCnf writes the credentials of a database secret as a MySQL option file to <fileName>, readable
+// by its owner only. The options are written to the [client] group, or the one named by the
+// section secretArg.
+func myCnf(in Input) ([]File, error) {
+	creds, err := parseDBCredentials(in)
+	if err != nil {
+		return nil, err
+	}
+	section := "client"
+	if v, ok := in.Args["section"]; ok {
+		section = fmt.Sprint(v)
+	}
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "[%s]\n", section)
+	for _, option := range []struct{ name, value string }{
+		{"user", creds.username},
+		{"password", creds.password},
+		{"host", creds.host},
+		{"port", creds.port},
+		{"database", creds.database},
+	} {
+		if option.value != "" {
+			fmt.Fprintf(&out, "%s=\"%s\"\n", option.name, quote.Replace(option.value))
+		}
+	}
+	return []File{{Path: in.FileName, Contents: out.Bytes(), Mode: clientFileMode}}, nil
+}
+
+// kubeconfigFile is a kubeconfig with a single cluster, user and context.
+type kubeconfigFile struct {
+	APIVersion     string            `yaml:"apiVersion"`
+	Kind           string            `yaml:"kind"`
+	Clusters       []kubeconfigNamed `yaml:"clusters"`
+	Users          []kubeconfigNamed `yaml:"users"`
+	Contexts       []kubeconfigNamed `yaml:"contexts"`
+	CurrentContext string            `yaml:"current-context"`
+}
+
+type kubeconfigNamed struct {
+	Name    string            `yaml:"name"`
+	Cluster map[string]string `yaml:"cluster,omitempty"`
+	User    map[string]string `yaml:"user,omitempty"`
+	Context map[string]string `yaml:"context,omitempty"`
+}
+
+// kubeconfig renders the token of a Kubernetes dynamic secret as a kubeconfig written to
+// <fileName>, readable by its owner only. The value is a JSON object with the k8s_token (or token).
+// The API server, its CA certificate and the namespace are the server, caCert and namespace
+// secretArgs, otherwise the value's k8s_cluster_endpoint, k8s_cluster_ca_cert and k8s_namespace
+// fields. The cluster, user and context are named after the cluster secretArg, "default" if unset.
+func kubeconfig(in Input) ([]File, error) {
+	var value map[string]interface{}
+	if err := json.Unmarshal(in.Value, &value); err != nil {
+		return nil, fmt.Errorf("value is not a JSON object: %w", err)
+	}
+	field := func(names ...string) string {
+		for _, name := range names {
+			if s, ok := value[name].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+	setting := func(arg string, names ...string) string {
+		if v, ok := in.Args[arg]; ok {
+			return fmt.Sprint(v)
+		}
+		return field(names...)
+	}
+
+	token := field("k8s_token", "token")
+	server := setting("server", "k8s_cluster_endpoint", "cluster_endpoint", "server")
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("value must contain a k8s_token, found keys: %v", strings.Join(sortedKeys(value), ", "))
+	case server == "":
+		return nil, fmt.Errorf("no API server, set the server secretArg or a k8s_cluster_endpoint field in the value")
+	}
+
+	cluster := map[string]string{"server": server}
+	if ca := setting("caCert", "k8s_cluster_ca_cert", "cluster_ca_cert"); ca != "" {
+		// the CA is stored PEM encoded or, as in the producer settings, base64 encoded PEM
+		if !strings.HasPrefix(strings.TrimSpace(ca), "-----BEGIN") {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(ca))
+			if err != nil {
+				return nil, fmt.Errorf("CA certificate is neither PEM nor base64 encoded PEM")
+			}
+			ca = string(decoded)
+		}
+		if len(pemCertificates([]byte(ca))) == 0 {
+			return nil, fmt.Errorf("CA certificate contains no PEM certificate")
+		}
+		cluster["certificate-authority-data"] = base64.StdEncoding.EncodeToString([]byte(ca))
+	}
+
+	name := setting("cluster")
+	if name == "" {
+		name = "default"
+	}
+	context := map[string]string{"cluster": name, "user": name}
+	if namespace := setting("namespace", "k8s_namespace", "namespace"); namespace != "" {
+		context["namespace"] = namespace
+	}
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(kubeconfigFile{
+		APIVersion:     "v1",
+		Kind:           "Config",
+		Clusters:       []kubeconfigNamed{{Name: name, Cluster: cluster}},
+		Users:          []kubeconfigNamed{{Name: name, User: map[string]string{"token": token}}},
+		Contexts:       []kubeconfigNamed{{Name: name, Context: context}},
+		CurrentContext: name,
+	}); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return []File{{Path: in.FileName, Contents: out.Bytes(), Mode: clientFileMode}}, nil
+}
+
+// pemCertificates returns the certificate blocks of a PEM text, in order.
+func pemCertificates(text []byte) []*pem.Block {
+	var certs []*pem.Block
+	for {
+		var block *pem.Block
+		block, text = pem.Decode(text)
+		if block == nil {
+			return certs
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, block)
+		}
+	}
+}
+
+// certificateFilesDER writes the DER encoded certificate files of a certificate item:
+// <fileName>/certificate.der, one <fileName>/ca_chain/<n>.der per CA of the chain, as DER has no
+// bundles, and <fileName>/private_key.der holding the key as unencrypted PKCS#8.
+func certificateFilesDER(fileName string, certs []*pem.Block, privateKeyPEM string) ([]File, error) {
+	files := []File{{Path: path.Join(fileName, "certificate.der"), Contents: certs[0].Bytes}}
+	for i, cert := range certs[1:] {
+		files = append(files, File{Path: path.Join(fileName, "ca_chain", fmt.Sprintf("%d.der", i)), Contents: cert.Bytes})
+	}
+	if privateKeyPEM == "" {
+		return files, nil
+	}
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("value contains no PEM private key")
+	}
+	key, err := pkcs8DER(block)
+	if err != nil {
+		return nil, err
+	}
+	return append(files, File{Path: path.Join(fileName, "private_key.der"), Contents: key}), nil
+}
+
+// pkcs8DER returns the PKCS#8 DER encoding of a PEM private key, which Java reads with
+// PKCS8EncodedKeySpec whatever the key's algorithm.
+func pkcs8DER(block *pem.Block) ([]byte, error) {
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		return block.Bytes, nil
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %v", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't parse private key: %w", err)
+	}
+	return x509.MarshalPKCS8PrivateKey(key)
+}
+
+// jsonExplode writes every top-level key of a JSON object value to its own file, <fileName>/<key>.
+// String values are written as is, other values JSON encoded.
+func jsonExplode(in Input) ([]File, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(in.Value, &obj); err != nil {
+		return nil, fmt.Errorf("value is not a JSON object: %w", err)
+	}
+
+	files := make([]File, 0, len(obj))
+	for _, key := range sortedKeys(obj) {
+		if key == "" || key == "." || key == ".." || path.Base(key) != key {
+			return nil, fmt.Errorf("key %q can't be used as a file name", key)
+		}
+		contents, err := encodeValue(obj[key])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: path.Join(in.FileName, key), Contents: contents})
+	}
+	return files, nil
+}
+
+// templateFuncs are the functions templates can use on top of the text/template builtins.
+var templateFuncs = sprigFuncs
+
+// templateBuiltins are the functions text/template predefines.
+var templateBuiltins = []string{"and", "call", "eq", "ge", "gt", "html", "index", "js", "le", "len", "lt", "ne", "not", "or", "print", "printf", "println", "slice", "urlquery"}
+
+// TemplateFunctions returns the names of the functions the template post-processor supports, sorted.
+func TemplateFunctions() []string {
+	names := append([]string(nil), templateBuiltins...)
+	for name := range templateFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderTemplate renders the Go text/template given in the "template" secretArg, with .Value
+// holding the value as a string and .JSON its parsed form when the value is JSON.
+func renderTemplate(in Input) ([]File, error) {
+	text, ok := in.Args["template"].(string)
+	if !ok || text == "" {
+		return nil, errors.New(`missing "template" secretArg`)
+	}
+
+	var parsed interface{}
+	_ = json.Unmarshal(in.Value, &parsed)
+
+	out, err := RenderTemplate(in.FileName, text, map[string]interface{}{"Value": string(in.Value), "JSON": parsed})
+	if err != nil {
+		return nil, err
+	}
+	return []File{{Path: in.FileName, Contents: out}}, nil
+}
+
+// ParseTemplate parses a template, with the template functions available.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// RenderTemplate renders a template with data, failing on missing keys.
+func RenderTemplate(name, text string, data interface{}) ([]byte, error) {
+	tmpl, err := ParseTemplate(name, text)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func encodeValue(v interface{}) ([]byte, error) {
+	if s, ok := v.(string); ok {
+		return []byte(s), nil
+	}
+	return json.Marshal(v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// EncodingAdd ensures the value ends with a newline or starts with a byte order mark
+	EncodingAdd = "add"
+	// EncodingStrip removes trailing newlines or a leading byte order mark from the value
+	EncodingStrip = "strip"
+)
+
+const (
+	// LineEndingsLF converts CRLF line endings to LF
+	LineEndingsLF = "lf"
+	// LineEndingsCRLF converts LF line endings to CRLF, for Windows applications
+	LineEndingsCRLF = "crlf"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Encoding controls the bytes of a mounted text file around its content: the line endings,
+// the trailing newline and the UTF-8 byte order mark. The zero value keeps files as they are.
+type Encoding struct {
+	// TrailingNewline is EncodingAdd, EncodingStrip or empty to keep the value's
+	TrailingNewline string
+	// LineEndings is LineEndingsLF, LineEndingsCRLF or empty to keep the value's
+	LineEndings string
+	// BOM is EncodingAdd, EncodingStrip or empty to keep the value's
+	BOM string
+}
+
+// Validate returns an error naming the first unsupported option of e.
+func (e Encoding) Validate() error {
+	switch e.TrailingNewline {
+	case "", EncodingAdd, EncodingStrip:
+	default:
+		return fmt.Errorf("unsupported trailingNewline %v, must be %v or %v", e.TrailingNewline, EncodingAdd, EncodingStrip)
+	}
+	switch e.LineEndings {
+	case "", LineEndingsLF, LineEndingsCRLF:
+	default:
+		return fmt.Errorf("unsupported lineEndings %v, must be %v or %v", e.LineEndings, LineEndingsLF, LineEndingsCRLF)
+	}
+	switch e.BOM {
+	case "", EncodingAdd, EncodingStrip:
+	default:
+		return fmt.Errorf("unsupported bom %v, must be %v or %v", e.BOM, EncodingAdd, EncodingStrip)
+	}
+	return nil
+}
+
+// IsZero reports whether e keeps files as they are.
+func (e Encoding) IsZero() bool {
+	return e == Encoding{}
+}
+
+// Apply returns value with the encoding of e, value itself is left unchanged.
+func (e Encoding) Apply(value []byte) []byte {
+	if e.IsZero() {
+		return value
+	}
+	bom := bytes.HasPrefix(value, utf8BOM)
+	out := bytes.TrimPrefix(value, utf8BOM)
+
+	switch e.LineEndings {
+	case LineEndingsLF:
+		out = bytes.ReplaceAll(out, []byte("\r\n"), []byte("\n"))
+	case LineEndingsCRLF:
+		out = bytes.ReplaceAll(out, []byte("\r\n"), []byte("\n"))
+		out = bytes.ReplaceAll(out, []byte("\n"), []byte("\r\n"))
+	}
+
+	switch e.TrailingNewline {
+	case EncodingStrip:
+		out = bytes.TrimRight(out, "\r\n")
+	case EncodingAdd:
+		if !bytes.HasSuffix(out, []byte("\n")) {
+			newline := "\n"
+			if e.LineEndings == LineEndingsCRLF {
+				newline = "\r\n"
+			}
+			out = append(append([]byte{}, out...), newline...)
+		}
+	}
+
+	switch e.BOM {
+	case EncodingAdd:
+		bom = true
+	case EncodingStrip:
+		bom = false
+	}
+	if bom {
+		out = append(append([]byte{}, utf8BOM...), out...)
+	}
+	return out
+}
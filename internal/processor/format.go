@@ -0,0 +1,328 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FormatArg is the secretArg selecting the format a JSON value is mounted in.
+const FormatArg = "objectFormat"
+
+const (
+	// FormatYAML renders a JSON value as YAML.
+	FormatYAML = "yaml"
+	// FormatProperties renders a JSON value as a Java properties file with dotted keys.
+	FormatProperties = "properties"
+	// FormatJSON renders a JSON value as indented JSON.
+	FormatJSON = "json"
+	// FormatINI renders a JSON object as an INI file with nested objects as sections.
+	FormatINI = "ini"
+)
+
+// formats convert a JSON value into the format they are named after.
+var formats = map[string]func(value []byte) ([]byte, error){
+	FormatYAML:       jsonToYAML,
+	FormatProperties: jsonToProperties,
+	FormatJSON:       jsonToJSON,
+	FormatINI:        jsonToINI,
+}
+
+// Formats returns the names of the supported object formats, sorted.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidFormat reports whether format is a supported object format, empty for none.
+func ValidFormat(format string) bool {
+	_, ok := formats[format]
+	return ok || format == ""
+}
+
+// Format converts a JSON value into format, an empty format keeps the value as is.
+func Format(format string, value []byte) ([]byte, error) {
+	if format == "" {
+		return value, nil
+	}
+	convert, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown objectFormat %v", format)
+	}
+	out, err := convert(value)
+	if err != nil {
+		return nil, fmt.Errorf("can't render value as %v: %w", format, err)
+	}
+	return out, nil
+}
+
+// DecodeBase64 decodes a base64 encoded value, padded or not and wrapped across lines or not,
+// e.g. a keystore stored as the output of base64.
+func DecodeBase64(value []byte) ([]byte, error) {
+	clean := bytes.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, value)
+	encoding := base64.StdEncoding
+	if len(clean)%4 != 0 {
+		encoding = base64.RawStdEncoding
+	}
+	out := make([]byte, encoding.DecodedLen(len(clean)))
+	n, err := encoding.Decode(out, clean)
+	if err != nil {
+		// the error holds the offset only, never part of the value
+		return nil, fmt.Errorf("value is not base64: %w", err)
+	}
+	return out[:n], nil
+}
+
+// parseJSONDocument parses a JSON object or array into a YAML node, JSON being a subset of
+// YAML, with the keys of every object sorted. Rendered files then only change with their values,
+// not when the gateway returns the same keys in another order, which would trigger app reloads.
+func parseJSONDocument(value []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(value, &doc); err != nil || !isJSONDocument(value) {
+		return nil, fmt.Errorf("value is not JSON")
+	}
+	sortMappings(&doc)
+	return &doc, nil
+}
+
+// sortMappings sorts the key/value pairs of every mapping of n by key.
+func sortMappings(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i][0].Value < pairs[j][0].Value })
+		for i, pair := range pairs {
+			n.Content[2*i], n.Content[2*i+1] = pair[0], pair[1]
+		}
+	}
+	for _, c := range n.Content {
+		sortMappings(c)
+	}
+}
+
+// jsonToJSON indents a JSON object or array with its keys sorted, numbers kept as written.
+func jsonToJSON(value []byte) ([]byte, error) {
+	if !isJSONDocument(value) {
+		return nil, fmt.Errorf("value is not JSON")
+	}
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("value is not JSON")
+	}
+
+	// maps are encoded with their keys sorted
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func jsonToYAML(value []byte) ([]byte, error) {
+	doc, err := parseJSONDocument(value)
+	if err != nil {
+		return nil, err
+	}
+	blockStyle(doc)
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// isJSONDocument reports whether value is a JSON object or array, scalars are left as they are.
+func isJSONDocument(value []byte) bool {
+	trimmed := bytes.TrimSpace(value)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+// blockStyle drops the flow and quoting styles parsing JSON sets, so the node is encoded as
+// idiomatic YAML, e.g. with multi-line strings such as PEM blocks as literal blocks.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// jsonToProperties flattens JSON into key=value lines, nested keys joined by dots and array
+// elements indexed as key[0], the way Spring binds properties.
+func jsonToProperties(value []byte) ([]byte, error) {
+	doc, err := parseJSONDocument(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	var flatten func(prefix string, n *yaml.Node)
+	flatten = func(prefix string, n *yaml.Node) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				flatten(prefix, c)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i].Value
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				flatten(key, n.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				flatten(fmt.Sprintf("%s[%d]", prefix, i), c)
+			}
+		default:
+			v := n.Value
+			if n.Tag == "!!null" {
+				v = ""
+			}
+			out.WriteString(escapeProperty(prefix, true))
+			out.WriteByte('=')
+			out.WriteString(escapeProperty(v, false))
+			out.WriteByte('\n')
+		}
+	}
+	flatten("", doc)
+	return out.Bytes(), nil
+}
+
+// escapeProperty escapes a key or value the way java.util.Properties stores them, with non-ASCII
+// characters as \uXXXX escapes, which Properties.load reads regardless of the file's encoding.
+func escapeProperty(s string, key bool) string {
+	var out bytes.Buffer
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			out.WriteString(`\\`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r == '\f':
+			out.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			out.WriteString(`\ `)
+		case key && (r == '=' || r == ':'), (r == '#' || r == '!') && i == 0:
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			if r > 0xffff {
+				// supplementary characters are escaped as their UTF-16 surrogate pair
+				r -= 0x10000
+				fmt.Fprintf(&out, `\u%04x\u%04x`, 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+				continue
+			}
+			fmt.Fprintf(&out, `\u%04x`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// jsonToINI renders a JSON object as an INI file. Scalars of the top-level object come first, without
+// a section, objects become [sections] and objects nested in them [section.subsection]. Arrays are
+// indexed as key[0] and objects within arrays joined by dots, as in properties.
+func jsonToINI(value []byte) ([]byte, error) {
+	doc, err := parseJSONDocument(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("value is not a JSON object")
+	}
+
+	var out bytes.Buffer
+	var section func(name string, n *yaml.Node)
+	section = func(name string, n *yaml.Node) {
+		var body bytes.Buffer
+		var subsections []int
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i+1].Kind == yaml.MappingNode {
+				subsections = append(subsections, i)
+				continue
+			}
+			writeINIKeys(&body, n.Content[i].Value, n.Content[i+1])
+		}
+		// sections holding subsections only are left out, as in git config
+		if name != "" && (body.Len() > 0 || len(subsections) == 0) {
+			if out.Len() > 0 {
+				out.WriteByte('\n')
+			}
+			fmt.Fprintf(&out, "[%s]\n", name)
+		}
+		out.Write(body.Bytes())
+		for _, i := range subsections {
+			sub := n.Content[i].Value
+			if name != "" {
+				sub = name + "." + sub
+			}
+			section(sub, n.Content[i+1])
+		}
+	}
+	section("", doc.Content[0])
+	return out.Bytes(), nil
+}
+
+// writeINIKeys writes the key=value lines of a value within a section.
+func writeINIKeys(out *bytes.Buffer, key string, n *yaml.Node) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			writeINIKeys(out, key+"."+n.Content[i].Value, n.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			writeINIKeys(out, fmt.Sprintf("%s[%d]", key, i), c)
+		}
+	default:
+		v := n.Value
+		if n.Tag == "!!null" {
+			v = ""
+		}
+		fmt.Fprintf(out, "%s=%s\n", key, quoteINI(v))
+	}
+}
+
+var iniEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// quoteINI double quotes values INI readers would otherwise trim, cut at a comment or split over
+// lines, escaping backslashes, quotes and line breaks.
+func quoteINI(v string) string {
+	if v == strings.TrimSpace(v) && !strings.ContainsAny(v, ";#\"\\\r\n") {
+		return v
+	}
+	return `"` + iniEscaper.Replace(v) + `"`
+}
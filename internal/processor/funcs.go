@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// sprigFuncs are the commonly used functions of the sprig library, with sprig's names and
+// argument order, so templates written for other tools render the same.
+var sprigFuncs = template.FuncMap{
+	"default": func(def interface{}, v ...interface{}) interface{} {
+		if len(v) == 0 || empty(v[0]) {
+			return def
+		}
+		return v[0]
+	},
+	"empty": empty,
+	"required": func(msg string, v interface{}) (interface{}, error) {
+		if empty(v) {
+			return nil, errors.New(msg)
+		}
+		return v, nil
+	},
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"join": func(sep string, v interface{}) string {
+		switch list := v.(type) {
+		case []string:
+			return strings.Join(list, sep)
+		case []interface{}:
+			parts := make([]string, 0, len(list))
+			for _, p := range list {
+				parts = append(parts, fmt.Sprint(p))
+			}
+			return strings.Join(parts, sep)
+		}
+		return fmt.Sprint(v)
+	},
+	"quote":   func(s interface{}) string { return fmt.Sprintf("%q", fmt.Sprint(s)) },
+	"squote":  func(s interface{}) string { return "'" + fmt.Sprint(s) + "'" },
+	"indent":  indent,
+	"nindent": func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+	"b64enc":  func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"b64dec": func(s string) (string, error) {
+		out, err := base64.StdEncoding.DecodeString(s)
+		return string(out), err
+	},
+	"sha256sum": func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"toJson": func(v interface{}) (string, error) {
+		out, err := json.Marshal(v)
+		return string(out), err
+	},
+	"toPrettyJson": func(v interface{}) (string, error) {
+		out, err := json.MarshalIndent(v, "", "  ")
+		return string(out), err
+	},
+	"fromJson": func(s string) (interface{}, error) {
+		var v interface{}
+		err := json.Unmarshal([]byte(s), &v)
+		return v, err
+	},
+}
+
+func empty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
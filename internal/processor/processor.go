@@ -0,0 +1,95 @@
+// Package processor defines the post-processors that transform a fetched secret value into the
+// files written to the mount, and the registry the provider looks them up in by name.
+package processor
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Input is a fetched secret handed to a post-processor.
+type Input struct {
+	// FileName is the file name configured for the object.
+	FileName string
+	// Value is the fetched secret value.
+	Value []byte
+	// Args are the secretArgs configured for the object.
+	Args map[string]interface{}
+}
+
+// File is an output file, its path is relative to the mount target path.
+type File struct {
+	Path     string
+	Contents []byte
+	// Mode is the permission of the file, zero for the filePermission of the mount.
+	Mode os.FileMode
+}
+
+// PostProcessor transforms a fetched secret value into one or more output files.
+type PostProcessor interface {
+	Process(in Input) ([]File, error)
+}
+
+// Func adapts a function to the PostProcessor interface.
+type Func func(in Input) ([]File, error)
+
+func (f Func) Process(in Input) ([]File, error) {
+	return f(in)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]PostProcessor)
+)
+
+// Register makes a post-processor available under name, it panics if name is already taken.
+func Register(name string, p PostProcessor) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("post-processor %q is already registered", name))
+	}
+	registry[name] = p
+}
+
+// Get returns the post-processor registered under name.
+func Get(name string) (PostProcessor, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names returns the names of all registered post-processors, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Process runs the named post-processor, an empty name writes the value as is to FileName.
+func Process(name string, in Input) ([]File, error) {
+	if name == "" {
+		return []File{{Path: in.FileName, Contents: in.Value}}, nil
+	}
+
+	p, ok := Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown post-processor %v", name)
+	}
+	files, err := p.Process(in)
+	if err != nil {
+		return nil, fmt.Errorf("post-processor %v failed for %v: %w", name, in.FileName, err)
+	}
+	return files, nil
+}
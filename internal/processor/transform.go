@@ -0,0 +1,304 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Transform is a jq-style expression reducing a JSON value before it is mounted, e.g.
+// ".credentials.password". An expression is a pipeline of stages separated by "|", each stage
+// being either a path or one of the functions keys, length, first, last and join("sep"). Paths
+// start with "." and select object keys with .name, ."name" or .["name"] and array elements with
+// [n], negative n counting from the end; "." alone is the value itself. Missing keys and elements
+// are errors rather than null, so a typo never mounts an empty file. String results are mounted
+// as they are, other results as JSON.
+type Transform struct {
+	expr   string
+	stages []func(v interface{}) (interface{}, error)
+}
+
+// ParseTransform parses a transform expression.
+func ParseTransform(expr string) (*Transform, error) {
+	t := &Transform{expr: expr}
+	parts, err := splitPipeline(expr)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		stage, err := parseStage(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform %q: %w", expr, err)
+		}
+		t.stages = append(t.stages, stage)
+	}
+	return t, nil
+}
+
+// Apply runs the transform on a JSON value.
+func (t *Transform) Apply(value []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		// the error may quote part of the value, so it is not wrapped
+		return nil, errors.New("value is not JSON")
+	}
+	for _, stage := range t.stages {
+		var err error
+		if v, err = stage(v); err != nil {
+			return nil, fmt.Errorf("transform %q: %w", t.expr, err)
+		}
+	}
+	return encodeValue(v)
+}
+
+// splitPipeline splits expr at the "|" outside of string literals.
+func splitPipeline(expr string) ([]string, error) {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == '|':
+			parts = append(parts, expr[start:i])
+			start = i + 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("invalid transform %q: unterminated string", expr)
+	}
+	return append(parts, expr[start:]), nil
+}
+
+func parseStage(stage string) (func(v interface{}) (interface{}, error), error) {
+	switch stage {
+	case "":
+		return nil, errors.New("empty stage")
+	case "keys":
+		return keysOf, nil
+	case "length":
+		return lengthOf, nil
+	case "first":
+		return func(v interface{}) (interface{}, error) { return index(v, 0) }, nil
+	case "last":
+		return func(v interface{}) (interface{}, error) { return index(v, -1) }, nil
+	}
+	if arg, ok := strings.CutPrefix(stage, "join("); ok {
+		arg, ok = strings.CutSuffix(arg, ")")
+		if !ok {
+			return nil, fmt.Errorf("invalid stage %v", stage)
+		}
+		sep, err := strconv.Unquote(strings.TrimSpace(arg))
+		if err != nil {
+			return nil, fmt.Errorf("join needs a string separator, got %v", arg)
+		}
+		return func(v interface{}) (interface{}, error) { return join(v, sep) }, nil
+	}
+	if strings.HasPrefix(stage, ".") {
+		return parsePath(stage)
+	}
+	return nil, fmt.Errorf("unknown stage %v", stage)
+}
+
+// parsePath parses a path stage into the function selecting it.
+func parsePath(path string) (func(v interface{}) (interface{}, error), error) {
+	var steps []func(v interface{}) (interface{}, error)
+	rest := path
+	for rest != "" {
+		var step func(v interface{}) (interface{}, error)
+		var err error
+		switch {
+		case strings.HasPrefix(rest, ".["), strings.HasPrefix(rest, "["):
+			rest = strings.TrimPrefix(rest, ".")
+			end := closingBracket(rest)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in %v", path)
+			}
+			step, err = bracketStep(strings.TrimSpace(rest[1:end]))
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, `."`):
+			var name string
+			name, rest, err = quotedPrefix(rest[1:])
+			step = keyStep(name)
+		case rest == ".":
+			rest = ""
+			continue
+		case strings.HasPrefix(rest, "."):
+			n := 1
+			for n < len(rest) && isIdentChar(rest[n], n == 1) {
+				n++
+			}
+			if n == 1 {
+				return nil, fmt.Errorf("invalid path %v", path)
+			}
+			step = keyStep(rest[1:n])
+			rest = rest[n:]
+		default:
+			return nil, fmt.Errorf("invalid path %v", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %v: %w", path, err)
+		}
+		steps = append(steps, step)
+	}
+	return func(v interface{}) (interface{}, error) {
+		for _, step := range steps {
+			var err error
+			if v, err = step(v); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}, nil
+}
+
+func isIdentChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+// closingBracket returns the index of the "]" closing the "[" s starts with, skipping strings.
+func closingBracket(s string) int {
+	quoted := false
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// quotedPrefix returns the string literal s starts with and the rest of s.
+func quotedPrefix(s string) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			name, err := strconv.Unquote(s[:i+1])
+			return name, s[i+1:], err
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+func bracketStep(inner string) (func(v interface{}) (interface{}, error), error) {
+	if strings.HasPrefix(inner, `"`) {
+		name, rest, err := quotedPrefix(inner)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("unexpected %v after key", rest)
+		}
+		return keyStep(name), nil
+	}
+	i, err := strconv.Atoi(inner)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index %v", inner)
+	}
+	return func(v interface{}) (interface{}, error) { return index(v, i) }, nil
+}
+
+func keyStep(name string) func(v interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("can't select key %v of %v", name, kindOf(v))
+		}
+		value, ok := obj[name]
+		if !ok {
+			return nil, fmt.Errorf("no key %v, available: %v", name, strings.Join(sortedKeys(obj), ", "))
+		}
+		return value, nil
+	}
+}
+
+func index(v interface{}, i int) (interface{}, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("can't select element %d of %v", i, kindOf(v))
+	}
+	if i < 0 {
+		i += len(arr)
+	}
+	if i < 0 || i >= len(arr) {
+		return nil, fmt.Errorf("no element %d of an array of length %d", i, len(arr))
+	}
+	return arr[i], nil
+}
+
+func keysOf(v interface{}) (interface{}, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("keys of %v", kindOf(v))
+	}
+	keys := make([]interface{}, 0, len(obj))
+	for _, k := range sortedKeys(obj) {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func lengthOf(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return len(v), nil
+	case []interface{}:
+		return len(v), nil
+	case string:
+		return len([]rune(v)), nil
+	}
+	return nil, fmt.Errorf("length of %v", kindOf(v))
+}
+
+func join(v interface{}, sep string) (interface{}, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("join of %v", kindOf(v))
+	}
+	elems := make([]string, len(arr))
+	for i, e := range arr {
+		switch e := e.(type) {
+		case string:
+			elems[i] = e
+		case json.Number:
+			elems[i] = e.String()
+		case bool:
+			elems[i] = strconv.FormatBool(e)
+		case nil:
+		default:
+			return nil, fmt.Errorf("join of an array containing %v", kindOf(e))
+		}
+	}
+	return strings.Join(elems, sep), nil
+}
+
+// kindOf names the JSON type of v for errors, which never include values.
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
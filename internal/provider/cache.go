@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+)
+
+// sharedCache holds secret values shared by the mounts on the node authenticated with the same
+// token, disabled until a TTL is set.
+var sharedCache = newValueCache(0)
+
+// SetCacheTTL enables the node-level secret value cache with the given TTL, 0 disables it.
+func SetCacheTTL(ttl time.Duration) {
+	sharedCache.mu.Lock()
+	defer sharedCache.mu.Unlock()
+	sharedCache.ttl = ttl
+}
+
+// CacheStats describes the node-level caches, for diagnostics.
+type CacheStats struct {
+	// Values is the number of cached secret values, Refreshing the ones being fetched right now
+	Values     int
+	Refreshing int
+	ValueTTL   time.Duration
+	// ItemMetadata is the number of items whose metadata can stand in for throttled describes
+	ItemMetadata int
+}
+
+// GetCacheStats returns the current sizes of the node-level caches.
+func GetCacheStats() CacheStats {
+	var stats CacheStats
+	sharedCache.mu.Lock()
+	stats.Values = len(sharedCache.entries)
+	stats.ValueTTL = sharedCache.ttl
+	for _, e := range sharedCache.entries {
+		if e.refreshing {
+			stats.Refreshing++
+		}
+	}
+	sharedCache.mu.Unlock()
+
+	itemMetadata.mu.Lock()
+	stats.ItemMetadata = len(itemMetadata.entries)
+	itemMetadata.mu.Unlock()
+	return stats
+}
+
+// valueCache caches fetched secret values and protects the gateway from stampedes when a popular
+// entry expires: only one caller refreshes it while all others keep getting the stale value
+// (stale-while-revalidate).
+type valueCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*valueEntry
+	// swept is when entries of tokens no longer in use were last dropped
+	swept time.Time
+}
+
+type valueEntry struct {
+	version    int32
+	value      string
+	fetched    time.Time
+	refreshing bool
+	// ready is closed once the first fetch of the entry completed
+	ready chan struct{}
+}
+
+type fetchFunc func() (int32, string, error)
+
+func newValueCache(ttl time.Duration) *valueCache {
+	return &valueCache{ttl: ttl, entries: make(map[string]*valueEntry)}
+}
+
+func (c *valueCache) get(key string, fetch fetchFunc) (int32, string, error) {
+	c.mu.Lock()
+	if c.ttl <= 0 {
+		c.mu.Unlock()
+		return fetch()
+	}
+
+	e, ok := c.entries[key]
+	if !ok {
+		if now := time.Now(); now.Sub(c.swept) > c.ttl {
+			c.sweep(now)
+			c.swept = now
+		}
+		// first fetch, concurrent callers wait for its result
+		e = &valueEntry{refreshing: true, ready: make(chan struct{})}
+		c.entries[key] = e
+		c.mu.Unlock()
+		return c.refresh(key, e, fetch)
+	}
+
+	if e.fetched.IsZero() {
+		c.mu.Unlock()
+		<-e.ready
+
+		c.mu.Lock()
+		fetched := !e.fetched.IsZero()
+		version, value := e.version, e.value
+		c.mu.Unlock()
+		if !fetched {
+			return fetch()
+		}
+		return version, value, nil
+	}
+
+	if time.Since(e.fetched) < c.ttl || e.refreshing {
+		version, value := e.version, e.value
+		c.mu.Unlock()
+		return version, value, nil
+	}
+
+	e.refreshing = true
+	c.mu.Unlock()
+	return c.refresh(key, e, fetch)
+}
+
+func (c *valueCache) refresh(key string, e *valueEntry, fetch fetchFunc) (int32, string, error) {
+	version, value, err := fetch()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.refreshing = false
+	if err == nil {
+		e.version, e.value, e.fetched = version, value, time.Now()
+	} else if e.fetched.IsZero() {
+		delete(c.entries, key)
+	}
+	select {
+	case <-e.ready:
+	default:
+		close(e.ready)
+	}
+
+	return version, value, err
+}
+
+// sweep drops the entries expired for more than a TTL, which no longer get refreshed since the
+// token of their key was replaced. c.mu must be held.
+func (c *valueCache) sweep(now time.Time) {
+	for key, e := range c.entries {
+		if !e.refreshing && !e.fetched.IsZero() && now.Sub(e.fetched) > 2*c.ttl {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *valueCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// cacheKey identifies a secret value together with whom it was fetched as, so values are only
+// shared between mounts of the same verified identity, never with a mount merely naming the same
+// access ID without authenticating as it.
+func cacheKey(cfg config.Config, secret config.Secret) string {
+	args, _ := json.Marshal(secret.SecretArgs)
+	return fetchIdentity(cfg) + "|" + cfg.AkeylessGatewayURL + "|" + secret.SecretPath + "|" + string(args)
+}
+
+// fetchIdentity is a hash of the identity the session of cfg authenticated as, standing in for it
+// in cache keys. Sessions that didn't authenticate through config.Parse, like the ones of tests
+// and tools holding a token obtained elsewhere, are identified by their token.
+func fetchIdentity(cfg config.Config) string {
+	var identity string
+	if cfg.Session != nil {
+		if identity = cfg.Session.Identity(); identity == "" {
+			identity = "token:" + cfg.Session.Token()
+		}
+	}
+	sum := sha256.Sum256([]byte(identity))
+	return hex.EncodeToString(sum[:])
+}
+
+// producedItemTypes are the item types issuing new credentials or certificates per fetch, which
+// belong to the mount they were issued to and bypass the node cache.
+var producedItemTypes = map[string]bool{
+	"DYNAMIC_SECRET":  true,
+	"PKI_CERT_ISSUER": true,
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueCache_StaleWhileRevalidate(t *testing.T) {
+	c := newValueCache(time.Hour)
+	var fetches int32
+	fetch := func() (int32, string, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		return n, "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, value, err := c.get("key", fetch)
+			require.NoError(t, err)
+			require.Equal(t, "value", value)
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 1, fetches)
+
+	// expire the entry, a single caller refreshes while the others get the stale value
+	c.entries["key"].fetched = time.Now().Add(-2 * time.Hour)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := c.get("key", fetch)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 2, fetches)
+}
+
+func TestValueCache_Disabled(t *testing.T) {
+	c := newValueCache(0)
+	var fetches int32
+	fetch := func() (int32, string, error) {
+		return atomic.AddInt32(&fetches, 1), "value", nil
+	}
+
+	for i := 0; i < 3; i++ {
+		_, _, err := c.get("key", fetch)
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, 3, fetches)
+}
+
+func TestValueCache_Sweep(t *testing.T) {
+	c := newValueCache(time.Minute)
+	fetch := func() (int32, string, error) { return 1, "value", nil }
+
+	_, _, err := c.get("old-token|key", fetch)
+	require.NoError(t, err)
+	_, _, err = c.get("token|key", fetch)
+	require.NoError(t, err)
+
+	// entries of replaced tokens are dropped once expired for more than a TTL
+	c.entries["old-token|key"].fetched = time.Now().Add(-3 * time.Minute)
+	c.entries["token|key"].fetched = time.Now().Add(-90 * time.Second)
+	c.swept = time.Time{}
+	_, _, err = c.get("new-token|key", fetch)
+	require.NoError(t, err)
+	require.NotContains(t, c.entries, "old-token|key")
+	require.Contains(t, c.entries, "token|key", "expired entries are still served while refreshed")
+}
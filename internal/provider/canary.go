@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+)
+
+// Canary periodically authenticates and fetches a designated low-value item, so that broken
+// authentication or gateway paths show up in the metrics even when no mounts occur.
+type Canary struct {
+	// Item is the path of the canary item
+	Item     string
+	Interval time.Duration
+	// Authenticate returns an authenticated config to fetch the item with
+	Authenticate func(ctx context.Context) (config.Config, error)
+}
+
+// Run probes the canary item every interval until ctx is done.
+func (c *Canary) Run(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for {
+			c.probe(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (c *Canary) probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Interval)
+	defer cancel()
+
+	startTime := time.Now()
+	err := c.fetch(ctx)
+	metrics.CanaryProbes.Inc(metrics.Result(err))
+	metrics.CanaryDuration.Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		log.Printf("canary probe of %v failed, error: %v", c.Item, err)
+	}
+	return err
+}
+
+func (c *Canary) fetch(ctx context.Context) error {
+	cfg, err := c.Authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	_, _, err = NewProvider().GetSecretByType(ctx, c.Item, cfg)
+	return err
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanary(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/canary": {itemType: "STATIC_SECRET", version: 1, value: "ok"},
+	})
+
+	authErr := errors.New("access denied")
+	c := &Canary{Item: "/canary", Interval: time.Minute, Authenticate: func(ctx context.Context) (config.Config, error) {
+		if authErr != nil {
+			return config.Config{}, authErr
+		}
+		return config.Config{Session: g.session}, nil
+	}}
+
+	require.ErrorIs(t, c.probe(context.Background()), authErr)
+	authErr = nil
+	require.NoError(t, c.probe(context.Background()))
+	require.Equal(t, 1, g.calls["/get-secret-value"])
+
+	g.failed["/canary"] = true
+	require.Error(t, c.probe(context.Background()))
+
+	var out bytes.Buffer
+	require.NoError(t, metrics.Default.WritePrometheus(&out))
+	require.Contains(t, out.String(), `akeyless_csi_provider_canary_probes_total{result="error"}`)
+	require.Contains(t, out.String(), `akeyless_csi_provider_canary_probes_total{result="success"}`)
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"sort"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/processor"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/version"
+)
+
+// Capabilities lists what the running version supports, so Helm chart validation and external
+// linters can query it instead of hardcoding it.
+type Capabilities struct {
+	Version string `json:"version"`
+	// ItemTypes are the item types that can be mounted
+	ItemTypes []string `json:"itemTypes"`
+	// DFCKeyTypes are the patterns of the DFC key types that can be mounted
+	DFCKeyTypes       []string `json:"dfcKeyTypes"`
+	AccessTypes       []string `json:"accessTypes"`
+	PublicKeyFormats  []string `json:"publicKeyFormats"`
+	PostProcessors    []string `json:"postProcessors"`
+	ObjectFormats     []string `json:"objectFormats"`
+	TemplateFunctions []string `json:"templateFunctions"`
+	Features          []string `json:"features"`
+}
+
+// GetCapabilities returns the capabilities of the running version.
+func GetCapabilities() Capabilities {
+	itemTypes := make([]string, 0, len(typedItemTypes))
+	for t := range typedItemTypes {
+		itemTypes = append(itemTypes, t)
+	}
+	sort.Strings(itemTypes)
+
+	dfcKeyTypes := make([]string, 0, len(dfcKeyTypePrefixes))
+	for _, prefix := range dfcKeyTypePrefixes {
+		dfcKeyTypes = append(dfcKeyTypes, prefix+"*")
+	}
+
+	return Capabilities{
+		Version:           version.BuildVersion,
+		ItemTypes:         itemTypes,
+		DFCKeyTypes:       dfcKeyTypes,
+		AccessTypes:       config.AccessTypes(),
+		PublicKeyFormats:  []string{publicKeyFormatJWK, publicKeyFormatPEM},
+		PostProcessors:    processor.Names(),
+		ObjectFormats:     processor.Formats(),
+		TemplateFunctions: processor.TemplateFunctions(),
+		Features:          config.FeatureNames(),
+	}
+}
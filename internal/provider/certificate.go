@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/jks"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/pkcs12"
+)
+
+// getCertificate returns the value of a certificate item, packaged as a PKCS#12 or JKS file
+// protected by the value of the static secret named by the "pkcs12PasswordSecret" or
+// "jksPasswordSecret" secretArg when one is set.
+func (p *Provider) getCertificate(ctx context.Context, itemName string, args map[string]interface{}, cfg config.Config) (string, error) {
+	value, err := p.GetCertificate(ctx, itemName, cfg)
+	p12PasswordSecret, jksPasswordSecret := stringArg(args, "pkcs12PasswordSecret"), stringArg(args, "jksPasswordSecret")
+	if err != nil || (p12PasswordSecret == "" && jksPasswordSecret == "") {
+		return value, err
+	}
+	if p12PasswordSecret != "" && jksPasswordSecret != "" {
+		return "", fmt.Errorf("certificate %v sets both pkcs12PasswordSecret and jksPasswordSecret", itemName)
+	}
+
+	format, passwordSecret, aliasArg := "PKCS#12", p12PasswordSecret, "pkcs12Alias"
+	if jksPasswordSecret != "" {
+		format, passwordSecret, aliasArg = "JKS", jksPasswordSecret, "jksAlias"
+	}
+	password, err := p.GetStaticSecret(ctx, passwordSecret, cfg)
+	if err != nil {
+		return "", fmt.Errorf("can't get %v password of certificate %v: %w", format, itemName, err)
+	}
+	alias := stringArg(args, aliasArg)
+	if alias == "" {
+		alias = path.Base(itemName)
+	}
+
+	key, certs, err := parseCertificateValue([]byte(value))
+	if err == nil && jksPasswordSecret != "" {
+		value, err = certificateJKS(key, certs, password, alias, stringArg(args, "jksTruststore"))
+	} else if err == nil {
+		var p12 []byte
+		p12, err = pkcs12.Encode(key, certs, password, alias)
+		value = string(p12)
+	}
+	if err != nil {
+		return "", fmt.Errorf("can't package certificate %v as %v: %w", itemName, format, err)
+	}
+	return value, nil
+}
+
+// certificateJKS returns the keystore of the certificate, or with truststore set the truststore
+// of its CA chain.
+func certificateJKS(key crypto.PrivateKey, certs []*x509.Certificate, password, alias, truststore string) (string, error) {
+	trust := false
+	if truststore != "" {
+		var err error
+		if trust, err = strconv.ParseBool(truststore); err != nil {
+			return "", fmt.Errorf("invalid jksTruststore %q, must be true or false", truststore)
+		}
+	}
+
+	var store []byte
+	var err error
+	if trust {
+		if len(certs) < 2 {
+			return "", errors.New("item has no CA chain for a truststore")
+		}
+		store, err = jks.Truststore(certs[1:], password, alias)
+	} else {
+		store, err = jks.Keystore(key, certs, password, alias)
+	}
+	return string(store), err
+}
+
+// parseCertificateValue returns the key and certificates of a certificate item value, the leaf
+// certificate first followed by its chain.
+func parseCertificateValue(value []byte) (crypto.PrivateKey, []*x509.Certificate, error) {
+	var cv struct {
+		CertificatePEM string `json:"certificate_pem"`
+		PrivateKeyPEM  string `json:"private_key_pem"`
+	}
+	if err := json.Unmarshal(value, &cv); err != nil {
+		return nil, nil, err
+	}
+
+	var certs []*x509.Certificate
+	rest := []byte(cv.CertificatePEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, nil, errors.New("item has no certificate")
+	}
+
+	block, _ := pem.Decode([]byte(cv.PrivateKeyPEM))
+	if block == nil {
+		return nil, nil, errors.New("item has no private key")
+	}
+	key, err := parseKey(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, certs, nil
+}
+
+func parseKey(block *pem.Block) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
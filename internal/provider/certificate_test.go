@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMountRequest_CertificatePKCS12(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "web"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/certs/web": {itemType: "CERTIFICATE", version: 2, value: map[string]interface{}{
+			"certificate_pem": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			"private_key_pem": string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+		}},
+		"/certs/web-p12-password": {itemType: "STATIC_SECRET", version: 1, value: "changeit"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "web.p12", SecretPath: "/certs/web", SecretArgs: map[string]interface{}{"pkcs12PasswordSecret": "/certs/web-p12-password"}}},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "/certs/web-p12-password", g.bodies["/get-secret-value"]["names"].([]interface{})[0])
+
+	var pfx struct {
+		Version int
+		Rest    asn1.RawValue
+		Mac     asn1.RawValue
+	}
+	_, err = asn1.Unmarshal([]byte(mountedFiles(resp)["web.p12"]), &pfx)
+	require.NoError(t, err)
+	require.Equal(t, 3, pfx.Version)
+
+	cfg.Secrets[0].SecretArgs["pkcs12PasswordSecret"] = "/certs/missing"
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "can't get PKCS#12 password of certificate /certs/web")
+}
+
+func TestHandleMountRequest_KeyPassphrase(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := "-----BEGIN CERTIFICATE-----\nZm9v\n-----END CERTIFICATE-----\n"
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/certs/web":            {itemType: "CERTIFICATE", version: 2, value: map[string]interface{}{"certificate_pem": certPEM, "private_key_pem": keyPEM}},
+		"/keys/bundle":          {itemType: "STATIC_SECRET", version: 1, value: certPEM + keyPEM},
+		"/certs/key-passphrase": {itemType: "STATIC_SECRET", version: 1, value: "passphrase"},
+	})
+	args := map[string]interface{}{"keyPassphraseSecret": "/certs/key-passphrase"}
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "web", SecretPath: "/certs/web", SecretArgs: args, PostProcessor: "certificate-files"},
+			{FileName: "bundle.pem", SecretPath: "/keys/bundle", SecretArgs: args, SecretType: "STATIC_SECRET"},
+		},
+	}}
+	p := NewProvider()
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, certPEM, files["web/certificate.pem"])
+	block, _ := pem.Decode([]byte(files["web/private_key.pem"]))
+	require.Equal(t, "ENCRYPTED PRIVATE KEY", block.Type)
+
+	cert, rest := pem.Decode([]byte(files["bundle.pem"]))
+	require.Equal(t, "CERTIFICATE", cert.Type)
+	block, _ = pem.Decode(rest)
+	require.Equal(t, "ENCRYPTED PRIVATE KEY", block.Type)
+
+	// keys are encrypted with a new salt on every mount, the versions stay those of the values
+	versions := resp.ObjectVersion
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.ElementsMatch(t, versions, resp.ObjectVersion)
+
+	g.items["/keys/bundle"] = fakeItem{itemType: "STATIC_SECRET", version: 1, value: certPEM}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "can't encrypt private key of /keys/bundle: value contains no unencrypted PEM private key")
+}
+
+func TestHandleMountRequest_CertificateJKS(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "ca"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour), IsCA: true, BasicConstraintsValid: true}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "web"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caTmpl, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/certs/web": {itemType: "CERTIFICATE", version: 2, value: map[string]interface{}{
+			"certificate_pem": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+				string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+			"private_key_pem": string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		}},
+		"/certs/web-jks-password": {itemType: "STATIC_SECRET", version: 1, value: "changeit"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "keystore.jks", SecretPath: "/certs/web", SecretArgs: map[string]interface{}{"jksPasswordSecret": "/certs/web-jks-password", "jksAlias": "server"}},
+			{FileName: "truststore.jks", SecretPath: "/certs/web", SecretArgs: map[string]interface{}{"jksPasswordSecret": "/certs/web-jks-password", "jksTruststore": true}},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+
+	files := mountedFiles(resp)
+	keystore, truststore := []byte(files["keystore.jks"]), []byte(files["truststore.jks"])
+	// magic, version and a single entry each
+	require.Equal(t, []byte{0xfe, 0xed, 0xfe, 0xed, 0, 0, 0, 2, 0, 0, 0, 1}, keystore[:12])
+	require.Equal(t, []byte{0xfe, 0xed, 0xfe, 0xed, 0, 0, 0, 2, 0, 0, 0, 1}, truststore[:12])
+	// a private key entry named server and a trusted certificate entry named after the item
+	require.Equal(t, append([]byte{0, 0, 0, 1, 0, 6}, "server"...), keystore[12:24])
+	require.Equal(t, append([]byte{0, 0, 0, 2, 0, 3}, "web"...), truststore[12:21])
+	require.True(t, bytes.Contains(truststore, caDER))
+	require.False(t, bytes.Contains(truststore, der))
+
+	cfg.Secrets = cfg.Secrets[:1]
+	cfg.Secrets[0].SecretArgs["pkcs12PasswordSecret"] = "/certs/web-jks-password"
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "sets both pkcs12PasswordSecret and jksPasswordSecret")
+}
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+const (
+	// decryptKeySecretArg names the DFC key decrypting the fetched value of an object.
+	decryptKeySecretArg = "decryptKey"
+	// encryptionContextSecretArg is the encryption context the value was encrypted with, if any.
+	encryptionContextSecretArg = "encryptionContext"
+)
+
+// decryptValue returns the plaintext of an object setting decryptKey, whose value is a ciphertext
+// of the named DFC key. The value stays encrypted at rest in Akeyless, readable only by the access
+// IDs allowed to decrypt with the key.
+func (p *Provider) decryptValue(ctx context.Context, secret config.Secret, ciphertext string, cfg config.Config) (string, error) {
+	keyName := stringArg(secret.SecretArgs, decryptKeySecretArg)
+	body := akeyless.NewDecrypt(keyName)
+	// static secrets often end with a newline the ciphertext doesn't have
+	body.SetCiphertext(strings.TrimSpace(ciphertext))
+	if encryptionContext, ok := secret.SecretArgs[encryptionContextSecretArg].(map[string]interface{}); ok {
+		context := make(map[string]string, len(encryptionContext))
+		for k, v := range encryptionContext {
+			context[k] = fmt.Sprint(v)
+		}
+		body.SetEncryptionContext(context)
+	}
+	if cfg.UsingUID() {
+		body.SetUidToken(cfg.Session.Token())
+	} else {
+		body.SetToken(cfg.Session.Token())
+	}
+
+	out, res, err := cfg.Session.Client.Decrypt(ctx).Body(*body).Execute()
+	if err := finishCall(res, err, fmt.Sprintf("can't decrypt %v with key %v", secret.SecretPath, keyName)); err != nil {
+		return "", err
+	}
+	return out.GetResult(), nil
+}
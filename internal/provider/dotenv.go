@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/processor"
+)
+
+var (
+	invalidKeyChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	// bareValue matches values that need no quoting in a dotenv file
+	bareValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+
+// renderDotenv renders the dotenv file of the SecretProviderClass from the fetched values, keyed
+// by the objects' mount paths. Lines are sorted by key, so the file only changes with its values.
+func renderDotenv(cfg config.Config, values map[string]string) []processor.File {
+	if cfg.Dotenv == nil {
+		return nil
+	}
+
+	lines := make(map[string]string)
+	for mountPath, value := range values {
+		if !dotenvSelected(cfg.Dotenv, mountPath) {
+			continue
+		}
+		key := dotenvKey(mountPath, cfg.Dotenv.KeyCase)
+		lines[key] = key + "=" + dotenvValue(value)
+	}
+
+	keys := make([]string, 0, len(lines))
+	for key := range lines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out strings.Builder
+	for _, key := range keys {
+		out.WriteString(lines[key])
+		out.WriteByte('\n')
+	}
+	return []processor.File{{Path: cfg.Dotenv.FileName, Contents: []byte(out.String())}}
+}
+
+func dotenvSelected(d *config.Dotenv, mountPath string) bool {
+	if len(d.Objects) == 0 {
+		return true
+	}
+	for _, name := range d.Objects {
+		if config.DotenvIncludes(name, mountPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// dotenvKey derives the key of a file, e.g. DB_PASSWORD for db/password.
+func dotenvKey(mountPath, keyCase string) string {
+	key := invalidKeyChars.ReplaceAllString(mountPath, "_")
+	if key != "" && key[0] >= '0' && key[0] <= '9' {
+		key = "_" + key
+	}
+	switch keyCase {
+	case config.KeyCaseLower:
+		return strings.ToLower(key)
+	case config.KeyCasePreserve:
+		return key
+	}
+	return strings.ToUpper(key)
+}
+
+func dotenvValue(value string) string {
+	if bareValue.MatchString(value) {
+		return value
+	}
+	return `"` + dotenvEscaper.Replace(value) + `"`
+}
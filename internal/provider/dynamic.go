@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// dynamicLease is a set of just-in-time credentials issued by a dynamic secret producer or
+// a PKI certificate issuer.
+type dynamicLease struct {
+	version int32
+	value   string
+	issued  time.Time
+	// renewAt is when new credentials are issued, zero to decide by the ttl secretArg
+	renewAt time.Time
+}
+
+// leaseKey identifies the lease of an item issued with args, so objects of one item with
+// different secretArgs, e.g. producer args or a certificate's common name, get leases of their own.
+// The reuse args only decide when the lease is renewed, they don't make a different lease.
+func leaseKey(itemName string, args map[string]interface{}, reuseArgs ...string) string {
+	issueArgs := make(map[string]interface{}, len(args))
+	for name, v := range args {
+		issueArgs[name] = v
+	}
+	for _, name := range reuseArgs {
+		delete(issueArgs, name)
+	}
+	return objectVersionID(config.Secret{SecretPath: itemName, SecretArgs: issueArgs})
+}
+
+// getDynamicSecret issues credentials of a dynamic secret, written as the producer's JSON output.
+// Credentials are issued once per mount and reused by rotation remounts, unless the "ttl" secretArg
+// is set, after which new credentials are issued. Supported secretArgs:
+//   - args: producer arguments, a map or a list of key=value strings
+//   - target: the target to issue credentials for
+//   - timeout: the producer timeout in seconds
+//   - ttl: how long issued credentials are reused before new ones are issued, e.g. "1h"
+//   - producer: the kind of a target-based producer, artifactory, github or gcp, mounting the
+//     issued token only and issuing a new one after 2/3 of its lifetime
+func (p *Provider) getDynamicSecret(ctx context.Context, itemName string, args map[string]interface{}, cfg config.Config) (int32, string, error) {
+	reuse, err := leaseTTL(args)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid ttl secretArg for %v: %w", itemName, err)
+	}
+
+	producer, err := tokenProducerOf(args)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid producer secretArg for %v: %w", itemName, err)
+	}
+
+	// objects setting noCache get new credentials on every mount, their leases keep no value
+	noCache := (config.Secret{SecretArgs: args}).NoCache()
+	leaseID := leaseKey(itemName, args, "ttl", "noCache")
+	lease := p.leases[leaseID]
+	if lease != nil && !noCache && (reuse == 0 || time.Since(lease.issued) < reuse) &&
+		(lease.renewAt.IsZero() || time.Now().Before(lease.renewAt)) {
+		return lease.version, lease.value, nil
+	}
+
+	body := akeyless.GetDynamicSecretValue{Name: itemName}
+	body.SetJson(true)
+	producerArgs, err := dynamicArgs(args["args"])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid args secretArg for %v: %w", itemName, err)
+	}
+	if producer != nil {
+		targetArgs, err := producer.producerArgs(args)
+		if err != nil {
+			return 0, "", fmt.Errorf("%v: %w", itemName, err)
+		}
+		producerArgs = append(producerArgs, targetArgs...)
+	}
+	if len(producerArgs) > 0 {
+		body.SetArgs(producerArgs)
+	}
+	if target, ok := args["target"].(string); ok && target != "" {
+		body.SetTarget(target)
+	}
+	if timeout, ok := args["timeout"]; ok {
+		seconds, err := strconv.ParseInt(fmt.Sprint(timeout), 10, 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid timeout secretArg for %v: %w", itemName, err)
+		}
+		body.SetTimeout(seconds)
+	}
+	if cfg.UsingUID() {
+		body.SetUidToken(cfg.Session.Token())
+	} else {
+		body.SetToken(cfg.Session.Token())
+	}
+
+	out, res, err := cfg.Session.Client.GetDynamicSecretValue(ctx).Body(body).Execute()
+	if err := finishCall(res, err, "can't get dynamic secret value"); err != nil {
+		return 0, "", err
+	}
+
+	issued := time.Now()
+	var value string
+	var expires time.Time
+	if producer != nil {
+		if value, expires, err = producer.render(out, issued); err != nil {
+			return 0, "", fmt.Errorf("can't get token of %v: %w", itemName, err)
+		}
+	} else {
+		encoded, err := json.Marshal(out)
+		if err != nil {
+			return 0, "", err
+		}
+		value = string(encoded)
+	}
+
+	version := int32(1)
+	if lease != nil {
+		version = lease.version + 1
+	}
+	lease = &dynamicLease{version: version, value: value, issued: issued}
+	if !expires.IsZero() {
+		lease.renewAt = issued.Add(expires.Sub(issued) * 2 / 3)
+	}
+	if noCache {
+		lease.value = ""
+	}
+	p.leases[leaseID] = lease
+	log.Printf("issued dynamic secret credentials, secretProviderClass: %v, item: %v, issue: %d", cfg.SecretProviderClass, itemName, version)
+	return version, value, nil
+}
+
+func leaseTTL(args map[string]interface{}) (time.Duration, error) {
+	v, ok := args["ttl"]
+	if !ok {
+		return 0, nil
+	}
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, nil
+		}
+	}
+	seconds, err := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("must be a duration or seconds, got %v", v)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// dynamicArgs converts the args secretArg into the producer's key=value arguments.
+func dynamicArgs(v interface{}) ([]string, error) {
+	switch args := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		out := make([]string, 0, len(args))
+		for _, a := range args {
+			out = append(out, fmt.Sprint(a))
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make([]string, 0, len(args))
+		for k, a := range args {
+			out = append(out, fmt.Sprintf("%s=%v", k, a))
+		}
+		sort.Strings(out)
+		return out, nil
+	}
+	return nil, fmt.Errorf("must be a map or a list, got %T", v)
+}
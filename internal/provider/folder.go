@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// object is a single item to mount. Items found by listing a folder carry the item returned
+// by ListItems, so their type and version are known without describing them.
+type object struct {
+	config.Secret
+	item *akeyless.Item
+}
+
+// folderItemTypes are the item types mounted from folders, other items are skipped
+var folderItemTypes = map[string]bool{
+	"STATIC_SECRET":  true,
+	"CERTIFICATE":    true,
+	"ROTATED_SECRET": true,
+}
+
+// isFolder reports whether the secret path names a folder, which mounts all of its items
+// under fileName, e.g. secretPath "/team-a/" with fileName "team-a". A folder wildcard like
+// "/team-a/*" works the same, its fileName is optional and defaults to the mount root.
+func isFolder(secretPath string) bool {
+	return strings.HasSuffix(secretPath, "/") || strings.HasSuffix(secretPath, "/*")
+}
+
+// folderPath returns the path of the folder a folder secret path names, ending with "/".
+func folderPath(secretPath string) string {
+	return strings.TrimSuffix(secretPath, "*")
+}
+
+// isSelection reports whether the object selects the items to mount by listing them, either as
+// a folder or by tags, rather than naming a single item.
+func isSelection(secret config.Secret) bool {
+	return isFolder(secret.SecretPath) || len(secret.Tags) > 0
+}
+
+// selectionName identifies a selecting object in logs and the last successful expansions.
+func selectionName(secret config.Secret) string {
+	if len(secret.Tags) == 0 {
+		return secret.SecretPath
+	}
+	return fmt.Sprintf("%v tags %v", secret.SecretPath, strings.Join(secret.Tags, ","))
+}
+
+func excluded(relPath string, excludes []func(string) bool) bool {
+	for _, exclude := range excludes {
+		if exclude(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTags reports whether the item has all of the tags.
+func hasTags(item *akeyless.Item, tags []string) bool {
+	itemTags := make(map[string]bool)
+	for _, t := range item.GetItemTags() {
+		itemTags[t] = true
+	}
+	for _, t := range tags {
+		if !itemTags[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// expandObjects returns the objects to mount, replacing every folder and tag selection with the
+// items it contains.
+func (p *Provider) expandObjects(ctx context.Context, cfg config.Config) ([]object, error) {
+	var objects []object
+	for _, secret := range cfg.Secrets {
+		if !isSelection(secret) {
+			objects = append(objects, object{Secret: secret})
+			continue
+		}
+
+		name := selectionName(secret)
+		children, err := p.expandFolder(ctx, secret, cfg)
+		if err != nil {
+			if !p.mounted || !cfg.PartialRotation() {
+				return nil, fmt.Errorf("failed to list folder %v: %w", name, err)
+			}
+			log.Printf("WARNING: rotation partially failed, keeping previous folder items, secretProviderClass: %v, folder: %v, error: %v", cfg.SecretProviderClass, name, err)
+			metrics.RotationObjectFailures.Inc(cfg.SecretProviderClass)
+			children = p.folders[name]
+		}
+		p.folders[name] = children
+		objects = append(objects, children...)
+	}
+	return objects, nil
+}
+
+// expandFolder lists the items the object selects. Objects selecting by tags without a folder
+// select from all items, which are mounted under their full paths.
+func (p *Provider) expandFolder(ctx context.Context, folder config.Secret, cfg config.Config) ([]object, error) {
+	prefix := folderPath(folder.SecretPath)
+	if prefix == "" {
+		prefix = "/"
+	}
+	var tag string
+	if len(folder.Tags) > 0 {
+		tag = folder.Tags[0]
+	}
+	var excludes []func(string) bool
+	for _, pattern := range folder.Exclude {
+		exclude, err := config.ExcludePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %v: %w", pattern, err)
+		}
+		excludes = append(excludes, exclude)
+	}
+
+	items, err := p.listItems(ctx, prefix, tag, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []object
+	for i := range items {
+		item := &items[i]
+		name := item.GetItemName()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !hasTags(item, folder.Tags) || excluded(strings.TrimPrefix(name, prefix), excludes) {
+			continue
+		}
+		if !folderItemTypes[item.GetItemType()] {
+			log.Printf("skipping item %v of folder %v, unsupported item type %v", name, folder.SecretPath, item.GetItemType())
+			continue
+		}
+
+		child := folder
+		child.SecretPath = name
+		child.Tags = nil
+		child.Exclude = nil
+		child.FileName = path.Join(folder.FileName, strings.TrimPrefix(name, prefix))
+		objects = append(objects, object{Secret: child, item: item})
+	}
+	return objects, nil
+}
+
+// ListItems returns all items below the folder, following pagination.
+func (p *Provider) ListItems(ctx context.Context, folder string, cfg config.Config) ([]akeyless.Item, error) {
+	return p.listItems(ctx, folder, "", cfg)
+}
+
+// listItems returns all items below the folder having the tag, any tag if empty.
+func (p *Provider) listItems(ctx context.Context, folder, tag string, cfg config.Config) ([]akeyless.Item, error) {
+	var items []akeyless.Item
+	var pageToken string
+	for {
+		body := akeyless.ListItems{}
+		if path := strings.TrimSuffix(folder, "/"); path != "" {
+			body.SetPath(path)
+		}
+		if tag != "" {
+			body.SetTag(tag)
+		}
+		if pageToken != "" {
+			body.SetPaginationToken(pageToken)
+		}
+		if cfg.UsingUID() {
+			body.SetUidToken(cfg.Session.Token())
+		} else {
+			body.SetToken(cfg.Session.Token())
+		}
+
+		out, res, err := cfg.Session.Client.ListItems(ctx).Body(body).Execute()
+		if err := finishCall(res, err, fmt.Sprintf("can't list items %v", folder)); err != nil {
+			return nil, err
+		}
+
+		items = append(items, out.GetItems()...)
+		pageToken = out.GetNextPage()
+		if pageToken == "" {
+			return items, nil
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/pkcs12"
+)
+
+// keyPassphraseSecretArg names the static secret whose value encrypts the private keys of an object.
+const keyPassphraseSecretArg = "keyPassphraseSecret"
+
+// encryptPrivateKeys returns the value of an object setting keyPassphraseSecret with its private
+// keys written as encrypted PKCS#8 PEM, protected with the value of the named static secret.
+// Certificate item values get their private_key_pem field encrypted, other values, e.g. PKI
+// certificate bundles or static secrets holding PEM keys, every unencrypted private key block.
+func (p *Provider) encryptPrivateKeys(ctx context.Context, secret config.Secret, value string, cfg config.Config) (string, error) {
+	passphraseSecret := stringArg(secret.SecretArgs, keyPassphraseSecretArg)
+	passphrase, err := p.GetStaticSecret(ctx, passphraseSecret, cfg)
+	if err != nil {
+		return "", fmt.Errorf("can't get key passphrase of %v: %w", secret.SecretPath, err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("key passphrase %v of %v is empty", passphraseSecret, secret.SecretPath)
+	}
+
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(value), &fields) == nil {
+		keyPEM, ok := fields["private_key_pem"].(string)
+		if !ok {
+			return "", fmt.Errorf("%v has no private key to encrypt", secret.SecretPath)
+		}
+		encrypted, err := encryptPEMKeys(keyPEM, passphrase)
+		if err != nil {
+			return "", fmt.Errorf("can't encrypt private key of %v: %w", secret.SecretPath, err)
+		}
+		fields["private_key_pem"] = encrypted
+		out, err := json.Marshal(fields)
+		if err != nil {
+			return "", fmt.Errorf("can't marshal %v: %w", secret.SecretPath, err)
+		}
+		return string(out), nil
+	}
+
+	encrypted, err := encryptPEMKeys(value, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("can't encrypt private key of %v: %w", secret.SecretPath, err)
+	}
+	return encrypted, nil
+}
+
+// encryptPEMKeys replaces the unencrypted private key blocks of a PEM text with encrypted PKCS#8
+// blocks, keeping all other blocks, e.g. certificates, as they are.
+func encryptPEMKeys(text, passphrase string) (string, error) {
+	var out strings.Builder
+	rest := []byte(text)
+	keys := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") || block.Type == "ENCRYPTED PRIVATE KEY" {
+			out.Write(pem.EncodeToMemory(block))
+			continue
+		}
+		key, err := parseKey(block)
+		if err != nil {
+			return "", err
+		}
+		der, err := pkcs12.EncryptPrivateKey(key, passphrase)
+		if err != nil {
+			return "", err
+		}
+		out.Write(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}))
+		keys++
+	}
+	if keys == 0 {
+		return "", errors.New("value contains no unencrypted PEM private key")
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// DefaultDescribeFallbackStaleness is how old item metadata may be to stand in for a throttled describe.
+const DefaultDescribeFallbackStaleness = 10 * time.Minute
+
+// itemMetadata remembers the last successful describe of every item on the node, so that mounts
+// can keep going when describes are throttled, e.g. by many rotation remounts at once.
+var itemMetadata = &metadataCache{staleness: DefaultDescribeFallbackStaleness, entries: make(map[string]metadataEntry)}
+
+// SetDescribeFallbackStaleness sets how old the metadata of an item may be to be used in place of
+// a throttled describe, 0 disables the fallback.
+func SetDescribeFallbackStaleness(staleness time.Duration) {
+	itemMetadata.mu.Lock()
+	defer itemMetadata.mu.Unlock()
+	itemMetadata.staleness = staleness
+}
+
+type metadataCache struct {
+	mu        sync.Mutex
+	staleness time.Duration
+	entries   map[string]metadataEntry
+}
+
+type metadataEntry struct {
+	item      akeyless.Item
+	described time.Time
+}
+
+func (c *metadataCache) put(key string, item *akeyless.Item, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.staleness <= 0 {
+		return
+	}
+	c.entries[key] = metadataEntry{item: *item, described: now}
+	// entries too stale to be used are dropped, so removed items don't accumulate
+	for k, e := range c.entries {
+		if now.Sub(e.described) > c.staleness {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// get returns the metadata of an item and its age, if it is within the staleness bound.
+func (c *metadataCache) get(key string, now time.Time) (*akeyless.Item, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || now.Sub(e.described) > c.staleness {
+		return nil, 0, false
+	}
+	item := e.item
+	return &item, now.Sub(e.described), true
+}
+
+// metadataKey identifies an item together with whom it was described as, like cacheKey.
+func metadataKey(cfg config.Config, itemName string) string {
+	return fetchIdentity(cfg) + "|" + cfg.AkeylessGatewayURL + "|" + itemName
+}
+
+// describeWithFallback describes an item, falling back to its last known type and version when
+// the gateway throttles the describe. The value itself is still fetched, so only a version
+// change since the last describe can be missed until describes succeed again.
+func (p *Provider) describeWithFallback(ctx context.Context, itemName string, cfg config.Config) (*akeyless.Item, error) {
+	key := metadataKey(cfg, itemName)
+	item, err := p.DescribeItem(ctx, itemName, cfg)
+	if err == nil {
+		itemMetadata.put(key, item, time.Now())
+		return item, nil
+	}
+	if !errors.Is(err, ErrThrottled) {
+		return nil, err
+	}
+
+	known, age, ok := itemMetadata.get(key, time.Now())
+	if !ok {
+		return nil, err
+	}
+	log.Printf("WARNING: describe throttled, using item metadata from %v ago, secretProviderClass: %v, item: %v, type: %v, version: %v",
+		age.Round(time.Second), cfg.SecretProviderClass, itemName, known.GetItemType(), known.GetLastVersion())
+	metrics.DescribeFallbacks.Inc(cfg.SecretProviderClass)
+	return known, nil
+}
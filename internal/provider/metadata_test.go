@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeWithFallback(t *testing.T) {
+	defer SetDescribeFallbackStaleness(DefaultDescribeFallbackStaleness)
+	SetDescribeFallbackStaleness(time.Minute)
+
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/a": {itemType: "STATIC_SECRET", version: 3, value: "value-a"},
+	})
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		AkeylessAccessID: t.Name(),
+		Secrets:          []config.Secret{{FileName: "a", SecretPath: "/a"}},
+	}}
+
+	p := NewProvider()
+	g.throttled["/describe-item"] = true
+	_, err := p.HandleMountRequest(context.Background(), cfg)
+	require.ErrorIs(t, err, ErrThrottled, "nothing known about the item yet")
+
+	g.throttled["/describe-item"] = false
+	_, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+
+	g.throttled["/describe-item"] = true
+	g.items["/a"] = fakeItem{itemType: "STATIC_SECRET", version: 3, value: "value-a2"}
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": "value-a2"}, mountedFiles(resp))
+	require.Equal(t, "3", resp.ObjectVersion[0].Version)
+
+	// a mount authenticated with a different token never uses the metadata described with another
+	// one, even naming the same access ID
+	cfg.Session = config.NewSessionWithToken(g.session.Client, "t-other")
+	_, err = p.HandleMountRequest(context.Background(), cfg)
+	require.ErrorIs(t, err, ErrThrottled)
+}
+
+func TestMetadataCache_Staleness(t *testing.T) {
+	c := &metadataCache{staleness: time.Minute, entries: make(map[string]metadataEntry)}
+	now := time.Now()
+	item := typedItem(config.Secret{SecretPath: "/a", SecretType: "STATIC_SECRET"})
+	c.put("a", item, now)
+
+	got, age, ok := c.get("a", now.Add(30*time.Second))
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, age)
+	require.Equal(t, "/a", got.GetItemName())
+
+	_, _, ok = c.get("a", now.Add(2*time.Minute))
+	require.False(t, ok)
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/processor"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// MetadataFileSuffix is appended to the fileName of objects setting metadata to name their sidecar file.
+const MetadataFileSuffix = ".meta.json"
+
+// objectMetadata is the provenance of a mounted object, as written to its sidecar file.
+type objectMetadata struct {
+	ItemName string `json:"itemName"`
+	ItemType string `json:"itemType"`
+	// Version is the object version reported to the driver for the mounted value
+	Version      string     `json:"version"`
+	Tags         []string   `json:"tags"`
+	LastRotation *time.Time `json:"lastRotation,omitempty"`
+}
+
+// metadataItem returns the described item of an object for its sidecar file. described is the
+// item the value was just fetched with, if it was described for it, otherwise the object's own
+// item is used unless it was only typed by secretType, in which case the item is described.
+func (p *Provider) metadataItem(ctx context.Context, obj object, typed bool, described *akeyless.Item, cfg config.Config) (*akeyless.Item, error) {
+	if described != nil {
+		return described, nil
+	}
+	if obj.item != nil && !typed {
+		return obj.item, nil
+	}
+	return p.describeWithFallback(ctx, obj.SecretPath, cfg)
+}
+
+func newObjectMetadata(item *akeyless.Item, version string) *objectMetadata {
+	meta := &objectMetadata{
+		ItemName: item.GetItemName(),
+		ItemType: item.GetItemType(),
+		Version:  version,
+		Tags:     item.GetItemTags(),
+	}
+	if meta.Tags == nil {
+		meta.Tags = []string{}
+	}
+	if rotated, ok := item.GetLastRotationDateOk(); ok && !rotated.IsZero() {
+		meta.LastRotation = rotated
+	}
+	return meta
+}
+
+// metadataFile renders the sidecar file of an object next to the file it is mounted as.
+func metadataFile(secret config.Secret, meta *objectMetadata) (processor.File, error) {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return processor.File{}, fmt.Errorf("can't marshal metadata of %v: %w", secret.SecretPath, err)
+	}
+	return processor.File{Path: secret.MountPath(secret.FileName + MetadataFileSuffix), Contents: data}, nil
+}
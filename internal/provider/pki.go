@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+const defaultPKIKeyType = "rsa2048"
+
+// getPKICertificate issues a certificate from a PKI certificate issuer. The private key is
+// generated locally and only a CSR is sent, the value is a PEM bundle of the certificate, its
+// issuing chain and the private key, see the cert-key post-processor to mount them as separate
+// files. The certificate is reused by rotation remounts until two thirds of its validity passed.
+// Supported secretArgs:
+//   - common-name: the certificate common name
+//   - alt-names: comma-separated DNS names and IP addresses
+//   - uri-sans: comma-separated URI subject alternative names
+//   - ttl: the certificate TTL, e.g. "24h", bounded by the issuer
+//   - key-type: rsa2048 (default), rsa3072, rsa4096, ec256 or ec384
+//   - extended-key-usage: comma-separated, e.g. "serverauth,clientauth"
+func (p *Provider) getPKICertificate(ctx context.Context, issuerName string, args map[string]interface{}, cfg config.Config) (int32, string, error) {
+	leaseID := leaseKey(issuerName, args)
+	lease := p.leases[leaseID]
+	if lease != nil && time.Now().Before(lease.renewAt) {
+		return lease.version, lease.value, nil
+	}
+
+	commonName := stringArg(args, "common-name")
+	altNames := stringArg(args, "alt-names")
+	uriSans := stringArg(args, "uri-sans")
+
+	key, err := generatePKIKey(stringArg(args, "key-type"))
+	if err != nil {
+		return 0, "", fmt.Errorf("can't issue certificate from %v: %w", issuerName, err)
+	}
+	csr, err := createCSR(key, commonName, altNames, uriSans)
+	if err != nil {
+		return 0, "", fmt.Errorf("can't issue certificate from %v: %w", issuerName, err)
+	}
+
+	body := akeyless.GetPKICertificate{CertIssuerName: issuerName}
+	body.SetCsrDataBase64(base64.StdEncoding.EncodeToString(csr))
+	if commonName != "" {
+		body.SetCommonName(commonName)
+	}
+	if altNames != "" {
+		body.SetAltNames(altNames)
+	}
+	if uriSans != "" {
+		body.SetUriSans(uriSans)
+	}
+	if ttl := stringArg(args, "ttl"); ttl != "" {
+		body.SetTtl(ttl)
+	}
+	if eku := stringArg(args, "extended-key-usage"); eku != "" {
+		body.SetExtendedKeyUsage(eku)
+	}
+	if cfg.UsingUID() {
+		body.SetUidToken(cfg.Session.Token())
+	} else {
+		body.SetToken(cfg.Session.Token())
+	}
+
+	out, res, err := cfg.Session.Client.GetPKICertificate(ctx).Body(body).Execute()
+	if err := finishCall(res, err, "can't issue certificate"); err != nil {
+		return 0, "", err
+	}
+
+	certPEM := strings.TrimSpace(out.GetData())
+	leaf, err := parseLeafCertificate(certPEM)
+	if err != nil {
+		return 0, "", fmt.Errorf("can't parse certificate issued by %v: %w", issuerName, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return 0, "", err
+	}
+
+	bundle := certPEM + "\n"
+	if chain := strings.TrimSpace(out.GetParentCert()); chain != "" {
+		bundle += chain + "\n"
+	}
+	bundle += string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+
+	version := int32(1)
+	if lease != nil {
+		version = lease.version + 1
+	}
+	validity := leaf.NotAfter.Sub(leaf.NotBefore)
+	p.leases[leaseID] = &dynamicLease{
+		version: version,
+		value:   bundle,
+		issued:  time.Now(),
+		renewAt: leaf.NotBefore.Add(validity * 2 / 3),
+	}
+	log.Printf("issued certificate, secretProviderClass: %v, issuer: %v, serial: %v, expires: %v", cfg.SecretProviderClass, issuerName, leaf.SerialNumber, leaf.NotAfter)
+	return version, bundle, nil
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	v, ok := args[name]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func generatePKIKey(keyType string) (crypto.Signer, error) {
+	if keyType == "" {
+		keyType = defaultPKIKeyType
+	}
+	switch keyType {
+	case "rsa2048":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "rsa3072":
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case "rsa4096":
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case "ec256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ec384":
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	}
+	return nil, fmt.Errorf("unsupported key-type %v", keyType)
+}
+
+func createCSR(key crypto.Signer, commonName, altNames, uriSans string) ([]byte, error) {
+	tmpl := &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}
+	for _, name := range splitList(altNames) {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+	for _, raw := range splitList(uriSans) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uri-sans %v: %w", raw, err)
+		}
+		tmpl.URIs = append(tmpl.URIs, u)
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSR: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+func parseLeafCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, errors.New("no PEM certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIssuer signs CSRs like a PKI certificate issuer, with the given validity.
+func fakeIssuer(t *testing.T, validity time.Duration) func(body map[string]interface{}) interface{} {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test-ca"}, IsCA: true,
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour), BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	serial := int64(1)
+	return func(body map[string]interface{}) interface{} {
+		csrPEM, err := base64.StdEncoding.DecodeString(body["csr-data-base64"].(string))
+		require.NoError(t, err)
+		block, _ := pem.Decode(csrPEM)
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		require.NoError(t, err)
+		require.NoError(t, csr.CheckSignature())
+
+		serial++
+		tmpl := &x509.Certificate{SerialNumber: big.NewInt(serial), Subject: csr.Subject, DNSNames: csr.DNSNames, IPAddresses: csr.IPAddresses,
+			NotBefore: time.Now().Add(-time.Second), NotAfter: time.Now().Add(validity)}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, csr.PublicKey, caKey)
+		require.NoError(t, err)
+		return map[string]interface{}{
+			"data":        string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			"parent_cert": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+		}
+	}
+}
+
+func TestHandleMountRequest_PKICertificate(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/pki/issuer": {itemType: "PKI_CERT_ISSUER", value: fakeIssuer(t, time.Hour)},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "tls", SecretPath: "/pki/issuer", PostProcessor: "cert-key", SecretArgs: map[string]interface{}{
+			"common-name": "app.example.com",
+			"alt-names":   "app.example.com,10.0.0.1",
+			"ttl":         "1h",
+			"key-type":    "ec256",
+		}}},
+	}}
+
+	p := NewProvider()
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+
+	certBlock, rest := pem.Decode([]byte(files["tls.crt"]))
+	require.NotNil(t, certBlock)
+	caBlock, _ := pem.Decode(rest)
+	require.NotNil(t, caBlock, "issuing chain")
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	require.NoError(t, err)
+	require.Equal(t, "app.example.com", cert.Subject.CommonName)
+	require.Equal(t, []string{"app.example.com"}, cert.DNSNames)
+	require.Len(t, cert.IPAddresses, 1)
+	require.Equal(t, "1h", g.bodies["/get-pki-certificate"]["ttl"])
+
+	keyBlock, _ := pem.Decode([]byte(files["tls.key"]))
+	require.NotNil(t, keyBlock)
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	require.NoError(t, err)
+	require.True(t, key.(*ecdsa.PrivateKey).PublicKey.Equal(cert.PublicKey))
+
+	// the certificate is reused until it is due for renewal
+	_, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, 1, g.calls["/get-pki-certificate"])
+
+	p.leases[leaseKey("/pki/issuer", cfg.Secrets[0].SecretArgs)].renewAt = time.Now()
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, 2, g.calls["/get-pki-certificate"])
+	require.Equal(t, "2", resp.ObjectVersion[0].Version)
+}
+
+func TestHandleMountRequest_PKICertificateArgs(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/pki/issuer": {itemType: "PKI_CERT_ISSUER", value: fakeIssuer(t, time.Hour)},
+	})
+
+	// two objects of one issuer with different common names get certificates of their own
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "api", SecretPath: "/pki/issuer", SecretArgs: map[string]interface{}{"common-name": "api.example.com", "key-type": "ec256"}},
+			{FileName: "web", SecretPath: "/pki/issuer", SecretArgs: map[string]interface{}{"common-name": "web.example.com", "key-type": "ec256"}},
+		},
+	}}
+	commonName := func(bundle string) string {
+		block, _ := pem.Decode([]byte(bundle))
+		require.NotNil(t, block)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		return cert.Subject.CommonName
+	}
+
+	p := NewProvider()
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, "api.example.com", commonName(files["api"]))
+	require.Equal(t, "web.example.com", commonName(files["web"]))
+	require.Equal(t, 2, g.calls["/get-pki-certificate"])
+
+	// and reuse them on rotation remounts
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "web.example.com", commonName(mountedFiles(resp)["web"]))
+	require.Equal(t, 2, g.calls["/get-pki-certificate"])
+}
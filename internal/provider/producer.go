@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// producerArg is the secretArg naming the kind of a target-based dynamic secret producer. Objects
+// setting it mount the short-lived token the producer issues instead of its JSON output.
+const producerArg = "producer"
+
+// tokenProducer is a kind of target-based dynamic secret producer issuing short-lived tokens.
+type tokenProducer struct {
+	// args are the secretArgs passed to the producer as arguments of the same name
+	args []string
+	// token returns the mounted token of the producer's output
+	token func(out map[string]interface{}) (string, error)
+}
+
+var tokenProducers = map[string]tokenProducer{
+	"artifactory": {args: []string{"scope", "audience"}, token: outputField("access_token")},
+	"github":      {args: []string{"repositories", "permissions", "installation-id"}, token: outputField("token")},
+	"gcp":         {args: []string{"scopes", "service-account"}, token: gcpCredentials},
+}
+
+// tokenProducerOf returns the producer named by the producer secretArg, nil if it isn't set.
+func tokenProducerOf(args map[string]interface{}) (*tokenProducer, error) {
+	name := stringArg(args, producerArg)
+	if name == "" {
+		return nil, nil
+	}
+	producer, ok := tokenProducers[name]
+	if !ok {
+		names := make([]string, 0, len(tokenProducers))
+		for n := range tokenProducers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unsupported producer %v, available: %v", name, strings.Join(names, ", "))
+	}
+	return &producer, nil
+}
+
+// producerArgs returns the producer-specific secretArgs as key=value producer arguments. Lists
+// are passed comma-separated, maps as JSON.
+func (t *tokenProducer) producerArgs(args map[string]interface{}) ([]string, error) {
+	var out []string
+	for _, name := range t.args {
+		v, ok := args[name]
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case []interface{}:
+			values := make([]string, 0, len(v))
+			for _, e := range v {
+				values = append(values, fmt.Sprint(e))
+			}
+			out = append(out, name+"="+strings.Join(values, ","))
+		case map[string]interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %v secretArg: %w", name, err)
+			}
+			out = append(out, name+"="+string(encoded))
+		default:
+			out = append(out, fmt.Sprintf("%s=%v", name, v))
+		}
+	}
+	return out, nil
+}
+
+// render returns the token of the producer's output and when it expires, zero if the output
+// doesn't tell.
+func (t *tokenProducer) render(out map[string]interface{}, issued time.Time) (string, time.Time, error) {
+	token, err := t.token(out)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, tokenExpiry(out, issued), nil
+}
+
+func outputField(name string) func(map[string]interface{}) (string, error) {
+	return func(out map[string]interface{}) (string, error) {
+		if token, ok := out[name].(string); ok && token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("producer output has no %v", name)
+	}
+}
+
+// gcpCredentials returns the service account key file of a GCP producer issuing keys, otherwise
+// its access token.
+func gcpCredentials(out map[string]interface{}) (string, error) {
+	if keyData, ok := out["private_key_data"].(string); ok && keyData != "" {
+		key, err := base64.StdEncoding.DecodeString(keyData)
+		if err != nil {
+			return "", fmt.Errorf("invalid private_key_data: %w", err)
+		}
+		return string(key), nil
+	}
+	for _, name := range []string{"access_token", "token"} {
+		if token, ok := out[name].(string); ok && token != "" {
+			return token, nil
+		}
+	}
+	return "", errors.New("producer output has no private_key_data or access_token")
+}
+
+// tokenExpiry returns when the token of a producer's output expires, from an RFC 3339 expires_at
+// or expiration field, or an expires_in or ttl in seconds. It returns zero if there is none.
+func tokenExpiry(out map[string]interface{}, issued time.Time) time.Time {
+	for _, name := range []string{"expires_at", "expiration"} {
+		if s, ok := out[name].(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return t
+			}
+		}
+	}
+	for _, name := range []string{"expires_in", "ttl"} {
+		v, ok := out[name]
+		if !ok {
+			continue
+		}
+		if seconds, err := strconv.ParseFloat(fmt.Sprint(v), 64); err == nil && seconds > 0 {
+			return issued.Add(time.Duration(seconds * float64(time.Second)))
+		}
+	}
+	return time.Time{}
+}
@@ -2,29 +2,54 @@ package provider
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/akeylesslabs/akeyless-go/v4"
 	"log"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/processor"
 	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
 )
 
-var apiErr akeyless.GenericOpenAPIError
+// ErrGatewayRequired is returned for items that can only be served by an Akeyless Gateway
+// when the provider is configured with the SaaS API endpoint.
+var ErrGatewayRequired = errors.New("item requires an Akeyless Gateway")
+
+// gatewayRequiredTypes are the item types the SaaS API can't serve, since their values are
+// generated or decrypted by the customer's gateway.
+var gatewayRequiredTypes = map[string]bool{
+	"DYNAMIC_SECRET": true,
+	"TOKENIZER":      true,
+}
 
 // Provider implements the secrets-store-csi-driver Provider interface and communicates with the Akeyless
 type cacheEntity struct {
 	EntryTime time.Time
 	FileName  string
 	Value     string
+	// Metadata is the provenance of the value for the object's sidecar file, if it has one
+	Metadata *objectMetadata
 }
 type Provider struct {
 	cache    map[string]*cacheEntity
 	versions map[string]string
+	// objects are the mounted objects, with folders expanded to the items they contain
+	objects []object
+	// folders holds the last successful expansion of every folder
+	folders map[string][]object
+	// leases holds the dynamic secret credentials issued to the mount, reused on rotation remounts
+	leases map[string]*dynamicLease
+	// mounted is set once a mount succeeded, so that later mounts are rotation remounts
+	mounted bool
 }
 
 type Item struct {
@@ -35,36 +60,213 @@ type Item struct {
 
 func NewProvider() *Provider {
 	p := &Provider{
-		cache: make(map[string]*cacheEntity),
+		cache:   make(map[string]*cacheEntity),
+		folders: make(map[string][]object),
+		leases:  make(map[string]*dynamicLease),
 	}
 	return p
 }
 
-func (p *Provider) loadItems(ctx context.Context, cfg config.Config) {
+// ClearCache drops all cached secret values of cfg so the next mount fetches them again.
+func (p *Provider) ClearCache(cfg config.Config) {
+	p.cache = make(map[string]*cacheEntity)
+	p.leases = make(map[string]*dynamicLease)
+	for _, secret := range cfg.Secrets {
+		sharedCache.delete(cacheKey(cfg, secret))
+	}
+	for _, obj := range p.objects {
+		sharedCache.delete(cacheKey(cfg, obj.Secret))
+	}
+}
+
+// evictNoCache drops the values of the objects setting noCache once they are in the mount
+// response, so they don't outlive the mount request in memory.
+func (p *Provider) evictNoCache() {
+	for _, obj := range p.objects {
+		if obj.NoCache() {
+			delete(p.cache, objectKey(obj.Secret))
+		}
+	}
+}
+
+// MountedPaths returns the Akeyless paths of the objects of the last mount, folders expanded.
+func (p *Provider) MountedPaths() []string {
+	paths := make([]string, 0, len(p.objects))
+	for _, obj := range p.objects {
+		paths = append(paths, obj.SecretPath)
+	}
+	return paths
+}
+
+// MountedContentTypes returns the declared content types of the objects of the last mount by
+// their Akeyless paths, objects without a content type are left out.
+func (p *Provider) MountedContentTypes() map[string]string {
+	types := make(map[string]string)
+	for _, obj := range p.objects {
+		if obj.ContentType != "" {
+			types[obj.SecretPath] = obj.ContentType
+		}
+	}
+	return types
+}
 
+func (p *Provider) loadItems(ctx context.Context, cfg config.Config) error {
+	previousVersions := p.versions
 	p.versions = make(map[string]string)
 
-	body := akeyless.GetSecretValue{}
-	if cfg.UsingUID() {
-		body.SetUidToken(config.GetAuthToken())
-	} else {
-		body.SetToken(config.GetAuthToken())
+	objects, err := p.expandObjects(ctx, cfg)
+	if err != nil {
+		return err
 	}
+	p.objects = objects
 
-	for _, secret := range cfg.Parameters.Secrets {
-		version, secVal, err := p.GetSecretByType(ctx, secret.SecretPath, cfg)
+	for _, obj := range objects {
+		obj := obj
+		secret := obj.Secret
+		versionKey := objectVersionID(secret)
+		key := objectKey(secret)
+		// objects typed by secretType are never described, their version is unknown
+		typed := false
+		if obj.item == nil {
+			obj.item = typedItem(secret)
+			typed = obj.item != nil
+		}
+		var described *akeyless.Item
+		produced := false
+		fetch := func() (int32, string, error) {
+			if obj.item != nil {
+				return p.getItemValue(ctx, obj.item, secret.SecretArgs, cfg)
+			}
+			item, err := p.describeWithFallback(ctx, secret.SecretPath, cfg)
+			if err != nil {
+				return 0, "", err
+			}
+			described = item
+			produced = producedItemTypes[item.GetItemType()]
+			return p.getItemValue(ctx, item, secret.SecretArgs, cfg)
+		}
+		var version int32
+		var secVal string
+		var err error
+		if secret.NoCache() || (obj.item != nil && producedItemTypes[obj.item.GetItemType()]) {
+			version, secVal, err = fetch()
+		} else {
+			sharedKey := cacheKey(cfg, secret)
+			version, secVal, err = sharedCache.get(sharedKey, fetch)
+			// the type of described items is only known once fetched
+			if produced {
+				sharedCache.delete(sharedKey)
+			}
+		}
+		// versions are those of the fetched value, decrypting it or encrypting its keys again
+		// doesn't rotate it
+		plain := secVal
+		if err == nil && stringArg(secret.SecretArgs, decryptKeySecretArg) != "" {
+			secVal, err = p.decryptValue(ctx, secret, secVal, cfg)
+		}
+		if err == nil && stringArg(secret.SecretArgs, keyPassphraseSecretArg) != "" {
+			secVal, err = p.encryptPrivateKeys(ctx, secret, secVal, cfg)
+		}
+		var metaItem *akeyless.Item
+		if err == nil && secret.Metadata {
+			metaItem, err = p.metadataItem(ctx, obj, typed, described, cfg)
+		}
 		if err != nil {
-			log.Fatalf(err.Error())
-			return
+			// Initial mounts always fail as a whole, so a pod never starts with missing files.
+			// Objects setting noCache have no previous value to keep.
+			if !p.mounted || !cfg.PartialRotation() || secret.NoCache() {
+				return fmt.Errorf("failed to load secret %v: %w", secret.SecretPath, err)
+			}
+
+			// Keep serving the previously mounted value, if any, until the object recovers.
+			log.Printf("WARNING: rotation partially failed, keeping previous value, secretProviderClass: %v, object: %v, error: %v", cfg.SecretProviderClass, secret.SecretPath, err)
+			metrics.RotationObjectFailures.Inc(cfg.SecretProviderClass)
+			if prev, ok := previousVersions[versionKey]; ok {
+				p.versions[versionKey] = prev
+			}
+			continue
+		}
+		warnIfSuspicious(cfg, secret.SecretPath, secVal)
+		if typed && version == 0 {
+			p.versions[versionKey] = contentVersion(plain)
+		} else {
+			p.versions[versionKey] = strconv.Itoa(int(version))
+		}
+		ce, ok := p.cache[key]
+		if !ok || ce == nil || time.Now().Sub(ce.EntryTime) > time.Minute*5 || secret.NoCache() {
+			p.cache[key] = &cacheEntity{FileName: secret.FileName}
 		}
-		p.versions[fmt.Sprintf("%s:%s", secret.FileName, secret.SecretPath)] = strconv.Itoa(int(version))
-		ce, ok := p.cache[secret.SecretPath]
-		if !ok || ce == nil || time.Now().Sub(ce.EntryTime) > time.Minute*5 {
-			p.cache[secret.SecretPath] = &cacheEntity{FileName: secret.FileName}
+		p.cache[key].Value = secVal
+		p.cache[key].EntryTime = time.Now()
+		if metaItem != nil {
+			p.cache[key].Metadata = newObjectMetadata(metaItem, p.versions[versionKey])
 		}
-		p.cache[secret.SecretPath].Value = secVal
-		p.cache[secret.SecretPath].EntryTime = time.Now()
 	}
+
+	return nil
+}
+
+// jsonKey returns the value of a key of a static secret holding a JSON object, strings as they are
+// and other values as JSON.
+func jsonKey(itemName, value, key string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("static secret %v is not a JSON object, can't get its key %q", itemName, key)
+	}
+	keyVal, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("static secret %v has no key %q, available keys: %v", itemName, key, strings.Join(fieldNames(fields), ", "))
+	}
+	if s, ok := keyVal.(string); ok {
+		return s, nil
+	}
+	out, err := json.Marshal(keyVal)
+	if err != nil {
+		return "", fmt.Errorf("can't marshal key %q of secret %v: %w", key, itemName, err)
+	}
+	return string(out), nil
+}
+
+func fieldNames(fields map[string]interface{}) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// versionArg returns the version an object is pinned to by the "version" secretArg, 0 if unset.
+func versionArg(args map[string]interface{}) (int32, error) {
+	v := stringArg(args, "version")
+	if v == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("version must be a positive number, got %q", v)
+	}
+	return int32(version), nil
+}
+
+// objectKey identifies an object of the mount, objects may share a secretPath but not the file
+// they are mounted as.
+func objectKey(secret config.Secret) string {
+	return fmt.Sprintf("%s:%s", secret.MountPath(secret.FileName), secret.SecretPath)
+}
+
+// objectVersionID is the ObjectVersion id of an object, which the driver's rotation reconciler
+// compares across remounts to detect changed content. It identifies the fetched value only, the
+// secretPath and the secretArgs selecting it if any, so it is stable across remounts and renames
+// of fileName, and objects mounting the same value share it along with its version.
+func objectVersionID(secret config.Secret) string {
+	if len(secret.SecretArgs) == 0 {
+		return secret.SecretPath
+	}
+	// maps are marshalled with sorted keys, the id doesn't depend on the order of the args
+	args, _ := json.Marshal(secret.SecretArgs)
+	sum := sha256.Sum256(args)
+	return secret.SecretPath + "#" + hex.EncodeToString(sum[:8])
 }
 
 func (p *Provider) GetSecretByType(ctx context.Context, itemName string, cfg config.Config) (int32, string, error) {
@@ -72,20 +274,59 @@ func (p *Provider) GetSecretByType(ctx context.Context, itemName string, cfg con
 	if err != nil {
 		return 0, "", err
 	}
+	return p.getItemValue(ctx, item, nil, cfg)
+}
+
+// getItemValue fetches the value of an item whose type and version are already known,
+// args are the secretArgs of the object.
+func (p *Provider) getItemValue(ctx context.Context, item *akeyless.Item, args map[string]interface{}, cfg config.Config) (int32, string, error) {
+	var err error
+	itemName := item.GetItemName()
 	version := item.GetLastVersion()
 	secretType := item.GetItemType()
+	if gatewayRequiredTypes[secretType] && cfg.UsingSaaS() {
+		return 0, "", fmt.Errorf("%w: %s is a %s, set akeylessGatewayURL to your gateway API URL instead of %s", ErrGatewayRequired, itemName, secretType, cfg.AkeylessGatewayURL)
+	}
 
 	var secret string
 	switch secretType {
 	case "STATIC_SECRET":
-		secret, err = p.GetStaticSecret(ctx, item.GetItemName(), cfg)
+		var pinned int32
+		if pinned, err = versionArg(args); err != nil {
+			err = fmt.Errorf("invalid version secretArg for %v: %w", itemName, err)
+			break
+		}
+		if pinned > 0 {
+			version = pinned
+		}
+		secret, err = p.getStaticSecretVersion(ctx, item.GetItemName(), pinned, cfg)
+		if key := stringArg(args, "key"); err == nil && key != "" {
+			secret, err = jsonKey(itemName, secret, key)
+		}
 	case "CERTIFICATE":
-		secret, err = p.GetCertificate(ctx, item.GetItemName(), cfg)
+		secret, err = p.getCertificate(ctx, item.GetItemName(), args, cfg)
 	case "ROTATED_SECRET":
-		secret, err = p.GetRotatedSecret(ctx, item.GetItemName(), cfg)
+		raw := config.Secret{SecretArgs: args}.Raw()
+		secret, err = p.GetRotatedSecret(ctx, item.GetItemName(), stringArg(args, "field"), raw, cfg)
+	case "DYNAMIC_SECRET":
+		version, secret, err = p.getDynamicSecret(ctx, item.GetItemName(), args, cfg)
+	case "PKI_CERT_ISSUER":
+		version, secret, err = p.getPKICertificate(ctx, item.GetItemName(), args, cfg)
+	case "USC":
+		secret, err = p.getUSCSecret(ctx, item.GetItemName(), args, cfg)
+	case "CLASSIC_KEY":
+		secret, err = p.getClassicKeyPublic(ctx, item.GetItemName(), args, cfg)
+	case "TOKENIZER":
+		secret, err = p.detokenize(ctx, item.GetItemName(), args, cfg)
 	default:
+		if isDFCKeyType(secretType) {
+			secret, err = p.getDFCKeyPublic(ctx, item, args, cfg)
+			break
+		}
 		return 0, "", fmt.Errorf("unsupported item type %s for secret %s", secretType, itemName)
 	}
+	ns, sa := metrics.Identity(cfg.PodInfo.Namespace, cfg.PodInfo.ServiceAccountName)
+	metrics.SecretFetches.Inc(cfg.SecretProviderClass, ns, sa, secretType, metrics.Result(err))
 	return version, secret, err
 }
 
@@ -95,24 +336,15 @@ func (p *Provider) DescribeItem(ctx context.Context, itemName string, cfg config
 	}
 
 	if cfg.UsingUID() {
-		body.SetUidToken(config.GetAuthToken())
+		body.SetUidToken(cfg.Session.Token())
 	} else {
-		body.SetToken(config.GetAuthToken())
+		body.SetToken(cfg.Session.Token())
 	}
 
-	gsvOut, res, err := config.AklClient.DescribeItem(ctx).Body(body).Execute()
-	if err != nil {
-		if errors.As(err, &apiErr) {
-			var item *Item
-			err = json.Unmarshal(apiErr.Body(), &item)
-			if err != nil {
-				return nil, fmt.Errorf("can't describe item: %v, error: %v", itemName, string(apiErr.Body()))
-			}
-		} else {
-			return nil, fmt.Errorf("can't describe item: %w", err)
-		}
+	gsvOut, res, err := cfg.Session.Client.DescribeItem(ctx).Body(body).Execute()
+	if err := finishCall(res, err, fmt.Sprintf("can't describe item %v", itemName)); err != nil {
+		return nil, err
 	}
-	defer res.Body.Close()
 
 	return &gsvOut, nil
 }
@@ -123,19 +355,15 @@ func (p *Provider) GetCertificate(ctx context.Context, itemName string, cfg conf
 	}
 
 	if cfg.UsingUID() {
-		body.SetUidToken(config.GetAuthToken())
+		body.SetUidToken(cfg.Session.Token())
 	} else {
-		body.SetToken(config.GetAuthToken())
+		body.SetToken(cfg.Session.Token())
 	}
 
-	gcvOut, res, err := config.AklClient.GetCertificateValue(ctx).Body(body).Execute()
-	if err != nil {
-		if errors.As(err, &apiErr) {
-			return "", fmt.Errorf("can't get certificate value: %v", string(apiErr.Body()))
-		}
-		return "", fmt.Errorf("can't get certificate value: %w", err)
+	gcvOut, res, err := cfg.Session.Client.GetCertificateValue(ctx).Body(body).Execute()
+	if err := finishCall(res, err, "can't get certificate value"); err != nil {
+		return "", err
 	}
-	defer res.Body.Close()
 
 	out, err := json.Marshal(gcvOut)
 	if err != nil {
@@ -146,24 +374,28 @@ func (p *Provider) GetCertificate(ctx context.Context, itemName string, cfg conf
 }
 
 func (p *Provider) GetStaticSecret(ctx context.Context, itemName string, cfg config.Config) (string, error) {
+	return p.getStaticSecretVersion(ctx, itemName, 0, cfg)
+}
+
+// getStaticSecretVersion returns the given version of a static secret, 0 for the latest one.
+func (p *Provider) getStaticSecretVersion(ctx context.Context, itemName string, version int32, cfg config.Config) (string, error) {
 	body := akeyless.GetSecretValue{
 		Names: []string{itemName},
 	}
+	if version > 0 {
+		body.SetVersion(version)
+	}
 
 	if cfg.UsingUID() {
-		body.SetUidToken(config.GetAuthToken())
+		body.SetUidToken(cfg.Session.Token())
 	} else {
-		body.SetToken(config.GetAuthToken())
+		body.SetToken(cfg.Session.Token())
 	}
 
-	gsvOut, res, err := config.AklClient.GetSecretValue(ctx).Body(body).Execute()
-	if err != nil {
-		if errors.As(err, &apiErr) {
-			return "", fmt.Errorf("can't get secret value: %v", string(apiErr.Body()))
-		}
-		return "", fmt.Errorf("can't get secret value: %w", err)
+	gsvOut, res, err := cfg.Session.Client.GetSecretValue(ctx).Body(body).Execute()
+	if err := finishCall(res, err, "can't get secret value"); err != nil {
+		return "", err
 	}
-	defer res.Body.Close()
 	val, ok := gsvOut[itemName]
 	if !ok {
 		return "", fmt.Errorf("can't get secret: %v", itemName)
@@ -177,18 +409,135 @@ func (p *Provider) GetStaticSecret(ctx context.Context, itemName string, cfg con
 
 // HandleMountRequest mounts content of the vault object to target path
 func (p *Provider) HandleMountRequest(ctx context.Context, cfg config.Config) (*pb.MountResponse, error) {
-	p.loadItems(ctx, cfg)
+	defer p.evictNoCache()
+	if err := p.loadItems(ctx, cfg); err != nil {
+		return nil, err
+	}
+	p.mounted = true
+
+	var outFiles []processor.File
+	// contentTypes holds the declared content type of every file of outFiles
+	var contentTypes []string
+	var mounted []mountedObject
+	// values feeds the templates, the dotenv and the aggregate file, keyed by the objects' mount paths
+	values := make(map[string]string)
+	// shared holds the index in outFiles of every file objects setting concat or a writeMode
+	// append to or overwrite
+	shared := make(map[string]int)
+	for _, obj := range p.objects {
+		secret := obj.Secret
+		value, ok := p.cache[objectKey(secret)]
+		if !ok {
+			continue
+		}
+		values[secret.MountPath(secret.FileName)] = value.Value
+		if secret.TemplateOnly {
+			continue
+		}
+		raw := []byte(value.Value)
+		if secret.Transform != "" {
+			transform, err := processor.ParseTransform(secret.Transform)
+			if err == nil {
+				raw, err = transform.Apply(raw)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to transform secret %v: %w", secret.SecretPath, err)
+			}
+		}
+		formatted, err := processor.Format(stringArg(secret.SecretArgs, processor.FormatArg), raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format secret %v: %w", secret.SecretPath, err)
+		}
+		if secret.DecodeBase64 {
+			if formatted, err = processor.DecodeBase64(formatted); err != nil {
+				return nil, fmt.Errorf("failed to decode secret %v: %w", secret.SecretPath, err)
+			}
+		}
+		out, err := processor.Process(secret.PostProcessor, processor.Input{
+			FileName: secret.FileName,
+			Value:    formatted,
+			Args:     secret.SecretArgs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoding := secret.Encoding()
+		for i := range out {
+			out[i].Contents = encoding.Apply(out[i].Contents)
+			out[i].Path = secret.MountPath(out[i].Path)
+			// post-processors derive paths from values, e.g. the keys of exploded JSON
+			if !config.WithinMount(out[i].Path) {
+				return nil, fmt.Errorf("file %q of secret %v escapes the mount", out[i].Path, secret.SecretPath)
+			}
+			if secret.Concat || secret.WriteMode == config.WriteModeOverwrite {
+				if at, ok := shared[out[i].Path]; ok && secret.Concat {
+					outFiles[at].Contents = concatPart(outFiles[at].Contents, out[i].Contents)
+					continue
+				} else if ok {
+					outFiles[at], contentTypes[at] = out[i], secret.ContentType
+					continue
+				}
+				shared[out[i].Path] = len(outFiles)
+			}
+			outFiles = append(outFiles, out[i])
+			contentTypes = append(contentTypes, secret.ContentType)
+		}
+		if secret.Metadata && value.Metadata != nil {
+			meta, err := metadataFile(secret, value.Metadata)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, meta)
+			outFiles = append(outFiles, meta)
+			contentTypes = append(contentTypes, "application/json")
+		}
+		mounted = append(mounted, mountedObject{secretPath: secret.SecretPath, files: out})
+	}
+	rendered, err := renderTemplates(cfg, values)
+	if err != nil {
+		return nil, err
+	}
+	rendered = append(rendered, renderDotenv(cfg, values)...)
+	if cfg.AggregateSecrets {
+		// map keys are marshalled sorted, so the file only changes with its values
+		all, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("can't marshal %v: %w", config.AggregateFileName, err)
+		}
+		rendered = append(rendered, processor.File{Path: config.AggregateFileName, Contents: all})
+	}
+	for _, f := range rendered {
+		outFiles = append(outFiles, f)
+		contentTypes = append(contentTypes, "")
+		mounted = append(mounted, mountedObject{secretPath: f.Path, files: []processor.File{f}})
+	}
+	if err := checkResponseSize(cfg, mounted); err != nil {
+		return nil, err
+	}
 
 	var files []*pb.File
-	for name, value := range p.cache {
-		files = append(files, &pb.File{Path: value.FileName, Mode: int32(cfg.FilePermission), Contents: []byte(value.Value)})
-		log.Printf("secret added to mount response, directory: %v, file: %v", cfg.TargetPath, name)
+	for i, f := range outFiles {
+		mode := cfg.FilePermission
+		if f.Mode != 0 {
+			// post-processors restrict the permission of files clients refuse to read otherwise
+			mode &= f.Mode
+		}
+		files = append(files, &pb.File{Path: f.Path, Mode: int32(mode), Contents: f.Contents})
+		switch {
+		case cfg.SummarizeLogs:
+		case contentTypes[i] != "":
+			log.Printf("secret added to mount response, secretProviderClass: %v, directory: %v, file: %v, contentType: %v", cfg.SecretProviderClass, cfg.TargetPath, f.Path, contentTypes[i])
+		default:
+			log.Printf("secret added to mount response, secretProviderClass: %v, directory: %v, file: %v", cfg.SecretProviderClass, cfg.TargetPath, f.Path)
+		}
 	}
 
 	var ov []*pb.ObjectVersion
 	for k, v := range p.versions {
 		ov = append(ov, &pb.ObjectVersion{Id: k, Version: v})
 	}
+	// sorted, so identical mounts get identical responses
+	sort.Slice(ov, func(i, j int) bool { return ov[i].Id < ov[j].Id })
 
 	return &pb.MountResponse{
 		ObjectVersion: ov,
@@ -196,29 +545,62 @@ func (p *Provider) HandleMountRequest(ctx context.Context, cfg config.Config) (*
 	}, nil
 }
 
-func (p *Provider) GetRotatedSecret(ctx context.Context, itemName string, cfg config.Config) (string, error) {
+// concatPart appends the next part of a concatenated file, separated by a newline unless the
+// previous part already ends with one, so PEM blocks never run into each other.
+func concatPart(contents, part []byte) []byte {
+	out := make([]byte, 0, len(contents)+1+len(part))
+	out = append(out, contents...)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	return append(out, part...)
+}
+
+// GetRotatedSecret returns the JSON value of a rotated secret, or only its field named by
+// field, e.g. "password", when set. Raw values are requested as the gateway stores them, strings
+// returned byte for byte and other values as compact instead of indented JSON.
+func (p *Provider) GetRotatedSecret(ctx context.Context, itemName, field string, raw bool, cfg config.Config) (string, error) {
 	body := akeyless.GetRotatedSecretValue{
 		Names: itemName,
 	}
-	body.SetJson(true)
+	if !raw {
+		body.SetJson(true)
+	}
 	if cfg.UsingUID() {
-		body.SetUidToken(config.GetAuthToken())
+		body.SetUidToken(cfg.Session.Token())
 	} else {
-		body.SetToken(config.GetAuthToken())
+		body.SetToken(cfg.Session.Token())
 	}
 
-	gsvOut, res, err := config.AklClient.GetRotatedSecretValue(ctx).Body(body).Execute()
-	if err != nil {
-		if errors.As(err, &apiErr) {
-			return "", fmt.Errorf("can't get secret value: %v", string(apiErr.Body()))
-		}
-		return "", fmt.Errorf("can't get secret value: %w", err)
+	gsvOut, res, err := cfg.Session.Client.GetRotatedSecretValue(ctx).Body(body).Execute()
+	if err := finishCall(res, err, "can't get secret value"); err != nil {
+		return "", err
 	}
-	defer res.Body.Close()
 	val, ok := gsvOut["value"]
 	if !ok {
 		return "", fmt.Errorf("can't get secret: %v", itemName)
 	}
+	if field != "" {
+		fields, _ := val.(map[string]interface{})
+		fieldVal, ok := fields[field]
+		if !ok {
+			return "", fmt.Errorf("rotated secret %v has no field %q, available fields: %v", itemName, field, strings.Join(fieldNames(fields), ", "))
+		}
+		if s, ok := fieldVal.(string); ok {
+			return s, nil
+		}
+		val = fieldVal
+	}
+	if raw {
+		if s, ok := val.(string); ok {
+			return s, nil
+		}
+		jsonValue, err := json.Marshal(val)
+		if err != nil {
+			return "", fmt.Errorf("can't marshal secret value: %v", val)
+		}
+		return string(jsonValue), nil
+	}
 	jsonValue, err := json.MarshalIndent(val, "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("can't marshal secret value: %v", val)
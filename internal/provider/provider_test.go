@@ -0,0 +1,1089 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+	"github.com/stretchr/testify/require"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// fakeGateway serves the subset of the Akeyless API used by the provider from in-memory items.
+type fakeGateway struct {
+	// session is the session of mounts against the fake gateway
+	session *config.Session
+	// url is the API URL of the fake gateway
+	url    string
+	items  map[string]fakeItem
+	failed map[string]bool
+	// throttled are the API paths answered with 429 Too Many Requests
+	throttled map[string]bool
+	calls     map[string]int
+	bodies    map[string]map[string]interface{}
+}
+
+type fakeItem struct {
+	itemType string
+	version  int32
+	value    interface{}
+	tags     []string
+	// lastRotation is the last_rotation_date of the item's description, if set
+	lastRotation string
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	name, _ := body["name"].(string)
+	if names, ok := body["names"].([]interface{}); ok && len(names) > 0 {
+		name, _ = names[0].(string)
+	}
+	if names, ok := body["names"].(string); ok {
+		name = names
+	}
+	if issuer, ok := body["cert-issuer-name"].(string); ok {
+		name = issuer
+	}
+	if usc, ok := body["usc-name"].(string); ok {
+		name = usc
+	}
+	if tokenizer, ok := body["tokenizer-name"].(string); ok {
+		name = tokenizer
+	}
+	if key, ok := body["key-name"].(string); ok {
+		name = key
+	}
+	g.calls[r.URL.Path]++
+	g.bodies[r.URL.Path] = body
+
+	if g.throttled[r.URL.Path] {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":"rate limit exceeded"}`))
+		return
+	}
+
+	if r.URL.Path == "/auth" {
+		// every authentication gets a token of its own
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": fmt.Sprintf("t-%v-%d", body["access-id"], g.calls[r.URL.Path])})
+		return
+	}
+
+	if r.URL.Path == "/list-items" {
+		folder, _ := body["path"].(string)
+		if g.failed[folder] {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"list failed"}`))
+			return
+		}
+		tag, _ := body["tag"].(string)
+		var items []map[string]interface{}
+		for name, item := range g.items {
+			if !strings.HasPrefix(name, folder+"/") {
+				continue
+			}
+			if tag != "" && !slices.Contains(item.tags, tag) {
+				continue
+			}
+			items = append(items, map[string]interface{}{"item_name": name, "item_type": item.itemType, "last_version": item.version, "item_tags": item.tags})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		return
+	}
+
+	item, ok := g.items[name]
+	if !ok || g.failed[name] {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"item not found"}`))
+		return
+	}
+
+	var out interface{}
+	switch r.URL.Path {
+	case "/describe-item":
+		desc := map[string]interface{}{"item_name": name, "item_type": item.itemType, "last_version": item.version, "item_tags": item.tags}
+		if item.lastRotation != "" {
+			desc["last_rotation_date"] = item.lastRotation
+		}
+		out = desc
+	case "/get-secret-value":
+		out = map[string]interface{}{name: item.value}
+	default:
+		out = item.value
+		if issue, ok := item.value.(func(body map[string]interface{}) interface{}); ok {
+			out = issue(body)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func newFakeGateway(t *testing.T, items map[string]fakeItem) *fakeGateway {
+	g := &fakeGateway{items: items, failed: map[string]bool{}, throttled: map[string]bool{}, calls: map[string]int{}, bodies: map[string]map[string]interface{}{}}
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+	g.url = srv.URL
+
+	g.session = config.NewSession(akeyless.NewAPIClient(&akeyless.Configuration{
+		Servers: []akeyless.ServerConfiguration{{URL: srv.URL}},
+	}).V2Api)
+
+	return g
+}
+
+func mountedFiles(resp *pb.MountResponse) map[string]string {
+	files := make(map[string]string)
+	for _, f := range resp.Files {
+		files[f.Path] = string(f.Contents)
+	}
+	return files
+}
+
+func TestHandleMountRequest(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/a": {itemType: "STATIC_SECRET", version: 1, value: "value-a"},
+		"/b": {itemType: "STATIC_SECRET", version: 2, value: "value-b"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, FilePermission: 0644, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "a", SecretPath: "/a"}, {FileName: "b", SecretPath: "/b"}},
+	}}
+
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": "value-a", "b": "value-b"}, mountedFiles(resp))
+}
+
+func TestHandleMountRequest_FileMode(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/prod/db": {itemType: "STATIC_SECRET", version: 1, value: `{"username":"orders","password":"p1"}`},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, FilePermission: 0644, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "db.json", SecretPath: "/prod/db"}, {FileName: ".pgpass", SecretPath: "/prod/db", PostProcessor: "pgpass"}},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.EqualValues(t, 0644, resp.Files[0].Mode)
+	require.EqualValues(t, 0600, resp.Files[1].Mode, "client files are only readable by their owner")
+
+	cfg.FilePermission = 0400
+	resp, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.EqualValues(t, 0400, resp.Files[1].Mode, "post-processors never loosen the filePermission")
+}
+
+func TestHandleMountRequest_PartialRotation(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/a": {itemType: "STATIC_SECRET", version: 1, value: "value-a"},
+		"/b": {itemType: "STATIC_SECRET", version: 1, value: "value-b"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "a", SecretPath: "/a"}, {FileName: "b", SecretPath: "/b"}},
+	}}
+
+	// initial mounts always fail as a whole
+	g.failed["/b"] = true
+	p := NewProvider()
+	_, err := p.HandleMountRequest(context.Background(), cfg)
+	require.Error(t, err)
+
+	g.failed["/b"] = false
+	_, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+
+	g.failed["/b"] = true
+	g.items["/a"] = fakeItem{itemType: "STATIC_SECRET", version: 2, value: "value-a2"}
+
+	_, err = p.HandleMountRequest(context.Background(), cfg)
+	require.Error(t, err, "fail policy")
+
+	cfg.RotationFailurePolicy = config.RotationFailurePolicyPartial
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": "value-a2", "b": "value-b"}, mountedFiles(resp))
+}
+
+func TestGetSecretByType_GatewayRequired(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/dynamic": {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "u1"}},
+	})
+
+	cfg := config.Config{Session: g.session, Parameters: config.Parameters{AkeylessGatewayURL: "https://api.akeyless.io"}}
+	_, _, err := NewProvider().GetSecretByType(context.Background(), "/dynamic", cfg)
+	require.ErrorIs(t, err, ErrGatewayRequired)
+
+	cfg.AkeylessGatewayURL = "https://gateway.example.com:8000/api/v2"
+	_, _, err = NewProvider().GetSecretByType(context.Background(), "/dynamic", cfg)
+	require.NoError(t, err)
+}
+
+func TestHandleMountRequest_Folder(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/team-a/db":         {itemType: "STATIC_SECRET", version: 3, value: "db-pass"},
+		"/team-a/nested/api": {itemType: "STATIC_SECRET", version: 1, value: "api-key"},
+		"/team-a/key":        {itemType: "CLASSIC_KEY", version: 1},
+		"/team-b/other":      {itemType: "STATIC_SECRET", version: 1, value: "other"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "team-a", SecretPath: "/team-a/"}},
+	}}
+
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team-a/db": "db-pass", "team-a/nested/api": "api-key"}, mountedFiles(resp))
+	require.Zero(t, g.calls["/describe-item"], "listed items must not be described")
+	require.Equal(t, 1, g.calls["/list-items"])
+
+	versions := map[string]string{}
+	for _, v := range resp.ObjectVersion {
+		versions[v.Id] = v.Version
+	}
+	require.Equal(t, "3", versions["/team-a/db"])
+}
+
+func TestHandleMountRequest_FolderWildcard(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/team-a/db":         {itemType: "STATIC_SECRET", version: 3, value: "db-pass"},
+		"/team-a/nested/api": {itemType: "STATIC_SECRET", version: 1, value: "api-key"},
+		"/team-ab/other":     {itemType: "STATIC_SECRET", version: 1, value: "other"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{SecretPath: "/team-a/*"}},
+	}}
+
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"db": "db-pass", "nested/api": "api-key"}, mountedFiles(resp))
+}
+
+func TestHandleMountRequest_FolderExclude(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/team-a/db":                 {itemType: "STATIC_SECRET", version: 1, value: "db-pass"},
+		"/team-a/db-staging":         {itemType: "STATIC_SECRET", version: 1, value: "staging-pass"},
+		"/team-a/nested/api":         {itemType: "STATIC_SECRET", version: 1, value: "api-key"},
+		"/team-a/nested/api-staging": {itemType: "STATIC_SECRET", version: 1, value: "staging-key"},
+		"/team-a/legacy/token":       {itemType: "STATIC_SECRET", version: 1, value: "token"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{SecretPath: "/team-a/*", Exclude: []string{"*-staging", "regex:^legacy/"}}},
+	}}
+
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"db": "db-pass", "nested/api": "api-key"}, mountedFiles(resp))
+}
+
+func TestHandleMountRequest_Tags(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/team-a/db":     {itemType: "STATIC_SECRET", version: 3, value: "db-pass", tags: []string{"payments", "prod"}},
+		"/team-a/api":    {itemType: "STATIC_SECRET", version: 1, value: "api-key", tags: []string{"payments", "staging"}},
+		"/team-b/ledger": {itemType: "STATIC_SECRET", version: 1, value: "ledger", tags: []string{"prod", "payments"}},
+		"/team-b/other":  {itemType: "STATIC_SECRET", version: 1, value: "other", tags: []string{"prod"}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "payments", Tags: []string{"payments", "prod"}},
+			{SecretPath: "/team-b/", FileName: "b", Tags: []string{"prod"}},
+		},
+	}}
+
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"payments/team-a/db":     "db-pass",
+		"payments/team-b/ledger": "ledger",
+		"b/ledger":               "ledger",
+		"b/other":                "other",
+	}, mountedFiles(resp))
+	require.Equal(t, "prod", g.bodies["/list-items"]["tag"])
+}
+
+func TestHandleMountRequest_SecretTypeSkipsDescribe(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db":  {itemType: "STATIC_SECRET", version: 3, value: "db-pass"},
+		"/api": {itemType: "STATIC_SECRET", version: 1, value: "api-key"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "db", SecretPath: "/db", SecretType: "static_secret"},
+			{FileName: "pinned", SecretPath: "/db", SecretType: "STATIC_SECRET", SecretArgs: map[string]interface{}{"version": 2}},
+			{FileName: "api", SecretPath: "/api", SecretType: "static"},
+		},
+	}}
+
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"db": "db-pass", "pinned": "db-pass", "api": "api-key"}, mountedFiles(resp))
+	require.Equal(t, 1, g.calls["/describe-item"], "only the object with an unknown secretType is described")
+
+	versions := map[string]string{}
+	for _, v := range resp.ObjectVersion {
+		versions[v.Id] = v.Version
+	}
+	require.Equal(t, contentVersion("db-pass"), versions["/db"])
+	require.Equal(t, "2", versions[objectVersionID(cfg.Secrets[1])])
+	require.Equal(t, "1", versions["/api"])
+}
+
+func TestHandleMountRequest_JSONKey(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db":    {itemType: "STATIC_SECRET", version: 1, value: `{"db_user":"app","db_password":"s3cr3t","port":5432}`},
+		"/plain": {itemType: "STATIC_SECRET", version: 1, value: "not json"},
+	})
+
+	mount := func(secrets ...config.Secret) (*pb.MountResponse, error) {
+		cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{Secrets: secrets}}
+		return NewProvider().HandleMountRequest(context.Background(), cfg)
+	}
+
+	resp, err := mount(
+		config.Secret{FileName: "password", SecretPath: "/db", SecretArgs: map[string]interface{}{"key": "db_password"}},
+		config.Secret{FileName: "port", SecretPath: "/db", SecretArgs: map[string]interface{}{"key": "port"}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"password": "s3cr3t", "port": "5432"}, mountedFiles(resp))
+
+	_, err = mount(config.Secret{FileName: "host", SecretPath: "/db", SecretArgs: map[string]interface{}{"key": "host"}})
+	require.ErrorContains(t, err, `has no key "host", available keys: db_password, db_user, port`)
+
+	_, err = mount(config.Secret{FileName: "plain", SecretPath: "/plain", SecretArgs: map[string]interface{}{"key": "host"}})
+	require.ErrorContains(t, err, "is not a JSON object")
+}
+
+func TestHandleMountRequest_Templates(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db":  {itemType: "STATIC_SECRET", version: 1, value: `{"user":"app","password":"s3cr3t"}`},
+		"/tls": {itemType: "STATIC_SECRET", version: 1, value: "cert"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "db", SecretPath: "/db", TemplateOnly: true},
+			{FileName: "tls.crt", SecretPath: "/tls", SubPath: "certs"},
+		},
+		Templates: []config.Template{
+			{FileName: "application.yaml", Template: "user: {{ .JSON.db.user }}\npassword: {{ .JSON.db.password | quote }}\n"},
+			{FileName: "nginx/ssl.conf", Template: `ssl_certificate {{ index .Secrets "certs/tls.crt" | len }};`},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"certs/tls.crt":    "cert",
+		"application.yaml": "user: app\npassword: \"s3cr3t\"\n",
+		"nginx/ssl.conf":   "ssl_certificate 4;",
+	}, mountedFiles(resp))
+
+	cfg.Templates = []config.Template{{FileName: "broken", Template: "{{ .Secrets.missing }}"}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "template broken")
+}
+
+func TestHandleMountRequest_Dotenv(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db-password": {itemType: "STATIC_SECRET", version: 1, value: `pa$$ "word"`},
+		"/api/key":     {itemType: "STATIC_SECRET", version: 1, value: "abc123"},
+		"/api/url":     {itemType: "STATIC_SECRET", version: 1, value: "https://api"},
+		"/other":       {itemType: "STATIC_SECRET", version: 1, value: "other"},
+	})
+
+	mount := func(dotenv config.Dotenv) (*pb.MountResponse, error) {
+		cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+			Secrets: []config.Secret{
+				{FileName: "db-password", SecretPath: "/db-password", TemplateOnly: true},
+				{FileName: "api", SecretPath: "/api/"},
+				{FileName: "other", SecretPath: "/other"},
+			},
+			Dotenv: &dotenv,
+		}}
+		return NewProvider().HandleMountRequest(context.Background(), cfg)
+	}
+
+	resp, err := mount(config.Dotenv{FileName: ".env", Objects: []string{"db-password", "api"}})
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, "API_KEY=abc123\nAPI_URL=https://api\n"+`DB_PASSWORD="pa\$\$ \"word\""`+"\n", files[".env"])
+	require.NotContains(t, files, "db-password")
+
+	resp, err = mount(config.Dotenv{FileName: "app.env", KeyCase: config.KeyCaseLower})
+	require.NoError(t, err)
+	require.Contains(t, mountedFiles(resp)["app.env"], "other=other\n")
+}
+
+func TestHandleMountRequest_AggregateSecrets(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/a": {itemType: "STATIC_SECRET", version: 1, value: "value-a"},
+		"/b": {itemType: "STATIC_SECRET", version: 1, value: `{"user":"app"}`},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets:          []config.Secret{{FileName: "a", SecretPath: "/a"}, {FileName: "b", SecretPath: "/b", SubPath: "db", TemplateOnly: true}},
+		AggregateSecrets: true,
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"a":                "value-a",
+		"all-secrets.json": "{\n  \"a\": \"value-a\",\n  \"db/b\": \"{\\\"user\\\":\\\"app\\\"}\"\n}",
+	}, mountedFiles(resp))
+}
+
+// changedObjects mirrors how the driver's rotation reconciler compares the ObjectVersions of a
+// remount with the ones recorded in the SecretProviderClassPodStatus: by id, any added, removed
+// or differing version means the mounted content changed.
+func changedObjects(recorded, remount []*pb.ObjectVersion) []string {
+	versions := map[string]string{}
+	for _, v := range recorded {
+		versions[v.Id] = v.Version
+	}
+	var changed []string
+	for _, v := range remount {
+		if prev, ok := versions[v.Id]; !ok || prev != v.Version {
+			changed = append(changed, v.Id)
+		}
+		delete(versions, v.Id)
+	}
+	for id := range versions {
+		changed = append(changed, id)
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+func TestHandleMountRequest_ObjectVersionIDs(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db":  {itemType: "STATIC_SECRET", version: 1, value: `{"user":"app","password":"s3cr3t"}`},
+		"/api": {itemType: "STATIC_SECRET", version: 1, value: "api-key"},
+	})
+
+	secrets := []config.Secret{
+		{FileName: "user", SecretPath: "/db", SecretArgs: map[string]interface{}{"key": "user"}},
+		{FileName: "password", SecretPath: "/db", SecretArgs: map[string]interface{}{"key": "password"}},
+		{FileName: "api", SecretPath: "/api"},
+	}
+	mount := func(p *Provider, secrets []config.Secret) []*pb.ObjectVersion {
+		cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{Secrets: secrets}}
+		resp, err := p.HandleMountRequest(context.Background(), cfg)
+		require.NoError(t, err)
+		return resp.ObjectVersion
+	}
+
+	p := NewProvider()
+	recorded := mount(p, secrets)
+	require.Len(t, recorded, 3, "objects selecting different values have distinct ids")
+	require.Empty(t, changedObjects(recorded, mount(p, secrets)), "unchanged remount")
+	require.Empty(t, changedObjects(recorded, mount(NewProvider(), secrets)), "remount after a provider restart")
+
+	renamed := append([]config.Secret(nil), secrets...)
+	renamed[2] = config.Secret{FileName: "api-key", SecretPath: "/api", SubPath: "keys"}
+	require.Empty(t, changedObjects(recorded, mount(p, renamed)), "fileName and subPath renames")
+
+	reordered := []config.Secret{{FileName: "password", SecretPath: "/db", SecretArgs: map[string]interface{}{"key": "password"}}, secrets[0], secrets[2]}
+	require.Empty(t, changedObjects(recorded, mount(p, reordered)), "reordered objects")
+
+	g.items["/api"] = fakeItem{itemType: "STATIC_SECRET", version: 2, value: "api-key2"}
+	require.Equal(t, []string{"/api"}, changedObjects(recorded, mount(p, secrets)))
+}
+
+func TestHandleMountRequest_ResponseTooLarge(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/big":   {itemType: "STATIC_SECRET", version: 1, value: strings.Repeat("x", 2048)},
+		"/small": {itemType: "STATIC_SECRET", version: 1, value: "small"},
+	})
+	defer SetMaxResponseSize(DefaultMaxResponseSize)
+	SetMaxResponseSize(1024)
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		SecretProviderClass: "my-spc",
+		Secrets:             []config.Secret{{FileName: "big", SecretPath: "/big"}, {FileName: "small", SecretPath: "/small"}},
+	}}
+	_, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "exceeding the limit of 1024 bytes")
+	require.ErrorContains(t, err, "largest objects: /big (2051 bytes), /small (10 bytes); move /big to a separate SecretProviderClass")
+
+	SetMaxResponseSize(0)
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+}
+
+func TestHandleMountRequest_SubPath(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/a/db": {itemType: "STATIC_SECRET", version: 1, value: "db-a"},
+		"/b/db": {itemType: "STATIC_SECRET", version: 1, value: "db-b"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "db", SecretPath: "/a/db", SubPath: "team-a"},
+			{FileName: "db", SecretPath: "/b/db", SubPath: "team-b"},
+		},
+	}}
+
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team-a/db": "db-a", "team-b/db": "db-b"}, mountedFiles(resp))
+	require.Len(t, resp.ObjectVersion, 2)
+}
+
+func TestHandleMountRequest_NestedFileName(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/tls/crt": {itemType: "STATIC_SECRET", version: 1, value: "crt"},
+		// item names come from the gateway, which mustn't be able to write outside of the mount
+		"/team/../../../etc/cron.d/x": {itemType: "STATIC_SECRET", version: 1, value: "evil"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "certs/tls.crt", SecretPath: "/tls/crt", SubPath: "app"}},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app/certs/tls.crt": "crt"}, mountedFiles(resp))
+
+	cfg.Secrets = []config.Secret{{FileName: "team", SecretPath: "/team/"}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, `file "../../etc/cron.d/x" of secret /team/../../../etc/cron.d/x escapes the mount`)
+}
+
+func TestHandleMountRequest_DynamicSecret(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db-producer": {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "tmp-1", "password": "p1"}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		AkeylessGatewayURL: "https://gateway.example.com:8000/api/v2",
+		Secrets: []config.Secret{{FileName: "db", SecretPath: "/db-producer", SecretArgs: map[string]interface{}{
+			"args":    map[string]interface{}{"db": "orders"},
+			"timeout": 30,
+		}}},
+	}}
+
+	p := NewProvider()
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"user":"tmp-1","password":"p1"}`, mountedFiles(resp)["db"])
+	require.Equal(t, []interface{}{"db=orders"}, g.bodies["/get-dynamic-secret-value"]["args"])
+	require.EqualValues(t, 30, g.bodies["/get-dynamic-secret-value"]["timeout"])
+
+	// rotation remounts reuse the issued credentials
+	g.items["/db-producer"] = fakeItem{itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "tmp-2", "password": "p2"}}
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"user":"tmp-1","password":"p1"}`, mountedFiles(resp)["db"])
+	require.Equal(t, 1, g.calls["/get-dynamic-secret-value"])
+
+	// new credentials are issued once the ttl passed
+	cfg.Secrets[0].SecretArgs["ttl"] = "1ns"
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"user":"tmp-2","password":"p2"}`, mountedFiles(resp)["db"])
+	require.Equal(t, "2", resp.ObjectVersion[0].Version)
+}
+
+func TestHandleMountRequest_DynamicSecretArgs(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db-producer": {itemType: "DYNAMIC_SECRET", value: func(body map[string]interface{}) interface{} {
+			args, _ := json.Marshal(body["args"])
+			return map[string]interface{}{"user": string(args)}
+		}},
+	})
+
+	// two objects of one producer with different args get credentials of their own
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "orders", SecretPath: "/db-producer", SecretArgs: map[string]interface{}{"args": map[string]interface{}{"db": "orders"}}},
+			{FileName: "users", SecretPath: "/db-producer", SecretArgs: map[string]interface{}{"args": map[string]interface{}{"db": "users"}}},
+		},
+	}}
+	p := NewProvider()
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.JSONEq(t, `{"user":"[\"db=orders\"]"}`, files["orders"])
+	require.JSONEq(t, `{"user":"[\"db=users\"]"}`, files["users"])
+	require.Equal(t, 2, g.calls["/get-dynamic-secret-value"])
+
+	// and reuse them on rotation remounts
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"user":"[\"db=users\"]"}`, mountedFiles(resp)["users"])
+	require.Equal(t, 2, g.calls["/get-dynamic-secret-value"])
+}
+
+func TestHandleMountRequest_TargetProducer(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/github-producer": {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{
+			"token": "ghs_1", "expires_at": time.Now().Add(time.Hour).Format(time.RFC3339),
+		}},
+		"/artifactory-producer": {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"access_token": "art-1", "expires_in": 0.01}},
+		"/gcp-producer": {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{
+			"private_key_data": base64.StdEncoding.EncodeToString([]byte(`{"type":"service_account"}`)),
+		}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		AkeylessGatewayURL: "https://gateway.example.com:8000/api/v2",
+		Secrets: []config.Secret{{FileName: "github-token", SecretPath: "/github-producer", SecretArgs: map[string]interface{}{
+			"producer":     "github",
+			"repositories": []interface{}{"api", "web"},
+			"permissions":  map[string]interface{}{"contents": "read"},
+		}}},
+	}}
+	p := NewProvider()
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "ghs_1", mountedFiles(resp)["github-token"])
+	require.Equal(t, []interface{}{"repositories=api,web", `permissions={"contents":"read"}`}, g.bodies["/get-dynamic-secret-value"]["args"])
+
+	// tokens are reused by rotation remounts until 2/3 of their lifetime passed
+	g.items["/github-producer"] = fakeItem{itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"token": "ghs_2"}}
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "ghs_1", mountedFiles(resp)["github-token"])
+
+	cfg.Secrets = []config.Secret{{FileName: "artifactory-token", SecretPath: "/artifactory-producer", SecretArgs: map[string]interface{}{"producer": "artifactory"}}}
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "art-1", mountedFiles(resp)["artifactory-token"])
+	time.Sleep(20 * time.Millisecond)
+	g.items["/artifactory-producer"] = fakeItem{itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"access_token": "art-2", "expires_in": 3600}}
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "art-2", mountedFiles(resp)["artifactory-token"], "expiring tokens are refreshed on rotation")
+	require.Equal(t, "2", resp.ObjectVersion[0].Version)
+
+	// GCP service account keys are mounted as key files
+	cfg.Secrets = []config.Secret{{FileName: "gcp-key.json", SecretPath: "/gcp-producer", SecretArgs: map[string]interface{}{"producer": "gcp"}}}
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"service_account"}`, mountedFiles(resp)["gcp-key.json"])
+
+	cfg.Secrets[0].SecretArgs["producer"] = "gitlab"
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "unsupported producer gitlab, available: artifactory, gcp, github")
+}
+
+func TestHandleMountRequest_NoCache(t *testing.T) {
+	SetCacheTTL(time.Hour)
+	defer SetCacheTTL(0)
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/prod/root-password": {itemType: "STATIC_SECRET", version: 1, value: "v1"},
+		"/prod/config":        {itemType: "STATIC_SECRET", version: 1, value: "c1"},
+		"/db-producer":        {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "tmp-1"}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		AkeylessGatewayURL: "https://gateway.example.com:8000/api/v2",
+		Secrets: []config.Secret{
+			{FileName: "root", SecretPath: "/prod/root-password", SecretArgs: map[string]interface{}{"noCache": true}},
+			{FileName: "config", SecretPath: "/prod/config"},
+			{FileName: "db", SecretPath: "/db-producer", SecretArgs: map[string]interface{}{"noCache": "true"}},
+		},
+	}}
+	p := NewProvider()
+	resp, err := p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "v1", mountedFiles(resp)["root"])
+	require.NotContains(t, p.cache, objectKey(cfg.Secrets[0]), "values of noCache objects are dropped after the mount")
+	require.NotContains(t, p.cache, objectKey(cfg.Secrets[2]))
+	require.Contains(t, p.cache, objectKey(cfg.Secrets[1]))
+	require.Empty(t, p.leases[leaseKey("/db-producer", nil)].value)
+
+	// a second mount fetches the noCache objects again, the others come from the node cache
+	g.items["/prod/root-password"] = fakeItem{itemType: "STATIC_SECRET", version: 1, value: "v2"}
+	g.items["/prod/config"] = fakeItem{itemType: "STATIC_SECRET", version: 1, value: "c2"}
+	g.items["/db-producer"] = fakeItem{itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "tmp-2"}}
+	resp, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, "v2", files["root"])
+	require.Equal(t, "c1", files["config"])
+	require.JSONEq(t, `{"user":"tmp-2"}`, files["db"])
+
+	// rotation remounts issue new dynamic credentials
+	resp, err = p.HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"user":"tmp-2"}`, mountedFiles(resp)["db"])
+	require.Equal(t, 3, g.calls["/get-dynamic-secret-value"])
+}
+
+func TestHandleMountRequest_SharedCache(t *testing.T) {
+	SetCacheTTL(time.Hour)
+	defer SetCacheTTL(0)
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/shared/config": {itemType: "STATIC_SECRET", version: 1, value: "c1"},
+		"/shared/db":     {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "tmp-1"}},
+		"/shared/typed":  {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "typed-1"}},
+	})
+
+	// mounts authenticate like the driver's do, every one of them with a token of its own
+	mount := func(accessID string) map[string]string {
+		params, err := json.Marshal(map[string]string{
+			"akeylessGatewayURL": g.url,
+			"akeylessAccessType": "access_key",
+			"akeylessAccessID":   accessID,
+			"akeylessAccessKey":  "key",
+			"objects": `
+- fileName: config
+  secretPath: /shared/config
+- fileName: db
+  secretPath: /shared/db
+- fileName: typed
+  secretPath: /shared/typed
+  secretType: dynamic_secret
+`,
+		})
+		require.NoError(t, err)
+		cfg, err := config.Parse(context.Background(), config.NewClient, "", string(params), "/target", "420", "", "")
+		require.NoError(t, err)
+		resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+		require.NoError(t, err)
+		return mountedFiles(resp)
+	}
+	mount("p-shared")
+
+	// another mount of the same identity gets the cached static value, but credentials of its own
+	g.items["/shared/config"] = fakeItem{itemType: "STATIC_SECRET", version: 1, value: "c2"}
+	g.items["/shared/db"] = fakeItem{itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "tmp-2"}}
+	g.items["/shared/typed"] = fakeItem{itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "typed-2"}}
+	files := mount("p-shared")
+	require.Equal(t, "c1", files["config"])
+	require.JSONEq(t, `{"user":"tmp-2"}`, files["db"])
+	require.JSONEq(t, `{"user":"typed-2"}`, files["typed"])
+	require.Equal(t, 2, g.calls["/auth"])
+
+	// a mount of another identity never gets the cached values
+	require.Equal(t, "c2", mount("p-other")["config"])
+
+	// nor does a session that didn't authenticate as the identity, even naming its access ID
+	cfg := config.Config{TargetPath: "/target", Session: config.NewSessionWithToken(g.session.Client, "t-unverified"), Parameters: config.Parameters{
+		AkeylessGatewayURL: g.url,
+		AkeylessAccessType: "access_key",
+		AkeylessAccessID:   "p-shared",
+		Secrets:            []config.Secret{{FileName: "config", SecretPath: "/shared/config"}},
+	}}
+	g.items["/shared/config"] = fakeItem{itemType: "STATIC_SECRET", version: 1, value: "c3"}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "c3", mountedFiles(resp)["config"])
+}
+
+func TestHandleMountRequest_USC(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/connectors/aws-sm": {itemType: "USC", version: 1, value: func(body map[string]interface{}) interface{} {
+			switch body["secret-id"] {
+			case "prod/db":
+				return map[string]interface{}{"value": "db-pass"}
+			default:
+				return map[string]interface{}{"value": "AAEC", "binary_value": true}
+			}
+		}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "db", SecretPath: "/connectors/aws-sm", SecretArgs: map[string]interface{}{"secret-id": "prod/db"}},
+			{FileName: "blob", SecretPath: "/connectors/aws-sm", SecretArgs: map[string]interface{}{"secret-id": "prod/blob"}},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"db": "db-pass", "blob": "\x00\x01\x02"}, mountedFiles(resp))
+	require.Equal(t, "/connectors/aws-sm", g.bodies["/usc-get"]["usc-name"])
+
+	cfg.Secrets = []config.Secret{{FileName: "db", SecretPath: "/connectors/aws-sm"}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "missing secret-id")
+}
+
+func TestHandleMountRequest_Tokenizer(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/tokenizers/ssn": {itemType: "TOKENIZER", value: func(body map[string]interface{}) interface{} {
+			return map[string]interface{}{"result": "123-45-" + body["ciphertext"].(string)[7:]}
+		}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		AkeylessGatewayURL: "https://gateway.example.com/api/v2",
+		Secrets: []config.Secret{
+			{FileName: "ssn", SecretPath: "/tokenizers/ssn", SecretArgs: map[string]interface{}{"ciphertext": "987-65-6789", "tweak": "dGVzdA=="}},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ssn": "123-45-6789"}, mountedFiles(resp))
+	require.Equal(t, "dGVzdA==", g.bodies["/detokenize"]["tweak"])
+
+	cfg.Secrets = []config.Secret{{FileName: "ssn", SecretPath: "/tokenizers/ssn", SecretType: "TOKENIZER"}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "missing ciphertext secretArg for tokenizer /tokenizers/ssn")
+}
+
+func TestHandleMountRequest_DecryptKey(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/encrypted/db": {itemType: "STATIC_SECRET", version: 3, value: "AQAAAAEIAgAAAA==\n"},
+		"/keys/aes": {itemType: "AES256GCM", value: func(body map[string]interface{}) interface{} {
+			if body["ciphertext"] != "AQAAAAEIAgAAAA==" {
+				return map[string]interface{}{"result": "garbage"}
+			}
+			return map[string]interface{}{"result": "s3cr3t"}
+		}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "db", SecretPath: "/encrypted/db", SecretArgs: map[string]interface{}{
+			"decryptKey":        "/keys/aes",
+			"encryptionContext": map[string]interface{}{"app": "orders"},
+		}}},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"db": "s3cr3t"}, mountedFiles(resp))
+	require.Equal(t, "3", resp.ObjectVersion[0].Version)
+	require.Equal(t, map[string]interface{}{"app": "orders"}, g.bodies["/decrypt"]["encryption-context"])
+
+	g.failed["/keys/aes"] = true
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "can't decrypt /encrypted/db with key /keys/aes")
+}
+
+func TestHandleMountRequest_RotatedSecretField(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/rotated/db": {itemType: "ROTATED_SECRET", version: 4, value: map[string]interface{}{
+			"value": map[string]interface{}{"username": "app", "password": "s3cret"},
+		}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "username", SecretPath: "/rotated/db", SecretArgs: map[string]interface{}{"field": "username"}},
+			{FileName: "password", SecretPath: "/rotated/db", SecretArgs: map[string]interface{}{"field": "password"}},
+			{FileName: "all", SecretPath: "/rotated/db"},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, "app", files["username"])
+	require.Equal(t, "s3cret", files["password"])
+	require.JSONEq(t, `{"username":"app","password":"s3cret"}`, files["all"])
+
+	cfg.Secrets = []config.Secret{{FileName: "token", SecretPath: "/rotated/db", SecretArgs: map[string]interface{}{"field": "token"}}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, `no field "token", available fields: password, username`)
+}
+
+func TestHandleMountRequest_RotatedSecretRaw(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/rotated/custom": {itemType: "ROTATED_SECRET", version: 2, value: func(body map[string]interface{}) interface{} {
+			if body["json"] == true {
+				return map[string]interface{}{"value": map[string]interface{}{"payload": "{ \"k\": 1 }\n"}}
+			}
+			return map[string]interface{}{"value": "{ \"k\": 1 }\n"}
+		}},
+		"/rotated/db": {itemType: "ROTATED_SECRET", version: 4, value: map[string]interface{}{
+			"value": map[string]interface{}{"username": "app", "password": "s3cret"},
+		}},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "custom", SecretPath: "/rotated/custom", SecretArgs: map[string]interface{}{"raw": true}},
+			{FileName: "db", SecretPath: "/rotated/db", SecretArgs: map[string]interface{}{"raw": "true"}},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, "{ \"k\": 1 }\n", files["custom"])
+	// structured values aren't indented
+	require.Equal(t, `{"password":"s3cret","username":"app"}`, files["db"])
+	require.Nil(t, g.bodies["/get-rotated-secret-value"]["json"])
+}
+
+func TestHandleMountRequest_ObjectFormatYAML(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/rotated/db": {itemType: "ROTATED_SECRET", version: 4, value: map[string]interface{}{
+			"value": map[string]interface{}{"username": "app", "password": "s3cret"},
+		}},
+		"/plain": {itemType: "STATIC_SECRET", version: 1, value: "not json"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "db.yaml", SecretPath: "/rotated/db", SecretArgs: map[string]interface{}{"objectFormat": "yaml"}}},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "password: s3cret\nusername: app\n", mountedFiles(resp)["db.yaml"])
+
+	cfg.Secrets = []config.Secret{{FileName: "plain.yaml", SecretPath: "/plain", SecretArgs: map[string]interface{}{"objectFormat": "yaml"}}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "failed to format secret /plain: can't render value as yaml: value is not JSON")
+}
+
+func TestHandleMountRequest_DecodeBase64(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/license": {itemType: "STATIC_SECRET", version: 1, value: "UEsDBAoAAAAA\nAAAA\n"},
+		"/plain":   {itemType: "STATIC_SECRET", version: 1, value: "not base64!"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets:          []config.Secret{{FileName: "license.zip", SecretPath: "/license", DecodeBase64: true}},
+		AggregateSecrets: true,
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, "PK\x03\x04\n\x00\x00\x00\x00\x00\x00\x00", files["license.zip"])
+	// the aggregate file keeps the value as stored
+	require.Contains(t, files[config.AggregateFileName], `"license.zip": "UEsDBAoAAAAA\nAAAA\n"`)
+
+	cfg.Secrets = []config.Secret{{FileName: "plain", SecretPath: "/plain", DecodeBase64: true}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "failed to decode secret /plain: value is not base64")
+}
+
+func TestHandleMountRequest_Encoding(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/app/config": {itemType: "STATIC_SECRET", version: 1, value: "[db]\nhost=db\n\n"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "app.ini", SecretPath: "/app/config", LineEndings: "crlf", TrailingNewline: "strip", BOM: "add"},
+			{FileName: "plain.ini", SecretPath: "/app/config"},
+		},
+		AggregateSecrets: true,
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, "\xef\xbb\xbf[db]\r\nhost=db", files["app.ini"])
+	require.Equal(t, "[db]\nhost=db\n\n", files["plain.ini"])
+	// the aggregate file keeps the value as stored
+	require.Contains(t, files[config.AggregateFileName], `"app.ini": "[db]\nhost=db\n\n"`)
+}
+
+func TestHandleMountRequest_Metadata(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db/password": {itemType: "ROTATED_SECRET", version: 7, value: map[string]interface{}{"value": map[string]interface{}{"password": "s3cret"}}, tags: []string{"team:db"}, lastRotation: "2026-10-01T12:00:00Z"},
+		"/api-key":     {itemType: "STATIC_SECRET", version: 2, value: "k"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "db-password", SecretPath: "/db/password", SecretArgs: map[string]interface{}{"field": "password"}, Metadata: true},
+			{FileName: "api-key", SecretPath: "/api-key", SecretType: "static_secret", Metadata: true},
+			{FileName: "plain", SecretPath: "/api-key"},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.JSONEq(t, `{"itemName":"/db/password","itemType":"ROTATED_SECRET","version":"7","tags":["team:db"],"lastRotation":"2026-10-01T12:00:00Z"}`, files["db-password.meta.json"])
+	// typed objects are described for their metadata, their version stays the one of the value
+	require.JSONEq(t, `{"itemName":"/api-key","itemType":"STATIC_SECRET","version":"`+contentVersion("k")+`","tags":[]}`, files["api-key.meta.json"])
+	require.NotContains(t, files, "plain.meta.json")
+}
+
+func TestHandleMountRequest_Transform(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db/creds": {itemType: "STATIC_SECRET", version: 1, value: `{"credentials":{"username":"app","password":"s3cret"}}`},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "password", SecretPath: "/db/creds", Transform: ".credentials.password"},
+			{FileName: "creds.yaml", SecretPath: "/db/creds", Transform: ".credentials", SecretArgs: map[string]interface{}{"objectFormat": "yaml"}},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, "s3cret", files["password"])
+	require.Equal(t, "password: s3cret\nusername: app\n", files["creds.yaml"])
+
+	cfg.Secrets = []config.Secret{{FileName: "password", SecretPath: "/db/creds", Transform: ".password"}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, `failed to transform secret /db/creds: transform ".password": no key password, available: credentials`)
+}
+
+func TestHandleMountRequest_Concat(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/pki/leaf":         {itemType: "STATIC_SECRET", version: 1, value: "-----BEGIN CERTIFICATE-----\nleaf\n-----END CERTIFICATE-----"},
+		"/pki/intermediate": {itemType: "STATIC_SECRET", version: 2, value: "-----BEGIN CERTIFICATE-----\nintermediate\n-----END CERTIFICATE-----\n"},
+		"/pki/root":         {itemType: "STATIC_SECRET", version: 3, value: "-----BEGIN CERTIFICATE-----\nroot\n-----END CERTIFICATE-----\n"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "chain.pem", SecretPath: "/pki/leaf", Concat: true},
+			{FileName: "root.pem", SecretPath: "/pki/root"},
+			{FileName: "chain.pem", SecretPath: "/pki/intermediate", Concat: true},
+			{FileName: "chain.pem", SecretPath: "/pki/root", Concat: true},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Len(t, resp.Files, 2)
+	files := mountedFiles(resp)
+	require.Equal(t, "-----BEGIN CERTIFICATE-----\nleaf\n-----END CERTIFICATE-----\n"+
+		"-----BEGIN CERTIFICATE-----\nintermediate\n-----END CERTIFICATE-----\n"+
+		"-----BEGIN CERTIFICATE-----\nroot\n-----END CERTIFICATE-----\n", files["chain.pem"])
+	// every part still has its own object version
+	require.Len(t, resp.ObjectVersion, 3)
+}
+
+func TestHandleMountRequest_WriteModeOverwrite(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/ca/default": {itemType: "STATIC_SECRET", version: 1, value: "default-ca"},
+		"/ca/team":    {itemType: "STATIC_SECRET", version: 2, value: "team-ca"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "ca.pem", SecretPath: "/ca/default", WriteMode: config.WriteModeOverwrite},
+			{FileName: "ca.pem", SecretPath: "/ca/team", WriteMode: config.WriteModeOverwrite, ContentType: "application/x-pem-file"},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Len(t, resp.Files, 1)
+	require.Equal(t, "team-ca", mountedFiles(resp)["ca.pem"])
+	require.Len(t, resp.ObjectVersion, 2)
+}
+
+func TestHandleMountRequest_PinnedVersion(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/db": {itemType: "STATIC_SECRET", version: 5, value: "v5"},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{{FileName: "db", SecretPath: "/db", SecretArgs: map[string]interface{}{"version": 3}}},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, g.bodies["/get-secret-value"]["version"])
+	require.Equal(t, "3", resp.ObjectVersion[0].Version)
+
+	cfg.Secrets[0].SecretArgs["version"] = "latest"
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "invalid version secretArg")
+}
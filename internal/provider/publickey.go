@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// Formats the public material of classic and DFC keys can be mounted in.
+const (
+	publicKeyFormatPEM = "pem"
+	publicKeyFormatJWK = "jwk"
+)
+
+// dfcKeyTypePrefixes start the names of the DFC key types, which are named after their
+// algorithm, e.g. RSA2048 or AES256GCM.
+var dfcKeyTypePrefixes = []string{"AES", "EC", "RSA"}
+
+// isDFCKeyType reports whether an item type is one of the DFC key types.
+func isDFCKeyType(itemType string) bool {
+	for _, prefix := range dfcKeyTypePrefixes {
+		if strings.HasPrefix(itemType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) getClassicKeyPublic(ctx context.Context, itemName string, args map[string]interface{}, cfg config.Config) (string, error) {
+	body := akeyless.ExportClassicKey{
+		Name: itemName,
+	}
+	body.SetExportPublicKey(true)
+	if cfg.UsingUID() {
+		body.SetUidToken(cfg.Session.Token())
+	} else {
+		body.SetToken(cfg.Session.Token())
+	}
+
+	out, res, err := cfg.Session.Client.ExportClassicKey(ctx).Body(body).Execute()
+	if err := finishCall(res, err, "can't export classic key"); err != nil {
+		return "", err
+	}
+
+	if out.GetKey() == "" {
+		return "", fmt.Errorf("classic key %v has no public key", itemName)
+	}
+	return formatPublicKey(out.GetKey(), stringArg(args, "format"))
+}
+
+func (p *Provider) getDFCKeyPublic(ctx context.Context, item *akeyless.Item, args map[string]interface{}, cfg config.Config) (string, error) {
+	itemName := item.GetItemName()
+	if strings.HasPrefix(item.GetItemType(), "AES") {
+		return "", fmt.Errorf("DFC key %v is symmetric and has no public key", itemName)
+	}
+
+	if !strings.HasPrefix(item.GetItemType(), "RSA") {
+		if item.GetPublicValue() == "" {
+			return "", fmt.Errorf("DFC key %v has no public key", itemName)
+		}
+		return formatPublicKey(item.GetPublicValue(), stringArg(args, "format"))
+	}
+
+	body := akeyless.GetRSAPublic{
+		Name: itemName,
+	}
+	if cfg.UsingUID() {
+		body.SetUidToken(cfg.Session.Token())
+	} else {
+		body.SetToken(cfg.Session.Token())
+	}
+
+	out, res, err := cfg.Session.Client.GetRSAPublic(ctx).Body(body).Execute()
+	if err := finishCall(res, err, "can't get RSA public key"); err != nil {
+		return "", err
+	}
+
+	public := out.GetPem()
+	if public == "" {
+		public = out.GetRaw()
+	}
+	if public == "" {
+		return "", fmt.Errorf("DFC key %v has no public key", itemName)
+	}
+	return formatPublicKey(public, stringArg(args, "format"))
+}
+
+// formatPublicKey renders a public key, given either as PEM or as base64 encoded DER, in the
+// requested format.
+func formatPublicKey(public, format string) (string, error) {
+	der, err := publicKeyDER(public)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "", publicKeyFormatPEM:
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+	case publicKeyFormatJWK:
+		key, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return "", fmt.Errorf("can't parse public key: %w", err)
+		}
+		jwk, err := publicJWK(key)
+		if err != nil {
+			return "", err
+		}
+		out, err := json.MarshalIndent(jwk, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("can't marshal public key: %w", err)
+		}
+		return string(out), nil
+	default:
+		return "", fmt.Errorf("unsupported public key format %q, expected %q or %q", format, publicKeyFormatPEM, publicKeyFormatJWK)
+	}
+}
+
+func publicKeyDER(public string) ([]byte, error) {
+	if block, _ := pem.Decode([]byte(public)); block != nil {
+		if block.Type == "RSA PUBLIC KEY" {
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("can't parse public key: %w", err)
+			}
+			return x509.MarshalPKIXPublicKey(key)
+		}
+		return block.Bytes, nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(public))
+	if err != nil {
+		return nil, fmt.Errorf("public key is neither PEM nor base64 encoded DER")
+	}
+	return der, nil
+}
+
+func publicJWK(key interface{}) (map[string]string, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   b64(k.N.Bytes()),
+			"e":   b64(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		var crv string
+		switch k.Curve {
+		case elliptic.P256():
+			crv = "P-256"
+		case elliptic.P384():
+			crv = "P-384"
+		case elliptic.P521():
+			crv = "P-521"
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %v", k.Curve.Params().Name)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC",
+			"crv": crv,
+			"x":   b64(k.X.FillBytes(make([]byte, size))),
+			"y":   b64(k.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   b64(k),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMountRequest_PublicKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	public := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/classic": {itemType: "CLASSIC_KEY", version: 1, value: map[string]interface{}{"key": public}},
+		"/dfc":     {itemType: "RSA2048", version: 1, value: map[string]interface{}{"pem": public}},
+		"/aes":     {itemType: "AES256GCM", version: 1},
+	})
+
+	cfg := config.Config{TargetPath: "/target", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "classic.pem", SecretPath: "/classic"},
+			{FileName: "dfc.jwk", SecretPath: "/dfc", SecretArgs: map[string]interface{}{"format": "jwk"}},
+		},
+	}}
+	resp, err := NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.NoError(t, err)
+	files := mountedFiles(resp)
+	require.Equal(t, public, files["classic.pem"])
+	require.Equal(t, true, g.bodies["/export-classic-key"]["export-public-key"])
+
+	var jwk map[string]string
+	require.NoError(t, json.Unmarshal([]byte(files["dfc.jwk"]), &jwk))
+	require.Equal(t, "RSA", jwk["kty"])
+	require.Equal(t, "AQAB", jwk["e"])
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(key.N.Bytes()), jwk["n"])
+
+	cfg.Secrets = []config.Secret{{FileName: "aes", SecretPath: "/aes"}}
+	_, err = NewProvider().HandleMountRequest(context.Background(), cfg)
+	require.ErrorContains(t, err, "symmetric")
+}
+
+func TestGetDFCKeyPublic_PublicValue(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	item := akeyless.NewItem()
+	item.SetItemName("/ec")
+	item.SetItemType("EC256")
+	item.SetPublicValue(base64.StdEncoding.EncodeToString(der))
+
+	out, err := NewProvider().getDFCKeyPublic(context.Background(), item, map[string]interface{}{"format": "jwk"}, config.Config{})
+	require.NoError(t, err)
+	var jwk map[string]string
+	require.NoError(t, json.Unmarshal([]byte(out), &jwk))
+	require.Equal(t, "EC", jwk["kty"])
+	require.Equal(t, "P-256", jwk["crv"])
+
+	_, err = NewProvider().getDFCKeyPublic(context.Background(), item, map[string]interface{}{"format": "der"}, config.Config{})
+	require.ErrorContains(t, err, "unsupported public key format")
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// ErrThrottled is returned for calls the gateway rejected with 429 Too Many Requests.
+var ErrThrottled = errors.New("throttled by the gateway")
+
+// ErrUnauthorized is returned for calls the gateway rejected with 401 Unauthorized, i.e. the
+// token of the mount is missing, expired or revoked.
+var ErrUnauthorized = errors.New("unauthorized by the gateway")
+
+// ErrForbidden is returned for calls the gateway rejected with 403 Forbidden, i.e. the role
+// of the mount has no access to the item.
+var ErrForbidden = errors.New("forbidden by the gateway")
+
+// finishCall ends an Akeyless API call: it releases the response body and turns the call's
+// error into one carrying the gateway's error message. The response is nil when the request
+// never reached the gateway, e.g. on transport errors during an outage.
+func finishCall(res *http.Response, err error, msg string) error {
+	if res != nil && res.Body != nil {
+		_ = res.Body.Close()
+	}
+	if err == nil {
+		return nil
+	}
+
+	var apiErr akeyless.GenericOpenAPIError
+	if res != nil && res.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%s: %w: %v", msg, ErrThrottled, err)
+	}
+	if errors.As(err, &apiErr) {
+		switch {
+		case res != nil && res.StatusCode == http.StatusUnauthorized:
+			return fmt.Errorf("%s: %w: %v", msg, ErrUnauthorized, string(apiErr.Body()))
+		case res != nil && res.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("%s: %w: %v", msg, ErrForbidden, string(apiErr.Body()))
+		}
+		return fmt.Errorf("%s: %v", msg, string(apiErr.Body()))
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
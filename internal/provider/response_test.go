@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFinishCall(t *testing.T) {
+	require.NoError(t, finishCall(nil, nil, "can't get secret value"))
+
+	transportErr := errors.New("connection refused")
+	err := finishCall(nil, transportErr, "can't get secret value")
+	require.ErrorIs(t, err, transportErr)
+	require.EqualError(t, err, "can't get secret value: connection refused")
+}
+
+func TestDescribeItem_Errors(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{})
+
+	item, err := NewProvider().DescribeItem(context.Background(), "/missing", config.Config{Session: g.session})
+	require.ErrorContains(t, err, "item not found")
+	require.Nil(t, item)
+
+	// the gateway is unreachable, the SDK returns no response at all
+	unreachable := config.NewSession(akeyless.NewAPIClient(&akeyless.Configuration{
+		Servers: []akeyless.ServerConfiguration{{URL: "http://127.0.0.1:1"}},
+	}).V2Api)
+	_, err = NewProvider().GetStaticSecret(context.Background(), "/a", config.Config{Session: unreachable})
+	require.ErrorContains(t, err, "can't get secret value")
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+)
+
+// Statuses of the checks of an access review.
+const (
+	ReviewPass = "PASS"
+	ReviewFail = "FAIL"
+	// ReviewNotFetched is the get status of items a review doesn't fetch, see unreviewedItemTypes
+	ReviewNotFetched = "NOT FETCHED"
+)
+
+// unreviewedItemTypes are the item types whose fetches issue credentials or certificates, or
+// detokenize, rather than read a value, so a review only describes them.
+var unreviewedItemTypes = map[string]bool{
+	"DYNAMIC_SECRET":  true,
+	"PKI_CERT_ISSUER": true,
+	"TOKENIZER":       true,
+}
+
+// ReviewResult is the outcome of reviewing the access to one object.
+type ReviewResult struct {
+	SecretPath string
+	Describe   string
+	Get        string
+	Error      string
+}
+
+// Failed reports whether the object can't be read.
+func (r ReviewResult) Failed() bool {
+	return r.Describe == ReviewFail || r.Get == ReviewFail
+}
+
+// Review checks, for every object of cfg, whether the session can describe the item and get its
+// value with the object's secretArgs. Folders and tag selections are expanded like mounts do, so
+// every item they contain is reviewed, having been listed counts as described.
+func (p *Provider) Review(ctx context.Context, cfg config.Config) ([]ReviewResult, error) {
+	objects, err := p.expandObjects(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ReviewResult, 0, len(objects))
+	for _, obj := range objects {
+		results = append(results, p.reviewObject(ctx, obj, cfg))
+	}
+	return results, nil
+}
+
+func (p *Provider) reviewObject(ctx context.Context, obj object, cfg config.Config) ReviewResult {
+	r := ReviewResult{SecretPath: obj.SecretPath, Describe: ReviewFail, Get: ReviewFail}
+
+	item := obj.item
+	if item == nil {
+		var err error
+		item, err = p.DescribeItem(ctx, obj.SecretPath, cfg)
+		if err == nil && item.GetItemType() == "" {
+			err = errors.New("item not found or not permitted")
+		}
+		if err != nil {
+			r.Error = err.Error()
+			return r
+		}
+	}
+	r.Describe = ReviewPass
+
+	if unreviewedItemTypes[item.GetItemType()] {
+		r.Get = ReviewNotFetched
+		return r
+	}
+	if _, _, err := p.getItemValue(ctx, item, obj.SecretArgs, cfg); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Get = ReviewPass
+	return r
+}
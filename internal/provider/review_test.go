@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReview(t *testing.T) {
+	g := newFakeGateway(t, map[string]fakeItem{
+		"/team/api":    {itemType: "STATIC_SECRET", version: 1, value: "a1"},
+		"/team/db":     {itemType: "STATIC_SECRET", version: 1, value: `{"password":"p1"}`},
+		"/db-producer": {itemType: "DYNAMIC_SECRET", value: map[string]interface{}{"user": "tmp-1"}},
+		"/pki/issuer":  {itemType: "PKI_CERT_ISSUER", value: fakeIssuer(t, 0)},
+		"/tokenizer":   {itemType: "TOKENIZER", value: map[string]interface{}{"result": "plain"}},
+		"/config":      {itemType: "STATIC_SECRET", version: 1, value: `{"url":"https://example.com"}`},
+	})
+
+	cfg := config.Config{TargetPath: "/access-review", Session: g.session, Parameters: config.Parameters{
+		Secrets: []config.Secret{
+			{FileName: "team", SecretPath: "/team/"},
+			{FileName: "db", SecretPath: "/db-producer"},
+			{FileName: "tls", SecretPath: "/pki/issuer"},
+			{FileName: "card", SecretPath: "/tokenizer", SecretArgs: map[string]interface{}{"ciphertext": "tok"}},
+			{FileName: "password", SecretPath: "/config", SecretArgs: map[string]interface{}{"key": "password"}},
+			{FileName: "missing", SecretPath: "/missing"},
+		},
+	}}
+	results, err := NewProvider().Review(context.Background(), cfg)
+	require.NoError(t, err)
+
+	byPath := make(map[string]ReviewResult)
+	for _, r := range results {
+		byPath[r.SecretPath] = r
+	}
+	require.Len(t, byPath, 7, "the folder is expanded to its items")
+	for _, path := range []string{"/team/api", "/team/db"} {
+		require.Equal(t, ReviewResult{SecretPath: path, Describe: ReviewPass, Get: ReviewPass}, byPath[path])
+	}
+	// items whose fetches have side effects are described only
+	for _, path := range []string{"/db-producer", "/pki/issuer", "/tokenizer"} {
+		require.Equal(t, ReviewResult{SecretPath: path, Describe: ReviewPass, Get: ReviewNotFetched}, byPath[path])
+		require.False(t, byPath[path].Failed())
+	}
+	require.Zero(t, g.calls["/get-dynamic-secret-value"])
+	require.Zero(t, g.calls["/get-pki-certificate"])
+	require.Zero(t, g.calls["/detokenize"])
+
+	// the secretArgs of the object apply
+	require.Equal(t, ReviewPass, byPath["/config"].Describe)
+	require.Equal(t, ReviewFail, byPath["/config"].Get)
+	require.Contains(t, byPath["/config"].Error, `has no key "password"`)
+	require.True(t, byPath["/missing"].Failed())
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"log"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+)
+
+// sanityWarnings enables warnings about suspicious mounted values, disabled by default.
+var sanityWarnings atomic.Bool
+
+// SetSanityWarnings enables logging and counting mounted values that look misconfigured.
+// Warnings never block a mount.
+func SetSanityWarnings(enabled bool) {
+	sanityWarnings.Store(enabled)
+}
+
+var (
+	placeholderValues = map[string]bool{
+		"changeme": true, "change-me": true, "change_me": true, "replaceme": true, "replace-me": true,
+		"replace_me": true, "todo": true, "tbd": true, "placeholder": true, "dummy": true,
+		"example": true, "null": true, "none": true, "xxx": true, "password": true, "secret": true,
+	}
+	pemBegin = regexp.MustCompile(`-----BEGIN ([A-Z0-9 ]+)-----`)
+)
+
+// suspiciousValue returns why value looks like an upstream misconfiguration, or an empty string.
+func suspiciousValue(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return "empty"
+	}
+	if placeholderValues[strings.ToLower(trimmed)] || (strings.HasPrefix(trimmed, "<") && strings.HasSuffix(trimmed, ">") && !strings.Contains(trimmed, "\n")) {
+		return "placeholder"
+	}
+
+	// JSON documents, like certificates and dynamic secret outputs, hold their PEM in string
+	// fields with escaped newlines, so the PEM of the fields is checked instead
+	var doc interface{}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Unmarshal([]byte(trimmed), &doc) == nil {
+		return jsonPEMProblem(doc)
+	}
+	return pemProblem(trimmed)
+}
+
+// pemProblem returns why the PEM blocks of value are broken, or an empty string.
+func pemProblem(value string) string {
+	for _, m := range pemBegin.FindAllStringSubmatch(value, -1) {
+		if !strings.Contains(value, "-----END "+m[1]+"-----") {
+			return "truncated_pem"
+		}
+	}
+	if pemBegin.MatchString(value) {
+		if block, _ := pem.Decode([]byte(value[strings.Index(value, "-----BEGIN"):])); block == nil {
+			return "malformed_pem"
+		}
+	}
+	return ""
+}
+
+// jsonPEMProblem returns why the PEM of a string field of doc is broken, or an empty string.
+func jsonPEMProblem(doc interface{}) string {
+	switch v := doc.(type) {
+	case string:
+		return pemProblem(strings.TrimSpace(v))
+	case map[string]interface{}:
+		for _, field := range v {
+			if reason := jsonPEMProblem(field); reason != "" {
+				return reason
+			}
+		}
+	case []interface{}:
+		for _, elem := range v {
+			if reason := jsonPEMProblem(elem); reason != "" {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+func warnIfSuspicious(cfg config.Config, secretPath, value string) {
+	if !sanityWarnings.Load() {
+		return
+	}
+	if reason := suspiciousValue(value); reason != "" {
+		log.Printf("WARNING: suspicious secret value, secretProviderClass: %v, object: %v, reason: %v, size: %v", cfg.SecretProviderClass, secretPath, reason, len(value))
+		metrics.SuspiciousValues.Inc(cfg.SecretProviderClass, reason)
+	}
+}
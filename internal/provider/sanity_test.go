@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-go/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuspiciousValue(t *testing.T) {
+	for value, reason := range map[string]string{
+		"":                                  "empty",
+		"  \n":                              "empty",
+		"ChangeMe":                          "placeholder",
+		"<your access key>":                 "placeholder",
+		"s3cr3t-v4lu3":                      "",
+		`{"user":"a","pass":1}`:             "",
+		"-----BEGIN CERTIFICATE-----\nMIIB": "truncated_pem",
+		"-----BEGIN CERTIFICATE-----\n!!!\n-----END CERTIFICATE-----":    "malformed_pem",
+		"-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n": "",
+	} {
+		require.Equal(t, reason, suspiciousValue(value), value)
+	}
+}
+
+func TestSuspiciousValue_CertificateJSON(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "app.example.com"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+
+	// certificates are mounted as the JSON of get-certificate-value, with escaped newlines
+	out := akeyless.GetCertificateValueOutput{}
+	out.SetCertificatePem(certPEM)
+	out.SetPrivateKeyPem(keyPEM)
+	value, err := json.Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, "", suspiciousValue(string(value)))
+
+	out.SetCertificatePem(certPEM[:len(certPEM)/2])
+	value, err = json.Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, "truncated_pem", suspiciousValue(string(value)))
+}
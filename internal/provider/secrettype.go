@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// typedItemTypes are the item types a secretType may name, all mountable item types but DFC keys,
+// whose public material comes with the described item.
+var typedItemTypes = map[string]bool{
+	"STATIC_SECRET":   true,
+	"CERTIFICATE":     true,
+	"ROTATED_SECRET":  true,
+	"DYNAMIC_SECRET":  true,
+	"PKI_CERT_ISSUER": true,
+	"USC":             true,
+	"CLASSIC_KEY":     true,
+	"TOKENIZER":       true,
+}
+
+// typedItem returns the item of an object setting secretType, so its value is fetched right away
+// without a describe round-trip, nil if the object has to be described.
+func typedItem(secret config.Secret) *akeyless.Item {
+	if secret.SecretType == "" {
+		return nil
+	}
+	itemType := strings.ToUpper(secret.SecretType)
+	if !typedItemTypes[itemType] {
+		log.Printf("WARNING: describing %v, secretType %v is not one of the item types that can skip it", secret.SecretPath, secret.SecretType)
+		return nil
+	}
+	item := akeyless.NewItem()
+	item.SetItemName(secret.SecretPath)
+	item.SetItemType(itemType)
+	return item
+}
+
+// contentVersion identifies the value of an item whose version is unknown, so the object version
+// still changes with the value.
+func contentVersion(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/processor"
+)
+
+// DefaultMaxResponseSize matches the driver's default gRPC receive limit (--max-call-recv-msg-size).
+const DefaultMaxResponseSize = 4 << 20
+
+// maxResponseSize is the largest mount response the driver accepts, 0 disables the check.
+var maxResponseSize = DefaultMaxResponseSize
+
+// maxReportedObjects bounds the number of objects an oversized response error names.
+const maxReportedObjects = 5
+
+// SetMaxResponseSize sets the total size of mounted files a mount response may carry, it must
+// not exceed the driver's gRPC receive limit. 0 disables the check.
+func SetMaxResponseSize(size int) {
+	maxResponseSize = size
+}
+
+// mountedObject is the files an object adds to the mount response.
+type mountedObject struct {
+	secretPath string
+	files      []processor.File
+}
+
+func (o mountedObject) size() int {
+	size := 0
+	for _, f := range o.files {
+		size += len(f.Path) + len(f.Contents)
+	}
+	return size
+}
+
+// checkResponseSize fails the mount with a precise error when the files would not fit into a
+// single mount response, instead of the driver rejecting the oversized response opaquely. The
+// error lists the largest objects with their sizes and the ones to move to another
+// SecretProviderClass for the rest to fit.
+func checkResponseSize(cfg config.Config, objects []mountedObject) error {
+	if maxResponseSize <= 0 {
+		return nil
+	}
+
+	total, files := 0, 0
+	sizes := make(map[string]int)
+	for _, o := range objects {
+		total += o.size()
+		files += len(o.files)
+		sizes[o.secretPath] += o.size()
+	}
+	if total <= maxResponseSize {
+		return nil
+	}
+
+	paths := make([]string, 0, len(sizes))
+	for p := range sizes {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if sizes[paths[i]] != sizes[paths[j]] {
+			return sizes[paths[i]] > sizes[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+
+	var largest, move []string
+	remaining := total
+	for i, p := range paths {
+		if i < maxReportedObjects {
+			largest = append(largest, fmt.Sprintf("%v (%d bytes)", p, sizes[p]))
+		}
+		if remaining > maxResponseSize {
+			move = append(move, p)
+			remaining -= sizes[p]
+		}
+	}
+
+	return fmt.Errorf("mount response of SecretProviderClass %v would be %d bytes for %d files, exceeding the limit of %d bytes, largest objects: %v; move %v to a separate SecretProviderClass for the rest to fit",
+		cfg.SecretProviderClass, total, files, maxResponseSize, strings.Join(largest, ", "), strings.Join(move, ", "))
+}
@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/processor"
+)
+
+// renderTemplates renders the templates of the SecretProviderClass from the fetched values,
+// keyed by the objects' mount paths.
+func renderTemplates(cfg config.Config, values map[string]string) ([]processor.File, error) {
+	if len(cfg.Templates) == 0 {
+		return nil, nil
+	}
+
+	parsed := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		var v interface{}
+		if json.Unmarshal([]byte(value), &v) == nil {
+			parsed[name] = v
+		}
+	}
+	data := map[string]interface{}{"Secrets": values, "JSON": parsed}
+
+	var files []processor.File
+	for _, t := range cfg.Templates {
+		out, err := processor.RenderTemplate(t.FileName, t.Template, data)
+		if err != nil {
+			return nil, fmt.Errorf("template %v, secretProviderClass: %v: %w", t.FileName, cfg.SecretProviderClass, err)
+		}
+		files = append(files, processor.File{Path: t.FileName, Contents: out})
+	}
+	return files, nil
+}
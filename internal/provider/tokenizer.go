@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// detokenize restores the plaintext of a tokenized payload with a tokenizer item. The object's
+// secretPath is the tokenizer, the "ciphertext" secretArg is the token to restore and the "tweak"
+// secretArg the tweak it was tokenized with, if the tokenizer doesn't generate tweaks itself.
+func (p *Provider) detokenize(ctx context.Context, tokenizerName string, args map[string]interface{}, cfg config.Config) (string, error) {
+	ciphertext := stringArg(args, "ciphertext")
+	if ciphertext == "" {
+		return "", fmt.Errorf("missing ciphertext secretArg for tokenizer %v", tokenizerName)
+	}
+
+	body := akeyless.NewDetokenize(ciphertext, tokenizerName)
+	if tweak := stringArg(args, "tweak"); tweak != "" {
+		body.SetTweak(tweak)
+	}
+	if cfg.UsingUID() {
+		body.SetUidToken(cfg.Session.Token())
+	} else {
+		body.SetToken(cfg.Session.Token())
+	}
+
+	out, res, err := cfg.Session.Client.Detokenize(ctx).Body(*body).Execute()
+	if err := finishCall(res, err, fmt.Sprintf("can't detokenize with tokenizer %v", tokenizerName)); err != nil {
+		return "", err
+	}
+	return out.GetResult(), nil
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+)
+
+// getUSCSecret fetches a secret of an external secret manager through a Universal Secrets
+// Connector. The object's secretPath is the connector and the "secret-id" secretArg names the
+// secret within the external manager, binary secrets are mounted decoded.
+func (p *Provider) getUSCSecret(ctx context.Context, uscName string, args map[string]interface{}, cfg config.Config) (string, error) {
+	secretID := stringArg(args, "secret-id")
+	if secretID == "" {
+		return "", fmt.Errorf("missing secret-id secretArg for universal secrets connector %v", uscName)
+	}
+
+	body := akeyless.UscGet{
+		UscName:  uscName,
+		SecretId: secretID,
+	}
+	if cfg.UsingUID() {
+		body.SetUidToken(cfg.Session.Token())
+	} else {
+		body.SetToken(cfg.Session.Token())
+	}
+
+	out, res, err := cfg.Session.Client.UscGet(ctx).Body(body).Execute()
+	if err := finishCall(res, err, fmt.Sprintf("can't get secret %v of universal secrets connector %v", secretID, uscName)); err != nil {
+		return "", err
+	}
+
+	if !out.GetBinaryValue() {
+		return out.GetValue(), nil
+	}
+	value, err := base64.StdEncoding.DecodeString(out.GetValue())
+	if err != nil {
+		return "", fmt.Errorf("can't decode binary secret %v of universal secrets connector %v: %w", secretID, uscName, err)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/provider"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// APIVersion is the version of the driver's provider API the server implements.
+const APIVersion = "v1alpha1"
+
+// mountError is the error of a failed mount. It reads and unwraps like the underlying error
+// and carries the gRPC status code the driver reports for the mount, see mountErrorCode.
+type mountError struct {
+	stage string
+	err   error
+}
+
+func (e *mountError) Error() string {
+	return e.err.Error()
+}
+
+func (e *mountError) Unwrap() error {
+	return e.err
+}
+
+// GRPCStatus is used by the gRPC server for the status of the call.
+func (e *mountError) GRPCStatus() *status.Status {
+	return status.New(mountErrorCode(e.stage, e.err), e.err.Error())
+}
+
+// mountErrorCode maps the error of a mount to a gRPC status code by how far the mount got:
+// invalid SecretProviderClasses fail with InvalidArgument, credentials that don't authenticate
+// with Unauthenticated, items the role can't read with PermissionDenied and other fetching
+// errors with Unavailable, which the driver retries.
+func mountErrorCode(stage string, err error) codes.Code {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, config.ErrAuthentication), errors.Is(err, provider.ErrUnauthorized):
+		return codes.Unauthenticated
+	case errors.Is(err, provider.ErrForbidden):
+		return codes.PermissionDenied
+	case errors.Is(err, provider.ErrThrottled):
+		return codes.ResourceExhausted
+	case errors.Is(err, provider.ErrGatewayRequired):
+		return codes.FailedPrecondition
+	}
+	switch stage {
+	case StageParse:
+		return codes.InvalidArgument
+	case StageAuthenticate:
+		return codes.Unauthenticated
+	}
+	return codes.Unavailable
+}
+
+// checkAPIVersion rejects drivers asking for another provider API version, an empty version
+// is accepted for drivers that don't negotiate.
+func checkAPIVersion(req *pb.VersionRequest) error {
+	if v := req.GetVersion(); v != "" && v != APIVersion {
+		return status.Errorf(codes.Unimplemented, "unsupported provider API version %q, supported: %v", v, APIVersion)
+	}
+	return nil
+}
+
+// currentObjectVersions returns the object versions the driver has mounted, as passed along
+// with remounts, standing in for the last mount's after a restart of the provider.
+func currentObjectVersions(req *pb.MountRequest) map[string]string {
+	if len(req.GetCurrentObjectVersion()) == 0 {
+		return nil
+	}
+	versions := make(map[string]string, len(req.GetCurrentObjectVersion()))
+	for _, v := range req.GetCurrentObjectVersion() {
+		versions[v.Id] = v.Version
+	}
+	return versions
+}
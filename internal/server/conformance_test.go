@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// TestConformance runs the checks of the driver's provider contract against the server over a
+// unix socket, as the driver talks to it: version negotiation, the status codes of failed mounts
+// and the rotation contract of object versions.
+func TestConformance(t *testing.T) {
+	var version int32 = 1
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/auth" && body["token"] != "t-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid token"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/auth":
+			if body["access-key"] != "key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"access denied"}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": "t-1"})
+		case "/describe-item":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"item_name": body["name"], "item_type": "STATIC_SECRET", "last_version": atomic.LoadInt32(&version)})
+		case "/get-secret-value":
+			name := body["names"].([]interface{})[0].(string)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{name: "value"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer gw.Close()
+
+	srv := grpc.NewServer()
+	pb.RegisterCSIDriverProviderServer(srv, &Server{VaultAddr: gw.URL, VaultMount: "kubernetes", NewClient: config.NewClient})
+	socket := filepath.Join(t.TempDir(), "akeyless.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(listener) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewCSIDriverProviderClient(conn)
+	ctx := context.Background()
+
+	mountRequest := func(targetPath, accessKey, objects string) *pb.MountRequest {
+		attributes, err := json.Marshal(map[string]string{
+			"secretProviderClass": "conformance",
+			"akeylessAccessType":  "access_key",
+			"akeylessAccessID":    "p-1",
+			"akeylessAccessKey":   accessKey,
+			"objects":             objects,
+		})
+		require.NoError(t, err)
+		return &pb.MountRequest{Attributes: string(attributes), TargetPath: targetPath, Permission: "420"}
+	}
+
+	t.Run("version negotiation", func(t *testing.T) {
+		resp, err := client.Version(ctx, &pb.VersionRequest{Version: APIVersion})
+		require.NoError(t, err)
+		require.Equal(t, APIVersion, resp.Version)
+
+		_, err = client.Version(ctx, &pb.VersionRequest{Version: "v2"})
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		_, err := client.Mount(ctx, &pb.MountRequest{Attributes: "not json", TargetPath: "/pods/a/mount", Permission: "420"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.Mount(ctx, mountRequest("/pods/a/mount", "key", "- secretPath: /db\n  fileName: ../db"))
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		require.Contains(t, status.Convert(err).Message(), "invalid fileName ../db")
+	})
+
+	t.Run("authentication failure", func(t *testing.T) {
+		_, err := client.Mount(ctx, mountRequest("/pods/b/mount", "wrong", "- secretPath: /db\n  fileName: db"))
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("rotation contract", func(t *testing.T) {
+		req := mountRequest("/pods/c/mount", "key", "- secretPath: /db\n  fileName: db")
+		resp, err := client.Mount(ctx, req)
+		require.NoError(t, err)
+		require.Nil(t, resp.Error)
+		require.Len(t, resp.Files, 1)
+		require.Equal(t, "db", resp.Files[0].Path)
+		require.EqualValues(t, 420, resp.Files[0].Mode)
+		require.Equal(t, []*pb.ObjectVersion{{Id: "/db", Version: "1"}}, versionsOf(resp))
+
+		// remounts of unchanged objects report the same versions, the driver then skips the update
+		req.CurrentObjectVersion = resp.ObjectVersion
+		resp, err = client.Mount(ctx, req)
+		require.NoError(t, err)
+		require.Equal(t, []*pb.ObjectVersion{{Id: "/db", Version: "1"}}, versionsOf(resp))
+
+		atomic.StoreInt32(&version, 2)
+		req.CurrentObjectVersion = resp.ObjectVersion
+		resp, err = client.Mount(ctx, req)
+		require.NoError(t, err)
+		require.Equal(t, []*pb.ObjectVersion{{Id: "/db", Version: "2"}}, versionsOf(resp))
+	})
+}
+
+// versionsOf returns the object versions of a mount response without the protobuf internals.
+func versionsOf(resp *pb.MountResponse) []*pb.ObjectVersion {
+	var versions []*pb.ObjectVersion
+	for _, v := range resp.GetObjectVersion() {
+		versions = append(versions, &pb.ObjectVersion{Id: v.Id, Version: v.Version})
+	}
+	return versions
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sort"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/provider"
+)
+
+// inflightMount is a mount request being handled.
+type inflightMount struct {
+	targetPath string
+	started    time.Time
+}
+
+// trackMount records a mount of the target path as in flight until the returned func is called.
+func (p *Server) trackMount(targetPath string, started time.Time) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.inflight == nil {
+		p.inflight = make(map[uint64]inflightMount)
+	}
+	p.nextMount++
+	id := p.nextMount
+	p.inflight[id] = inflightMount{targetPath: targetPath, started: started}
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.inflight, id)
+	}
+}
+
+// WriteDiagnostics writes a snapshot of the provider for investigating a hung provider: in-flight
+// mounts, sessions, cache stats and goroutine stacks. It holds no secret values or tokens, and
+// never waits for sessions locked by a mount, which are reported as busy instead.
+func (p *Server) WriteDiagnostics(w io.Writer) error {
+	now := time.Now()
+
+	p.mu.Lock()
+	inflight := make([]inflightMount, 0, len(p.inflight))
+	for _, m := range p.inflight {
+		inflight = append(inflight, m)
+	}
+	targetPaths := make([]string, 0, len(p.sessions))
+	sessions := make(map[string]*session, len(p.sessions))
+	for targetPath, s := range p.sessions {
+		targetPaths = append(targetPaths, targetPath)
+		sessions[targetPath] = s
+	}
+	p.mu.Unlock()
+
+	sort.Slice(inflight, func(i, j int) bool { return inflight[i].started.Before(inflight[j].started) })
+	fmt.Fprintf(w, "in-flight mounts: %d\n", len(inflight))
+	for _, m := range inflight {
+		fmt.Fprintf(w, "  %v, running for %v\n", m.targetPath, now.Sub(m.started).Round(time.Millisecond))
+	}
+
+	sort.Strings(targetPaths)
+	fmt.Fprintf(w, "sessions: %d\n", len(targetPaths))
+	for _, targetPath := range targetPaths {
+		s := sessions[targetPath]
+		if !s.mu.TryLock() {
+			fmt.Fprintf(w, "  %v: busy, a mount holds the session\n", targetPath)
+			continue
+		}
+		cfg, mounted, objects := s.cfg, s.mounted, len(s.prov.MountedPaths())
+		s.mu.Unlock()
+
+		accessType, tokenAge := "", "none"
+		if cfg.Session != nil {
+			accessType = cfg.Session.AccessType()
+			if issued := cfg.Session.TokenIssued(); !issued.IsZero() {
+				tokenAge = now.Sub(issued).Round(time.Second).String()
+			}
+		}
+		fmt.Fprintf(w, "  %v: secretProviderClass: %v, pod: %v/%v, accessType: %v, accessID: %v, objects: %d, mounted: %v ago, token age: %v\n",
+			targetPath, cfg.SecretProviderClass, cfg.PodInfo.Namespace, cfg.PodInfo.Name, accessType, cfg.AkeylessAccessID,
+			objects, now.Sub(mounted).Round(time.Second), tokenAge)
+	}
+
+	stats := provider.GetCacheStats()
+	fmt.Fprintf(w, "cache: values: %d, refreshing: %d, ttl: %v, item metadata: %d\n", stats.Values, stats.Refreshing, stats.ValueTTL, stats.ItemMetadata)
+
+	fmt.Fprintln(w, "goroutines:")
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDiagnostics(t *testing.T) {
+	s := &Server{}
+	done := s.trackMount("/pods/b/mount", time.Now().Add(-time.Minute))
+
+	idle := s.session("/pods/a/mount")
+	idle.cfg = config.Config{TargetPath: "/pods/a/mount", Session: config.NewSession(nil), Parameters: config.Parameters{
+		SecretProviderClass: "spc-a", PodInfo: config.PodInfo{Namespace: "team-a", Name: "web-0"}, AkeylessAccessID: "p-123",
+		AkeylessAccessKey: config.NewCredential("s3cr3t-key"),
+	}}
+	idle.mounted = time.Now()
+
+	busy := s.session("/pods/b/mount")
+	busy.mu.Lock()
+	defer busy.mu.Unlock()
+
+	var out bytes.Buffer
+	require.NoError(t, s.WriteDiagnostics(&out))
+	dump := out.String()
+	require.Contains(t, dump, "in-flight mounts: 1\n  /pods/b/mount, running for 1m")
+	require.Contains(t, dump, "/pods/a/mount: secretProviderClass: spc-a, pod: team-a/web-0, accessType: , accessID: p-123, objects: 0")
+	require.Contains(t, dump, "/pods/b/mount: busy, a mount holds the session")
+	require.Contains(t, dump, "cache: values: 0")
+	require.Contains(t, dump, "goroutine ")
+	require.NotContains(t, dump, "s3cr3t-key")
+
+	done()
+	out.Reset()
+	require.NoError(t, s.WriteDiagnostics(&out))
+	require.Contains(t, out.String(), "in-flight mounts: 0\n")
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// StageWarmUp is waiting for a slot of the warm-up ramp after startup, see WarmUp
+	StageWarmUp = "warm-up"
+	// StageParse is the parsing of the mount request, which includes the initial authentication
+	StageParse = "parse"
+	// StageAuthenticate is the start of the routine keeping the mount's token valid
+	StageAuthenticate = "authenticate"
+	// StageFetch is fetching the objects and building the mount response
+	StageFetch = "fetch"
+
+	// maxFailuresPerTargetPath is how many recent failures are kept per target path
+	maxFailuresPerTargetPath = 10
+	// maxFailedTargetPaths bounds the target paths failures are kept for, the ones failing
+	// longest ago are dropped first
+	maxFailedTargetPaths = 1000
+)
+
+// MountFailure is a failed mount request of a target path.
+type MountFailure struct {
+	Time time.Time `json:"time"`
+	// Stage is how far the mount got, StageWarmUp, StageParse, StageAuthenticate or StageFetch
+	Stage    string `json:"stage"`
+	Error    string `json:"error"`
+	Duration string `json:"duration"`
+}
+
+// TargetPathFailures are the recent failed mounts of a target path, oldest first.
+type TargetPathFailures struct {
+	TargetPath          string         `json:"targetPath"`
+	SecretProviderClass string         `json:"secretProviderClass,omitempty"`
+	Namespace           string         `json:"namespace,omitempty"`
+	Pod                 string         `json:"pod,omitempty"`
+	Failures            []MountFailure `json:"failures"`
+}
+
+// failureLog keeps the recent failures of target paths until they mount successfully, so that
+// the kubelet's retries of a mount can be correlated.
+type failureLog struct {
+	mu     sync.Mutex
+	byPath map[string]*TargetPathFailures
+}
+
+func (l *failureLog) record(targetPath string, info *mountInfo, f MountFailure) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.byPath == nil {
+		l.byPath = make(map[string]*TargetPathFailures)
+	}
+	entry, ok := l.byPath[targetPath]
+	if !ok {
+		if len(l.byPath) >= maxFailedTargetPaths {
+			l.evictOldest()
+		}
+		entry = &TargetPathFailures{TargetPath: targetPath}
+		l.byPath[targetPath] = entry
+	}
+	// parsing may fail before the request is known
+	if info.SecretProviderClass != "" {
+		entry.SecretProviderClass = info.SecretProviderClass
+		entry.Namespace = info.PodInfo.Namespace
+		entry.Pod = info.PodInfo.Name
+	}
+	entry.Failures = append(entry.Failures, f)
+	if len(entry.Failures) > maxFailuresPerTargetPath {
+		entry.Failures = append([]MountFailure(nil), entry.Failures[len(entry.Failures)-maxFailuresPerTargetPath:]...)
+	}
+}
+
+func (l *failureLog) evictOldest() {
+	oldest := ""
+	var oldestTime time.Time
+	for targetPath, entry := range l.byPath {
+		last := entry.Failures[len(entry.Failures)-1].Time
+		if oldest == "" || last.Before(oldestTime) {
+			oldest, oldestTime = targetPath, last
+		}
+	}
+	delete(l.byPath, oldest)
+}
+
+func (l *failureLog) clear(targetPath string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.byPath, targetPath)
+}
+
+// RecentFailures returns the recent failed mounts of the target paths that haven't mounted
+// successfully since, sorted by target path, or only the ones of targetPath if it isn't empty.
+func (p *Server) RecentFailures(targetPath string) []TargetPathFailures {
+	p.failures.mu.Lock()
+	defer p.failures.mu.Unlock()
+
+	entries := []TargetPathFailures{}
+	for path, entry := range p.failures.byPath {
+		if targetPath != "" && path != targetPath {
+			continue
+		}
+		e := *entry
+		e.Failures = append([]MountFailure(nil), entry.Failures...)
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].TargetPath < entries[j].TargetPath })
+	return entries
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestRecentFailures(t *testing.T) {
+	var itemMissing atomic.Bool
+	itemMissing.Store(true)
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/auth":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": "t-1"})
+		case itemMissing.Load():
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"item not found"}`))
+		case r.URL.Path == "/describe-item":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"item_name": body["name"], "item_type": "STATIC_SECRET", "last_version": 1})
+		case r.URL.Path == "/get-secret-value":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"/db": "value"})
+		}
+	}))
+	defer gw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &Server{VaultAddr: gw.URL, VaultMount: "kubernetes", NewClient: config.NewClient}
+	attributes, err := json.Marshal(map[string]string{
+		"secretProviderClass":              "spc-a",
+		"csi.storage.k8s.io/pod.name":      "pod-a",
+		"csi.storage.k8s.io/pod.namespace": "team-a",
+		"akeylessAccessType":               "access_key",
+		"akeylessAccessID":                 "p-1",
+		"akeylessAccessKey":                "key",
+		"objects":                          "- secretPath: /db\n  fileName: db",
+	})
+	require.NoError(t, err)
+	req := &pb.MountRequest{Attributes: string(attributes), TargetPath: "/pods/a/mount", Permission: "420"}
+
+	for i := 0; i < maxFailuresPerTargetPath+2; i++ {
+		_, err = s.Mount(ctx, req)
+		require.Error(t, err)
+	}
+	// a request that doesn't parse fails before the objects are fetched
+	_, err = s.Mount(ctx, &pb.MountRequest{Attributes: "not json", TargetPath: "/pods/b/mount", Permission: "420"})
+	require.Error(t, err)
+
+	failures := s.RecentFailures("")
+	require.Len(t, failures, 2)
+	a := failures[0]
+	require.Equal(t, "/pods/a/mount", a.TargetPath)
+	require.Equal(t, "spc-a", a.SecretProviderClass)
+	require.Equal(t, "team-a", a.Namespace)
+	require.Equal(t, "pod-a", a.Pod)
+	require.Len(t, a.Failures, maxFailuresPerTargetPath)
+	for _, f := range a.Failures {
+		require.Equal(t, StageFetch, f.Stage)
+		require.Contains(t, f.Error, "item not found")
+	}
+	require.Equal(t, StageParse, failures[1].Failures[0].Stage)
+	require.Equal(t, []TargetPathFailures{failures[1]}, s.RecentFailures("/pods/b/mount"))
+
+	// a successful mount clears the failures of its target path
+	itemMissing.Store(false)
+	_, err = s.Mount(ctx, req)
+	require.NoError(t, err)
+	require.Empty(t, s.RecentFailures("/pods/a/mount"))
+}
+
+func TestFailureLog_EvictsOldest(t *testing.T) {
+	var l failureLog
+	start := time.Now()
+	for i := 0; i <= maxFailedTargetPaths; i++ {
+		l.record(fmt.Sprintf("/pods/%d", i), &mountInfo{}, MountFailure{Time: start.Add(time.Duration(i) * time.Second), Stage: StageParse})
+	}
+	require.Len(t, l.byPath, maxFailedTargetPaths)
+	require.NotContains(t, l.byPath, "/pods/0")
+	require.Contains(t, l.byPath, fmt.Sprintf("/pods/%d", maxFailedTargetPaths))
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// providerName matches the names SecretProviderClasses can refer a provider by.
+var providerName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// Identity is an additional provider name the server is registered under, with its own default
+// gateway, e.g. "akeyless-dev" next to "akeyless" so test and prod secret planes share a node pool.
+type Identity struct {
+	Name       string
+	VaultAddr  string
+	VaultMount string
+}
+
+// ParseIdentity parses an identity given as name=akeyless-address.
+func ParseIdentity(s string) (Identity, error) {
+	name, addr, ok := strings.Cut(s, "=")
+	if !ok || addr == "" {
+		return Identity{}, fmt.Errorf("invalid provider identity %q, expected name=akeyless-address", s)
+	}
+	if !providerName.MatchString(name) {
+		return Identity{}, fmt.Errorf("invalid provider name %q, it must consist of lower case alphanumeric characters or '-'", name)
+	}
+	addr, err := config.NormalizeGatewayURL(addr)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid akeyless-address of provider identity %v: %w", name, err)
+	}
+	return Identity{Name: name, VaultAddr: addr}, nil
+}
+
+// identityServer serves mounts of an additional identity. Sessions are shared with the server,
+// so forced refreshes and the inventory cover the mounts of every identity.
+type identityServer struct {
+	*Server
+	identity Identity
+}
+
+// WithIdentity returns the gRPC service of the identity, to be registered on the identity's own
+// socket. An empty VaultMount of the identity defaults to the server's.
+func (p *Server) WithIdentity(identity Identity) pb.CSIDriverProviderServer {
+	if identity.VaultMount == "" {
+		identity.VaultMount = p.VaultMount
+	}
+	return &identityServer{Server: p, identity: identity}
+}
+
+func (i *identityServer) Mount(ctx context.Context, req *pb.MountRequest) (*pb.MountResponse, error) {
+	return i.Server.mountWithDefaults(ctx, req, i.identity.VaultAddr, i.identity.VaultMount)
+}
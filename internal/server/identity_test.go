@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-go/v4"
+	"github.com/stretchr/testify/require"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestParseIdentity(t *testing.T) {
+	identity, err := ParseIdentity("akeyless-dev=https://gw.dev.example.com:8000/api/v2")
+	require.NoError(t, err)
+	require.Equal(t, Identity{Name: "akeyless-dev", VaultAddr: "https://gw.dev.example.com:8000/api/v2"}, identity)
+
+	for _, invalid := range []string{"akeyless-dev", "akeyless-dev=", "../akeyless=https://api.akeyless.io", "Akeyless=https://api.akeyless.io", "akeyless-dev=gw.dev.example.com:8000"} {
+		_, err := ParseIdentity(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestWithIdentity(t *testing.T) {
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/auth":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": "t"})
+		case "/describe-item":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"item_name": body["name"], "item_type": "STATIC_SECRET", "last_version": 1})
+		case "/get-secret-value":
+			name := body["names"].([]interface{})[0].(string)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{name: "value"})
+		}
+	}))
+	defer gw.Close()
+
+	// the identity's default gateway is resolved to the fake gateway, the server's is unreachable
+	var gatewayURLs []string
+	s := &Server{VaultAddr: "https://prod.example.com", VaultMount: "kubernetes", NewClient: func(url string) *akeyless.V2ApiService {
+		gatewayURLs = append(gatewayURLs, url)
+		return config.NewClient(gw.URL)
+	}}
+	dev := s.WithIdentity(Identity{Name: "akeyless-dev", VaultAddr: "https://dev.example.com"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attributes, err := json.Marshal(map[string]string{
+		"akeylessAccessType": "access_key",
+		"akeylessAccessID":   "p-dev",
+		"akeylessAccessKey":  "key",
+		"objects":            "- secretPath: /db\n  fileName: db",
+	})
+	require.NoError(t, err)
+	_, err = dev.Mount(ctx, &pb.MountRequest{Attributes: string(attributes), TargetPath: "/pods/dev/mount", Permission: "420"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://dev.example.com"}, gatewayURLs)
+
+	// mounts of every identity share the server's sessions
+	require.Len(t, s.Inventory(), 1)
+}
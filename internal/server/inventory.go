@@ -0,0 +1,103 @@
+package server
+
+import (
+	"log"
+	"sort"
+	"strings"
+)
+
+// InventoryEntry is an Akeyless path mounted on the node.
+type InventoryEntry struct {
+	Path string `json:"path"`
+	// Mounts is the number of target paths the path is mounted to
+	Mounts                int      `json:"mounts"`
+	SecretProviderClasses []string `json:"secretProviderClasses"`
+	Namespaces            []string `json:"namespaces"`
+	// ContentTypes are the content types the objects mounting the path declare
+	ContentTypes []string `json:"contentTypes,omitempty"`
+}
+
+// Inventory returns the distinct Akeyless paths mounted on the node, sorted by path, so
+// security teams can assess the blast radius of the node and review least-privilege policies.
+func (p *Server) Inventory() []InventoryEntry {
+	p.mu.Lock()
+	sessions := make([]*session, 0, len(p.sessions))
+	for _, s := range p.sessions {
+		sessions = append(sessions, s)
+	}
+	p.mu.Unlock()
+
+	type usage struct {
+		mounts       int
+		spcs         map[string]bool
+		namespaces   map[string]bool
+		contentTypes map[string]bool
+	}
+	usages := make(map[string]*usage)
+	for _, s := range sessions {
+		s.mu.Lock()
+		spc, ns := s.cfg.SecretProviderClass, s.cfg.PodInfo.Namespace
+		paths := s.prov.MountedPaths()
+		contentTypes := s.prov.MountedContentTypes()
+		s.mu.Unlock()
+
+		seen := make(map[string]bool)
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			u, ok := usages[path]
+			if !ok {
+				u = &usage{spcs: map[string]bool{}, namespaces: map[string]bool{}, contentTypes: map[string]bool{}}
+				usages[path] = u
+			}
+			u.mounts++
+			if spc != "" {
+				u.spcs[spc] = true
+			}
+			if ns != "" {
+				u.namespaces[ns] = true
+			}
+			if ct := contentTypes[path]; ct != "" {
+				u.contentTypes[ct] = true
+			}
+		}
+	}
+
+	entries := make([]InventoryEntry, 0, len(usages))
+	for path, u := range usages {
+		entry := InventoryEntry{
+			Path:                  path,
+			Mounts:                u.mounts,
+			SecretProviderClasses: sortedSet(u.spcs),
+			Namespaces:            sortedSet(u.namespaces),
+		}
+		if len(u.contentTypes) > 0 {
+			entry.ContentTypes = sortedSet(u.contentTypes)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// LogInventory logs the inventory of mounted Akeyless paths.
+func (p *Server) LogInventory() {
+	entries := p.Inventory()
+	log.Printf("inventory of mounted secret paths, distinct paths: %d", len(entries))
+	for _, e := range entries {
+		log.Printf("inventory path: %v, mounts: %d, secretProviderClasses: %v, namespaces: %v",
+			e.Path, e.Mounts, strings.Join(e.SecretProviderClasses, ","), strings.Join(e.Namespaces, ","))
+	}
+}
+
+func sortedSet(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// rotationNotifyTimeout bounds the delivery of a rotation notification, which never delays mounts.
+const rotationNotifyTimeout = 10 * time.Second
+
+// RotationNotification tells which objects of a pod's mount changed on a rotation remount. It
+// carries the objects' ids and versions only, never their values.
+type RotationNotification struct {
+	Time                time.Time       `json:"time"`
+	SecretProviderClass string          `json:"secretProviderClass"`
+	Namespace           string          `json:"namespace"`
+	Pod                 string          `json:"pod"`
+	TargetPath          string          `json:"targetPath"`
+	Objects             []ChangedObject `json:"objects"`
+}
+
+// ChangedObject is an object whose version changed, OldVersion is empty for new objects and
+// NewVersion for removed ones.
+type ChangedObject struct {
+	ID         string `json:"id"`
+	OldVersion string `json:"oldVersion"`
+	NewVersion string `json:"newVersion"`
+}
+
+// objectVersions returns the versions of a mount response by object id.
+func objectVersions(resp *pb.MountResponse) map[string]string {
+	versions := make(map[string]string, len(resp.GetObjectVersion()))
+	for _, v := range resp.GetObjectVersion() {
+		versions[v.Id] = v.Version
+	}
+	return versions
+}
+
+// changedObjects compares the object versions of two mounts, sorted by id.
+func changedObjects(previous, current map[string]string) []ChangedObject {
+	var changed []ChangedObject
+	for id, version := range current {
+		if prev, ok := previous[id]; !ok || prev != version {
+			changed = append(changed, ChangedObject{ID: id, OldVersion: previous[id], NewVersion: version})
+		}
+	}
+	for id, version := range previous {
+		if _, ok := current[id]; !ok {
+			changed = append(changed, ChangedObject{ID: id, OldVersion: version})
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].ID < changed[j].ID })
+	return changed
+}
+
+// notifyRotation posts the objects that changed since the previous mount of the target path to
+// the rotation webhook, in the background. Nothing is sent for initial mounts or unchanged remounts.
+func (p *Server) notifyRotation(cfg config.Config, previous, current map[string]string) {
+	if p.RotationWebhookURL == "" || previous == nil {
+		return
+	}
+	changed := changedObjects(previous, current)
+	if len(changed) == 0 {
+		return
+	}
+
+	n := RotationNotification{
+		Time:                time.Now().UTC(),
+		SecretProviderClass: cfg.SecretProviderClass,
+		Namespace:           cfg.PodInfo.Namespace,
+		Pod:                 cfg.PodInfo.Name,
+		TargetPath:          cfg.TargetPath,
+		Objects:             changed,
+	}
+	go func() {
+		if err := postNotification(p.RotationWebhookURL, n); err != nil {
+			log.Printf("failed to send rotation notification, secretProviderClass: %v, pod: %v/%v, error: %v", n.SecretProviderClass, n.Namespace, n.Pod, err)
+		}
+	}()
+}
+
+func postNotification(url string, n RotationNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rotationNotifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestChangedObjects(t *testing.T) {
+	require.Empty(t, changedObjects(map[string]string{"/a": "1"}, map[string]string{"/a": "1"}))
+	require.Equal(t, []ChangedObject{
+		{ID: "/a", OldVersion: "1", NewVersion: "2"},
+		{ID: "/b", OldVersion: "1"},
+		{ID: "/c", NewVersion: "1"},
+	}, changedObjects(map[string]string{"/a": "1", "/b": "1"}, map[string]string{"/a": "2", "/c": "1"}))
+}
+
+func TestMount_RotationNotification(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/auth":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": "t"})
+		case "/describe-item":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"item_name": body["name"], "item_type": "STATIC_SECRET", "last_version": version.Load()})
+		case "/get-secret-value":
+			name := body["names"].([]interface{})[0].(string)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{name: "s3cr3t"})
+		}
+	}))
+	defer gw.Close()
+
+	notifications := make(chan RotationNotification, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n RotationNotification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		notifications <- n
+	}))
+	defer hook.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &Server{VaultAddr: gw.URL, VaultMount: "kubernetes", NewClient: config.NewClient, RotationWebhookURL: hook.URL}
+	attributes, err := json.Marshal(map[string]string{
+		"secretProviderClass":              "my-spc",
+		"csi.storage.k8s.io/pod.name":      "web-0",
+		"csi.storage.k8s.io/pod.namespace": "team-a",
+		"akeylessAccessType":               "access_key",
+		"akeylessAccessID":                 "p-1",
+		"akeylessAccessKey":                "key",
+		"objects":                          "- secretPath: /db\n  fileName: db",
+	})
+	require.NoError(t, err)
+	mount := func() {
+		_, err := s.Mount(ctx, &pb.MountRequest{Attributes: string(attributes), TargetPath: "/pods/a/mount", Permission: "420"})
+		require.NoError(t, err)
+	}
+
+	mount()
+	mount()
+	version.Store(2)
+	mount()
+
+	select {
+	case n := <-notifications:
+		require.Equal(t, "my-spc", n.SecretProviderClass)
+		require.Equal(t, "team-a", n.Namespace)
+		require.Equal(t, "web-0", n.Pod)
+		require.Equal(t, "/pods/a/mount", n.TargetPath)
+		require.Equal(t, []ChangedObject{{ID: "/db", OldVersion: "1", NewVersion: "2"}}, n.Objects)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rotation notification")
+	}
+	require.Empty(t, notifications, "initial mounts and unchanged remounts are not notified")
+
+	// after a restart, the versions passed by the driver stand in for the last mount's
+	s = &Server{VaultAddr: gw.URL, VaultMount: "kubernetes", NewClient: config.NewClient, RotationWebhookURL: hook.URL}
+	version.Store(3)
+	_, err = s.Mount(ctx, &pb.MountRequest{Attributes: string(attributes), TargetPath: "/pods/a/mount", Permission: "420",
+		CurrentObjectVersion: []*pb.ObjectVersion{{Id: "/db", Version: "2"}}})
+	require.NoError(t, err)
+	select {
+	case n := <-notifications:
+		require.Equal(t, []ChangedObject{{ID: "/db", OldVersion: "2", NewVersion: "3"}}, n.Objects)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rotation notification after restart")
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+const (
+	// prewarmLead is how long before the expected rotation remount of a target path its token is refreshed
+	prewarmLead = 30 * time.Second
+	// prewarmCheckInterval is how often the sessions are checked for upcoming rotation remounts
+	prewarmCheckInterval = 5 * time.Second
+)
+
+// PrewarmTokens refreshes the token of every session shortly before the rotation remount of its
+// target path is expected, so remounts of a large node, which the driver issues all at once,
+// reuse fresh tokens instead of authenticating together or racing the expiry of their tokens.
+// It blocks until ctx is done and does nothing without a RotationInterval.
+func (p *Server) PrewarmTokens(ctx context.Context) {
+	if p.RotationInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(prewarmCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.prewarm(ctx, now)
+		}
+	}
+}
+
+// prewarm refreshes the tokens of the sessions whose rotation remount is due within prewarmLead
+// of now, unless their token was already obtained that close to it.
+func (p *Server) prewarm(ctx context.Context, now time.Time) {
+	p.mu.Lock()
+	sessions := make(map[string]*session, len(p.sessions))
+	for targetPath, s := range p.sessions {
+		sessions[targetPath] = s
+	}
+	p.mu.Unlock()
+
+	due := make(map[string]*config.Session)
+	for targetPath, s := range sessions {
+		s.mu.Lock()
+		window := s.mounted.Add(p.RotationInterval - prewarmLead)
+		if s.cfg.Session != nil && !now.Before(window) && s.cfg.Session.TokenIssued().Before(window) {
+			due[targetPath] = s.cfg.Session
+		}
+		s.mu.Unlock()
+	}
+
+	for targetPath, session := range due {
+		if err := session.Reauthenticate(ctx); err != nil {
+			log.Printf("failed to pre-warm token for target path %v, error: %v", targetPath, err)
+			continue
+		}
+		log.Printf("pre-warmed token for target path %v", targetPath)
+	}
+}
+
+// warmSession returns the session of the last mount of the target path for the remount to reuse,
+// if the remount has the same parameters and the session's token was pre-warmed for it.
+func (p *Server) warmSession(targetPath, request string) *config.Session {
+	if p.RotationInterval <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	s, ok := p.sessions[targetPath]
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.request != request || s.cfg.Session == nil {
+		return nil
+	}
+	if time.Since(s.cfg.Session.TokenIssued()) > 2*prewarmLead {
+		return nil
+	}
+	return s.cfg.Session
+}
+
+// requestFingerprint identifies the parameters and credentials of a mount request.
+func requestFingerprint(req *pb.MountRequest, vaultAddr, vaultMount string) string {
+	h := sha256.New()
+	for _, part := range []string{req.GetAttributes(), req.GetSecrets(), req.GetPermission(), vaultAddr, vaultMount} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
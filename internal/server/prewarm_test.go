@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/stretchr/testify/require"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestPrewarm_RemountReusesToken(t *testing.T) {
+	// every authentication issues a new token, and every secret value reveals the token it was fetched with
+	var auths int32
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/auth":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": fmt.Sprintf("t-%d", atomic.AddInt32(&auths, 1))})
+		case "/describe-item":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"item_name": body["name"], "item_type": "STATIC_SECRET", "last_version": 1})
+		case "/get-secret-value":
+			name := body["names"].([]interface{})[0].(string)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{name: body["token"]})
+		}
+	}))
+	defer gw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mount := func(s *Server, accessKey string) string {
+		attributes, err := json.Marshal(map[string]string{
+			"akeylessAccessType": "access_key",
+			"akeylessAccessID":   "p-1",
+			"akeylessAccessKey":  accessKey,
+			"objects":            "- secretPath: /db\n  fileName: db",
+		})
+		require.NoError(t, err)
+		resp, err := s.Mount(ctx, &pb.MountRequest{Attributes: string(attributes), TargetPath: "/pods/a/mount", Permission: "420"})
+		require.NoError(t, err)
+		return string(resp.Files[0].Contents)
+	}
+
+	s := &Server{VaultAddr: gw.URL, VaultMount: "kubernetes", NewClient: config.NewClient, RotationInterval: 2 * time.Minute}
+	require.Equal(t, "t-1", mount(s, "key"))
+
+	// not due yet
+	s.prewarm(ctx, time.Now())
+	require.EqualValues(t, 1, atomic.LoadInt32(&auths))
+
+	s.prewarm(ctx, time.Now().Add(s.RotationInterval-prewarmLead))
+	require.EqualValues(t, 2, atomic.LoadInt32(&auths))
+
+	require.Equal(t, "t-2", mount(s, "key"))
+	require.EqualValues(t, 2, atomic.LoadInt32(&auths))
+
+	// other credentials never reuse the session
+	require.Equal(t, "t-3", mount(s, "other-key"))
+}
+
+func TestPrewarm_Disabled(t *testing.T) {
+	s := &Server{}
+	s.session("/pods/a/mount").cfg = config.Config{Session: config.NewSession(nil)}
+	require.Nil(t, s.warmSession("/pods/a/mount", ""))
+	s.PrewarmTokens(context.Background())
+}
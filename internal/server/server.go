@@ -2,10 +2,15 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/audit"
 	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
 	"github.com/akeylesslabs/akeyless-csi-provider/internal/provider"
 	"github.com/akeylesslabs/akeyless-csi-provider/internal/version"
 	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
@@ -13,42 +18,256 @@ import (
 
 var (
 	_ pb.CSIDriverProviderServer = (*Server)(nil)
+
+	// ErrSessionNotFound is returned when no mount was handled for the requested target path.
+	ErrSessionNotFound = errors.New("no session found for target path")
 )
 
 // Server implements the secrets-store-csi-driver provider gRPC service interface.
 type Server struct {
 	VaultAddr  string
 	VaultMount string
+	// NewClient creates the Akeyless client each mount's session uses
+	NewClient config.ClientFactory
+	// RotationInterval is the driver's rotation poll interval, when set the tokens of mounts are
+	// refreshed shortly before their rotation remounts, which reuse them
+	RotationInterval time.Duration
+	// MountStormThreshold is the number of mount requests per minute above which requests skip
+	// their per-request logs in favor of a summary, 0 to log every request
+	MountStormThreshold int
+	// RotationWebhookURL receives a RotationNotification whenever a remount changes the content
+	// of a pod's mount, empty to disable
+	RotationWebhookURL string
+	// WarmUp limits the concurrent mounts after startup, nil to never limit them
+	WarmUp *WarmUp
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	// sessionsSwept is when idle sessions were last evicted
+	sessionsSwept time.Time
+	storms        stormTracker
+	// inflight are the mounts being handled right now, by request
+	inflight  map[uint64]inflightMount
+	nextMount uint64
+	failures  failureLog
+}
+
+const (
+	// sessionIdleTTL is how long the session of a target path is kept after its last mount,
+	// rotation remounts of a mounted pod keep using it
+	sessionIdleTTL = 24 * time.Hour
+	// sessionSweepInterval is how often new sessions check for idle ones
+	sessionSweepInterval = time.Minute
+	// maxSessions bounds the target paths sessions are kept for, the ones mounted longest ago
+	// are dropped first
+	maxSessions = 10000
+)
+
+// session holds the state of the most recent mount of a target path, so that rotation
+// remounts reuse the provider cache and operators can force a refresh of it.
+type session struct {
+	mu   sync.Mutex
+	cfg  config.Config
+	prov *provider.Provider
+	// mounted is when the target path was last mounted
+	mounted time.Time
+	// request identifies the parameters of the last mount, remounts with other ones never
+	// reuse its session
+	request string
+	// versions are the object versions of the last successful mount, nil before the first one
+	versions map[string]string
 }
 
-func (p *Server) Version(context.Context, *pb.VersionRequest) (*pb.VersionResponse, error) {
+func (p *Server) Version(_ context.Context, req *pb.VersionRequest) (*pb.VersionResponse, error) {
+	if err := checkAPIVersion(req); err != nil {
+		return nil, err
+	}
 	return &pb.VersionResponse{
-		Version:        "v1alpha1",
+		Version:        APIVersion,
 		RuntimeName:    "akeyless-csi-provider",
 		RuntimeVersion: version.BuildVersion,
 	}, nil
 }
 
 func (p *Server) Mount(ctx context.Context, req *pb.MountRequest) (*pb.MountResponse, error) {
-	cfg, err := config.Parse(req.GetSecrets(), req.Attributes, req.TargetPath, req.Permission, p.VaultAddr, p.VaultMount)
+	return p.mountWithDefaults(ctx, req, p.VaultAddr, p.VaultMount)
+}
+
+// mountWithDefaults mounts using the given default gateway and Kubernetes mount path, which
+// differ per identity the server is registered under.
+func (p *Server) mountWithDefaults(ctx context.Context, req *pb.MountRequest, vaultAddr, vaultMount string) (*pb.MountResponse, error) {
+	startTime := time.Now()
+	info := mountInfo{storm: p.storms.observe(startTime, p.MountStormThreshold)}
+	done := p.trackMount(req.GetTargetPath(), startTime)
+	resp, err := p.mount(ctx, req, vaultAddr, vaultMount, &info)
+	done()
+	if err != nil {
+		p.failures.record(req.GetTargetPath(), &info, MountFailure{Time: startTime, Stage: info.stage, Error: err.Error(), Duration: time.Since(startTime).String()})
+		err = &mountError{stage: info.stage, err: err}
+	} else {
+		p.failures.clear(req.GetTargetPath())
+	}
+	ns, sa := metrics.Identity(info.PodInfo.Namespace, info.PodInfo.ServiceAccountName)
+	metrics.MountRequests.Inc(info.SecretProviderClass, ns, sa, metrics.Result(err))
+	metrics.MountDuration.Observe(time.Since(startTime).Seconds(), info.SecretProviderClass, ns, sa)
+
+	record := audit.Record{
+		SecretProviderClass: info.SecretProviderClass,
+		Namespace:           info.PodInfo.Namespace,
+		Pod:                 info.PodInfo.Name,
+		ServiceAccount:      info.PodInfo.ServiceAccountName,
+		TargetPath:          req.GetTargetPath(),
+		Objects:             info.objects,
+		Result:              metrics.Result(err),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	audit.Log(record)
+	return resp, err
+}
+
+// mountInfo is what a mount request was about, filled in as far as the mount got.
+type mountInfo struct {
+	config.Parameters
+	// objects are the Akeyless paths the mount fetched, folders expanded
+	objects []string
+	// storm is set for requests of a mount storm, which skip their per-request logs
+	storm bool
+	// stage is how far the mount got, see MountFailure
+	stage string
+}
+
+func (p *Server) mount(ctx context.Context, req *pb.MountRequest, vaultAddr, vaultMount string, info *mountInfo) (*pb.MountResponse, error) {
+	info.stage = StageWarmUp
+	release, err := p.WarmUp.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	request := requestFingerprint(req, vaultAddr, vaultMount)
+	info.stage = StageParse
+	cfg, err := config.ParseWithSession(ctx, p.warmSession(req.TargetPath, request), p.NewClient, req.GetSecrets(), req.Attributes, req.TargetPath, req.Permission, vaultAddr, vaultMount)
 	if err != nil {
 		return nil, err
 	}
+	info.SecretProviderClass = cfg.SecretProviderClass
+	info.PodInfo = cfg.PodInfo
+	cfg.SummarizeLogs = info.storm
 
-	log.Printf("starting authentication routine to %v", cfg.AkeylessGatewayURL)
+	if !cfg.SummarizeLogs {
+		log.Printf("starting authentication routine to %v, secretProviderClass: %v", cfg.AkeylessGatewayURL, cfg.SecretProviderClass)
+	}
 	closed := make(chan bool, 1)
+	info.stage = StageAuthenticate
 	err = cfg.StartAuthentication(ctx, closed)
 
 	if err != nil {
-		log.Printf("failed to start authentication routine, error: %v", err)
+		log.Printf("failed to start authentication routine, secretProviderClass: %v, error: %v", cfg.SecretProviderClass, err)
 		return nil, err
 	}
+	// the authentication routine holds its own copy from now on
+	cfg.WipeCredentials()
+
+	s := p.session(cfg.TargetPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	prov := provider.NewProvider()
-	resp, err := prov.HandleMountRequest(ctx, cfg)
+	s.cfg = cfg
+	s.mounted = time.Now()
+	s.request = request
+	info.stage = StageFetch
+	resp, err := s.prov.HandleMountRequest(ctx, cfg)
+	info.objects = s.prov.MountedPaths()
 	if err != nil {
-		return nil, fmt.Errorf("error making mount request: %w", err)
+		return nil, fmt.Errorf("error making mount request for SecretProviderClass %v: %w", cfg.SecretProviderClass, err)
 	}
+	versions := objectVersions(resp)
+	previous := s.versions
+	if previous == nil {
+		previous = currentObjectVersions(req)
+	}
+	p.notifyRotation(cfg, previous, versions)
+	s.versions = versions
 
 	return resp, nil
 }
+
+// Refresh clears the cached secrets of the given target path and re-authenticates its session,
+// so the next mount of the target path fetches fresh secret values.
+func (p *Server) Refresh(ctx context.Context, targetPath string) error {
+	p.mu.Lock()
+	s, ok := p.sessions[targetPath]
+	p.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %v", ErrSessionNotFound, targetPath)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prov.ClearCache(s.cfg)
+	log.Printf("cleared cache for target path %v", targetPath)
+
+	if err := s.cfg.Session.Reauthenticate(ctx); err != nil {
+		return fmt.Errorf("failed to re-authenticate session for target path %v: %w", targetPath, err)
+	}
+	log.Printf("re-authenticated session for target path %v", targetPath)
+
+	return nil
+}
+
+func (p *Server) session(targetPath string) *session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sessions == nil {
+		p.sessions = make(map[string]*session)
+	}
+
+	s, ok := p.sessions[targetPath]
+	if !ok {
+		if now := time.Now(); now.Sub(p.sessionsSwept) > sessionSweepInterval || len(p.sessions) >= maxSessions {
+			p.evictSessions(now)
+			p.sessionsSwept = now
+		}
+		s = &session{prov: provider.NewProvider()}
+		p.sessions[targetPath] = s
+	}
+	return s
+}
+
+// evictSessions drops the sessions of target paths not mounted for sessionIdleTTL, whose pods
+// are most likely gone, and the ones mounted longest ago beyond maxSessions. Sessions locked by
+// a mount are in use and kept. p.mu must be held.
+func (p *Server) evictSessions(now time.Time) {
+	mounted := make(map[string]time.Time, len(p.sessions))
+	for targetPath, s := range p.sessions {
+		if !s.mu.TryLock() {
+			continue
+		}
+		last := s.mounted
+		s.mu.Unlock()
+		// a session is created right before its first mount sets mounted
+		if last.IsZero() {
+			continue
+		}
+		if now.Sub(last) > sessionIdleTTL {
+			delete(p.sessions, targetPath)
+			continue
+		}
+		mounted[targetPath] = last
+	}
+
+	for len(p.sessions) >= maxSessions && len(mounted) > 0 {
+		oldest := ""
+		for targetPath, last := range mounted {
+			if oldest == "" || last.Before(mounted[oldest]) {
+				oldest = targetPath
+			}
+		}
+		delete(p.sessions, oldest)
+		delete(mounted, oldest)
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/version"
+	"github.com/stretchr/testify/require"
+	pb "sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestVersion_NoGatewayCalls(t *testing.T) {
+	var calls int32
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer gw.Close()
+
+	s := &Server{VaultAddr: gw.URL, VaultMount: "kubernetes", NewClient: config.NewClient}
+	resp, err := s.Version(context.Background(), &pb.VersionRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "v1alpha1", resp.Version)
+	require.Equal(t, "akeyless-csi-provider", resp.RuntimeName)
+	require.Equal(t, version.BuildVersion, resp.RuntimeVersion)
+	require.Zero(t, atomic.LoadInt32(&calls))
+}
+
+func TestInventory(t *testing.T) {
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/describe-item":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"item_name": body["name"], "item_type": "STATIC_SECRET", "last_version": 1})
+		case "/get-secret-value":
+			name := body["names"].([]interface{})[0].(string)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{name: "value"})
+		}
+	}))
+	defer gw.Close()
+
+	session := config.NewSession(config.NewClient(gw.URL))
+
+	s := &Server{}
+	require.Empty(t, s.Inventory())
+
+	for targetPath, cfg := range map[string]config.Config{
+		"/pods/a/mount": {TargetPath: "/pods/a/mount", Session: session, Parameters: config.Parameters{
+			SecretProviderClass: "spc-a", PodInfo: config.PodInfo{Namespace: "team-a"},
+			Secrets: []config.Secret{{FileName: "db", SecretPath: "/shared/db"}, {FileName: "a", SecretPath: "/team-a/key", ContentType: "application/x-pem-file"}},
+		}},
+		"/pods/b/mount": {TargetPath: "/pods/b/mount", Session: session, Parameters: config.Parameters{
+			SecretProviderClass: "spc-b", PodInfo: config.PodInfo{Namespace: "team-b"},
+			Secrets: []config.Secret{{FileName: "db", SecretPath: "/shared/db"}},
+		}},
+	} {
+		sess := s.session(targetPath)
+		sess.cfg = cfg
+		_, err := sess.prov.HandleMountRequest(context.Background(), cfg)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []InventoryEntry{
+		{Path: "/shared/db", Mounts: 2, SecretProviderClasses: []string{"spc-a", "spc-b"}, Namespaces: []string{"team-a", "team-b"}},
+		{Path: "/team-a/key", Mounts: 1, SecretProviderClasses: []string{"spc-a"}, Namespaces: []string{"team-a"}, ContentTypes: []string{"application/x-pem-file"}},
+	}, s.Inventory())
+}
+
+func TestMount_Concurrent(t *testing.T) {
+	// every access ID gets its own token, and every secret value reveals the token it was fetched with
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/auth":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": "t-" + body["access-id"].(string)})
+		case "/describe-item":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"item_name": body["name"], "item_type": "STATIC_SECRET", "last_version": 1})
+		case "/get-secret-value":
+			name := body["names"].([]interface{})[0].(string)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{name: body["token"]})
+		}
+	}))
+	defer gw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &Server{VaultAddr: gw.URL, VaultMount: "kubernetes", NewClient: config.NewClient}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			accessID := fmt.Sprintf("p-%d", i)
+			attributes, err := json.Marshal(map[string]string{
+				"akeylessAccessType": "access_key",
+				"akeylessAccessID":   accessID,
+				"akeylessAccessKey":  "key",
+				"objects":            "- secretPath: /db\n  fileName: db",
+			})
+			require.NoError(t, err)
+
+			resp, err := s.Mount(ctx, &pb.MountRequest{
+				Attributes: string(attributes),
+				TargetPath: fmt.Sprintf("/pods/%d/mount", i),
+				Permission: "420",
+			})
+			require.NoError(t, err)
+			require.Len(t, resp.Files, 1)
+			require.Equal(t, "t-"+accessID, string(resp.Files[0].Contents))
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestEvictSessions(t *testing.T) {
+	now := time.Now()
+	s := &Server{}
+	mount := func(targetPath string, mounted time.Time) *session {
+		sess := s.session(targetPath)
+		sess.mounted = mounted
+		return sess
+	}
+
+	mount("/pods/idle/mount", now.Add(-sessionIdleTTL-time.Minute))
+	mount("/pods/recent/mount", now.Add(-time.Minute))
+	busy := mount("/pods/busy/mount", now.Add(-sessionIdleTTL-time.Minute))
+	busy.mu.Lock()
+	s.sessionsSwept = time.Time{}
+	s.session("/pods/new/mount")
+	busy.mu.Unlock()
+
+	// the idle session is dropped, the busy one is in use by a mount
+	require.Len(t, s.sessions, 3)
+	require.NotContains(t, s.sessions, "/pods/idle/mount")
+	require.Contains(t, s.sessions, "/pods/busy/mount")
+	require.Contains(t, s.sessions, "/pods/recent/mount")
+
+	// beyond maxSessions the ones mounted longest ago are dropped first
+	s.sessions = nil
+	for i := 0; i < maxSessions; i++ {
+		mount(fmt.Sprintf("/pods/%d/mount", i), now.Add(time.Duration(i)*time.Second))
+	}
+	mount("/pods/next/mount", now)
+	require.Len(t, s.sessions, maxSessions)
+	require.NotContains(t, s.sessions, "/pods/0/mount")
+	require.Contains(t, s.sessions, "/pods/1/mount")
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+)
+
+// stormWindow is the window mount requests are counted in to detect mount storms.
+const stormWindow = time.Minute
+
+// stormTracker counts mount requests per window. Once a window exceeds the threshold, the rest of
+// its requests skip their per-request logs and the storm is summarized by a single log line
+// when the window ends.
+type stormTracker struct {
+	mu    sync.Mutex
+	start time.Time
+	count int
+	// suppressed counts the requests of the window that skipped their per-request logs
+	suppressed int
+}
+
+// observe counts a mount request at now and reports whether it is part of a mount storm, which
+// is more than threshold requests within the window. A threshold of 0 never detects storms.
+func (t *stormTracker) observe(now time.Time, threshold int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.start.IsZero() || now.Sub(t.start) >= stormWindow {
+		t.closeWindow()
+		t.start = now
+	}
+	t.count++
+	if threshold <= 0 || t.count <= threshold {
+		return false
+	}
+
+	if t.suppressed == 0 {
+		log.Printf("mount storm detected, more than %d mount requests within %v, summarizing instead of logging every request", threshold, stormWindow)
+		metrics.MountStorms.Inc()
+		start := t.start
+		time.AfterFunc(stormWindow-now.Sub(start), func() { t.flush(start) })
+	}
+	t.suppressed++
+	return true
+}
+
+// flush closes the window that began at start, unless a later request already did.
+func (t *stormTracker) flush(start time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.start.Equal(start) {
+		t.closeWindow()
+		t.start = time.Time{}
+	}
+}
+
+func (t *stormTracker) closeWindow() {
+	if t.count > 0 {
+		metrics.MountRequestsPerWindow.Observe(float64(t.count))
+	}
+	if t.suppressed > 0 {
+		log.Printf("mount storm summary, mount requests: %d within %v, requests without per-request logs: %d", t.count, stormWindow, t.suppressed)
+	}
+	t.count, t.suppressed = 0, 0
+}
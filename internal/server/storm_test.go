@@ -0,0 +1,39 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStormTracker(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	var tracker stormTracker
+	start := time.Now()
+	var storm []bool
+	for i := 0; i < 5; i++ {
+		storm = append(storm, tracker.observe(start.Add(time.Duration(i)*time.Second), 3))
+	}
+	require.Equal(t, []bool{false, false, false, true, true}, storm)
+	require.Contains(t, logs.String(), "mount storm detected, more than 3 mount requests within 1m0s")
+
+	// the next window starts calm and summarizes the storm
+	require.False(t, tracker.observe(start.Add(stormWindow), 3))
+	require.Contains(t, logs.String(), "mount storm summary, mount requests: 5 within 1m0s, requests without per-request logs: 2")
+
+	// the scheduled flush of the closed storm window leaves the new window alone
+	tracker.flush(start)
+	require.Equal(t, 1, tracker.count)
+
+	var disabled stormTracker
+	for i := 0; i < 10; i++ {
+		require.False(t, disabled.observe(start, 0))
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+)
+
+// WarmUp ramps up the number of concurrent mounts after the provider started, so a node
+// rebooting with hundreds of pods doesn't hit the gateway with all of their mounts at once.
+// The limit grows exponentially from InitialConcurrency to MaxConcurrency over Duration, after
+// which mounts are no longer limited. Mounts over the limit wait for a slot within their deadline.
+type WarmUp struct {
+	Duration           time.Duration
+	InitialConcurrency int
+	MaxConcurrency     int
+
+	mu       sync.Mutex
+	started  time.Time
+	inFlight int
+	waiting  int
+	// released is closed and replaced whenever a mount finishes or the limit may have grown
+	released chan struct{}
+}
+
+// warmUpPoll is how often waiting mounts recheck the limit, which grows without mounts finishing.
+const warmUpPoll = 100 * time.Millisecond
+
+// Start begins the warm-up at now.
+func (w *WarmUp) Start(now time.Time) error {
+	if w.InitialConcurrency < 1 || w.MaxConcurrency < w.InitialConcurrency {
+		return fmt.Errorf("invalid warm-up concurrency %d to %d, the initial concurrency must be positive and at most the maximum", w.InitialConcurrency, w.MaxConcurrency)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.started = now
+	w.released = make(chan struct{})
+	return nil
+}
+
+// limit returns the concurrent mounts allowed at now, 0 once the warm-up is over.
+func (w *WarmUp) limit(now time.Time) int {
+	elapsed := now.Sub(w.started)
+	if w.started.IsZero() || w.Duration <= 0 || elapsed >= w.Duration {
+		return 0
+	}
+	growth := float64(w.MaxConcurrency) / float64(w.InitialConcurrency)
+	return int(math.Floor(float64(w.InitialConcurrency) * math.Pow(growth, float64(elapsed)/float64(w.Duration))))
+}
+
+// acquire waits until the mount may start and returns the function releasing its slot. A nil
+// WarmUp never waits.
+func (w *WarmUp) acquire(ctx context.Context) (func(), error) {
+	if w == nil {
+		return func() {}, nil
+	}
+	startTime := time.Now()
+	w.mu.Lock()
+	for {
+		limit := w.limit(time.Now())
+		if limit == 0 || w.inFlight < limit {
+			w.inFlight++
+			w.mu.Unlock()
+			if waited := time.Since(startTime); waited > time.Millisecond {
+				metrics.WarmUpWaits.Observe(waited.Seconds())
+			}
+			return w.release, nil
+		}
+		released := w.released
+		w.waiting++
+		w.mu.Unlock()
+
+		var err error
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-released:
+		case <-time.After(warmUpPoll):
+		}
+		w.mu.Lock()
+		w.waiting--
+		if err != nil {
+			w.mu.Unlock()
+			return nil, fmt.Errorf("mount waited %v for the warm-up ramp: %w", time.Since(startTime).Round(time.Millisecond), err)
+		}
+	}
+}
+
+func (w *WarmUp) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inFlight--
+	if w.released != nil {
+		close(w.released)
+		w.released = make(chan struct{})
+	}
+}
+
+// WarmUpState is the warm-up state reported in the readiness details.
+type WarmUpState struct {
+	Active bool `json:"active"`
+	// Remaining is the time left until mounts are no longer limited
+	Remaining string `json:"remaining,omitempty"`
+	// Limit is the concurrent mounts allowed right now
+	Limit    int `json:"limit,omitempty"`
+	InFlight int `json:"inFlight"`
+	Waiting  int `json:"waiting"`
+}
+
+// State returns the current warm-up state.
+func (w *WarmUp) State() WarmUpState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	state := WarmUpState{InFlight: w.inFlight, Waiting: w.waiting}
+	if limit := w.limit(now); limit > 0 {
+		state.Active = true
+		state.Limit = limit
+		state.Remaining = (w.Duration - now.Sub(w.started)).Round(time.Second).String()
+	}
+	return state
+}
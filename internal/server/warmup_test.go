@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmUp_Limit(t *testing.T) {
+	start := time.Now()
+	w := &WarmUp{Duration: 4 * time.Minute, InitialConcurrency: 4, MaxConcurrency: 64}
+	require.NoError(t, w.Start(start))
+
+	require.Equal(t, 4, w.limit(start))
+	require.Equal(t, 8, w.limit(start.Add(time.Minute)))
+	require.Equal(t, 16, w.limit(start.Add(2*time.Minute)))
+	require.Equal(t, 32, w.limit(start.Add(3*time.Minute)))
+	require.Zero(t, w.limit(start.Add(4*time.Minute)), "mounts are no longer limited after the warm-up")
+
+	require.Error(t, (&WarmUp{Duration: time.Minute, InitialConcurrency: 0, MaxConcurrency: 8}).Start(start))
+	require.Error(t, (&WarmUp{Duration: time.Minute, InitialConcurrency: 8, MaxConcurrency: 4}).Start(start))
+}
+
+func TestWarmUp_Acquire(t *testing.T) {
+	w := &WarmUp{Duration: time.Hour, InitialConcurrency: 1, MaxConcurrency: 1}
+	require.NoError(t, w.Start(time.Now()))
+
+	release, err := w.acquire(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, WarmUpState{Active: true, Remaining: "1h0m0s", Limit: 1, InFlight: 1}, w.State())
+
+	// a second mount waits for the first one, until its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = w.acquire(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "for the warm-up ramp")
+
+	acquired := make(chan func())
+	go func() {
+		next, err := w.acquire(context.Background())
+		require.NoError(t, err)
+		acquired <- next
+	}()
+	require.Eventually(t, func() bool { return w.State().Waiting == 1 }, time.Second, time.Millisecond)
+	release()
+	select {
+	case next := <-acquired:
+		next()
+	case <-time.After(time.Second):
+		t.Fatal("waiting mount didn't start after the first one finished")
+	}
+	require.Zero(t, w.State().InFlight)
+
+	// without a warm-up, mounts never wait
+	release, err = (*WarmUp)(nil).acquire(context.Background())
+	require.NoError(t, err)
+	release()
+}
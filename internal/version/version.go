@@ -2,16 +2,69 @@ package version
 
 import (
 	"encoding/json"
+	"runtime"
+	"runtime/debug"
 )
 
 const minDriverVersion = "v0.0.1"
 
+// The version of the binary, stamped from its build info at startup, see stamp.
 var (
 	BuildDate    string
 	BuildVersion string
 	GoVersion    string
 )
 
+func init() {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		stamp(info)
+	}
+	if GoVersion == "" {
+		GoVersion = runtime.Version()
+	}
+}
+
+// stamp sets the version from the build info Go embeds in every binary: the module version of
+// `go install ...@version` builds, otherwise the VCS revision of builds from a checkout with a
+// -dirty suffix for uncommitted changes, and the commit time as the build date.
+func stamp(info *debug.BuildInfo) {
+	GoVersion = info.GoVersion
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		BuildVersion = v
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.time":
+			BuildDate = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if BuildVersion == "" && revision != "" {
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		BuildVersion = revision
+		if modified == "true" {
+			BuildVersion += "-dirty"
+		}
+	}
+	if BuildVersion == "" {
+		BuildVersion = "(devel)"
+	}
+}
+
+// SetVersion overrides the stamped version, e.g. with the release tag of an image built from a
+// source archive without VCS information. An empty version keeps the stamped one.
+func SetVersion(v string) {
+	if v != "" {
+		BuildVersion = v
+	}
+}
+
 // providerVersion holds current provider version
 type providerVersion struct {
 	Version          string `json:"version"`          // Version of the binary.
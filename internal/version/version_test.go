@@ -2,6 +2,7 @@ package version
 
 import (
 	"fmt"
+	"runtime/debug"
 	"strings"
 	"testing"
 )
@@ -21,3 +22,57 @@ func TestGetVersion(t *testing.T) {
 		t.Fatalf("string doesn't match, expected %s, got %s", expected, v)
 	}
 }
+
+func TestStamp(t *testing.T) {
+	defer func(v, d, g string) { BuildVersion, BuildDate, GoVersion = v, d, g }(BuildVersion, BuildDate, GoVersion)
+
+	tests := []struct {
+		name     string
+		info     debug.BuildInfo
+		expected string
+	}{
+		{
+			name:     "go install",
+			info:     debug.BuildInfo{Main: debug.Module{Version: "v1.4.0"}, Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef"}}},
+			expected: "v1.4.0",
+		},
+		{
+			name:     "checkout",
+			info:     debug.BuildInfo{Main: debug.Module{Version: "(devel)"}, Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef"}, {Key: "vcs.modified", Value: "false"}}},
+			expected: "0123456789ab",
+		},
+		{
+			name:     "uncommitted changes",
+			info:     debug.BuildInfo{Main: debug.Module{Version: "(devel)"}, Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef"}, {Key: "vcs.modified", Value: "true"}}},
+			expected: "0123456789ab-dirty",
+		},
+		{
+			name:     "no VCS information",
+			info:     debug.BuildInfo{Main: debug.Module{Version: "(devel)"}},
+			expected: "(devel)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			BuildVersion, BuildDate = "", ""
+			tt.info.GoVersion = "go1.23.3"
+			tt.info.Settings = append(tt.info.Settings, debug.BuildSetting{Key: "vcs.time", Value: "2024-11-05T10:00:00Z"})
+			stamp(&tt.info)
+			if BuildVersion != tt.expected {
+				t.Fatalf("expected version %s, got %s", tt.expected, BuildVersion)
+			}
+			if BuildDate != "2024-11-05T10:00:00Z" || GoVersion != "go1.23.3" {
+				t.Fatalf("expected the commit time and Go version, got %s and %s", BuildDate, GoVersion)
+			}
+		})
+	}
+
+	SetVersion("v1.5.0-rc.1")
+	if BuildVersion != "v1.5.0-rc.1" {
+		t.Fatalf("expected the overridden version, got %s", BuildVersion)
+	}
+	SetVersion("")
+	if BuildVersion != "v1.5.0-rc.1" {
+		t.Fatalf("expected an empty override to keep the version, got %s", BuildVersion)
+	}
+}
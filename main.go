@@ -1,17 +1,29 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/admin"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/audit"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/cli"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/config"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/health"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/metrics"
+	"github.com/akeylesslabs/akeyless-csi-provider/internal/provider"
 	providerserver "github.com/akeylesslabs/akeyless-csi-provider/internal/server"
 	"github.com/akeylesslabs/akeyless-csi-provider/internal/version"
 	"google.golang.org/grpc"
@@ -21,15 +33,58 @@ import (
 
 func realMain() error {
 	var (
-		endpoint    = flag.String("endpoint", "/tmp/akeyless.sock", "path to socket on which to listen for driver gRPC calls")
-		selfVersion = flag.Bool("version", false, "prints the version information")
-		vaultAddr   = flag.String("akeyless-address", "https://api.akeyless.io", "Akeyless API URL")
-		vaultMount  = flag.String("mount", "kubernetes", "default mount path for Kubernetes authentication")
-		healthAddr  = flag.String("health-address", ":8080", "configure http listener for reporting health")
+		endpoint     = flag.String("endpoint", "/tmp/akeyless.sock", "path to socket on which to listen for driver gRPC calls")
+		selfVersion  = flag.Bool("version", false, "prints the version information")
+		versionName  = flag.String("version-override", "", "version reported by -version and the Version RPC instead of the one stamped from the build info, e.g. the release tag of a build without VCS information")
+		vaultAddr    = flag.String("akeyless-address", "", "Akeyless API URL used when a SecretProviderClass sets neither akeylessGatewayURL nor AKEYLESS_URL")
+		saasFallback = flag.Bool("saas-fallback", false, "use the Akeyless SaaS API "+config.SaaSURL+" when no -akeyless-address is set, instead of failing mounts that don't name a gateway")
+		vaultMount   = flag.String("mount", "kubernetes", "default mount path for Kubernetes authentication")
+		healthAddr   = flag.String("health-address", ":8080", "configure http listener for reporting health")
+		tempDir      = flag.String("temp-dir", os.TempDir(), "writable directory for temporary files, required with a read-only root filesystem")
+		pushURL      = flag.String("metrics-push-url", "", "Pushgateway or OTLP metrics endpoint to push metrics to, empty to disable")
+		pushFormat   = flag.String("metrics-push-format", metrics.PushFormatPushgateway, "metrics push format, pushgateway or otlp")
+		pushInterval = flag.Duration("metrics-push-interval", time.Minute, "interval between metrics pushes")
+		cacheTTL     = flag.Duration("cache-ttl", 0, "node-level cache TTL of secret values shared by the authenticated mounts of one identity, 0 to disable")
+		sanityWarn   = flag.Bool("sanity-warnings", false, "warn about mounted values that look misconfigured, e.g. empty, placeholder or truncated PEM values")
+		accessTTL    = flag.Duration("access-type-cache-ttl", 10*time.Minute, "how long a detected access type is remembered per access ID, 0 to probe on every mount")
+		vaultCompat  = flag.Bool("vault-compat-parameters", false, "accept the HashiCorp Vault CSI provider's parameter names (vaultAddress, roleName, objectName/secretKey objects)")
+		identLimit   = flag.Int("metrics-identity-limit", 100, "maximum distinct namespace/service account pairs labeled in metrics, the rest are labeled \"other\", 0 to disable the labels")
+		maxRespSize  = flag.Int("max-response-size", provider.DefaultMaxResponseSize, "maximum total bytes of files in a mount response, must not exceed the driver's --max-call-recv-msg-size, 0 to disable the check")
+		readyAuth    = flag.Bool("readiness-requires-credential", false, "report not ready until the default credential from the AKEYLESS_* environment authenticated once")
+		inventoryLog = flag.Duration("inventory-log-interval", 0, "interval of logging the inventory of mounted secret paths, 0 to disable")
+		adminAddr    = flag.String("admin-address", "127.0.0.1:8081", "configure localhost http listener for administrative endpoints, empty to disable")
+		canaryItem   = flag.String("canary-item", "", "path of a low-value item fetched periodically with the default credential from the AKEYLESS_* environment, to verify authentication and the gateway path, empty to disable")
+		canaryEvery  = flag.Duration("canary-interval", 5*time.Minute, "interval between canary item fetches")
+		affinity     = flag.Bool("gateway-session-affinity", false, "keep the cookies of gateway responses for the duration of a mount, for gateway clusters behind sticky load balancers")
+		auditSink    = flag.String("audit-sink", "", "syslog://host:port, syslog+tcp://host:port or http(s) webhook URL to ship audit records to in addition to the logs, empty to disable")
+		auditBatch   = flag.Int("audit-batch-size", 100, "maximum number of audit records shipped at once")
+		auditFlush   = flag.Duration("audit-flush-interval", 5*time.Second, "how long audit records wait for a batch to fill up")
+		auditRetries = flag.Int("audit-max-retries", 5, "how often a failed batch of audit records is retried before it is dropped")
+		stormLimit   = flag.Int("mount-storm-threshold", 0, "mount requests per minute above which requests skip their per-request logs in favor of a single summary, 0 to log every request")
+		rotationHint = flag.Duration("rotation-interval-hint", 0, "the driver's --rotation-poll-interval, to refresh the tokens of mounts shortly before their rotation remounts, which reuse them, 0 to disable")
+		describeAge  = flag.Duration("describe-fallback-staleness", provider.DefaultDescribeFallbackStaleness, "how old the last known type and version of an item may be to be used when describing it is throttled, 0 to fail throttled describes")
+		rotationHook = flag.String("rotation-webhook-url", "", "URL to POST a notification with the changed object ids and versions to whenever a remount changes the content of a pod's mount, empty to disable")
+		gatewayCA    = flag.String("gateway-ca-configmap", "", "namespace/name#key of a ConfigMap holding PEM CA certificates to trust for the Akeyless API and gateways in addition to the system roots, reloaded on change, empty to disable")
+		caInterval   = flag.Duration("gateway-ca-configmap-interval", config.DefaultCAConfigMapInterval, "interval of checking the -gateway-ca-configmap ConfigMap and the caConfigMap ConfigMaps of SecretProviderClasses for changes")
+		authTimeout  = flag.Duration("auth-timeout", config.DefaultRequestTimeout, "timeout of a single authentication call to the gateway or OAuth2 identity provider")
+		fetchTimeout = flag.Duration("fetch-timeout", config.DefaultRequestTimeout, "timeout of a single call fetching an item's value or description from the gateway")
+		listTimeout  = flag.Duration("list-timeout", config.DefaultRequestTimeout, "timeout of a single call listing the items of a folder")
+		warmUpTime   = flag.Duration("warm-up-duration", 0, "how long concurrent mounts are ramped up after startup, to spare the gateway a thundering herd when a node reboots, 0 to disable")
+		warmUpStart  = flag.Int("warm-up-initial-concurrency", 4, "concurrent mounts allowed right after startup, growing exponentially over -warm-up-duration")
+		warmUpMax    = flag.Int("warm-up-max-concurrency", 64, "concurrent mounts allowed at the end of -warm-up-duration, after which mounts are no longer limited")
+		gwMaxConns   = flag.Int("gateway-max-conns", config.DefaultGatewayMaxConns, "concurrent connections allowed per gateway URL, every gateway has a connection pool of its own")
+		gwMaxIdle    = flag.Int("gateway-max-idle-conns", config.DefaultGatewayMaxIdleConns, "idle connections kept per gateway URL for reuse by later mounts")
+		clusterName  = flag.String("cluster-name", "", "name of the cluster sent with every Akeyless request, to tell apart clusters using the same access IDs in the Akeyless audit log, empty to disable")
+		identities   identityFlags
 	)
+	flag.Var(&identities, "identity", "additional provider name=akeyless-address to register, listening on <name>.sock next to -endpoint, repeatable")
 
 	flag.Parse()
 
+	recentLogs := admin.NewLogBuffer(1000)
+	log.SetOutput(io.MultiWriter(os.Stderr, recentLogs))
+
+	version.SetVersion(*versionName)
 	if *selfVersion {
 		v, err := version.GetVersion()
 		if err != nil {
@@ -40,26 +95,77 @@ func realMain() error {
 		return err
 	}
 
+	// Verify all writable paths up front, so a read-only root filesystem without the
+	// required volumes fails at startup rather than on the first mount.
+	if err := config.CheckWritableDir("socket", filepath.Dir(*endpoint)); err != nil {
+		return err
+	}
+	if err := config.CheckWritableDir("temp", *tempDir); err != nil {
+		return err
+	}
+	if persistDir := os.Getenv(config.AkeylessUIDTokenPersist); persistDir != "" {
+		if err := config.CheckWritableDir("UID token persistence", persistDir); err != nil {
+			return err
+		}
+	}
+	config.TempDir = *tempDir
+	if err := config.CheckTokenSeal(os.Getenv(config.AkeylessUIDTokenSeal), os.Getenv(config.AkeylessUIDTokenPersist)); err != nil {
+		return err
+	}
+	if *vaultAddr == "" && *saasFallback {
+		*vaultAddr = config.SaaSURL
+	}
+	if *vaultAddr != "" {
+		addr, err := config.NormalizeGatewayURL(*vaultAddr)
+		if err != nil {
+			return fmt.Errorf("invalid -akeyless-address %q: %w", *vaultAddr, err)
+		}
+		*vaultAddr = addr
+	}
+	config.SetAccessTypeCacheTTL(*accessTTL)
+	config.VaultCompatParameters = *vaultCompat
+	config.SetGatewaySessionAffinity(*affinity)
+	if *gwMaxConns <= 0 || *gwMaxIdle <= 0 {
+		return fmt.Errorf("-gateway-max-conns and -gateway-max-idle-conns must be positive")
+	}
+	config.SetGatewayConnectionLimits(*gwMaxConns, *gwMaxIdle)
+	if err := config.SetClusterName(*clusterName); err != nil {
+		return err
+	}
+	if *authTimeout <= 0 || *fetchTimeout <= 0 || *listTimeout <= 0 {
+		return fmt.Errorf("-auth-timeout, -fetch-timeout and -list-timeout must be positive")
+	}
+	config.SetRequestTimeouts(*authTimeout, *fetchTimeout, *listTimeout)
+	provider.SetCacheTTL(*cacheTTL)
+	metrics.SetIdentityLimit(*identLimit)
+	provider.SetSanityWarnings(*sanityWarn)
+	provider.SetMaxResponseSize(*maxRespSize)
+	provider.SetDescribeFallbackStaleness(*describeAge)
+	config.SetCAConfigMapInterval(*caInterval)
+	if *gatewayCA != "" {
+		caCtx, cancelCA := context.WithCancel(context.Background())
+		defer cancelCA()
+		if err := config.WatchGatewayCAConfigMap(caCtx, *gatewayCA, *caInterval); err != nil {
+			return err
+		}
+		log.Printf("Trusting the gateway CA of ConfigMap %v", *gatewayCA)
+	}
+
 	log.Print("Creating new gRPC server")
-	server := grpc.NewServer(
-		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-			startTime := time.Now()
-			log.Printf("Processing unary gRPC call grpc.method: %v", info.FullMethod)
-			resp, err := handler(ctx, req)
-			log.Printf("Finished unary gRPC call grpc.method: %v, grpc.time: %v, grpc.code: %v", info.FullMethod, time.Since(startTime), status.Code(err))
-			if err != nil {
-				log.Printf("Error: %v", err.Error())
-			}
-			log.Print("Finished unary gRPC call")
-			return resp, err
-		}),
-	)
+	server := newGRPCServer()
+	identityServers := make([]*grpc.Server, len(identities))
+	for i := range identities {
+		identityServers[i] = newGRPCServer()
+	}
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
 		sig := <-c
 		log.Printf("Caught signal %s, shutting down", sig)
+		for _, is := range identityServers {
+			is.GracefulStop()
+		}
 		server.GracefulStop()
 	}()
 
@@ -72,14 +178,103 @@ func realMain() error {
 	s := &providerserver.Server{
 		VaultAddr:  *vaultAddr,
 		VaultMount: *vaultMount,
+		NewClient:  config.NewClient,
+
+		RotationInterval:    *rotationHint,
+		MountStormThreshold: *stormLimit,
+		RotationWebhookURL:  *rotationHook,
+	}
+	if *warmUpTime > 0 {
+		s.WarmUp = &providerserver.WarmUp{Duration: *warmUpTime, InitialConcurrency: *warmUpStart, MaxConcurrency: *warmUpMax}
+		if err := s.WarmUp.Start(time.Now()); err != nil {
+			return err
+		}
+		log.Printf("Ramping up concurrent mounts from %d to %d over %v", *warmUpStart, *warmUpMax, *warmUpTime)
 	}
 	pb.RegisterCSIDriverProviderServer(server, s)
 
+	// SIGQUIT dumps diagnostics instead of the Go runtime's default of exiting with a stack dump
+	dump := make(chan os.Signal, 1)
+	signal.Notify(dump, syscall.SIGQUIT)
+	go func() {
+		for range dump {
+			var diagnostics bytes.Buffer
+			if err := s.WriteDiagnostics(&diagnostics); err != nil {
+				log.Printf("failed to write diagnostic dump, error: %v", err)
+			}
+			log.Printf("Caught signal SIGQUIT, diagnostic dump:\n%s", diagnostics.String())
+		}
+	}()
+
+	for i, identity := range identities {
+		identityEndpoint := filepath.Join(filepath.Dir(*endpoint), identity.Name+".sock")
+		if identityEndpoint == *endpoint {
+			return fmt.Errorf("provider identity %v listens on the -endpoint socket %v", identity.Name, *endpoint)
+		}
+		identityListener, err := listen(identityEndpoint)
+		if err != nil {
+			return err
+		}
+		defer identityListener.Close()
+
+		is := identityServers[i]
+		pb.RegisterCSIDriverProviderServer(is, s.WithIdentity(identity))
+		go func(identity providerserver.Identity) {
+			log.Printf("Starting gRPC server of provider identity %v, akeyless-address: %v", identity.Name, identity.VaultAddr)
+			if err := is.Serve(identityListener); err != nil {
+				log.Fatalf("Error running gRPC server of provider identity %v, error: %v", identity.Name, err.Error())
+			}
+		}(identity)
+	}
+
+	if *rotationHint > 0 {
+		prewarmCtx, cancelPrewarm := context.WithCancel(context.Background())
+		defer cancelPrewarm()
+		go s.PrewarmTokens(prewarmCtx)
+		log.Printf("Pre-warming tokens before rotation remounts, rotation interval: %v", *rotationHint)
+	}
+
+	if *inventoryLog > 0 {
+		go func() {
+			ticker := time.NewTicker(*inventoryLog)
+			defer ticker.Stop()
+			for range ticker.C {
+				s.LogInventory()
+			}
+		}()
+	}
+
 	// Create health handler
-	mux := http.NewServeMux()
+	var readiness health.Readiness
+	if *readyAuth {
+		gate := health.NewGate()
+		gateCtx, cancelGate := context.WithCancel(context.Background())
+		defer cancelGate()
+		gate.Run(gateCtx, func(ctx context.Context) error {
+			return config.CheckDefaultCredential(ctx, *vaultAddr, *vaultMount)
+		}, 10*time.Second)
+		readiness = gate.Ready
+	}
+	if *canaryItem != "" {
+		canaryCtx, cancelCanary := context.WithCancel(context.Background())
+		defer cancelCanary()
+		canary := &provider.Canary{
+			Item:     *canaryItem,
+			Interval: *canaryEvery,
+			Authenticate: func(ctx context.Context) (config.Config, error) {
+				return config.AuthenticateDefaultCredential(ctx, config.NewClient, *vaultAddr, *vaultMount)
+			},
+		}
+		canary.Run(canaryCtx)
+		log.Printf("Probing canary item, item: %v, interval: %v", *canaryItem, *canaryEvery)
+	}
+	var details []health.Detail
+	if s.WarmUp != nil {
+		details = append(details, func() (string, interface{}) { return "warmUp", s.WarmUp.State() })
+	}
 	ms := http.Server{
 		Addr:    *healthAddr,
-		Handler: mux,
+		Handler: health.NewHandler(readiness, details...),
 	}
 	defer func() {
 		err := ms.Shutdown(context.Background())
@@ -88,9 +283,37 @@ func realMain() error {
 		}
 	}()
 
-	mux.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
+	if *auditSink != "" {
+		auditCtx, cancelAudit := context.WithCancel(context.Background())
+		defer cancelAudit()
+		err = audit.StartExporter(auditCtx, audit.ExportConfig{
+			URL:           *auditSink,
+			BatchSize:     *auditBatch,
+			FlushInterval: *auditFlush,
+			MaxRetries:    *auditRetries,
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Shipping audit records, sink: %v", *auditSink)
+	}
+
+	if *pushURL != "" {
+		hostname, _ := os.Hostname()
+		pushCtx, cancelPush := context.WithCancel(context.Background())
+		defer cancelPush()
+		err = metrics.StartPusher(pushCtx, metrics.PushConfig{
+			URL:      *pushURL,
+			Format:   *pushFormat,
+			Interval: *pushInterval,
+			Job:      "akeyless-csi-provider",
+			Instance: hostname,
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Pushing metrics, url: %v, format: %v, interval: %v", *pushURL, *pushFormat, *pushInterval)
+	}
 
 	// Start health handler
 	go func() {
@@ -100,6 +323,27 @@ func realMain() error {
 		}
 	}()
 
+	if *adminAddr != "" {
+		as := http.Server{
+			Addr:    *adminAddr,
+			Handler: admin.NewHandler(s, admin.Diagnostics{Logs: recentLogs, Flags: effectiveFlags()}),
+		}
+		defer func() {
+			err := as.Shutdown(context.Background())
+			if err != nil {
+				log.Fatalf("Error shutting down admin handler, err: %v", err.Error())
+			}
+		}()
+
+		// Start admin handler
+		go func() {
+			log.Printf("Starting admin handler, addr: %v", *adminAddr)
+			if err := as.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Error with admin handler, error: %v", err.Error())
+			}
+		}()
+	}
+
 	log.Print("Starting gRPC server")
 	err = server.Serve(listener)
 	if err != nil {
@@ -109,6 +353,66 @@ func realMain() error {
 	return nil
 }
 
+func newGRPCServer() *grpc.Server {
+	return grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			startTime := time.Now()
+			log.Printf("Processing unary gRPC call grpc.method: %v", info.FullMethod)
+			resp, err := handler(ctx, req)
+			log.Printf("Finished unary gRPC call grpc.method: %v, grpc.time: %v, grpc.code: %v", info.FullMethod, time.Since(startTime), status.Code(err))
+			if err != nil {
+				log.Printf("Error: %v", err.Error())
+			}
+			log.Print("Finished unary gRPC call")
+			return resp, err
+		}),
+	)
+}
+
+// effectiveFlags returns the values of all flags, defaults included.
+func effectiveFlags() map[string]string {
+	flags := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		flags[f.Name] = redactURL(f.Value.String())
+	})
+	return flags
+}
+
+// redactURL hides the credentials and query of URL flag values, such as webhook tokens.
+func redactURL(v string) string {
+	u, err := url.Parse(v)
+	if err != nil || u.Host == "" || (u.User == nil && u.RawQuery == "") {
+		return v
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	return u.String()
+}
+
+// identityFlags collects the repeated -identity flags.
+type identityFlags []providerserver.Identity
+
+func (f *identityFlags) String() string {
+	names := make([]string, 0, len(*f))
+	for _, identity := range *f {
+		names = append(names, identity.Name+"="+identity.VaultAddr)
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *identityFlags) Set(value string) error {
+	identity, err := providerserver.ParseIdentity(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, identity)
+	return nil
+}
+
 func listen(endpoint string) (net.Listener, error) {
 	// Because the unix socket is created in a host volume (i.e. persistent
 	// storage), it can persist from previous runs if the pod was not terminated
@@ -134,6 +438,26 @@ func listen(endpoint string) (net.Listener, error) {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "access-review":
+			if err := cli.AccessReview(os.Args[2:], os.Stdout); err != nil {
+				log.Fatalf("Error running access review: %v", err.Error())
+			}
+			return
+		case "soak":
+			if err := cli.Soak(os.Args[2:], os.Stdout); err != nil {
+				log.Fatalf("Error running soak: %v", err.Error())
+			}
+			return
+		case "support-bundle":
+			if err := cli.SupportBundle(os.Args[2:], os.Stdout); err != nil {
+				log.Fatalf("Error creating support bundle: %v", err.Error())
+			}
+			return
+		}
+	}
+
 	err := realMain()
 	if err != nil {
 		log.Fatalf("Error running provider: %v", err.Error())